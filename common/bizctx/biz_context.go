@@ -118,6 +118,20 @@ func GetClientIP(ctx context.Context) string {
 	return cv.metadata.GetString(MetaKeyClientIP)
 }
 
+// GetTraceId 从traceparent中获取trace id, 没有时返回请求ID
+func GetTraceId(ctx context.Context) string {
+	cv := getCtxValue(ctx)
+	if cv == nil {
+		return ""
+	}
+
+	// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+	if parts := strings.Split(cv.metadata.GetString(MetaKeyTraceParent), "-"); len(parts) == 4 && len(parts[1]) == 32 {
+		return parts[1]
+	}
+	return cv.metadata.GetString(MetaKeyRequestId)
+}
+
 func GetAppId(ctx context.Context) string {
 	cv := getCtxValue(ctx)
 	if cv == nil {
@@ -0,0 +1,33 @@
+package bizctx
+
+import "context"
+
+// 日志中bizctx元数据的字段名
+const (
+	LogFieldTid      = "tid"
+	LogFieldUid      = "uid"
+	LogFieldClientIP = "client_ip"
+	LogFieldTraceId  = "trace_id"
+)
+
+// LogFields 返回ctx中用于关联日志的keyvals, 包括tid, uid, client_ip和trace_id, 值为空的字段不返回
+func LogFields(ctx context.Context) []any {
+	if ctx == nil || getCtxValue(ctx) == nil {
+		return nil
+	}
+
+	var keyvals []any
+	if tid := GetTid(ctx); tid != 0 {
+		keyvals = append(keyvals, LogFieldTid, tid)
+	}
+	if uid := GetUid(ctx); uid != 0 {
+		keyvals = append(keyvals, LogFieldUid, uid)
+	}
+	if clientIP := GetClientIP(ctx); clientIP != "" {
+		keyvals = append(keyvals, LogFieldClientIP, clientIP)
+	}
+	if traceId := GetTraceId(ctx); traceId != "" {
+		keyvals = append(keyvals, LogFieldTraceId, traceId)
+	}
+	return keyvals
+}
@@ -9,4 +9,7 @@ const (
 	MetaKeyUsn      = "hd-usn"       // user sn
 	MetaKeyRoleIds  = "hd-role-ids"  // role ids
 	MetaKeyClientIP = "hd-client-ip" // client ip
+	// 链路追踪
+	MetaKeyTraceParent = "traceparent"  // W3C trace context, 格式: 00-<trace-id>-<span-id>-<flags>
+	MetaKeyRequestId   = "x-request-id" // 请求ID, 没有traceparent时作为trace id
 )
@@ -0,0 +1,338 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// Validator 需要额外校验逻辑的配置结构可以实现该接口, 在struct tag校验之后调用
+type Validator interface {
+	Validate() error
+}
+
+// Defaulter 缺省值依赖其他配置项时, 配置结构可以实现该接口, 在default tag设置之后调用
+type Defaulter interface {
+	SetDefaults()
+}
+
+// Errors 配置校验时收集的所有错误
+type Errors []error
+
+const (
+	tagName     = "mapstructure"
+	tagDefault  = "default"
+	tagValidate = "validate"
+)
+
+var (
+	ErrSectionNotFound = errors.New("config section not found")
+)
+
+// Parse 读取section对应的配置到configVar中, 然后设置缺省值并校验, configVar必须是结构体指针
+// configVar中已有的非零值会被保留, 因此可以预先填充缺省值
+//
+//	type redisConfig struct {
+//		Host string `mapstructure:"host" validate:"required"`
+//		Port int    `mapstructure:"port" default:"6379"`
+//	}
+func Parse(configProvider provider.Config, section string, configVar any) error {
+	if configProvider == nil {
+		return errors.New("config provider not initialized")
+	}
+
+	if configProvider.Get(section) == nil {
+		return errors.Wrap(ErrSectionNotFound, section)
+	}
+
+	err := configProvider.Unmarshal(configVar, section)
+	if err != nil {
+		return errors.Wrapf(err, "unmarshal config, section: %s", section)
+	}
+
+	if err = SetDefaults(configVar); err != nil {
+		return err
+	}
+
+	return Validate(configVar, section)
+}
+
+// SetDefaults 为零值字段设置default tag指定的缺省值, 支持嵌套的结构体, 结构体指针和结构体切片
+func SetDefaults(configVar any) error {
+	v, err := getStructValue(configVar)
+	if err != nil {
+		return err
+	}
+
+	var errs Errors
+	walk(v, "", func(field reflect.StructField, value reflect.Value, path string) {
+		tag, exists := field.Tag.Lookup(tagDefault)
+		if !exists || !value.IsZero() {
+			return
+		}
+
+		if err := setValue(value, tag); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid default value %q: %v", path, tag, err))
+		}
+	}, func(value reflect.Value, path string) {
+		if d, ok := value.Addr().Interface().(Defaulter); ok {
+			d.SetDefaults()
+		}
+	})
+
+	return errs.orNil()
+}
+
+// Validate 校验validate tag, 返回所有不满足的字段, prefix为错误信息中字段路径的前缀
+// 支持的规则: required, oneof=a b c, min=n, max=n, 字符串和切片比较长度, 多个规则用逗号分隔
+func Validate(configVar any, prefix string) error {
+	v, err := getStructValue(configVar)
+	if err != nil {
+		return err
+	}
+
+	var errs Errors
+	walk(v, prefix, func(field reflect.StructField, value reflect.Value, path string) {
+		tag := field.Tag.Get(tagValidate)
+		if tag == "" {
+			return
+		}
+
+		for _, rule := range strings.Split(tag, ",") {
+			if err := checkRule(value, strings.TrimSpace(rule)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %v", path, err))
+			}
+		}
+	}, func(value reflect.Value, path string) {
+		if validator, ok := value.Addr().Interface().(Validator); ok {
+			if err := validator.Validate(); err != nil {
+				if path == "" {
+					errs = append(errs, err)
+				} else {
+					errs = append(errs, fmt.Errorf("%s: %v", path, err))
+				}
+			}
+		}
+	})
+
+	return errs.orNil()
+}
+
+func (errs Errors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (errs Errors) Unwrap() []error {
+	return errs
+}
+
+func (errs Errors) orNil() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func getStructValue(configVar any) (reflect.Value, error) {
+	v := reflect.ValueOf(configVar)
+	for v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("config var must be a non-nil pointer to struct, got: %T", configVar)
+	}
+	return v.Elem(), nil
+}
+
+// walk 深度优先遍历结构体字段, 先对字段调用onField, 再递归子结构体, 最后对结构体本身调用onStruct
+func walk(v reflect.Value, path string, onField func(reflect.StructField, reflect.Value, string), onStruct func(reflect.Value, string)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := getFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		// squash的嵌入结构体的字段和外层结构体的字段在同一层级
+		fieldPath := path
+		if !isSquash(field) {
+			fieldPath = joinPath(path, name)
+		}
+		value := v.Field(i)
+		onField(field, value, fieldPath)
+
+		switch value.Kind() {
+		case reflect.Struct:
+			if value.Type() != reflect.TypeOf(time.Time{}) {
+				walk(value, fieldPath, onField, onStruct)
+			}
+		case reflect.Ptr:
+			if !value.IsNil() && value.Elem().Kind() == reflect.Struct {
+				walk(value.Elem(), fieldPath, onField, onStruct)
+			}
+		case reflect.Slice, reflect.Array:
+			for j := 0; j < value.Len(); j++ {
+				item := value.Index(j)
+				if item.Kind() == reflect.Ptr {
+					if item.IsNil() {
+						continue
+					}
+					item = item.Elem()
+				}
+				if item.Kind() == reflect.Struct {
+					walk(item, fmt.Sprintf("%s[%d]", fieldPath, j), onField, onStruct)
+				}
+			}
+		}
+	}
+
+	onStruct(v, path)
+}
+
+func checkRule(value reflect.Value, rule string) error {
+	name, param, _ := strings.Cut(rule, "=")
+	switch name {
+	case "":
+		return nil
+	case "required":
+		if value.IsZero() {
+			return errors.New("required")
+		}
+	case "oneof":
+		if value.IsZero() {
+			return nil
+		}
+		current := fmt.Sprint(value.Interface())
+		for _, option := range strings.Fields(param) {
+			if current == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of [%s]", param)
+	case "min", "max":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return fmt.Errorf("invalid rule: %s", rule)
+		}
+
+		n, ok := getSize(value)
+		if !ok || value.IsZero() {
+			return nil
+		}
+
+		if name == "min" && n < limit {
+			return fmt.Errorf("must be greater than or equal to %s", param)
+		}
+		if name == "max" && n > limit {
+			return fmt.Errorf("must be less than or equal to %s", param)
+		}
+	default:
+		return fmt.Errorf("unsupported rule: %s", rule)
+	}
+	return nil
+}
+
+// getSize 数字返回值本身, 字符串, 切片和map返回长度
+func getSize(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true
+	}
+	return 0, false
+}
+
+func setValue(value reflect.Value, s string) error {
+	if value.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(n)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Slice {
+			return fmt.Errorf("unsupported type: %s", value.Type())
+		}
+		items := strings.Split(s, ",")
+		slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type: %s", value.Type())
+	}
+	return nil
+}
+
+func getFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get(tagName), ",")
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+func isSquash(field reflect.StructField) bool {
+	_, options, _ := strings.Cut(field.Tag.Get(tagName), ",")
+	return field.Anonymous && strings.Contains(options, "squash")
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
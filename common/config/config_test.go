@@ -0,0 +1,81 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type testProviderConfig struct {
+	Default *testConfig   `mapstructure:"default"`
+	Items   []*testConfig `mapstructure:"items"`
+}
+
+type testConfig struct {
+	Name     string        `mapstructure:"name"`
+	Host     string        `mapstructure:"host" validate:"required"`
+	Port     int           `mapstructure:"port" default:"5432"`
+	Mode     string        `mapstructure:"mode" validate:"oneof=a b"`
+	Timeout  time.Duration `mapstructure:"timeout" default:"3s"`
+	Password string        `mapstructure:"password"`
+
+	PoolConfig `mapstructure:",squash"`
+}
+
+type PoolConfig struct {
+	MaxConns int `mapstructure:"max_conns" default:"10" validate:"max=100"`
+}
+
+func (c *testProviderConfig) Validate() error {
+	for _, item := range c.Items {
+		if item.Name == "" {
+			return errors.New("item name is required")
+		}
+	}
+	return nil
+}
+
+func TestSetDefaultsAndValidate(t *testing.T) {
+	c := &testProviderConfig{
+		Default: &testConfig{Host: "localhost", Port: 6432},
+		Items:   []*testConfig{{Mode: "c", PoolConfig: PoolConfig{MaxConns: 200}}},
+	}
+
+	if err := SetDefaults(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Default.Port != 6432 || c.Items[0].Port != 5432 || c.Items[0].Timeout != 3*time.Second || c.Default.MaxConns != 10 {
+		t.Fatalf("unexpected defaults: %+v, %+v", c.Default, c.Items[0])
+	}
+
+	err := Validate(c, "sdk.test")
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 4 {
+		t.Fatalf("Validate() = %v, want 4 errors", err)
+	}
+
+	want := "sdk.test.items[0].host: required; sdk.test.items[0].mode: must be one of [a b]; sdk.test.items[0].max_conns: must be less than or equal to 100; sdk.test: item name is required"
+	if err.Error() != want {
+		t.Fatalf("Validate() = %s, want %s", err, want)
+	}
+}
+
+func TestMask(t *testing.T) {
+	m := Mask(map[string]any{
+		"default": ToMap(&testConfig{Host: "localhost", Password: "123456"}),
+		"token":   "",
+	})
+
+	if got := m["default"].(map[string]any)["password"]; got != maskedValue {
+		t.Fatalf("password = %v, want masked", got)
+	}
+	if got := m["default"].(map[string]any)["host"]; got != "localhost" {
+		t.Fatalf("host = %v, want localhost", got)
+	}
+	if got := m["default"].(map[string]any)["max_conns"]; got != 0 {
+		t.Fatalf("max_conns = %v, want 0", got)
+	}
+	if got := m["token"]; got != "" {
+		t.Fatalf("empty token = %v, want empty", got)
+	}
+}
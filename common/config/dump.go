@@ -0,0 +1,125 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	maskedValue = "******"
+)
+
+var (
+	// sensitiveKeywords 配置项名称包含以下关键字时, 值会被屏蔽
+	sensitiveKeywords = []string{"password", "passwd", "secret", "token", "credential", "private_key", "access_key", "api_key", "apikey"}
+)
+
+// ToMap 将配置结构按mapstructure tag转换成map, nil指针字段会被忽略
+func ToMap(configVar any) map[string]any {
+	v, err := getStructValue(configVar)
+	if err != nil {
+		return nil
+	}
+	return structToMap(v)
+}
+
+// Mask 返回屏蔽了敏感配置值的副本, 例如: password, secret, token
+func Mask(m map[string]any) map[string]any {
+	masked := make(map[string]any, len(m))
+	for k, v := range m {
+		if isSensitive(k) {
+			if s, ok := v.(string); !ok || s != "" {
+				v = maskedValue
+			}
+		}
+		masked[k] = maskValue(v)
+	}
+	return masked
+}
+
+func maskValue(v any) any {
+	switch vv := v.(type) {
+	case map[string]any:
+		return Mask(vv)
+	case []any:
+		items := make([]any, len(vv))
+		for i, item := range vv {
+			items[i] = maskValue(item)
+		}
+		return items
+	}
+	return v
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, keyword := range sensitiveKeywords {
+		if strings.Contains(key, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+func structToMap(v reflect.Value) map[string]any {
+	m := make(map[string]any)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := getFieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		value, ok := toValue(v.Field(i))
+		if !ok {
+			continue
+		}
+
+		// squash的嵌入结构体的字段展开到外层
+		if embedded, isMap := value.(map[string]any); isMap && isSquash(field) {
+			for k, item := range embedded {
+				m[k] = item
+			}
+			continue
+		}
+		m[name] = value
+	}
+	return m
+}
+
+func toValue(v reflect.Value) (any, bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, false
+		}
+		return toValue(v.Elem())
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			return v.Interface(), true
+		}
+		return structToMap(v), true
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, false
+		}
+		items := make([]any, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if item, ok := toValue(v.Index(i)); ok {
+				items = append(items, item)
+			}
+		}
+		return items, true
+	}
+
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		return time.Duration(v.Int()).String(), true
+	}
+	return v.Interface(), true
+}
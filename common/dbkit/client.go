@@ -0,0 +1,213 @@
+package dbkit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+)
+
+// Client 各数据库provider共用的provider.DbClient实现,
+// 在*sql.DB的基础上增加钩子和支持嵌套的事务
+type Client struct {
+	*sql.DB
+	logger provider.Logger
+	mutex  sync.RWMutex
+	hooks  []provider.DbHook
+}
+
+func NewClient(db *sql.DB, logger provider.Logger, hooks ...provider.DbHook) *Client {
+	return &Client{DB: db, logger: logger, hooks: hooks}
+}
+
+func (c *Client) Close() error {
+	return c.DB.Close()
+}
+
+func (c *Client) Db() *sql.DB {
+	return c.DB
+}
+
+// AddHook 添加钩子, 并发安全
+func (c *Client) AddHook(hooks ...provider.DbHook) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.hooks = append(c.hooks[:len(c.hooks):len(c.hooks)], hooks...)
+}
+
+func (c *Client) Exec(query string, args ...any) (sql.Result, error) {
+	return c.ExecContext(context.Background(), query, args...)
+}
+
+func (c *Client) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.QueryContext(context.Background(), query, args...)
+}
+
+func (c *Client) QueryRow(query string, args ...any) *sql.Row {
+	return c.QueryRowContext(context.Background(), query, args...)
+}
+
+func (c *Client) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, event, after := c.before(ctx, provider.DbOpExec, query, args)
+	result, err := c.DB.ExecContext(ctx, event.Query, args...)
+	after(err)
+	return result, err
+}
+
+// QueryContext 钩子的After在返回rows时调用, event.Duration不包含读取rows的时间, 读取rows时的错误也不会传给钩子,
+// 返回值必须是*sql.Rows, 无法在rows关闭时再调用After, 需要统计读取时间时由调用方记录
+func (c *Client) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, event, after := c.before(ctx, provider.DbOpQuery, query, args)
+	rows, err := c.DB.QueryContext(ctx, event.Query, args...)
+	after(err)
+	return rows, err
+}
+
+func (c *Client) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, event, after := c.before(ctx, provider.DbOpQueryRow, query, args)
+	row := c.DB.QueryRowContext(ctx, event.Query, args...)
+	after(row.Err())
+	return row
+}
+
+// RunInTransaction 在事务中执行函数，支持嵌套事务（通过 SAVEPOINT 实现）
+func (c *Client) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	// 检查是否已在事务中
+	if tx, ok := GetTx(ctx); ok {
+		return c.runInSavepoint(ctx, tx, fn)
+	}
+
+	// 开始新事务
+	_, _, after := c.before(ctx, provider.DbOpBegin, "", nil)
+	sqlTx, err := c.BeginTx(ctx, getTxOptions(ctx))
+	after(err)
+	if err != nil {
+		return fmt.Errorf("begin transaction failed: %w", err)
+	}
+	tx := &Tx{Tx: sqlTx, client: c}
+
+	defer func() {
+		if p := recover(); p != nil {
+			c.rollback(ctx, tx)
+			panic(p)
+		}
+	}()
+
+	// 将事务放入 context，支持嵌套事务检测, 事务中的语句通过Tx执行时同样调用钩子
+	txCtx, callbacks := withAfterCommit(context.WithValue(ctx, provider.TxCtxKey{}, tx))
+	err = fn(txCtx)
+	if err != nil {
+		c.rollback(ctx, tx)
+		return err
+	}
+
+	_, _, after = c.before(ctx, provider.DbOpCommit, "", nil)
+	err = tx.Commit()
+	after(err)
+	if err != nil {
+		return err
+	}
+
+	// 提交后的回调使用事务外的ctx
+	callbacks.run(ctx)
+	return nil
+}
+
+// runInSavepoint 已在事务中，创建 SAVEPOINT 实现嵌套事务
+func (c *Client) runInSavepoint(ctx context.Context, tx *Tx, fn func(ctx context.Context) error) error {
+	spName := fmt.Sprintf("sp_%d", time.Now().UnixNano())
+
+	// 创建 SAVEPOINT
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("SAVEPOINT %s", spName))
+	if err != nil {
+		// SAVEPOINT 创建失败，说明事务可能已 aborted
+		c.logError("create savepoint failed",
+			"savepoint", spName,
+			"error", err,
+			"hint", "transaction may be aborted by previous SQL error")
+		return fmt.Errorf("create savepoint %s failed: %w", spName, err)
+	}
+
+	// SAVEPOINT 中注册的提交后回调单独保存, 回滚时丢弃
+	spCtx := ctx
+	parent, _ := ctx.Value(afterCommitCtxKey{}).(*afterCommit)
+	var callbacks *afterCommit
+	if parent != nil {
+		spCtx, callbacks = withAfterCommit(ctx)
+	}
+
+	err = fn(spCtx)
+	if err != nil {
+		// 回滚到 SAVEPOINT
+		_, rbErr := tx.ExecContext(ctx, fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", spName))
+		if rbErr != nil {
+			c.logError("rollback to savepoint failed",
+				"savepoint", spName,
+				"error", rbErr,
+				"original_error", err)
+			// 返回原始错误，但记录回滚失败信息
+			return fmt.Errorf("rollback to savepoint %s failed: %w (original: %v)", spName, rbErr, err)
+		}
+		return err
+	}
+
+	// 释放 SAVEPOINT
+	_, relErr := tx.ExecContext(ctx, fmt.Sprintf("RELEASE SAVEPOINT %s", spName))
+	if relErr != nil {
+		c.logWarn("release savepoint failed",
+			"savepoint", spName,
+			"error", relErr,
+			"hint", "savepoint will be released at transaction commit")
+	}
+
+	if parent != nil {
+		parent.merge(callbacks)
+	}
+	return nil
+}
+
+func (c *Client) rollback(ctx context.Context, tx *Tx) {
+	_, _, after := c.before(ctx, provider.DbOpRollback, "", nil)
+	after(tx.Rollback())
+}
+
+// before 依次调用钩子的Before, 返回的after按相反顺序调用钩子的After
+func (c *Client) before(ctx context.Context, op provider.DbOp, query string, args []any) (context.Context, *provider.DbEvent, func(error)) {
+	c.mutex.RLock()
+	hooks := c.hooks
+	c.mutex.RUnlock()
+
+	event := &provider.DbEvent{Op: op, Query: query, Args: args, Start: time.Now()}
+	if len(hooks) == 0 {
+		return ctx, event, func(error) {}
+	}
+
+	ctxs := make([]context.Context, len(hooks))
+	for i, hook := range hooks {
+		ctx = hook.Before(ctx, event)
+		ctxs[i] = ctx
+	}
+
+	return ctx, event, func(err error) {
+		event.Duration = time.Since(event.Start)
+		event.Err = err
+		for i := len(hooks) - 1; i >= 0; i-- {
+			hooks[i].After(ctxs[i], event)
+		}
+	}
+}
+
+func (c *Client) logError(msg string, keyvals ...any) {
+	if c.logger != nil {
+		c.logger.Error(msg, keyvals...)
+	}
+}
+
+func (c *Client) logWarn(msg string, keyvals ...any) {
+	if c.logger != nil {
+		c.logger.Warn(msg, keyvals...)
+	}
+}
@@ -0,0 +1,250 @@
+package dbkit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hdget/sdk/common/provider"
+)
+
+// testDriver 记录执行的语句, 查询返回匹配的stub结果, 没有匹配时返回空结果集
+type testDriver struct {
+	mutex      sync.Mutex
+	statements []string
+	stubs      []*testStub
+	pingErr    error
+}
+
+// testStub 语句包含query时返回的结果, err不为空时返回错误
+type testStub struct {
+	query   string
+	columns []string
+	rows    [][]driver.Value
+	err     error
+}
+
+type testConn struct {
+	driver *testDriver
+}
+
+type testStmt struct {
+	conn  *testConn
+	query string
+}
+
+type testRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+// recordHook 记录钩子收到的操作, Before和After分别记录
+type recordHook struct {
+	mutex  sync.Mutex
+	before []string
+	after  []string
+}
+
+// testLogger 记录日志的级别和消息
+type testLogger struct {
+	provider.Logger
+	mutex   sync.Mutex
+	entries []string
+}
+
+func newTestClient(hooks ...provider.DbHook) (*Client, *testDriver) {
+	d := &testDriver{}
+	return NewClient(sql.OpenDB(d), nil, hooks...), d
+}
+
+func (d *testDriver) Connect(context.Context) (driver.Conn, error) {
+	return &testConn{driver: d}, nil
+}
+
+func (d *testDriver) Driver() driver.Driver {
+	return d
+}
+
+func (d *testDriver) Open(string) (driver.Conn, error) {
+	return &testConn{driver: d}, nil
+}
+
+func (d *testDriver) record(query string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.statements = append(d.statements, query)
+}
+
+func (d *testDriver) Statements() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]string(nil), d.statements...)
+}
+
+// Stub 之后包含query的查询返回rows
+func (d *testDriver) Stub(query string, columns []string, rows ...[]driver.Value) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.stubs = append(d.stubs, &testStub{query: query, columns: columns, rows: rows})
+}
+
+// StubError 之后包含query的查询返回err
+func (d *testDriver) StubError(query string, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.stubs = append(d.stubs, &testStub{query: query, err: err})
+}
+
+// SetPingError 之后的Ping返回err
+func (d *testDriver) SetPingError(err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.pingErr = err
+}
+
+// match 后添加的stub优先
+func (d *testDriver) match(query string) *testStub {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for i := len(d.stubs) - 1; i >= 0; i-- {
+		if strings.Contains(query, d.stubs[i].query) {
+			return d.stubs[i]
+		}
+	}
+	return nil
+}
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) {
+	return &testStmt{conn: c, query: query}, nil
+}
+
+func (c *testConn) Close() error {
+	return nil
+}
+
+func (c *testConn) Ping(context.Context) error {
+	c.driver.mutex.Lock()
+	defer c.driver.mutex.Unlock()
+	return c.driver.pingErr
+}
+
+func (c *testConn) Begin() (driver.Tx, error) {
+	c.driver.record("BEGIN")
+	return c, nil
+}
+
+func (c *testConn) Commit() error {
+	c.driver.record("COMMIT")
+	return nil
+}
+
+func (c *testConn) Rollback() error {
+	c.driver.record("ROLLBACK")
+	return nil
+}
+
+func (c *testConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.driver.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (c *testConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.driver.record(query)
+	stub := c.driver.match(query)
+	if stub == nil {
+		return &testRows{}, nil
+	}
+	if stub.err != nil {
+		return nil, stub.err
+	}
+	return &testRows{columns: stub.columns, rows: stub.rows}, nil
+}
+
+func (s *testStmt) Close() error {
+	return nil
+}
+
+func (s *testStmt) NumInput() int {
+	return -1
+}
+
+func (s *testStmt) Exec([]driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, nil)
+}
+
+func (s *testStmt) Query([]driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, nil)
+}
+
+func (r *testRows) Columns() []string {
+	return r.columns
+}
+
+func (r *testRows) Close() error {
+	return nil
+}
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func (h *recordHook) Before(ctx context.Context, event *provider.DbEvent) context.Context {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.before = append(h.before, string(event.Op)+" "+event.Query)
+	return ctx
+}
+
+func (h *recordHook) After(_ context.Context, event *provider.DbEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.after = append(h.after, string(event.Op)+" "+event.Query)
+}
+
+func (l *testLogger) Debug(msg string, _ ...any) {
+	l.record("debug", msg)
+}
+
+func (l *testLogger) Info(msg string, _ ...any) {
+	l.record("info", msg)
+}
+
+func (l *testLogger) Warn(msg string, _ ...any) {
+	l.record("warn", msg)
+}
+
+func (l *testLogger) Error(msg string, _ ...any) {
+	l.record("error", msg)
+}
+
+// Contains 是否记录了level级别并且包含msg的日志
+func (l *testLogger) Contains(level, msg string) bool {
+	return l.Count(level, msg) > 0
+}
+
+// Count level级别并且包含msg的日志数量
+func (l *testLogger) Count(level, msg string) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	var n int
+	for _, entry := range l.entries {
+		if strings.HasPrefix(entry, level+" ") && strings.Contains(entry, msg) {
+			n++
+		}
+	}
+	return n
+}
+
+func (l *testLogger) record(level, msg string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, level+" "+msg)
+}
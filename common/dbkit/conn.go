@@ -0,0 +1,212 @@
+package dbkit
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// PoolConfig 连接池配置, 通过`mapstructure:",squash"`嵌入到各数据库的连接配置中
+type PoolConfig struct {
+	// MaxOpenConns 最大连接数, 小于0时不限制
+	MaxOpenConns int `mapstructure:"max_open_conns"`
+	// MaxIdleConns 最大空闲连接数, 缺省为MaxOpenConns的20%, 小于0时不保留空闲连接
+	MaxIdleConns int `mapstructure:"max_idle_conns"`
+	// ConnMaxLifetime 连接最长使用时间, 单位: 秒, 小于0时不限制
+	ConnMaxLifetime int `mapstructure:"conn_max_lifetime"`
+	// ConnMaxIdleTime 连接最长空闲时间, 单位: 秒, 小于0时不限制
+	ConnMaxIdleTime int `mapstructure:"conn_max_idle_time"`
+}
+
+// TLSConfig 加密连接配置, 通过`mapstructure:",squash"`嵌入到各数据库的连接配置中
+type TLSConfig struct {
+	// TLSMode 为空时使用驱动的缺省行为
+	//   - disable: 不加密
+	//   - require: 加密但不校验服务端证书
+	//   - verify-ca: 校验服务端证书由受信任的CA签发
+	//   - verify-full: 校验服务端证书并且校验主机名
+	TLSMode string `mapstructure:"tls_mode" validate:"oneof=disable require verify-ca verify-full"`
+	// TLSCA 校验服务端证书的CA证书文件, 为空时使用系统证书
+	TLSCA string `mapstructure:"tls_ca"`
+	// TLSCert 客户端证书文件
+	TLSCert string `mapstructure:"tls_cert"`
+	// TLSKey 客户端私钥文件
+	TLSKey string `mapstructure:"tls_key"`
+	// TLSServerName 校验的主机名, 为空时使用连接的主机
+	TLSServerName string `mapstructure:"tls_server_name"`
+}
+
+const (
+	TLSModeDisable    = "disable"
+	TLSModeRequire    = "require"
+	TLSModeVerifyCA   = "verify-ca"
+	TLSModeVerifyFull = "verify-full"
+
+	// 没有限制最大连接数时的最大空闲连接数, 和database/sql的缺省值一致
+	defaultMaxIdleConns = 2
+)
+
+// MergeDefaults 没有配置的项使用defaults中的值, 不同数据库的缺省值不同
+func (c *PoolConfig) MergeDefaults(defaults PoolConfig) {
+	if c.MaxOpenConns == 0 {
+		c.MaxOpenConns = defaults.MaxOpenConns
+	}
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = defaults.MaxIdleConns
+	}
+	if c.ConnMaxLifetime == 0 {
+		c.ConnMaxLifetime = defaults.ConnMaxLifetime
+	}
+	if c.ConnMaxIdleTime == 0 {
+		c.ConnMaxIdleTime = defaults.ConnMaxIdleTime
+	}
+}
+
+// ApplyPool 设置db的连接池参数, 配置变化时也可以重新设置
+func (c *PoolConfig) ApplyPool(db *sql.DB) {
+	maxIdleConns := c.MaxIdleConns
+	if maxIdleConns == 0 {
+		maxIdleConns = defaultMaxIdleConns
+		if c.MaxOpenConns > 0 {
+			maxIdleConns = max(c.MaxOpenConns/5, 1)
+		}
+	}
+
+	db.SetMaxOpenConns(max(c.MaxOpenConns, 0))
+	db.SetMaxIdleConns(max(maxIdleConns, 0))
+	db.SetConnMaxLifetime(time.Duration(max(c.ConnMaxLifetime, 0)) * time.Second)
+	db.SetConnMaxIdleTime(time.Duration(max(c.ConnMaxIdleTime, 0)) * time.Second)
+}
+
+// Enabled 是否需要加密连接
+func (c *TLSConfig) Enabled() bool {
+	return c.TLSMode != "" && c.TLSMode != TLSModeDisable
+}
+
+// LoadTLS 加载证书并生成*tls.Config, 不需要加密时返回nil
+func (c *TLSConfig) LoadTLS(serverName string) (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{ServerName: serverName, MinVersion: tls.VersionTLS12}
+	if c.TLSServerName != "" {
+		tlsConfig.ServerName = c.TLSServerName
+	}
+
+	if c.TLSCA != "" {
+		data, err := os.ReadFile(c.TLSCA)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read tls ca, file: %s", c.TLSCA)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.Errorf("invalid tls ca, file: %s", c.TLSCA)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.TLSCert != "" || c.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCert, c.TLSKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "load tls client cert, cert: %s, key: %s", c.TLSCert, c.TLSKey)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	switch c.TLSMode {
+	case TLSModeRequire:
+		tlsConfig.InsecureSkipVerify = true
+	case TLSModeVerifyCA:
+		// 跳过主机名校验, 只校验证书链
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = verifyCertChain(tlsConfig.RootCAs)
+	}
+	return tlsConfig, nil
+}
+
+// EncodeParams 合并缺省的连接参数和配置的连接参数, 配置的参数优先, 返回按key排序编码后的查询字符串
+func EncodeParams(defaults map[string]string, params map[string]string) string {
+	values := make(url.Values)
+	for k, v := range defaults {
+		values.Set(k, v)
+	}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+// Stats 返回各客户端连接池的统计信息, key为default, master, slaves.<序号>和items.<名字>, 没有配置的客户端不返回
+func Stats(defaultDb, masterDb provider.DbClient, slaveDbs []provider.DbClient, extraDbs map[string]provider.DbClient) map[string]sql.DBStats {
+	stats := make(map[string]sql.DBStats)
+	if defaultDb != nil {
+		stats["default"] = defaultDb.Db().Stats()
+	}
+	if masterDb != nil {
+		stats["master"] = masterDb.Db().Stats()
+	}
+	for i, slaveDb := range slaveDbs {
+		if slaveDb != nil {
+			stats["slaves."+strconv.Itoa(i)] = slaveDb.Db().Stats()
+		}
+	}
+	for name, extraDb := range extraDbs {
+		if extraDb != nil {
+			stats["items."+name] = extraDb.Db().Stats()
+		}
+	}
+	return stats
+}
+
+// Close 依次关闭各客户端, 参数和Stats相同, 某个客户端关闭失败时仍然关闭其他客户端, 返回第一个错误
+func Close(defaultDb, masterDb provider.DbClient, slaveDbs []provider.DbClient, extraDbs map[string]provider.DbClient) error {
+	clients := append([]provider.DbClient{defaultDb, masterDb}, slaveDbs...)
+	for _, extraDb := range extraDbs {
+		clients = append(clients, extraDb)
+	}
+
+	var firstErr error
+	for _, client := range clients {
+		if client == nil {
+			continue
+		}
+		if err := client.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func verifyCertChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no server certificate")
+		}
+
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return errors.Wrap(err, "parse server certificate")
+			}
+			certs[i] = cert
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
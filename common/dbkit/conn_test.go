@@ -0,0 +1,253 @@
+package dbkit
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// testCert 测试用的证书, der为证书内容, certFile和keyFile为写入的PEM文件
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	der      []byte
+	certFile string
+	keyFile  string
+}
+
+// closeClient 记录是否关闭, Close返回err
+type closeClient struct {
+	provider.DbClient
+	closed bool
+	err    error
+}
+
+func (c *closeClient) Close() error {
+	c.closed = true
+	return c.err
+}
+
+// newTestCert 生成证书, parent为空时生成自签名的CA证书
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	c := &testCert{cert: cert, key: key, der: der, certFile: filepath.Join(dir, "cert.pem"), keyFile: filepath.Join(dir, "key.pem")}
+	writeTestFile(t, c.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeTestFile(t, c.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+	return c
+}
+
+func writeTestFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	client := newTestCert(t, "client", ca)
+
+	testCases := []struct {
+		name           string
+		config         TLSConfig
+		wantNil        bool
+		wantServerName string
+		wantInsecure   bool
+		wantVerify     bool
+	}{
+		{name: "empty", config: TLSConfig{}, wantNil: true},
+		{name: "disable", config: TLSConfig{TLSMode: TLSModeDisable, TLSCA: "not-exist"}, wantNil: true},
+		{name: "require", config: TLSConfig{TLSMode: TLSModeRequire}, wantServerName: "db", wantInsecure: true},
+		{name: "verify-ca", config: TLSConfig{TLSMode: TLSModeVerifyCA, TLSCA: ca.certFile}, wantServerName: "db", wantInsecure: true, wantVerify: true},
+		{name: "verify-full", config: TLSConfig{TLSMode: TLSModeVerifyFull, TLSCA: ca.certFile}, wantServerName: "db"},
+		{name: "server name", config: TLSConfig{TLSMode: TLSModeVerifyFull, TLSServerName: "db.internal"}, wantServerName: "db.internal"},
+		{name: "client cert", config: TLSConfig{TLSMode: TLSModeVerifyFull, TLSCert: client.certFile, TLSKey: client.keyFile}, wantServerName: "db"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.config.LoadTLS("db")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.wantNil {
+				if got != nil {
+					t.Fatalf("LoadTLS() = %+v, want nil", got)
+				}
+				return
+			}
+
+			if got.ServerName != tc.wantServerName || got.InsecureSkipVerify != tc.wantInsecure || (got.VerifyPeerCertificate != nil) != tc.wantVerify {
+				t.Fatalf("LoadTLS() server name = %s, insecure = %v, verify = %v", got.ServerName, got.InsecureSkipVerify, got.VerifyPeerCertificate != nil)
+			}
+			if got.MinVersion != tls.VersionTLS12 {
+				t.Fatalf("min version = %x, want tls 1.2", got.MinVersion)
+			}
+			if (got.RootCAs != nil) != (tc.config.TLSCA != "") || len(got.Certificates) > 0 != (tc.config.TLSCert != "") {
+				t.Fatalf("LoadTLS() root cas = %v, certificates = %d", got.RootCAs != nil, len(got.Certificates))
+			}
+		})
+	}
+}
+
+func TestLoadTLSError(t *testing.T) {
+	invalid := filepath.Join(t.TempDir(), "invalid.pem")
+	writeTestFile(t, invalid, []byte("invalid"))
+
+	for _, config := range []TLSConfig{
+		{TLSMode: TLSModeVerifyFull, TLSCA: filepath.Join(t.TempDir(), "not-exist.pem")},
+		{TLSMode: TLSModeVerifyFull, TLSCA: invalid},
+		{TLSMode: TLSModeRequire, TLSCert: invalid, TLSKey: invalid},
+		{TLSMode: TLSModeRequire, TLSKey: invalid},
+	} {
+		if _, err := config.LoadTLS("db"); err == nil {
+			t.Errorf("LoadTLS(%+v) want error", config)
+		}
+	}
+}
+
+// verify-ca只校验证书链, 不校验主机名
+func TestVerifyCertChain(t *testing.T) {
+	ca := newTestCert(t, "ca", nil)
+	server := newTestCert(t, "other.host", ca)
+	other := newTestCert(t, "other", nil)
+
+	config, err := (&TLSConfig{TLSMode: TLSModeVerifyCA, TLSCA: ca.certFile}).LoadTLS("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = config.VerifyPeerCertificate([][]byte{server.der}, nil); err != nil {
+		t.Fatalf("verify cert signed by ca: %v", err)
+	}
+	if err = config.VerifyPeerCertificate([][]byte{other.der}, nil); err == nil {
+		t.Fatal("verify cert signed by other ca, want error")
+	}
+	if err = config.VerifyPeerCertificate(nil, nil); err == nil {
+		t.Fatal("verify without cert, want error")
+	}
+	if err = config.VerifyPeerCertificate([][]byte{[]byte("invalid")}, nil); err == nil {
+		t.Fatal("verify invalid cert, want error")
+	}
+}
+
+func TestEncodeParams(t *testing.T) {
+	testCases := []struct {
+		name     string
+		defaults map[string]string
+		params   map[string]string
+		want     string
+	}{
+		{name: "empty", want: ""},
+		{name: "defaults", defaults: map[string]string{"charset": "utf8mb4", "parseTime": "true"}, want: "charset=utf8mb4&parseTime=true"},
+		{name: "override", defaults: map[string]string{"loc": "Local", "charset": "utf8mb4"}, params: map[string]string{"loc": "Asia/Shanghai"}, want: "charset=utf8mb4&loc=Asia%2FShanghai"},
+		{name: "params", params: map[string]string{"b": "2", "a": "1 2"}, want: "a=1+2&b=2"},
+	}
+
+	for _, tc := range testCases {
+		if got := EncodeParams(tc.defaults, tc.params); got != tc.want {
+			t.Errorf("%s: EncodeParams() = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestPoolConfig(t *testing.T) {
+	c := PoolConfig{MaxOpenConns: 20, ConnMaxIdleTime: -1}
+	c.MergeDefaults(PoolConfig{MaxOpenConns: 10, MaxIdleConns: 5, ConnMaxLifetime: 3600, ConnMaxIdleTime: 600})
+	if c != (PoolConfig{MaxOpenConns: 20, MaxIdleConns: 5, ConnMaxLifetime: 3600, ConnMaxIdleTime: -1}) {
+		t.Fatalf("MergeDefaults() = %+v", c)
+	}
+
+	testCases := []struct {
+		name     string
+		config   PoolConfig
+		wantOpen int
+	}{
+		{name: "default", config: PoolConfig{}, wantOpen: 0},
+		{name: "limited", config: PoolConfig{MaxOpenConns: 10}, wantOpen: 10},
+		{name: "unlimited", config: PoolConfig{MaxOpenConns: -1, MaxIdleConns: -1, ConnMaxLifetime: -1}, wantOpen: 0},
+	}
+
+	for _, tc := range testCases {
+		db := sql.OpenDB(&testDriver{})
+		tc.config.ApplyPool(db)
+		if got := db.Stats().MaxOpenConnections; got != tc.wantOpen {
+			t.Errorf("%s: max open conns = %d, want %d", tc.name, got, tc.wantOpen)
+		}
+		_ = db.Close()
+	}
+}
+
+// 关闭失败时继续关闭其他客户端, 返回第一个错误
+func TestClose(t *testing.T) {
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+	master := &closeClient{}
+	slaves := []*closeClient{{err: errFirst}, {}}
+	extra := &closeClient{err: errSecond}
+
+	err := Close(nil, master, []provider.DbClient{slaves[0], nil, slaves[1]}, map[string]provider.DbClient{"extra": extra, "empty": nil})
+	if !errors.Is(err, errFirst) {
+		t.Fatalf("Close() = %v, want %v", err, errFirst)
+	}
+	for i, c := range []*closeClient{master, slaves[0], slaves[1], extra} {
+		if !c.closed {
+			t.Fatalf("client %d not closed", i)
+		}
+	}
+
+	if err = Close(nil, nil, nil, nil); err != nil {
+		t.Fatalf("Close() without clients = %v", err)
+	}
+}
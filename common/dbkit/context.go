@@ -0,0 +1,26 @@
+package dbkit
+
+import (
+	"context"
+
+	"github.com/hdget/sdk/common/provider"
+)
+
+type primaryCtxKey struct{}
+
+// WithPrimary 标记之后的读操作使用主库, 用于写入后需要立即读到写入结果的场景
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryCtxKey{}, true)
+}
+
+// UsePrimary ctx中有事务或者标记了WithPrimary时读操作需要使用主库
+func UsePrimary(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	if ctx.Value(provider.TxCtxKey{}) != nil {
+		return true
+	}
+	primary, _ := ctx.Value(primaryCtxKey{}).(bool)
+	return primary
+}
@@ -0,0 +1,214 @@
+package dbkit
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hdget/sdk/common/bizctx"
+	"github.com/hdget/sdk/common/provider"
+)
+
+// slowQueryHook 记录执行时间超过阈值的操作
+type slowQueryHook struct {
+	logger    provider.Logger
+	threshold time.Duration
+}
+
+// latencyHook 按语句统计执行时间
+type latencyHook struct {
+	observer LatencyObserver
+}
+
+// commentHook 在语句后添加sqlcommenter格式的注释
+type commentHook struct{}
+
+// LatencyObserver 记录每条语句的执行时间, 例如上报到prometheus
+type LatencyObserver interface {
+	ObserveLatency(op provider.DbOp, statement string, duration time.Duration, err error)
+}
+
+// Histogram 内存中的执行时间直方图, 按操作类型和语句分别统计
+type Histogram struct {
+	mutex   sync.Mutex
+	buckets []time.Duration
+	series  map[histogramKey]*HistogramSnapshot
+}
+
+// HistogramSnapshot 一条语句的统计结果, Counts[i]为执行时间小于等于Buckets[i]的次数, 最后一个为所有次数
+type HistogramSnapshot struct {
+	Op        provider.DbOp
+	Statement string
+	Buckets   []time.Duration
+	Counts    []uint64
+	Sum       time.Duration
+	Errors    uint64
+}
+
+type histogramKey struct {
+	op        provider.DbOp
+	statement string
+}
+
+const (
+	// 统计时语句的最大长度
+	maxStatementLength = 256
+)
+
+var (
+	// DefaultHistogramBuckets 缺省的直方图分桶
+	DefaultHistogramBuckets = []time.Duration{
+		time.Millisecond, 5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond,
+		100 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second,
+	}
+	sqlCommentPattern = regexp.MustCompile(`/\*.*?\*/`)
+	spacePattern      = regexp.MustCompile(`\s+`)
+)
+
+// NewSlowQueryHook 执行时间超过threshold的操作通过logger记录为warn, 参数不会被记录
+func NewSlowQueryHook(logger provider.Logger, threshold time.Duration) provider.DbHook {
+	return &slowQueryHook{logger: logger, threshold: threshold}
+}
+
+func (h *slowQueryHook) Before(ctx context.Context, _ *provider.DbEvent) context.Context {
+	return ctx
+}
+
+func (h *slowQueryHook) After(_ context.Context, event *provider.DbEvent) {
+	if event.Duration < h.threshold {
+		return
+	}
+
+	keyvals := []any{"op", event.Op, "query", event.Query, "cost", event.Duration}
+	if event.Err != nil {
+		keyvals = append(keyvals, "err", event.Err)
+	}
+	h.logger.Warn("slow query", keyvals...)
+}
+
+// NewLatencyHook 将每条语句的执行时间交给observer, 语句中的注释和多余空白会被去掉
+func NewLatencyHook(observer LatencyObserver) provider.DbHook {
+	return &latencyHook{observer: observer}
+}
+
+func (h *latencyHook) Before(ctx context.Context, _ *provider.DbEvent) context.Context {
+	return ctx
+}
+
+func (h *latencyHook) After(_ context.Context, event *provider.DbEvent) {
+	h.observer.ObserveLatency(event.Op, NormalizeStatement(event.Query), event.Duration, event.Err)
+}
+
+// NewCommentHook 在语句后添加包含bizctx中tid和uid的注释, 例如: SELECT 1 /*tid='1',uid='2'*/
+// 便于在数据库的慢查询日志和会话列表中定位请求, ctx中没有tid和uid时不添加
+func NewCommentHook() provider.DbHook {
+	return &commentHook{}
+}
+
+func (h *commentHook) Before(ctx context.Context, event *provider.DbEvent) context.Context {
+	if event.Query == "" {
+		return ctx
+	}
+
+	tags := make([]string, 0, 2)
+	if tid := bizctx.GetTid(ctx); tid != 0 {
+		tags = append(tags, "tid='"+url.QueryEscape(strconv.FormatInt(tid, 10))+"'")
+	}
+	if uid := bizctx.GetUid(ctx); uid != 0 {
+		tags = append(tags, "uid='"+url.QueryEscape(strconv.FormatInt(uid, 10))+"'")
+	}
+	if len(tags) > 0 {
+		event.Query = strings.TrimRight(event.Query, "; \t\n") + " /*" + strings.Join(tags, ",") + "*/"
+	}
+	return ctx
+}
+
+func (h *commentHook) After(context.Context, *provider.DbEvent) {}
+
+// NewHistogram 按buckets分桶统计执行时间, buckets为空时使用DefaultHistogramBuckets
+func NewHistogram(buckets ...time.Duration) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultHistogramBuckets
+	}
+
+	sorted := append([]time.Duration{}, buckets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return &Histogram{buckets: sorted, series: make(map[histogramKey]*HistogramSnapshot)}
+}
+
+func (h *Histogram) ObserveLatency(op provider.DbOp, statement string, duration time.Duration, err error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	key := histogramKey{op: op, statement: statement}
+	s, exists := h.series[key]
+	if !exists {
+		s = &HistogramSnapshot{Op: op, Statement: statement, Buckets: h.buckets, Counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+
+	for i, bucket := range h.buckets {
+		if duration <= bucket {
+			s.Counts[i]++
+		}
+	}
+	s.Counts[len(h.buckets)]++
+	s.Sum += duration
+	if err != nil {
+		s.Errors++
+	}
+}
+
+// Snapshot 返回当前所有语句的统计结果
+func (h *Histogram) Snapshot() []HistogramSnapshot {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	snapshots := make([]HistogramSnapshot, 0, len(h.series))
+	for _, s := range h.series {
+		snapshot := *s
+		snapshot.Counts = append([]uint64{}, s.Counts...)
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].Statement == snapshots[j].Statement {
+			return snapshots[i].Op < snapshots[j].Op
+		}
+		return snapshots[i].Statement < snapshots[j].Statement
+	})
+	return snapshots
+}
+
+// NormalizeStatement 去掉语句中的注释和多余空白, 超长的语句会被截断
+func NormalizeStatement(query string) string {
+	s := sqlCommentPattern.ReplaceAllString(query, "")
+	s = strings.TrimSpace(spacePattern.ReplaceAllString(s, " "))
+	if len(s) > maxStatementLength {
+		// 在字符边界截断, 避免截断多字节字符
+		n := maxStatementLength
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		s = s[:n]
+	}
+	return s
+}
+
+// NewHooks 根据配置创建内置的钩子, slowQueryThreshold小于等于0时不记录慢查询, sqlComment为true时添加注释
+func NewHooks(logger provider.Logger, slowQueryThreshold time.Duration, sqlComment bool) []provider.DbHook {
+	hooks := make([]provider.DbHook, 0, 2)
+	if sqlComment {
+		hooks = append(hooks, NewCommentHook())
+	}
+	if slowQueryThreshold > 0 && logger != nil {
+		hooks = append(hooks, NewSlowQueryHook(logger, slowQueryThreshold))
+	}
+	return hooks
+}
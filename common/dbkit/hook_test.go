@@ -0,0 +1,223 @@
+package dbkit
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hdget/sdk/common/bizctx"
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+func TestHooks(t *testing.T) {
+	logger := &testLogger{}
+	histogram := NewHistogram()
+	client, d := newTestClient()
+	defer func() {
+		_ = client.Close()
+	}()
+	client.AddHook(NewCommentHook(), NewLatencyHook(histogram), NewSlowQueryHook(logger, 0))
+
+	ctx := bizctx.WithUid(bizctx.WithTid(context.Background(), 1), 2)
+	err := client.RunInTransaction(ctx, func(ctx context.Context) error {
+		return client.RunInTransaction(ctx, func(ctx context.Context) error { return nil })
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.ExecContext(ctx, "UPDATE t SET a = ?;", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	statements := d.Statements()
+	if len(statements) != 5 || !strings.HasPrefix(statements[1], "SAVEPOINT ") || statements[4] != "UPDATE t SET a = ? /*tid='1',uid='2'*/" {
+		t.Fatalf("unexpected statements: %v", statements)
+	}
+
+	// 统计的语句不包含注释
+	ops := make(map[provider.DbOp]uint64)
+	for _, s := range histogram.Snapshot() {
+		ops[s.Op] += s.Counts[len(s.Counts)-1]
+		if s.Op == provider.DbOpExec && strings.HasPrefix(s.Statement, "UPDATE") && s.Statement != "UPDATE t SET a = ?" {
+			t.Fatalf("unexpected snapshot: %+v", s)
+		}
+	}
+	if ops[provider.DbOpBegin] != 1 || ops[provider.DbOpCommit] != 1 || ops[provider.DbOpExec] != 3 {
+		t.Fatalf("unexpected observed ops: %v", ops)
+	}
+
+	if !logger.Contains("warn", "slow query") {
+		t.Fatal("slow query should be logged")
+	}
+}
+
+func TestCommentHook(t *testing.T) {
+	testCases := []struct {
+		name  string
+		ctx   context.Context
+		query string
+		want  string
+	}{
+		{name: "tid and uid", ctx: bizctx.WithUid(bizctx.WithTid(context.Background(), 1), 2), query: "SELECT 1", want: "SELECT 1 /*tid='1',uid='2'*/"},
+		{name: "tid only", ctx: bizctx.WithTid(context.Background(), 1), query: "SELECT 1; \n", want: "SELECT 1 /*tid='1'*/"},
+		{name: "no metadata", ctx: context.Background(), query: "SELECT 1;", want: "SELECT 1;"},
+		{name: "empty query", ctx: bizctx.WithTid(context.Background(), 1), query: "", want: ""},
+	}
+
+	hook := NewCommentHook()
+	for _, tc := range testCases {
+		event := &provider.DbEvent{Op: provider.DbOpQuery, Query: tc.query}
+		hook.Before(tc.ctx, event)
+		if event.Query != tc.want {
+			t.Errorf("%s: query = %q, want %q", tc.name, event.Query, tc.want)
+		}
+	}
+}
+
+func TestSlowQueryHook(t *testing.T) {
+	logger := &testLogger{}
+	hook := NewSlowQueryHook(logger, 100*time.Millisecond)
+
+	hook.After(context.Background(), &provider.DbEvent{Op: provider.DbOpQuery, Query: "SELECT 1", Duration: 10 * time.Millisecond})
+	if logger.Contains("warn", "slow query") {
+		t.Fatal("fast query should not be logged")
+	}
+
+	hook.After(context.Background(), &provider.DbEvent{Op: provider.DbOpQuery, Query: "SELECT 1", Duration: time.Second})
+	if !logger.Contains("warn", "slow query") {
+		t.Fatal("slow query should be logged")
+	}
+}
+
+// 查询的After在返回rows时调用, 慢慢读取rows的时间不计入查询时间
+func TestQueryHookSlowRows(t *testing.T) {
+	const delay = 50 * time.Millisecond
+
+	logger := &testLogger{}
+	hook := &recordHook{}
+	client, d := newTestClient(hook, NewSlowQueryHook(logger, 2*delay))
+	defer func() {
+		_ = client.Close()
+	}()
+	d.Stub("SELECT a FROM t", []string{"a"}, []driver.Value{int64(1)}, []driver.Value{int64(2)}, []driver.Value{int64(3)})
+
+	readSlowly := func(ctx context.Context, executor provider.DbContextExecutor) {
+		rows, err := executor.QueryContext(ctx, "SELECT a FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+
+		if got := hook.after[len(hook.after)-1]; got != "query SELECT a FROM t" {
+			t.Fatalf("last hook after = %q, want query called before reading rows", got)
+		}
+
+		var n int
+		for rows.Next() {
+			time.Sleep(delay)
+			n++
+		}
+		if err = rows.Err(); err != nil || n != 3 {
+			t.Fatalf("read %d rows, err: %v", n, err)
+		}
+	}
+
+	readSlowly(context.Background(), client)
+	err := client.RunInTransaction(context.Background(), func(ctx context.Context) error {
+		tx, _ := GetTx(ctx)
+		readSlowly(ctx, tx)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if logger.Contains("warn", "slow query") {
+		t.Fatal("time spent reading rows should not be counted in the query duration")
+	}
+}
+
+func TestNormalizeStatement(t *testing.T) {
+	long := "SELECT " + strings.Repeat("a", maxStatementLength)
+	// 第maxStatementLength个字节在"中"的中间
+	multiByte := "SELECT '" + strings.Repeat("a", maxStatementLength-9) + "中文'"
+	testCases := []struct {
+		query string
+		want  string
+	}{
+		{query: "SELECT 1", want: "SELECT 1"},
+		{query: "  SELECT\n\t*  FROM t\n WHERE id = ?  ", want: "SELECT * FROM t WHERE id = ?"},
+		{query: "SELECT 1 /*tid='1',uid='2'*/", want: "SELECT 1"},
+		{query: "/* a */ SELECT /* b */ 1", want: "SELECT 1"},
+		{query: long, want: long[:maxStatementLength]},
+		{query: multiByte, want: multiByte[:maxStatementLength-1]},
+	}
+
+	for _, tc := range testCases {
+		if got := NormalizeStatement(tc.query); got != tc.want {
+			t.Errorf("NormalizeStatement(%q) = %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram(10*time.Millisecond, time.Millisecond)
+	h.ObserveLatency(provider.DbOpQuery, "SELECT 1", 500*time.Microsecond, nil)
+	h.ObserveLatency(provider.DbOpQuery, "SELECT 1", 5*time.Millisecond, nil)
+	h.ObserveLatency(provider.DbOpQuery, "SELECT 1", time.Second, errors.New("timeout"))
+	h.ObserveLatency(provider.DbOpExec, "SELECT 1", time.Millisecond, nil)
+	h.ObserveLatency(provider.DbOpExec, "DELETE FROM t", time.Millisecond, nil)
+
+	want := []HistogramSnapshot{
+		{Op: provider.DbOpExec, Statement: "DELETE FROM t", Counts: []uint64{1, 1, 1}, Sum: time.Millisecond},
+		{Op: provider.DbOpExec, Statement: "SELECT 1", Counts: []uint64{1, 1, 1}, Sum: time.Millisecond},
+		{Op: provider.DbOpQuery, Statement: "SELECT 1", Counts: []uint64{1, 2, 3}, Sum: 500*time.Microsecond + 5*time.Millisecond + time.Second, Errors: 1},
+	}
+	snapshots := h.Snapshot()
+	for i := range snapshots {
+		// 分桶按从小到大排序
+		if !reflect.DeepEqual(snapshots[i].Buckets, []time.Duration{time.Millisecond, 10 * time.Millisecond}) {
+			t.Fatalf("buckets = %v", snapshots[i].Buckets)
+		}
+		snapshots[i].Buckets = nil
+	}
+	if !reflect.DeepEqual(snapshots, want) {
+		t.Fatalf("Snapshot() = %+v, want %+v", snapshots, want)
+	}
+
+	// 快照不受之后的统计影响
+	h.ObserveLatency(provider.DbOpExec, "DELETE FROM t", time.Millisecond, nil)
+	if snapshots[0].Counts[2] != 1 {
+		t.Fatal("snapshot changed after observe")
+	}
+
+	if got := NewHistogram().buckets; !reflect.DeepEqual(got, DefaultHistogramBuckets) {
+		t.Fatalf("default buckets = %v, want %v", got, DefaultHistogramBuckets)
+	}
+}
+
+func TestNewHooks(t *testing.T) {
+	testCases := []struct {
+		name      string
+		threshold time.Duration
+		comment   bool
+		want      int
+	}{
+		{name: "none", threshold: 0, comment: false, want: 0},
+		{name: "comment", threshold: 0, comment: true, want: 1},
+		{name: "slow query", threshold: time.Second, comment: false, want: 1},
+		{name: "all", threshold: time.Second, comment: true, want: 2},
+	}
+
+	for _, tc := range testCases {
+		if got := NewHooks(&testLogger{}, tc.threshold, tc.comment); len(got) != tc.want {
+			t.Errorf("%s: NewHooks() returns %d hooks, want %d", tc.name, len(got), tc.want)
+		}
+	}
+}
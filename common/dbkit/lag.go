@@ -0,0 +1,111 @@
+package dbkit
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// 最后接收和回放的wal位置相同时没有延迟, 否则按最后回放的事务时间计算,
+	// 避免主库没有写入时把空闲时间当成延迟
+	postgresLagQuery = `SELECT CASE
+	WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`
+
+	// mysql账号没有SUPER或者REPLICATION CLIENT权限时的错误码
+	mysqlErrSpecificAccessDenied = "Error 1227"
+)
+
+var (
+	// ErrLagUnknown 没有权限查询复制延迟, ReplicaSet此时只检查从库的连通性
+	ErrLagUnknown = errors.New("replication lag unknown")
+)
+
+// PostgresReplicationLag 查询postgresql从库的复制延迟
+func PostgresReplicationLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	var seconds float64
+	if err := db.QueryRowContext(ctx, postgresLagQuery).Scan(&seconds); err != nil {
+		return 0, errors.Wrap(err, "query postgresql replication lag")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// MysqlReplicationLag 查询mysql从库的复制延迟, 复制线程停止时返回错误
+// 优先使用8.0.22之后的SHOW REPLICA STATUS, 不支持时使用SHOW SLAVE STATUS
+// 查询需要REPLICATION CLIENT权限, 没有权限时返回ErrLagUnknown
+func MysqlReplicationLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	status, err := queryMysqlReplicaStatus(ctx, db, "SHOW REPLICA STATUS")
+	if err != nil {
+		status, err = queryMysqlReplicaStatus(ctx, db, "SHOW SLAVE STATUS")
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), mysqlErrSpecificAccessDenied) {
+			return 0, errors.Wrap(ErrLagUnknown, err.Error())
+		}
+		return 0, err
+	}
+
+	// 不是从库
+	if status == nil {
+		return 0, nil
+	}
+
+	for _, column := range []string{"Seconds_Behind_Source", "Seconds_Behind_Master"} {
+		value, exists := status[column]
+		if !exists {
+			continue
+		}
+		if !value.Valid {
+			return 0, errors.New("mysql replication is not running")
+		}
+
+		seconds, err := strconv.ParseInt(strings.TrimSpace(value.String), 10, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "parse mysql replication lag, value: %s", value.String)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, errors.New("mysql replication lag column not found")
+}
+
+// queryMysqlReplicaStatus 返回列名到值的映射, 没有复制状态时返回nil
+func queryMysqlReplicaStatus(ctx context.Context, db *sql.DB, query string) (map[string]sql.NullString, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "query mysql replica status, query: %s", query)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, errors.Wrap(err, "get mysql replica status columns")
+	}
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, errors.Wrap(err, "read mysql replica status")
+		}
+		return nil, nil
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return nil, errors.Wrap(err, "scan mysql replica status")
+	}
+
+	status := make(map[string]sql.NullString, len(columns))
+	for i, column := range columns {
+		status[column] = values[i]
+	}
+	return status, nil
+}
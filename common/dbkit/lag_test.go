@@ -0,0 +1,125 @@
+package dbkit
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestMysqlReplicationLag(t *testing.T) {
+	errUnsupported := errors.New("You have an error in your SQL syntax")
+
+	testCases := []struct {
+		name    string
+		stub    func(d *testDriver)
+		want    time.Duration
+		wantErr bool
+	}{
+		{
+			name: "replica status",
+			stub: func(d *testDriver) {
+				d.Stub("SHOW REPLICA STATUS", []string{"Source_Host", "Seconds_Behind_Source"}, []driver.Value{"master", []byte("5")})
+			},
+			want: 5 * time.Second,
+		},
+		{
+			name: "slave status",
+			stub: func(d *testDriver) {
+				d.StubError("SHOW REPLICA STATUS", errUnsupported)
+				d.Stub("SHOW SLAVE STATUS", []string{"Master_Host", "Seconds_Behind_Master"}, []driver.Value{"master", " 12 "})
+			},
+			want: 12 * time.Second,
+		},
+		{
+			name: "not replica",
+			stub: func(d *testDriver) {
+				d.Stub("SHOW REPLICA STATUS", []string{"Source_Host", "Seconds_Behind_Source"})
+			},
+			want: 0,
+		},
+		{
+			name: "replication stopped",
+			stub: func(d *testDriver) {
+				d.Stub("SHOW REPLICA STATUS", []string{"Seconds_Behind_Source"}, []driver.Value{nil})
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid value",
+			stub: func(d *testDriver) {
+				d.Stub("SHOW REPLICA STATUS", []string{"Seconds_Behind_Source"}, []driver.Value{"abc"})
+			},
+			wantErr: true,
+		},
+		{
+			name: "column not found",
+			stub: func(d *testDriver) {
+				d.Stub("SHOW REPLICA STATUS", []string{"Source_Host"}, []driver.Value{"master"})
+			},
+			wantErr: true,
+		},
+		{
+			name: "query error",
+			stub: func(d *testDriver) {
+				d.StubError("SHOW REPLICA STATUS", errUnsupported)
+				d.StubError("SHOW SLAVE STATUS", errUnsupported)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, d := newTestClient()
+			defer func() {
+				_ = client.Close()
+			}()
+			tc.stub(d)
+
+			got, err := MysqlReplicationLag(context.Background(), client.Db())
+			if (err != nil) != tc.wantErr || got != tc.want {
+				t.Fatalf("MysqlReplicationLag() = %v, %v, want %v, error %v", got, err, tc.want, tc.wantErr)
+			}
+		})
+	}
+}
+
+// 没有REPLICATION CLIENT权限时无法获取延迟, 其他错误不是
+func TestMysqlReplicationLagAccessDenied(t *testing.T) {
+	client, d := newTestClient()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	d.StubError("SHOW REPLICA STATUS", errors.New("Error 1064 (42000): You have an error in your SQL syntax"))
+	d.StubError("SHOW SLAVE STATUS", errors.New("Error 1227 (42000): Access denied; you need (at least one of) the SUPER, REPLICATION CLIENT privilege(s) for this operation"))
+	if _, err := MysqlReplicationLag(context.Background(), client.Db()); !errors.Is(err, ErrLagUnknown) {
+		t.Fatalf("MysqlReplicationLag() err = %v, want %v", err, ErrLagUnknown)
+	}
+
+	d.StubError("SHOW SLAVE STATUS", errors.New("Error 1045 (28000): Access denied for user"))
+	if _, err := MysqlReplicationLag(context.Background(), client.Db()); err == nil || errors.Is(err, ErrLagUnknown) {
+		t.Fatalf("MysqlReplicationLag() err = %v, want other error", err)
+	}
+}
+
+func TestPostgresReplicationLag(t *testing.T) {
+	client, d := newTestClient()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	d.Stub("pg_last_xact_replay_timestamp", []string{"lag"}, []driver.Value{1.5})
+	got, err := PostgresReplicationLag(context.Background(), client.Db())
+	if err != nil || got != 1500*time.Millisecond {
+		t.Fatalf("PostgresReplicationLag() = %v, %v, want 1.5s", got, err)
+	}
+
+	d.StubError("pg_last_xact_replay_timestamp", errors.New("connection reset"))
+	if _, err = PostgresReplicationLag(context.Background(), client.Db()); err == nil {
+		t.Fatal("PostgresReplicationLag() with query error, want error")
+	}
+}
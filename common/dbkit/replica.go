@@ -0,0 +1,192 @@
+package dbkit
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// ReplicaSet 从库集合, 定期检查从库的连通性和复制延迟,
+// 检查失败或者延迟超过限制的从库被剔除, 恢复后重新加入
+type ReplicaSet struct {
+	logger        provider.Logger
+	replicas      []*replica
+	lagProbe      LagProbe
+	checkInterval time.Duration
+	maxLag        time.Duration
+	idx           uint64 // 用于轮询选择从库
+	closing       chan struct{}
+	closeOnce     sync.Once
+	wg            sync.WaitGroup
+}
+
+// LagProbe 查询从库的复制延迟, 无法获取延迟时返回ErrLagUnknown
+type LagProbe func(ctx context.Context, db *sql.DB) (time.Duration, error)
+
+type ReplicaOption func(*ReplicaSet)
+
+const (
+	defaultCheckTimeout = 5 * time.Second
+)
+
+type replica struct {
+	index      int
+	client     provider.DbClient
+	healthy    atomic.Bool
+	lagUnknown atomic.Bool // 是否已经记录过无法获取复制延迟
+}
+
+// WithLagProbe 查询复制延迟的方法, 不指定时只检查连通性
+func WithLagProbe(probe LagProbe) ReplicaOption {
+	return func(s *ReplicaSet) {
+		s.lagProbe = probe
+	}
+}
+
+// WithCheckInterval 检查间隔, 小于等于0时不检查, 所有从库始终可用
+func WithCheckInterval(interval time.Duration) ReplicaOption {
+	return func(s *ReplicaSet) {
+		s.checkInterval = interval
+	}
+}
+
+// WithMaxLag 最大复制延迟, 小于等于0时不限制
+func WithMaxLag(maxLag time.Duration) ReplicaOption {
+	return func(s *ReplicaSet) {
+		s.maxLag = maxLag
+	}
+}
+
+// NewReplicaSet 创建从库集合并开始后台检查, 检查之前所有从库都认为可用
+func NewReplicaSet(logger provider.Logger, clients []provider.DbClient, options ...ReplicaOption) *ReplicaSet {
+	s := &ReplicaSet{
+		logger:   logger,
+		replicas: make([]*replica, len(clients)),
+		closing:  make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	for i, client := range clients {
+		s.replicas[i] = &replica{index: i, client: client}
+		s.replicas[i].healthy.Store(true)
+	}
+
+	if s.checkInterval > 0 && len(s.replicas) > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.run()
+		}()
+	}
+
+	return s
+}
+
+// Next 轮询选择可用的从库, 没有可用的从库时返回nil
+func (s *ReplicaSet) Next() provider.DbClient {
+	n := uint64(len(s.replicas))
+	if n == 0 {
+		return nil
+	}
+
+	start := atomic.AddUint64(&s.idx, 1) - 1
+	for i := uint64(0); i < n; i++ {
+		r := s.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r.client
+		}
+	}
+	return nil
+}
+
+// Healthy 第i个从库当前是否可用
+func (s *ReplicaSet) Healthy(i int) bool {
+	if i < 0 || i >= len(s.replicas) {
+		return false
+	}
+	return s.replicas[i].healthy.Load()
+}
+
+// Close 停止后台检查, 不会关闭从库连接
+func (s *ReplicaSet) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	s.wg.Wait()
+}
+
+func (s *ReplicaSet) run() {
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		s.Check()
+
+		select {
+		case <-ticker.C:
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+// Check 检查所有从库并更新可用状态
+func (s *ReplicaSet) Check() {
+	for _, r := range s.replicas {
+		healthy := s.check(r)
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				s.logger.Info("db replica recovered", "index", r.index)
+			} else {
+				s.logger.Warn("db replica ejected, read from other replicas or master", "index", r.index)
+			}
+		}
+	}
+}
+
+func (s *ReplicaSet) check(r *replica) bool {
+	timeout := s.checkInterval
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	db := r.client.Db()
+	if err := db.PingContext(ctx); err != nil {
+		s.logger.Debug("ping db replica", "index", r.index, "err", err)
+		return false
+	}
+
+	if s.lagProbe == nil {
+		return true
+	}
+
+	lag, err := s.lagProbe(ctx, db)
+	if errors.Is(err, ErrLagUnknown) {
+		// 例如没有查询复制状态的权限, 不能因此剔除所有从库, 只检查连通性
+		if !r.lagUnknown.Swap(true) {
+			s.logger.Warn("db replica lag unknown, only check connectivity", "index", r.index, "err", err)
+		}
+		return true
+	}
+	if err != nil {
+		s.logger.Warn("probe db replica lag", "index", r.index, "err", err)
+		return false
+	}
+
+	if s.maxLag > 0 && lag > s.maxLag {
+		s.logger.Debug("db replica lag exceeds limit", "index", r.index, "lag", lag, "max", s.maxLag)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+package dbkit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+func testLagProbe(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	var seconds int64
+	if err := db.QueryRowContext(ctx, "SELECT lag").Scan(&seconds); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+func TestReplicaSet(t *testing.T) {
+	logger := &testLogger{}
+	c1, d1 := newTestClient()
+	c2, d2 := newTestClient()
+	defer func() {
+		_ = c1.Close()
+		_ = c2.Close()
+	}()
+	d1.Stub("SELECT lag", []string{"lag"}, []driver.Value{int64(100)})
+	d2.Stub("SELECT lag", []string{"lag"}, []driver.Value{int64(1)})
+
+	set := NewReplicaSet(logger, []provider.DbClient{c1, c2}, WithLagProbe(testLagProbe), WithMaxLag(10*time.Second))
+	defer set.Close()
+
+	// 检查之前所有从库都可用
+	if !set.Healthy(0) || !set.Healthy(1) {
+		t.Fatal("replicas should be healthy before check")
+	}
+
+	set.Check()
+	if set.Healthy(0) || !set.Healthy(1) {
+		t.Fatalf("healthy = %v %v, want false true", set.Healthy(0), set.Healthy(1))
+	}
+	for i := 0; i < 4; i++ {
+		if set.Next() != c2 {
+			t.Fatal("Next() should skip lagging replica")
+		}
+	}
+	if !logger.Contains("warn", "db replica ejected") {
+		t.Fatal("ejected replica should be logged")
+	}
+
+	// 查询延迟出错记录为warn
+	d2.StubError("SELECT lag", errors.New("access denied"))
+	set.Check()
+	if !logger.Contains("warn", "probe db replica lag") {
+		t.Fatal("probe error should be logged")
+	}
+
+	// 查询延迟失败的从库也被剔除, 没有可用从库时返回nil
+	if set.Next() != nil {
+		t.Fatal("Next() should return nil when no replica is healthy")
+	}
+
+	// 延迟恢复后重新加入
+	d1.Stub("SELECT lag", []string{"lag"}, []driver.Value{int64(0)})
+	set.Check()
+	if set.Next() != c1 || !logger.Contains("info", "db replica recovered") {
+		t.Fatal("recovered replica should be used again")
+	}
+}
+
+// 无法获取复制延迟时不剔除从库, 只记录一次
+func TestReplicaSetLagUnknown(t *testing.T) {
+	logger := &testLogger{}
+	client, d := newTestClient()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	probe := func(context.Context, *sql.DB) (time.Duration, error) {
+		return 0, errors.Wrap(ErrLagUnknown, "access denied")
+	}
+	set := NewReplicaSet(logger, []provider.DbClient{client}, WithLagProbe(probe), WithMaxLag(time.Second))
+	defer set.Close()
+
+	set.Check()
+	set.Check()
+	if !set.Healthy(0) {
+		t.Fatal("replica with unknown lag should be healthy")
+	}
+	if n := logger.Count("warn", "db replica lag unknown"); n != 1 {
+		t.Fatalf("lag unknown logged %d times, want 1", n)
+	}
+
+	// 连接失败时仍然剔除
+	d.SetPingError(errors.New("connection refused"))
+	set.Check()
+	if set.Healthy(0) {
+		t.Fatal("unreachable replica should be ejected")
+	}
+}
+
+// 不指定LagProbe时只检查连通性
+func TestReplicaSetPing(t *testing.T) {
+	c1, d1 := newTestClient()
+	c2, _ := newTestClient()
+	defer func() {
+		_ = c1.Close()
+		_ = c2.Close()
+	}()
+	d1.SetPingError(errors.New("connection refused"))
+
+	set := NewReplicaSet(&testLogger{}, []provider.DbClient{c1, c2}, WithCheckInterval(10*time.Millisecond))
+	deadline := time.Now().Add(time.Second)
+	for set.Healthy(0) {
+		if time.Now().After(deadline) {
+			t.Fatal("replica with ping error should be ejected by background check")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	set.Close()
+
+	if !set.Healthy(1) || set.Next() != c2 {
+		t.Fatal("Next() should return the reachable replica")
+	}
+	if set.Healthy(-1) || set.Healthy(2) {
+		t.Fatal("Healthy() out of range should be false")
+	}
+}
+
+func TestReplicaSetEmpty(t *testing.T) {
+	set := NewReplicaSet(&testLogger{}, nil, WithCheckInterval(time.Millisecond))
+	defer set.Close()
+
+	if set.Next() != nil {
+		t.Fatal("Next() without replicas should return nil")
+	}
+}
+
+func TestUsePrimary(t *testing.T) {
+	testCases := []struct {
+		name string
+		ctx  context.Context
+		want bool
+	}{
+		{name: "default", ctx: context.Background(), want: false},
+		{name: "primary", ctx: WithPrimary(context.Background()), want: true},
+		{name: "transaction", ctx: context.WithValue(context.Background(), provider.TxCtxKey{}, &Tx{}), want: true},
+	}
+
+	for _, tc := range testCases {
+		if got := UsePrimary(tc.ctx); got != tc.want {
+			t.Errorf("%s: UsePrimary() = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
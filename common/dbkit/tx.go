@@ -0,0 +1,119 @@
+package dbkit
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/hdget/sdk/common/provider"
+)
+
+var (
+	_ provider.DbContextExecutor = (*Tx)(nil)
+)
+
+// Tx RunInTransaction放入ctx中的事务, 通过它执行的语句和Client一样会调用钩子
+type Tx struct {
+	*sql.Tx
+	client *Client
+}
+
+type txOptionsCtxKey struct{}
+
+type afterCommitCtxKey struct{}
+
+// afterCommit 事务或者SAVEPOINT中注册的提交后回调
+type afterCommit struct {
+	mutex sync.Mutex
+	fns   []func(ctx context.Context)
+}
+
+// WithTxOptions 指定之后开始的事务的隔离级别和是否只读, 嵌套的SAVEPOINT使用最外层事务的设置
+func WithTxOptions(ctx context.Context, opts *sql.TxOptions) context.Context {
+	return context.WithValue(ctx, txOptionsCtxKey{}, opts)
+}
+
+// AfterCommit 注册最外层事务提交后执行的回调, 例如: 清除缓存, 发布事件
+// 所在的事务或者SAVEPOINT回滚时回调被丢弃, ctx不在RunInTransaction的事务中时立即执行
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if callbacks, ok := ctx.Value(afterCommitCtxKey{}).(*afterCommit); ok {
+		callbacks.add(fn)
+		return
+	}
+	fn(ctx)
+}
+
+// GetTx 获取ctx中RunInTransaction开始的事务, 不在事务中时返回false
+func GetTx(ctx context.Context) (*Tx, bool) {
+	tx, ok := ctx.Value(provider.TxCtxKey{}).(*Tx)
+	return tx, ok
+}
+
+func (t *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.ExecContext(context.Background(), query, args...)
+}
+
+func (t *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.QueryContext(context.Background(), query, args...)
+}
+
+func (t *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return t.QueryRowContext(context.Background(), query, args...)
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, event, after := t.client.before(ctx, provider.DbOpExec, query, args)
+	result, err := t.Tx.ExecContext(ctx, event.Query, args...)
+	after(err)
+	return result, err
+}
+
+// QueryContext 和Client.QueryContext一样, 钩子的After在返回rows时调用, 不包含读取rows的时间
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, event, after := t.client.before(ctx, provider.DbOpQuery, query, args)
+	rows, err := t.Tx.QueryContext(ctx, event.Query, args...)
+	after(err)
+	return rows, err
+}
+
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, event, after := t.client.before(ctx, provider.DbOpQueryRow, query, args)
+	row := t.Tx.QueryRowContext(ctx, event.Query, args...)
+	after(row.Err())
+	return row
+}
+
+func getTxOptions(ctx context.Context) *sql.TxOptions {
+	opts, _ := ctx.Value(txOptionsCtxKey{}).(*sql.TxOptions)
+	return opts
+}
+
+// withAfterCommit 返回的ctx中注册的回调保存到callbacks中
+func withAfterCommit(ctx context.Context) (context.Context, *afterCommit) {
+	callbacks := &afterCommit{}
+	return context.WithValue(ctx, afterCommitCtxKey{}, callbacks), callbacks
+}
+
+func (a *afterCommit) add(fns ...func(ctx context.Context)) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.fns = append(a.fns, fns...)
+}
+
+// merge SAVEPOINT释放后, 其中注册的回调转移到上一层
+func (a *afterCommit) merge(child *afterCommit) {
+	child.mutex.Lock()
+	fns := child.fns
+	child.mutex.Unlock()
+	a.add(fns...)
+}
+
+// run 按注册顺序执行回调
+func (a *afterCommit) run(ctx context.Context) {
+	a.mutex.Lock()
+	fns := a.fns
+	a.mutex.Unlock()
+	for _, fn := range fns {
+		fn(ctx)
+	}
+}
@@ -0,0 +1,139 @@
+package dbkit
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// 事务中通过ctx中的Tx执行的语句同样调用钩子
+func TestTxHooks(t *testing.T) {
+	hook := &recordHook{}
+	client, d := newTestClient(hook)
+
+	err := client.RunInTransaction(context.Background(), func(ctx context.Context) error {
+		tx, ok := ctx.Value(provider.TxCtxKey{}).(provider.DbContextExecutor)
+		if !ok {
+			t.Fatal("transaction executor not found in ctx")
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE t SET a = 1"); err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx, "SELECT a FROM t")
+		if err != nil {
+			return err
+		}
+		_ = rows.Close()
+
+		return client.RunInTransaction(ctx, func(ctx context.Context) error {
+			_, err := tx.Exec("DELETE FROM t")
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"begin ", "exec UPDATE t SET a = 1", "query SELECT a FROM t", "exec DELETE FROM t", "commit "}
+	var got []string
+	for _, op := range hook.before {
+		if !strings.Contains(op, "SAVEPOINT") {
+			got = append(got, op)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("hook before = %v, want %v", got, want)
+	}
+	if len(hook.after) != len(hook.before) {
+		t.Fatalf("hook after called %d times, before %d times", len(hook.after), len(hook.before))
+	}
+
+	statements := d.Statements()
+	if len(statements) != 7 || statements[0] != "BEGIN" || statements[len(statements)-1] != "COMMIT" {
+		t.Fatalf("statements = %v", statements)
+	}
+}
+
+// 嵌套事务出错时只回滚到SAVEPOINT, 最外层事务出错时回滚整个事务
+func TestRunInTransactionSavepoint(t *testing.T) {
+	client, d := newTestClient()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	err := client.RunInTransaction(context.Background(), func(ctx context.Context) error {
+		inner := client.RunInTransaction(ctx, func(ctx context.Context) error {
+			return errors.New("inner")
+		})
+		if inner == nil || inner.Error() != "inner" {
+			t.Fatalf("inner transaction err = %v, want inner", inner)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements := d.Statements()
+	if len(statements) != 4 || !strings.HasPrefix(statements[1], "SAVEPOINT ") ||
+		statements[2] != "ROLLBACK TO "+statements[1] || statements[3] != "COMMIT" {
+		t.Fatalf("statements = %v", statements)
+	}
+
+	client, d = newTestClient()
+	defer func() {
+		_ = client.Close()
+	}()
+	err = client.RunInTransaction(context.Background(), func(ctx context.Context) error {
+		return errors.New("outer")
+	})
+	if err == nil || !reflect.DeepEqual(d.Statements(), []string{"BEGIN", "ROLLBACK"}) {
+		t.Fatalf("RunInTransaction() = %v, statements = %v", err, d.Statements())
+	}
+}
+
+// 提交后的回调在最外层事务提交后按注册顺序执行, 回滚的SAVEPOINT中注册的回调被丢弃
+func TestAfterCommit(t *testing.T) {
+	client, _ := newTestClient()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	var called []string
+	register := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			AfterCommit(ctx, func(context.Context) { called = append(called, name) })
+			if name == "rollback" {
+				return errors.New(name)
+			}
+			return nil
+		}
+	}
+
+	err := client.RunInTransaction(context.Background(), func(ctx context.Context) error {
+		_ = register("outer")(ctx)
+		_ = client.RunInTransaction(ctx, register("rollback"))
+		if len(called) > 0 {
+			t.Fatal("callbacks should run after commit")
+		}
+		return client.RunInTransaction(ctx, register("nested"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(called, ",") != "outer,nested" {
+		t.Fatalf("unexpected callbacks: %v", called)
+	}
+
+	// 事务回滚时丢弃回调, 不在事务中时立即执行
+	called = nil
+	_ = client.RunInTransaction(context.Background(), register("rollback"))
+	_ = register("direct")(context.Background())
+	if strings.Join(called, ",") != "direct" {
+		t.Fatalf("unexpected callbacks: %v", called)
+	}
+}
@@ -3,6 +3,8 @@ module github.com/hdget/sdk/common
 go 1.24.0
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
+	github.com/google/uuid v1.6.0
 	github.com/pkg/errors v0.9.1
 	go.uber.org/fx v1.24.0
 	golang.org/x/exp v0.0.0-20251219203646-944ab1f22d93
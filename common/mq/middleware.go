@@ -0,0 +1,154 @@
+package mq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// MetricsRecorder 记录消息处理的结果和耗时, 例如上报到prometheus
+type MetricsRecorder interface {
+	ObserveMessage(topic string, duration time.Duration, err error)
+}
+
+// DedupStore 记录已经处理成功的消息
+type DedupStore interface {
+	// IsProcessed 消息是否已经处理成功
+	IsProcessed(ctx context.Context, key string) (bool, error)
+	// MarkProcessed 标记消息已经处理成功
+	MarkProcessed(ctx context.Context, key string) error
+}
+
+// memoryDedupStore 进程内的DedupStore, 只适合单实例
+type memoryDedupStore struct {
+	mutex     sync.Mutex
+	ttl       time.Duration
+	processed map[string]time.Time // key => 过期时间
+}
+
+// redisDedupStore 基于redis的DedupStore, 多个实例共享
+type redisDedupStore struct {
+	client provider.RedisClient
+	prefix string
+	ttl    int
+}
+
+const (
+	defaultDedupKeyPrefix = "mq:dedup:"
+)
+
+// Logging 记录处理失败的消息, debug级别下同时记录处理成功的消息
+func Logging(logger provider.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *provider.Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			if err != nil {
+				logger.Error("handle message", "topic", GetTopic(ctx), "uuid", msg.UUID, "cost", time.Since(start), "err", err)
+				return err
+			}
+			logger.Debug("handle message", "topic", GetTopic(ctx), "uuid", msg.UUID, "cost", time.Since(start))
+			return nil
+		}
+	}
+}
+
+// Metrics 记录每条消息的处理结果和耗时
+func Metrics(recorder MetricsRecorder) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *provider.Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+			recorder.ObserveMessage(GetTopic(ctx), time.Since(start), err)
+			return err
+		}
+	}
+}
+
+// Timeout 限制处理函数的执行时间, 处理函数需要响应ctx的取消
+func Timeout(timeout time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *provider.Message) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next(ctx, msg)
+		}
+	}
+}
+
+// Dedup 按消息UUID去重, 已经处理成功的消息直接确认
+// 只有处理成功后才标记, 同一条消息并发投递时仍然可能被重复处理
+func Dedup(store DedupStore) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, msg *provider.Message) error {
+			key := GetTopic(ctx) + ":" + msg.UUID
+			processed, err := store.IsProcessed(ctx, key)
+			if err != nil {
+				return errors.Wrapf(err, "check message processed, uuid: %s", msg.UUID)
+			}
+			if processed {
+				return nil
+			}
+
+			if err = next(ctx, msg); err != nil {
+				return err
+			}
+
+			if err = store.MarkProcessed(ctx, key); err != nil {
+				return errors.Wrapf(err, "mark message processed, uuid: %s", msg.UUID)
+			}
+			return nil
+		}
+	}
+}
+
+// NewMemoryDedupStore 进程内的DedupStore, 标记保留ttl时间
+func NewMemoryDedupStore(ttl time.Duration) DedupStore {
+	return &memoryDedupStore{ttl: ttl, processed: make(map[string]time.Time)}
+}
+
+func (s *memoryDedupStore) IsProcessed(_ context.Context, key string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	expiredAt, exists := s.processed[key]
+	if !exists {
+		return false, nil
+	}
+	if time.Now().After(expiredAt) {
+		delete(s.processed, key)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (s *memoryDedupStore) MarkProcessed(_ context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	// 标记时顺便清理过期的key
+	for k, expiredAt := range s.processed {
+		if now.After(expiredAt) {
+			delete(s.processed, k)
+		}
+	}
+	s.processed[key] = now.Add(s.ttl)
+	return nil
+}
+
+// NewRedisDedupStore 基于redis的DedupStore, 标记保留ttl时间, 不足1秒按1秒计算
+func NewRedisDedupStore(client provider.RedisClient, ttl time.Duration) DedupStore {
+	return &redisDedupStore{client: client, prefix: defaultDedupKeyPrefix, ttl: max(int(ttl/time.Second), 1)}
+}
+
+func (s *redisDedupStore) IsProcessed(ctx context.Context, key string) (bool, error) {
+	return s.client.WithContext(ctx).Exists(s.prefix + key)
+}
+
+func (s *redisDedupStore) MarkProcessed(ctx context.Context, key string) error {
+	return s.client.WithContext(ctx).SetEx(s.prefix+key, 1, s.ttl)
+}
@@ -0,0 +1,183 @@
+package mq
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+type testRecorder struct {
+	topic string
+	err   error
+}
+
+// testDedupStore 查询或者标记时返回err
+type testDedupStore struct {
+	DedupStore
+	err error
+}
+
+func (r *testRecorder) ObserveMessage(topic string, _ time.Duration, err error) {
+	r.topic, r.err = topic, err
+}
+
+func (s *testDedupStore) IsProcessed(context.Context, string) (bool, error) {
+	return false, s.err
+}
+
+func newTestContext(topic string) context.Context {
+	return context.WithValue(context.Background(), topicCtxKey{}, topic)
+}
+
+// Router的中间件先于处理函数的中间件执行, 先注册的在外层
+func TestMiddlewareOrder(t *testing.T) {
+	var called []string
+	middleware := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, msg *provider.Message) error {
+				called = append(called, name)
+				return next(ctx, msg)
+			}
+		}
+	}
+
+	router := NewRouter(&testLogger{})
+	router.Use(middleware("a"), middleware("b"))
+	router.AddHandler(newTestSubscriber(), "topic", func(context.Context, *provider.Message) error {
+		called = append(called, "handler")
+		panic("boom")
+	}, WithHandlerMiddleware(middleware("c")))
+
+	fn := router.chain(router.handlers[0], router.middlewares)
+	err := fn(newTestContext("topic"), provider.NewMessage(nil))
+
+	var p *panicError
+	if !errors.As(err, &p) || p.value != "boom" {
+		t.Fatalf("handler panic err = %v, want panicError", err)
+	}
+	if strings.Join(called, ",") != "a,b,c,handler" {
+		t.Fatalf("called = %v, want a,b,c,handler", called)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	var handled int
+	failed := true
+	fn := Dedup(NewMemoryDedupStore(time.Minute))(func(context.Context, *provider.Message) error {
+		handled++
+		if failed {
+			return errors.New("fail")
+		}
+		return nil
+	})
+
+	msg := provider.NewMessage(nil)
+	ctx := newTestContext("topic")
+
+	// 处理失败时不标记, 重新投递后再次处理
+	if err := fn(ctx, msg); err == nil {
+		t.Fatal("Dedup() should return handler error")
+	}
+	failed = false
+	for i := 0; i < 3; i++ {
+		if err := fn(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if handled != 2 {
+		t.Fatalf("handled = %d, want 2", handled)
+	}
+
+	// 不同topic的相同消息分别去重
+	if err := fn(newTestContext("other"), msg); err != nil || handled != 3 {
+		t.Fatalf("handled = %d, %v, want 3", handled, err)
+	}
+}
+
+func TestDedupStoreError(t *testing.T) {
+	fn := Dedup(&testDedupStore{err: errors.New("connection refused")})(func(context.Context, *provider.Message) error {
+		t.Fatal("handler should not be called")
+		return nil
+	})
+
+	if err := fn(newTestContext("topic"), provider.NewMessage(nil)); err == nil {
+		t.Fatal("Dedup() with store error, want error")
+	}
+}
+
+func TestMemoryDedupStore(t *testing.T) {
+	store := NewMemoryDedupStore(20 * time.Millisecond)
+	ctx := context.Background()
+
+	if err := store.MarkProcessed(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if processed, _ := store.IsProcessed(ctx, "a"); !processed {
+		t.Fatal("IsProcessed() = false, want true")
+	}
+	if processed, _ := store.IsProcessed(ctx, "b"); processed {
+		t.Fatal("IsProcessed() = true for unknown key")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if processed, _ := store.IsProcessed(ctx, "a"); processed {
+		t.Fatal("IsProcessed() = true after ttl")
+	}
+
+	// 标记时清理过期的key
+	_ = store.MarkProcessed(ctx, "b")
+	time.Sleep(30 * time.Millisecond)
+	_ = store.MarkProcessed(ctx, "c")
+	if n := len(store.(*memoryDedupStore).processed); n != 1 {
+		t.Fatalf("processed keys = %d, want 1", n)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	recorder := &testRecorder{}
+	fn := Metrics(recorder)(func(context.Context, *provider.Message) error {
+		return errors.New("fail")
+	})
+
+	if err := fn(newTestContext("topic"), provider.NewMessage(nil)); err == nil {
+		t.Fatal("Metrics() should return handler error")
+	}
+	if recorder.topic != "topic" || recorder.err == nil {
+		t.Fatalf("recorded = %s, %v, want topic and error", recorder.topic, recorder.err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	fn := Timeout(20 * time.Millisecond)(func(ctx context.Context, _ *provider.Message) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	if err := fn(newTestContext("topic"), provider.NewMessage(nil)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Timeout() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	logger := &testLogger{}
+	fn := Logging(logger)(func(_ context.Context, msg *provider.Message) error {
+		if string(msg.Payload) == "fail" {
+			return errors.New("fail")
+		}
+		return nil
+	})
+
+	_ = fn(newTestContext("topic"), provider.NewMessage([]byte("ok")))
+	if !logger.Contains("debug", "handle message") || logger.Contains("error", "handle message") {
+		t.Fatal("handled message should be logged at debug level")
+	}
+
+	_ = fn(newTestContext("topic"), provider.NewMessage([]byte("fail")))
+	if !logger.Contains("error", "handle message") {
+		t.Fatal("failed message should be logged at error level")
+	}
+}
@@ -0,0 +1,321 @@
+// Package mqtest MessageQueue实现的一致性测试, 每个实现都需要通过这些测试以保证语义一致:
+//   - 相同name的订阅者竞争消费, 每条消息只被其中一个订阅者收到
+//   - 不同name的订阅者都会收到所有消息
+//   - Nack的消息会重新投递
+//   - 延迟消息到期后才投递
+//
+// Subscribe返回时订阅必须已经生效, 之后发布的消息不能丢失
+package mqtest
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+)
+
+// Features 实现支持的可选特性
+type Features struct {
+	DelayMessage bool          // 支持延迟消息
+	DeadLetter   bool          // 实现了provider.MessageQueueDeadLetter
+	Timeout      time.Duration // 等待消息的最长时间, 缺省为10秒
+}
+
+// NewMessageQueue 为每个测试创建新的消息队列
+type NewMessageQueue func(t *testing.T) provider.MessageQueue
+
+const (
+	defaultTimeout = 10 * time.Second
+	// 确认没有多余消息时的等待时间
+	quietPeriod = 500 * time.Millisecond
+)
+
+var (
+	invalidTopicChars = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// Run 运行所有一致性测试
+func Run(t *testing.T, newMq NewMessageQueue, features Features) {
+	if features.Timeout <= 0 {
+		features.Timeout = defaultTimeout
+	}
+
+	t.Run("PublishSubscribe", func(t *testing.T) { testPublishSubscribe(t, newMq(t), features) })
+	t.Run("CompetingConsumers", func(t *testing.T) { testCompetingConsumers(t, newMq(t), features) })
+	t.Run("Broadcast", func(t *testing.T) { testBroadcast(t, newMq(t), features) })
+	t.Run("NackRedelivery", func(t *testing.T) { testNackRedelivery(t, newMq(t), features) })
+	t.Run("ContextCancel", func(t *testing.T) { testContextCancel(t, newMq(t), features) })
+	if features.DelayMessage {
+		t.Run("DelayMessage", func(t *testing.T) { testDelayMessage(t, newMq(t), features) })
+	}
+	if features.DeadLetter {
+		t.Run("DeadLetter", func(t *testing.T) { testDeadLetter(t, newMq(t), features) })
+	}
+}
+
+func testPublishSubscribe(t *testing.T, mq provider.MessageQueue, features Features) {
+	topic := newTopic(t)
+	messages := subscribe(t, mq, "test", topic, nil)
+	publisher := newPublisher(t, mq, "test", nil)
+
+	out := provider.NewMessage([]byte("hello"))
+	out.Metadata.Set(provider.MetadataKeyCorrelationID, "cid")
+	out.Metadata.Set("key", "value")
+	if err := publisher.PublishMessage(topic, []*provider.Message{out}); err != nil {
+		t.Fatalf("PublishMessage() error: %v", err)
+	}
+	if err := publisher.Publish(topic, [][]byte{[]byte("world")}); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+
+	msg := receive(t, messages, features)
+	if msg.UUID != out.UUID || string(msg.Payload) != "hello" {
+		t.Fatalf("received message = %s %s, want %s hello", msg.UUID, msg.Payload, out.UUID)
+	}
+	if msg.Metadata.Get(provider.MetadataKeyCorrelationID) != "cid" || msg.Metadata.Get("key") != "value" {
+		t.Fatalf("metadata not delivered: %v", msg.Metadata)
+	}
+	msg.Ack()
+
+	msg = receive(t, messages, features)
+	if string(msg.Payload) != "world" || msg.UUID == "" {
+		t.Fatalf("received message = %s %s, want world with uuid", msg.UUID, msg.Payload)
+	}
+	msg.Ack()
+}
+
+func testCompetingConsumers(t *testing.T, mq provider.MessageQueue, features Features) {
+	topic := newTopic(t)
+	messages1 := subscribe(t, mq, "group", topic, nil)
+	messages2 := subscribe(t, mq, "group", topic, nil)
+	publisher := newPublisher(t, mq, "test", nil)
+
+	const total = 20
+	payloads := make([][]byte, total)
+	for i := range payloads {
+		payloads[i] = []byte(fmt.Sprintf("message-%d", i))
+	}
+	if err := publisher.Publish(topic, payloads); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+
+	received := make(map[string]int)
+	deadline := time.After(features.Timeout)
+	for len(received) < total {
+		select {
+		case msg := <-messages1:
+			received[string(msg.Payload)]++
+			msg.Ack()
+		case msg := <-messages2:
+			received[string(msg.Payload)]++
+			msg.Ack()
+		case <-deadline:
+			t.Fatalf("received %d messages, want %d", len(received), total)
+		}
+	}
+
+	select {
+	case msg := <-messages1:
+		t.Fatalf("unexpected message: %s", msg.Payload)
+	case msg := <-messages2:
+		t.Fatalf("unexpected message: %s", msg.Payload)
+	case <-time.After(quietPeriod):
+	}
+
+	for payload, count := range received {
+		if count != 1 {
+			t.Fatalf("message %s received %d times, want 1", payload, count)
+		}
+	}
+}
+
+func testBroadcast(t *testing.T, mq provider.MessageQueue, features Features) {
+	topic := newTopic(t)
+	messages1 := subscribe(t, mq, "first", topic, nil)
+	messages2 := subscribe(t, mq, "second", topic, nil)
+	publisher := newPublisher(t, mq, "test", nil)
+
+	if err := publisher.Publish(topic, [][]byte{[]byte("hello")}); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+
+	for _, messages := range []<-chan *provider.Message{messages1, messages2} {
+		msg := receive(t, messages, features)
+		if string(msg.Payload) != "hello" {
+			t.Fatalf("payload = %s, want hello", msg.Payload)
+		}
+		msg.Ack()
+	}
+}
+
+func testNackRedelivery(t *testing.T, mq provider.MessageQueue, features Features) {
+	topic := newTopic(t)
+	messages := subscribe(t, mq, "test", topic, nil)
+	publisher := newPublisher(t, mq, "test", nil)
+
+	if err := publisher.Publish(topic, [][]byte{[]byte("hello")}); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+
+	first := receive(t, messages, features)
+	first.Nack()
+
+	msg := receive(t, messages, features)
+	if msg.UUID != first.UUID || string(msg.Payload) != "hello" {
+		t.Fatalf("redelivered message = %s %s, want %s hello", msg.UUID, msg.Payload, first.UUID)
+	}
+	msg.Ack()
+}
+
+func testContextCancel(t *testing.T, mq provider.MessageQueue, features Features) {
+	subscriber, err := mq.NewSubscriber("test")
+	if err != nil {
+		t.Fatalf("NewSubscriber() error: %v", err)
+	}
+	t.Cleanup(func() { _ = subscriber.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages, err := subscriber.Subscribe(ctx, newTopic(t))
+	if err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+	cancel()
+
+	deadline := time.After(features.Timeout)
+	for {
+		select {
+		case _, ok := <-messages:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("message channel not closed after ctx canceled")
+		}
+	}
+}
+
+func testDelayMessage(t *testing.T, mq provider.MessageQueue, features Features) {
+	topic := newTopic(t)
+	messages := subscribe(t, mq, "test", topic, &provider.SubscriberOption{SubscribeDelayMessage: true})
+	publisher := newPublisher(t, mq, "test", &provider.PublisherOption{PublishDelayMessage: true})
+
+	start := time.Now()
+	if err := publisher.Publish(topic, [][]byte{[]byte("delayed")}, 1); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+
+	msg := receive(t, messages, features)
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Fatalf("delay message received after %v, want at least 1s", elapsed)
+	}
+	if string(msg.Payload) != "delayed" {
+		t.Fatalf("payload = %s, want delayed", msg.Payload)
+	}
+	msg.Ack()
+}
+
+func testDeadLetter(t *testing.T, mq provider.MessageQueue, features Features) {
+	dlq, ok := mq.(provider.MessageQueueDeadLetter)
+	if !ok {
+		t.Fatal("message queue does not implement provider.MessageQueueDeadLetter")
+	}
+
+	topic := newTopic(t)
+	policy := &provider.RetryPolicy{MaxAttempts: 2}
+	messages := subscribe(t, mq, "test", topic, &provider.SubscriberOption{RetryPolicy: policy})
+	publisher := newPublisher(t, mq, "test", nil)
+
+	if err := publisher.Publish(topic, [][]byte{[]byte("hello")}); err != nil {
+		t.Fatalf("Publish() error: %v", err)
+	}
+
+	for i := 0; i < policy.MaxAttempts; i++ {
+		receive(t, messages, features).Nack()
+	}
+
+	var deadLetters []*provider.Message
+	deadline := time.Now().Add(features.Timeout)
+	for len(deadLetters) == 0 && time.Now().Before(deadline) {
+		var err error
+		if deadLetters, err = dlq.ListDeadLetters(topic, "test", 0); err != nil {
+			t.Fatalf("ListDeadLetters() error: %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(deadLetters) != 1 || string(deadLetters[0].Payload) != "hello" {
+		t.Fatalf("ListDeadLetters() = %v, want 1 message", deadLetters)
+	}
+
+	count, err := dlq.RepublishDeadLetters(topic, "test", 0)
+	if err != nil || count != 1 {
+		t.Fatalf("RepublishDeadLetters() = %d, %v, want 1", count, err)
+	}
+
+	msg := receive(t, messages, features)
+	if string(msg.Payload) != "hello" {
+		t.Fatalf("republished payload = %s, want hello", msg.Payload)
+	}
+	msg.Ack()
+}
+
+// newTopic 每个测试使用不同的topic, 避免共享broker时互相影响
+func newTopic(t *testing.T) string {
+	name := strings.Trim(invalidTopicChars.ReplaceAllString(strings.ToLower(t.Name()), "-"), "-")
+	return fmt.Sprintf("mqtest-%s-%s", name, provider.NewUUID()[:8])
+}
+
+func subscribe(t *testing.T, mq provider.MessageQueue, name, topic string, option *provider.SubscriberOption) <-chan *provider.Message {
+	var args []*provider.SubscriberOption
+	if option != nil {
+		args = append(args, option)
+	}
+
+	subscriber, err := mq.NewSubscriber(name, args...)
+	if err != nil {
+		t.Fatalf("NewSubscriber() error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		cancel()
+		_ = subscriber.Close()
+	})
+
+	messages, err := subscriber.Subscribe(ctx, topic)
+	if err != nil {
+		t.Fatalf("Subscribe() error: %v", err)
+	}
+	return messages
+}
+
+func newPublisher(t *testing.T, mq provider.MessageQueue, name string, option *provider.PublisherOption) provider.MessageQueuePublisher {
+	var args []*provider.PublisherOption
+	if option != nil {
+		args = append(args, option)
+	}
+
+	publisher, err := mq.NewPublisher(name, args...)
+	if err != nil {
+		t.Fatalf("NewPublisher() error: %v", err)
+	}
+	t.Cleanup(func() { _ = publisher.Close() })
+	return publisher
+}
+
+func receive(t *testing.T, messages <-chan *provider.Message, features Features) *provider.Message {
+	t.Helper()
+	select {
+	case msg, ok := <-messages:
+		if !ok {
+			t.Fatal("message channel closed")
+		}
+		return msg
+	case <-time.After(features.Timeout):
+		t.Fatal("message not received")
+	}
+	return nil
+}
@@ -0,0 +1,502 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// Outbox 事务发件箱
+// 在业务事务中将待发送的消息写入发件箱表, 事务提交后再由Relay转发到消息队列,
+// 保证数据库变更和消息要么都成功要么都失败。转发是至少一次语义, 消费者需要按消息UUID去重
+// 发布失败的消息按指数退避重试, 达到最大次数或者无法解析的消息被搁置(parked_at不为0), 不再阻塞之后的消息,
+// 处理后将parked_at和attempts设置为0可以重新转发
+type Outbox struct {
+	logger       provider.Logger
+	db           provider.DbClient
+	publisher    provider.MessageQueuePublisher
+	table        string
+	dialect      OutboxDialect
+	batchSize    int
+	pollInterval time.Duration
+	maxAttempts  int
+	claimTimeout time.Duration
+	notify       chan struct{}
+}
+
+type OutboxDialect string
+
+const (
+	OutboxDialectMysql    OutboxDialect = "mysql"
+	OutboxDialectPostgres OutboxDialect = "postgres"
+	OutboxDialectSqlite   OutboxDialect = "sqlite3"
+)
+
+type OutboxOption func(*Outbox)
+
+const (
+	defaultOutboxTable        = "mq_outbox"
+	defaultOutboxBatchSize    = 100
+	defaultOutboxPollInterval = 5 * time.Second
+	defaultOutboxMaxAttempts  = 10
+	defaultOutboxClaimTimeout = time.Minute
+	// 发布失败后重试的最长间隔
+	maxOutboxRetryInterval = time.Hour
+)
+
+type outboxRecord struct {
+	id           int64
+	topic        string
+	delaySeconds int64
+	attempts     int
+	message      *provider.Message
+}
+
+// outboxFailure 发布失败的记录
+type outboxFailure struct {
+	record *outboxRecord
+	err    error
+}
+
+// WithOutboxTable 发件箱表名, 缺省为mq_outbox
+func WithOutboxTable(table string) OutboxOption {
+	return func(o *Outbox) {
+		o.table = table
+	}
+}
+
+// WithOutboxDialect 数据库类型, 缺省为mysql
+func WithOutboxDialect(dialect OutboxDialect) OutboxOption {
+	return func(o *Outbox) {
+		o.dialect = dialect
+	}
+}
+
+// WithOutboxBatchSize 每次转发的最大消息数量
+func WithOutboxBatchSize(batchSize int) OutboxOption {
+	return func(o *Outbox) {
+		if batchSize > 0 {
+			o.batchSize = batchSize
+		}
+	}
+}
+
+// WithOutboxPollInterval Run轮询发件箱的间隔, 事务提交后会立即触发一次转发
+func WithOutboxPollInterval(interval time.Duration) OutboxOption {
+	return func(o *Outbox) {
+		if interval > 0 {
+			o.pollInterval = interval
+		}
+	}
+}
+
+// WithOutboxMaxAttempts 消息发布失败达到该次数后被搁置, 缺省为10
+func WithOutboxMaxAttempts(maxAttempts int) OutboxOption {
+	return func(o *Outbox) {
+		if maxAttempts > 0 {
+			o.maxAttempts = maxAttempts
+		}
+	}
+}
+
+// WithOutboxClaimTimeout 转发时占用消息的最长时间, 缺省为1分钟
+// 转发的实例在这段时间内没有完成时, 例如: 进程退出, 消息可以被其他实例重新转发
+func WithOutboxClaimTimeout(timeout time.Duration) OutboxOption {
+	return func(o *Outbox) {
+		if timeout > 0 {
+			o.claimTimeout = timeout
+		}
+	}
+}
+
+func NewOutbox(logger provider.Logger, db provider.DbClient, publisher provider.MessageQueuePublisher, options ...OutboxOption) *Outbox {
+	o := &Outbox{
+		logger:       logger,
+		db:           db,
+		publisher:    publisher,
+		table:        defaultOutboxTable,
+		dialect:      OutboxDialectMysql,
+		batchSize:    defaultOutboxBatchSize,
+		pollInterval: defaultOutboxPollInterval,
+		maxAttempts:  defaultOutboxMaxAttempts,
+		claimTimeout: defaultOutboxClaimTimeout,
+		notify:       make(chan struct{}, 1),
+	}
+
+	for _, option := range options {
+		option(o)
+	}
+
+	return o
+}
+
+// CreateTable 创建发件箱表
+func (o *Outbox) CreateTable(ctx context.Context) error {
+	var ddl string
+	switch o.dialect {
+	case OutboxDialectPostgres:
+		ddl = `CREATE TABLE IF NOT EXISTS %s (
+	id BIGSERIAL PRIMARY KEY,
+	uuid VARCHAR(64) NOT NULL,
+	topic VARCHAR(255) NOT NULL,
+	payload BYTEA NOT NULL,
+	metadata TEXT NOT NULL,
+	delay_seconds BIGINT NOT NULL DEFAULT 0,
+	created_at BIGINT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at BIGINT NOT NULL DEFAULT 0,
+	parked_at BIGINT NOT NULL DEFAULT 0,
+	last_error TEXT
+)`
+	case OutboxDialectSqlite:
+		ddl = `CREATE TABLE IF NOT EXISTS %s (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	uuid TEXT NOT NULL,
+	topic TEXT NOT NULL,
+	payload BLOB NOT NULL,
+	metadata TEXT NOT NULL,
+	delay_seconds INTEGER NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at INTEGER NOT NULL DEFAULT 0,
+	parked_at INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT
+)`
+	default:
+		ddl = `CREATE TABLE IF NOT EXISTS %s (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	uuid VARCHAR(64) NOT NULL,
+	topic VARCHAR(255) NOT NULL,
+	payload LONGBLOB NOT NULL,
+	metadata TEXT NOT NULL,
+	delay_seconds BIGINT NOT NULL DEFAULT 0,
+	created_at BIGINT NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at BIGINT NOT NULL DEFAULT 0,
+	parked_at BIGINT NOT NULL DEFAULT 0,
+	last_error TEXT
+)`
+	}
+
+	_, err := o.db.ExecContext(ctx, fmt.Sprintf(ddl, o.table))
+	if err != nil {
+		return errors.Wrapf(err, "create outbox table, table: %s", o.table)
+	}
+	return nil
+}
+
+// Add 将消息写入发件箱, 必须在RunInTransaction的事务中调用
+func (o *Outbox) Add(ctx context.Context, topic string, messages []*provider.Message, delaySeconds ...int64) error {
+	tx, ok := ctx.Value(provider.TxCtxKey{}).(provider.DbContextExecutor)
+	if !ok {
+		return errors.New("outbox add must be called in transaction")
+	}
+
+	var delay int64
+	if len(delaySeconds) > 0 {
+		delay = delaySeconds[0]
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (uuid, topic, payload, metadata, delay_seconds, created_at) VALUES (%s)", o.table, o.placeholders(1, 6))
+	for _, msg := range messages {
+		if msg.UUID == "" {
+			msg.UUID = provider.NewUUID()
+		}
+
+		metadata, err := json.Marshal(msg.Metadata)
+		if err != nil {
+			return errors.Wrapf(err, "marshal outbox message metadata, uuid: %s", msg.UUID)
+		}
+
+		_, err = tx.ExecContext(ctx, query, msg.UUID, topic, msg.Payload, string(metadata), delay, time.Now().UnixMilli())
+		if err != nil {
+			return errors.Wrapf(err, "insert outbox message, uuid: %s", msg.UUID)
+		}
+	}
+
+	return nil
+}
+
+// RunInTransaction 在事务中执行fn, 最外层事务提交后通知Run立即转发消息
+func (o *Outbox) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	nested := ctx.Value(provider.TxCtxKey{}) != nil
+
+	err := o.db.RunInTransaction(ctx, fn)
+	if err != nil {
+		return err
+	}
+
+	if !nested {
+		o.Notify()
+	}
+	return nil
+}
+
+// Notify 通知Run立即转发消息
+func (o *Outbox) Notify() {
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Relay 转发一批消息, 发布成功的消息会从发件箱删除, 返回转发成功的消息数量
+// 先在短事务中占用一批消息, 发布时不持有行锁, 发布失败的消息等待重试, 不影响其他消息的发布
+func (o *Outbox) Relay(ctx context.Context) (int, error) {
+	records, err := o.claim(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "relay outbox messages")
+	}
+
+	published, failures := o.publish(records)
+	if len(published) > 0 {
+		if err = o.delete(ctx, published); err != nil {
+			return 0, errors.Wrap(err, "relay outbox messages")
+		}
+	}
+
+	for _, f := range failures {
+		if err = o.retryLater(ctx, f.record, f.err); err != nil {
+			return len(published), errors.Wrap(err, "relay outbox messages")
+		}
+	}
+
+	if len(failures) > 0 {
+		return len(published), errors.Wrap(failures[0].err, "publish outbox messages")
+	}
+	return len(published), nil
+}
+
+// Run 循环转发发件箱中的消息直到ctx取消
+func (o *Outbox) Run(ctx context.Context) error {
+	ticker := time.NewTicker(o.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := o.Relay(ctx)
+			if err != nil {
+				o.logger.Error("outbox relay", "err", err)
+				break
+			}
+			if n < o.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-o.notify:
+		}
+	}
+}
+
+// claim 在事务中取出一批可以转发的消息并占用claimTimeout, 无法解析的消息直接搁置
+func (o *Outbox) claim(ctx context.Context) ([]*outboxRecord, error) {
+	var records []*outboxRecord
+	err := o.db.RunInTransaction(ctx, func(ctx context.Context) error {
+		tx, ok := ctx.Value(provider.TxCtxKey{}).(provider.DbContextExecutor)
+		if !ok {
+			return errors.New("outbox claim requires transaction")
+		}
+
+		now := time.Now()
+		fetched, parked, err := o.fetch(ctx, tx, now)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range parked {
+			if err = o.park(ctx, tx, f.record, f.err, now); err != nil {
+				return err
+			}
+		}
+
+		if len(fetched) == 0 {
+			return nil
+		}
+
+		args := []any{now.Add(o.claimTimeout).UnixMilli()}
+		for _, r := range fetched {
+			args = append(args, r.id)
+		}
+		query := fmt.Sprintf("UPDATE %s SET next_attempt_at = %s WHERE id IN (%s)", o.table, o.placeholders(1, 1), o.placeholders(2, len(fetched)))
+		if _, err = tx.ExecContext(ctx, query, args...); err != nil {
+			return errors.Wrap(err, "claim outbox messages")
+		}
+		records = fetched
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// fetch 查询没有被搁置并且到了重试时间的消息, 返回可以发布的记录和需要搁置的记录
+func (o *Outbox) fetch(ctx context.Context, tx provider.DbContextExecutor, now time.Time) ([]*outboxRecord, []*outboxFailure, error) {
+	query := fmt.Sprintf("SELECT id, uuid, topic, payload, metadata, delay_seconds, attempts FROM %s WHERE parked_at = 0 AND next_attempt_at <= %s ORDER BY id LIMIT %d",
+		o.table, o.placeholders(1, 1), o.batchSize)
+	// 多个实例同时转发时跳过已被锁定的消息
+	if o.dialect != OutboxDialectSqlite {
+		query += " FOR UPDATE SKIP LOCKED"
+	}
+
+	rows, err := tx.QueryContext(ctx, query, now.UnixMilli())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "query outbox messages")
+	}
+	defer rows.Close()
+
+	var (
+		records = make([]*outboxRecord, 0)
+		parked  []*outboxFailure
+	)
+	for rows.Next() {
+		var (
+			r        outboxRecord
+			uuid     string
+			payload  []byte
+			metadata string
+		)
+		if err = rows.Scan(&r.id, &uuid, &r.topic, &payload, &metadata, &r.delaySeconds, &r.attempts); err != nil {
+			return nil, nil, errors.Wrap(err, "scan outbox message")
+		}
+
+		r.message = provider.NewMessageWithUUID(uuid, payload)
+		if err = json.Unmarshal([]byte(metadata), &r.message.Metadata); err != nil {
+			parked = append(parked, &outboxFailure{record: &r, err: errors.Wrapf(err, "unmarshal outbox message metadata, uuid: %s", uuid)})
+			continue
+		}
+		records = append(records, &r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, "iterate outbox messages")
+	}
+	return records, parked, nil
+}
+
+// publish 按顺序将相同topic和延迟时间的连续消息一起发布, 返回发布成功的记录id和发布失败的记录
+// 一批消息发布失败后继续发布之后的消息
+func (o *Outbox) publish(records []*outboxRecord) ([]int64, []*outboxFailure) {
+	var (
+		published = make([]int64, 0, len(records))
+		failures  []*outboxFailure
+	)
+	for start := 0; start < len(records); {
+		end := start + 1
+		for end < len(records) && records[end].topic == records[start].topic && records[end].delaySeconds == records[start].delaySeconds {
+			end++
+		}
+
+		batch := records[start:end]
+		start = end
+
+		messages := make([]*provider.Message, len(batch))
+		for i, r := range batch {
+			messages[i] = r.message
+		}
+
+		var delaySeconds []int64
+		if batch[0].delaySeconds > 0 {
+			delaySeconds = append(delaySeconds, batch[0].delaySeconds)
+		}
+
+		err := o.publisher.PublishMessage(batch[0].topic, messages, delaySeconds...)
+		if err == nil {
+			for _, r := range batch {
+				published = append(published, r.id)
+			}
+			continue
+		}
+
+		// 部分消息发布失败时只有失败的消息需要重试
+		var failed map[*provider.Message]struct{}
+		var publishErr *provider.PublishError
+		if errors.As(err, &publishErr) {
+			failed = make(map[*provider.Message]struct{}, len(publishErr.Failed))
+			for _, msg := range publishErr.Failed {
+				failed[msg] = struct{}{}
+			}
+		}
+		for _, r := range batch {
+			if _, exists := failed[r.message]; failed != nil && !exists {
+				published = append(published, r.id)
+			} else {
+				failures = append(failures, &outboxFailure{record: r, err: err})
+			}
+		}
+	}
+	return published, failures
+}
+
+func (o *Outbox) delete(ctx context.Context, ids []int64) error {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE id IN (%s)", o.table, o.placeholders(1, len(ids)))
+	_, err := o.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "delete relayed outbox messages")
+	}
+	return nil
+}
+
+// retryLater 增加发布次数, 按指数退避设置下次发布的时间, 达到最大次数时搁置
+func (o *Outbox) retryLater(ctx context.Context, r *outboxRecord, cause error) error {
+	now := time.Now()
+	attempts := r.attempts + 1
+	if attempts >= o.maxAttempts {
+		r.attempts = attempts
+		return o.park(ctx, o.db, r, cause, now)
+	}
+
+	interval := maxOutboxRetryInterval
+	if shift := attempts - 1; shift < 32 && o.pollInterval<<shift < maxOutboxRetryInterval {
+		interval = o.pollInterval << shift
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET attempts = %s, next_attempt_at = %s, last_error = %s WHERE id = %s", o.table,
+		o.placeholders(1, 1), o.placeholders(2, 1), o.placeholders(3, 1), o.placeholders(4, 1))
+	_, err := o.db.ExecContext(ctx, query, attempts, now.Add(interval).UnixMilli(), cause.Error(), r.id)
+	if err != nil {
+		return errors.Wrapf(err, "update outbox message attempts, uuid: %s", r.message.UUID)
+	}
+	return nil
+}
+
+// park 搁置不能发布的消息, 之后不再转发
+func (o *Outbox) park(ctx context.Context, executor provider.DbContextExecutor, r *outboxRecord, cause error, now time.Time) error {
+	query := fmt.Sprintf("UPDATE %s SET attempts = %s, parked_at = %s, last_error = %s WHERE id = %s", o.table,
+		o.placeholders(1, 1), o.placeholders(2, 1), o.placeholders(3, 1), o.placeholders(4, 1))
+	_, err := executor.ExecContext(ctx, query, r.attempts, now.UnixMilli(), cause.Error(), r.id)
+	if err != nil {
+		return errors.Wrapf(err, "park outbox message, uuid: %s", r.message.UUID)
+	}
+
+	o.logger.Error("outbox message parked", "id", r.id, "uuid", r.message.UUID, "topic", r.topic, "attempts", r.attempts, "err", cause)
+	return nil
+}
+
+// placeholders 生成从start开始的n个占位符, postgres使用$n, 其他使用?
+func (o *Outbox) placeholders(start, n int) string {
+	items := make([]string, n)
+	for i := range items {
+		if o.dialect == OutboxDialectPostgres {
+			items[i] = fmt.Sprintf("$%d", start+i)
+		} else {
+			items[i] = "?"
+		}
+	}
+	return strings.Join(items, ", ")
+}
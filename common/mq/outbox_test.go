@@ -0,0 +1,304 @@
+package mq
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hdget/sdk/common/dbkit"
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// testPublisher 记录发布的消息, fail不为空时由它决定是否发布失败
+type testPublisher struct {
+	mutex     sync.Mutex
+	published []testPublished
+	fail      func(messages []*provider.Message) error
+}
+
+type testPublished struct {
+	topic        string
+	uuids        []string
+	delaySeconds []int64
+}
+
+// millisAfter 匹配当前时间之后d的毫秒时间戳, 允许1秒的误差
+type millisAfter struct {
+	d time.Duration
+}
+
+const (
+	testOutboxInsert = "INSERT INTO mq_outbox (uuid, topic, payload, metadata, delay_seconds, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	testOutboxSelect = "SELECT id, uuid, topic, payload, metadata, delay_seconds, attempts FROM mq_outbox WHERE parked_at = 0 AND next_attempt_at <= ? ORDER BY id LIMIT 100"
+	testOutboxRetry  = "UPDATE mq_outbox SET attempts = ?, next_attempt_at = ?, last_error = ? WHERE id = ?"
+	testOutboxPark   = "UPDATE mq_outbox SET attempts = ?, parked_at = ?, last_error = ? WHERE id = ?"
+)
+
+// testQueryMatcher 语句相同时匹配, SAVEPOINT的名字包含时间戳, 只匹配前缀
+var testQueryMatcher = sqlmock.QueryMatcherFunc(func(expected, actual string) error {
+	if actual == expected || (strings.HasSuffix(expected, " sp_") && strings.HasPrefix(actual, expected)) {
+		return nil
+	}
+	return errors.Errorf("actual sql: %q does not equal to expected %q", actual, expected)
+})
+
+func (a millisAfter) Match(v driver.Value) bool {
+	ms, ok := v.(int64)
+	if !ok {
+		return false
+	}
+	diff := time.UnixMilli(ms).Sub(time.Now().Add(a.d))
+	return diff > -time.Second && diff < time.Second
+}
+
+func (p *testPublisher) Publish(string, [][]byte, ...int64) error {
+	return errors.New("not implemented")
+}
+
+func (p *testPublisher) PublishMessage(topic string, messages []*provider.Message, delaySeconds ...int64) error {
+	if p.fail != nil {
+		if err := p.fail(messages); err != nil {
+			return err
+		}
+	}
+
+	uuids := make([]string, len(messages))
+	for i, msg := range messages {
+		uuids[i] = msg.UUID
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.published = append(p.published, testPublished{topic: topic, uuids: uuids, delaySeconds: delaySeconds})
+	return nil
+}
+
+func (p *testPublisher) Close() error {
+	return nil
+}
+
+func newTestOutbox(t *testing.T, publisher provider.MessageQueuePublisher, options ...OutboxOption) (*Outbox, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(testQueryMatcher))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		_ = db.Close()
+	})
+	return NewOutbox(&testLogger{}, dbkit.NewClient(db, nil), publisher, options...), mock
+}
+
+func newTestOutboxRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "uuid", "topic", "payload", "metadata", "delay_seconds", "attempts"})
+}
+
+func TestOutboxAdd(t *testing.T) {
+	outbox, mock := newTestOutbox(t, &testPublisher{})
+
+	msg := provider.NewMessage([]byte("hello"))
+	msg.Metadata.Set("key", "value")
+	noUUID := &provider.Message{Payload: []byte("world")}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp_").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(testOutboxInsert).
+		WithArgs(msg.UUID, "topic", []byte("hello"), `{"key":"value"}`, int64(5), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(testOutboxInsert).
+		WithArgs(sqlmock.AnyArg(), "topic", []byte("world"), "null", int64(5), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT sp_").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := outbox.RunInTransaction(context.Background(), func(ctx context.Context) error {
+		// 嵌套的事务提交后不通知
+		err := outbox.RunInTransaction(ctx, func(ctx context.Context) error {
+			return outbox.Add(ctx, "topic", []*provider.Message{msg, noUUID}, 5)
+		})
+		if len(outbox.notify) != 0 {
+			t.Fatal("nested transaction should not notify")
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if noUUID.UUID == "" {
+		t.Fatal("Add() should generate uuid for message")
+	}
+	if len(outbox.notify) != 1 {
+		t.Fatal("RunInTransaction() should notify after commit")
+	}
+
+	if err = outbox.Add(context.Background(), "topic", []*provider.Message{msg}); err == nil {
+		t.Fatal("Add() outside transaction, want error")
+	}
+}
+
+func TestOutboxAddPostgres(t *testing.T) {
+	outbox, mock := newTestOutbox(t, &testPublisher{}, WithOutboxDialect(OutboxDialectPostgres), WithOutboxTable("outbox"))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO outbox (uuid, topic, payload, metadata, delay_seconds, created_at) VALUES ($1, $2, $3, $4, $5, $6)").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+
+	err := outbox.RunInTransaction(context.Background(), func(ctx context.Context) error {
+		if err := outbox.Add(ctx, "topic", []*provider.Message{provider.NewMessage(nil)}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if err == nil {
+		t.Fatal("RunInTransaction() should return fn error")
+	}
+	if len(outbox.notify) != 0 {
+		t.Fatal("rollback should not notify")
+	}
+}
+
+// 相同topic和延迟时间的连续消息一起发布, 占用消息的事务提交后再发布, 发布成功后删除
+func TestOutboxRelay(t *testing.T) {
+	publisher := &testPublisher{}
+	outbox, mock := newTestOutbox(t, publisher)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(testOutboxSelect+" FOR UPDATE SKIP LOCKED").WithArgs(millisAfter{}).WillReturnRows(newTestOutboxRows().
+		AddRow(int64(1), "u1", "a", []byte("1"), `{"key":"value"}`, int64(0), 0).
+		AddRow(int64(2), "u2", "a", []byte("2"), "{}", int64(0), 0).
+		AddRow(int64(3), "u3", "a", []byte("3"), "{}", int64(10), 0).
+		AddRow(int64(4), "u4", "b", []byte("4"), "{}", int64(10), 0))
+	mock.ExpectExec("UPDATE mq_outbox SET next_attempt_at = ? WHERE id IN (?, ?, ?, ?)").
+		WithArgs(millisAfter{d: time.Minute}, int64(1), int64(2), int64(3), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM mq_outbox WHERE id IN (?, ?, ?, ?)").
+		WithArgs(int64(1), int64(2), int64(3), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	n, err := outbox.Relay(context.Background())
+	if err != nil || n != 4 {
+		t.Fatalf("Relay() = %d, %v, want 4", n, err)
+	}
+
+	want := []testPublished{
+		{topic: "a", uuids: []string{"u1", "u2"}},
+		{topic: "a", uuids: []string{"u3"}, delaySeconds: []int64{10}},
+		{topic: "b", uuids: []string{"u4"}, delaySeconds: []int64{10}},
+	}
+	if !reflect.DeepEqual(publisher.published, want) {
+		t.Fatalf("published = %+v, want %+v", publisher.published, want)
+	}
+}
+
+// 部分消息发布失败时删除发布成功的消息, 失败的消息等待重试, 之后的批次继续发布
+func TestOutboxRelayPartial(t *testing.T) {
+	publisher := &testPublisher{fail: func(messages []*provider.Message) error {
+		for _, msg := range messages {
+			if msg.UUID == "u2" {
+				return &provider.PublishError{Failed: []*provider.Message{msg}, Err: errors.New("nack")}
+			}
+		}
+		return nil
+	}}
+	outbox, mock := newTestOutbox(t, publisher, WithOutboxDialect(OutboxDialectSqlite), WithOutboxBatchSize(3))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, uuid, topic, payload, metadata, delay_seconds, attempts FROM mq_outbox WHERE parked_at = 0 AND next_attempt_at <= ? ORDER BY id LIMIT 3").
+		WillReturnRows(newTestOutboxRows().
+			AddRow(int64(1), "u1", "a", []byte("1"), "{}", int64(0), 0).
+			AddRow(int64(2), "u2", "a", []byte("2"), "{}", int64(0), 0).
+			AddRow(int64(3), "u3", "b", []byte("3"), "{}", int64(0), 0))
+	mock.ExpectExec("UPDATE mq_outbox SET next_attempt_at = ? WHERE id IN (?, ?, ?)").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM mq_outbox WHERE id IN (?, ?)").WithArgs(int64(1), int64(3)).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(testOutboxRetry).WithArgs(1, millisAfter{d: 5 * time.Second}, "publish 1 messages failed: nack", int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := outbox.Relay(context.Background())
+	var publishErr *provider.PublishError
+	if n != 2 || !errors.As(err, &publishErr) {
+		t.Fatalf("Relay() = %d, %v, want 2 and publish error", n, err)
+	}
+
+	want := []testPublished{{topic: "b", uuids: []string{"u3"}}}
+	if !reflect.DeepEqual(publisher.published, want) {
+		t.Fatalf("published = %+v, want %+v", publisher.published, want)
+	}
+}
+
+// 发布出错时按指数退避重试, 查询出错时回滚
+func TestOutboxRelayError(t *testing.T) {
+	publisher := &testPublisher{fail: func([]*provider.Message) error { return errors.New("connection refused") }}
+	outbox, mock := newTestOutbox(t, publisher, WithOutboxDialect(OutboxDialectPostgres))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, uuid, topic, payload, metadata, delay_seconds, attempts FROM mq_outbox WHERE parked_at = 0 AND next_attempt_at <= $1 ORDER BY id LIMIT 100 FOR UPDATE SKIP LOCKED").
+		WillReturnRows(newTestOutboxRows().AddRow(int64(1), "u1", "a", []byte("1"), "{}", int64(0), 2))
+	mock.ExpectExec("UPDATE mq_outbox SET next_attempt_at = $1 WHERE id IN ($2)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("UPDATE mq_outbox SET attempts = $1, next_attempt_at = $2, last_error = $3 WHERE id = $4").
+		WithArgs(3, millisAfter{d: 20 * time.Second}, "connection refused", int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if n, err := outbox.Relay(context.Background()); n != 0 || err == nil {
+		t.Fatalf("Relay() = %d, %v, want publish error", n, err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, uuid, topic, payload, metadata, delay_seconds, attempts FROM mq_outbox WHERE parked_at = 0 AND next_attempt_at <= $1 ORDER BY id LIMIT 100 FOR UPDATE SKIP LOCKED").
+		WillReturnError(errors.New("deadlock"))
+	mock.ExpectRollback()
+
+	if n, err := outbox.Relay(context.Background()); n != 0 || err == nil {
+		t.Fatalf("Relay() = %d, %v, want query error", n, err)
+	}
+}
+
+// 无法解析的消息和达到最大发布次数的消息被搁置, 不影响其他消息
+func TestOutboxRelayPark(t *testing.T) {
+	publisher := &testPublisher{fail: func(messages []*provider.Message) error {
+		if messages[0].UUID == "u2" {
+			return errors.New("message too large")
+		}
+		return nil
+	}}
+	outbox, mock := newTestOutbox(t, publisher, WithOutboxMaxAttempts(3), WithOutboxClaimTimeout(time.Second))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(testOutboxSelect+" FOR UPDATE SKIP LOCKED").WillReturnRows(newTestOutboxRows().
+		AddRow(int64(1), "u1", "a", []byte("1"), "{", int64(0), 0).
+		AddRow(int64(2), "u2", "b", []byte("2"), "{}", int64(0), 2).
+		AddRow(int64(3), "u3", "c", []byte("3"), "{}", int64(0), 0))
+	mock.ExpectExec(testOutboxPark).WithArgs(0, millisAfter{}, sqlmock.AnyArg(), int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE mq_outbox SET next_attempt_at = ? WHERE id IN (?, ?)").
+		WithArgs(millisAfter{d: time.Second}, int64(2), int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectExec("DELETE FROM mq_outbox WHERE id IN (?)").WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(testOutboxPark).WithArgs(3, millisAfter{}, "message too large", int64(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := outbox.Relay(context.Background())
+	if n != 1 || err == nil {
+		t.Fatalf("Relay() = %d, %v, want 1 and publish error", n, err)
+	}
+
+	want := []testPublished{{topic: "c", uuids: []string{"u3"}}}
+	if !reflect.DeepEqual(publisher.published, want) {
+		t.Fatalf("published = %+v, want %+v", publisher.published, want)
+	}
+	if logger := outbox.logger.(*testLogger); len(logger.entries) != 2 || !logger.Contains("error", "outbox message parked") {
+		t.Fatalf("log entries = %v, want 2 parked messages", logger.entries)
+	}
+}
@@ -0,0 +1,316 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// HandlerFunc 消息处理函数, 返回nil时确认消息, 返回错误时拒绝消息
+type HandlerFunc func(ctx context.Context, msg *provider.Message) error
+
+// Middleware 包装HandlerFunc, 先注册的中间件在外层
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Router 为每个topic注册消息处理函数, 并发处理订阅到的消息:
+//   - 处理函数返回nil时Ack, 返回错误或者panic时Nack
+//   - 每个处理函数同时订阅prefetch次, 最多预取prefetch条未确认的消息, 由workers个goroutine并发处理
+//   - 停止时先停止分发新的消息, 等待正在处理的消息完成后再关闭订阅, 未分发的预取消息由消息队列重新投递
+type Router struct {
+	logger       provider.Logger
+	middlewares  []Middleware
+	closeTimeout time.Duration
+	handlers     []*routerHandler
+	mutex        sync.Mutex
+	running      bool
+	closing      chan struct{}
+	closeOnce    sync.Once
+	stopped      chan struct{}
+}
+
+type RouterOption func(*Router)
+
+type HandlerOption func(*routerHandler)
+
+type routerHandler struct {
+	subscriber  provider.MessageQueueSubscriber
+	topic       string
+	fn          HandlerFunc
+	workers     int
+	prefetch    int
+	middlewares []Middleware
+}
+
+type topicCtxKey struct{}
+
+const (
+	defaultRouterCloseTimeout = 30 * time.Second
+	defaultHandlerWorkers     = 1
+)
+
+// WithRouterCloseTimeout 停止时等待正在处理的消息的最长时间, 超时后取消处理函数的ctx
+func WithRouterCloseTimeout(timeout time.Duration) RouterOption {
+	return func(r *Router) {
+		if timeout > 0 {
+			r.closeTimeout = timeout
+		}
+	}
+}
+
+// WithHandlerWorkers 并发处理消息的goroutine数量, 缺省为1
+func WithHandlerWorkers(workers int) HandlerOption {
+	return func(h *routerHandler) {
+		if workers > 0 {
+			h.workers = workers
+		}
+	}
+}
+
+// WithHandlerPrefetch 最多预取的未确认消息数量, 不能小于workers, 缺省和workers相同
+func WithHandlerPrefetch(prefetch int) HandlerOption {
+	return func(h *routerHandler) {
+		if prefetch > 0 {
+			h.prefetch = prefetch
+		}
+	}
+}
+
+// WithHandlerMiddleware 只对该处理函数生效的中间件, 在Router的中间件之后执行
+func WithHandlerMiddleware(middlewares ...Middleware) HandlerOption {
+	return func(h *routerHandler) {
+		h.middlewares = append(h.middlewares, middlewares...)
+	}
+}
+
+func NewRouter(logger provider.Logger, options ...RouterOption) *Router {
+	r := &Router{
+		logger:       logger,
+		closeTimeout: defaultRouterCloseTimeout,
+		closing:      make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// Use 添加对所有处理函数生效的中间件, 需要在Run之前调用
+func (r *Router) Use(middlewares ...Middleware) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+}
+
+// AddHandler 注册topic的处理函数, 需要在Run之前调用
+// 相同name的subscriber竞争消费, 多个实例可以使用相同name的subscriber分担消息
+func (r *Router) AddHandler(subscriber provider.MessageQueueSubscriber, topic string, fn HandlerFunc, options ...HandlerOption) {
+	h := &routerHandler{
+		subscriber: subscriber,
+		topic:      topic,
+		fn:         fn,
+		workers:    defaultHandlerWorkers,
+	}
+
+	for _, option := range options {
+		option(h)
+	}
+
+	if h.prefetch < h.workers {
+		h.prefetch = h.workers
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.handlers = append(r.handlers, h)
+}
+
+// Run 订阅所有topic并处理消息, 阻塞直到ctx取消或者调用Close, 然后停止处理并返回
+func (r *Router) Run(ctx context.Context) error {
+	r.mutex.Lock()
+	if r.running {
+		r.mutex.Unlock()
+		return errors.New("router is already running")
+	}
+	r.running = true
+	handlers := r.handlers
+	middlewares := r.middlewares
+	r.mutex.Unlock()
+	defer close(r.stopped)
+
+	// 订阅的ctx不随ctx取消, 保证停止时正在处理的消息仍然可以确认
+	subCtx, subCancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer subCancel()
+
+	var (
+		stopping = make(chan struct{})
+		workerWg sync.WaitGroup
+		fwdWg    sync.WaitGroup
+	)
+	for _, h := range handlers {
+		messages := make(chan *provider.Message)
+		for i := 0; i < h.prefetch; i++ {
+			ch, err := h.subscriber.Subscribe(subCtx, h.topic)
+			if err != nil {
+				close(stopping)
+				subCancel()
+				workerWg.Wait()
+				fwdWg.Wait()
+				return errors.Wrapf(err, "router subscribe, topic: %s", h.topic)
+			}
+
+			fwdWg.Add(1)
+			go func() {
+				defer fwdWg.Done()
+				forward(ch, messages, stopping)
+			}()
+		}
+
+		fn := r.chain(h, middlewares)
+		for i := 0; i < h.workers; i++ {
+			workerWg.Add(1)
+			go func() {
+				defer workerWg.Done()
+				r.work(h.topic, fn, messages, stopping)
+			}()
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-r.closing:
+	}
+
+	// 停止分发新消息, 等待正在处理的消息完成
+	close(stopping)
+	done := make(chan struct{})
+	go func() {
+		workerWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(r.closeTimeout):
+		r.logger.Error("router close timeout, cancel running handlers", "timeout", r.closeTimeout)
+	}
+
+	subCancel()
+	<-done
+	fwdWg.Wait()
+	return nil
+}
+
+// Close 停止Run并等待其返回
+func (r *Router) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closing)
+	})
+
+	r.mutex.Lock()
+	running := r.running
+	r.mutex.Unlock()
+	if running {
+		<-r.stopped
+	}
+	return nil
+}
+
+// GetTopic 获取处理函数ctx中正在处理的消息的topic
+func GetTopic(ctx context.Context) string {
+	topic, _ := ctx.Value(topicCtxKey{}).(string)
+	return topic
+}
+
+// chain 按注册顺序包装中间件, 处理函数的panic转换为错误
+func (r *Router) chain(h *routerHandler, middlewares []Middleware) HandlerFunc {
+	fn := recoverer(h.fn)
+
+	all := append(append([]Middleware{}, middlewares...), h.middlewares...)
+	for i := len(all) - 1; i >= 0; i-- {
+		fn = all[i](fn)
+	}
+	return fn
+}
+
+func (r *Router) work(topic string, fn HandlerFunc, messages <-chan *provider.Message, stopping <-chan struct{}) {
+	for {
+		select {
+		case msg := <-messages:
+			r.handle(topic, fn, msg)
+		case <-stopping:
+			return
+		}
+	}
+}
+
+func (r *Router) handle(topic string, fn HandlerFunc, msg *provider.Message) {
+	ctx := context.WithValue(msg.Context(), topicCtxKey{}, topic)
+
+	// 中间件中的panic也不能影响其他消息的处理
+	err := recoverer(fn)(ctx, msg)
+	if err != nil {
+		var p *panicError
+		if errors.As(err, &p) {
+			r.logger.Error("message handler panic", "topic", topic, "uuid", msg.UUID, "err", p)
+		}
+		msg.Nack()
+		return
+	}
+	msg.Ack()
+}
+
+// forward 将订阅到的消息转发给worker, 停止后不再转发, 未转发的消息在关闭订阅后由消息队列重新投递
+func forward(in <-chan *provider.Message, out chan<- *provider.Message, stopping <-chan struct{}) {
+	for {
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+			select {
+			case out <- msg:
+			case <-stopping:
+				drain(in)
+				return
+			}
+		case <-stopping:
+			drain(in)
+			return
+		}
+	}
+}
+
+// drain 读取订阅关闭前的剩余消息, 避免订阅的goroutine阻塞
+func drain(in <-chan *provider.Message) {
+	for range in {
+	}
+}
+
+// panicError 处理函数panic时返回的错误
+type panicError struct {
+	value any
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("handler panic: %v\n%s", e.value, e.stack)
+}
+
+func recoverer(fn HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, msg *provider.Message) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &panicError{value: r, stack: debug.Stack()}
+			}
+		}()
+		return fn(ctx, msg)
+	}
+}
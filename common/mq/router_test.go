@@ -0,0 +1,271 @@
+package mq
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// testSubscriber 所有订阅共享同一个队列, 每个订阅同时只投递一条未确认的消息, Nack的消息重新放回队列
+type testSubscriber struct {
+	queue chan *provider.Message
+	err   error
+}
+
+// testLogger 记录日志的级别和消息
+type testLogger struct {
+	provider.Logger
+	mutex   sync.Mutex
+	entries []string
+}
+
+func newTestSubscriber(payloads ...string) *testSubscriber {
+	s := &testSubscriber{queue: make(chan *provider.Message, 100)}
+	for _, payload := range payloads {
+		s.queue <- provider.NewMessage([]byte(payload))
+	}
+	return s
+}
+
+func (s *testSubscriber) Subscribe(ctx context.Context, _ string) (<-chan *provider.Message, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	out := make(chan *provider.Message)
+	go func() {
+		defer close(out)
+		for {
+			var msg *provider.Message
+			select {
+			case msg = <-s.queue:
+			case <-ctx.Done():
+				return
+			}
+
+			msg.SetContext(ctx)
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				s.queue <- msg.Copy()
+				return
+			}
+
+			select {
+			case <-msg.Acked():
+			case <-msg.Nacked():
+				s.queue <- msg.Copy()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (s *testSubscriber) Close() error {
+	return nil
+}
+
+func (l *testLogger) Debug(msg string, _ ...any) {
+	l.record("debug", msg)
+}
+
+func (l *testLogger) Error(msg string, _ ...any) {
+	l.record("error", msg)
+}
+
+// Contains 是否记录了level级别并且包含msg的日志
+func (l *testLogger) Contains(level, msg string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, entry := range l.entries {
+		if strings.HasPrefix(entry, level+" ") && strings.Contains(entry, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *testLogger) record(level, msg string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.entries = append(l.entries, level+" "+msg)
+}
+
+// 处理失败和panic的消息重新投递, 多个worker并发处理
+func TestRouter(t *testing.T) {
+	logger := &testLogger{}
+	payloads := []string{"fail", "panic"}
+	for i := 0; i < 8; i++ {
+		payloads = append(payloads, string(rune('a'+i)))
+	}
+	subscriber := newTestSubscriber(payloads...)
+
+	var (
+		mutex     sync.Mutex
+		handled   = make(map[string]int)
+		running   atomic.Int32
+		maxActive atomic.Int32
+	)
+	router := NewRouter(logger)
+	router.Use(Logging(logger), Dedup(NewMemoryDedupStore(time.Minute)))
+	router.AddHandler(subscriber, "topic", func(ctx context.Context, msg *provider.Message) error {
+		if GetTopic(ctx) != "topic" {
+			return errors.New("topic not in context")
+		}
+
+		active := running.Add(1)
+		defer running.Add(-1)
+		for prev := maxActive.Load(); active > prev && !maxActive.CompareAndSwap(prev, active); prev = maxActive.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		mutex.Lock()
+		handled[string(msg.Payload)]++
+		count := handled[string(msg.Payload)]
+		mutex.Unlock()
+
+		switch {
+		case string(msg.Payload) == "fail" && count == 1:
+			return errors.New("fail")
+		case string(msg.Payload) == "panic" && count == 1:
+			panic("boom")
+		}
+		return nil
+	}, WithHandlerWorkers(4), WithHandlerPrefetch(6))
+
+	done := make(chan error, 1)
+	go func() { done <- router.Run(context.Background()) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mutex.Lock()
+		finished := len(handled) == 10 && handled["fail"] == 2 && handled["panic"] == 2
+		mutex.Unlock()
+		if finished {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("handled = %v, want all messages handled", handled)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := router.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	if maxActive.Load() < 2 {
+		t.Fatalf("max concurrent handlers = %d, want at least 2", maxActive.Load())
+	}
+	if !logger.Contains("error", "message handler panic") || !logger.Contains("error", "handle message") {
+		t.Fatal("handler errors should be logged")
+	}
+
+	if err := router.Run(context.Background()); err == nil {
+		t.Fatal("Run() twice, want error")
+	}
+}
+
+// 停止时等待正在处理的消息完成并确认
+func TestRouterClose(t *testing.T) {
+	subscriber := newTestSubscriber("a")
+	started := make(chan *provider.Message, 1)
+	router := NewRouter(&testLogger{})
+	router.AddHandler(subscriber, "topic", func(ctx context.Context, msg *provider.Message) error {
+		started <- msg
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- router.Run(ctx) }()
+
+	msg := <-started
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-msg.Acked():
+	default:
+		t.Fatal("running message should be acked before Run returns")
+	}
+	if err := router.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 超过停止等待时间后取消处理函数的ctx
+func TestRouterCloseTimeout(t *testing.T) {
+	logger := &testLogger{}
+	subscriber := newTestSubscriber("a")
+	started := make(chan struct{})
+	router := NewRouter(logger, WithRouterCloseTimeout(20*time.Millisecond))
+	router.AddHandler(subscriber, "topic", func(ctx context.Context, msg *provider.Message) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- router.Run(context.Background()) }()
+
+	<-started
+	if err := router.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !logger.Contains("error", "router close timeout") {
+		t.Fatal("close timeout should be logged")
+	}
+}
+
+func TestRouterSubscribeError(t *testing.T) {
+	subscriber := newTestSubscriber()
+	subscriber.err = errors.New("connection refused")
+
+	router := NewRouter(&testLogger{})
+	router.AddHandler(subscriber, "topic", func(context.Context, *provider.Message) error { return nil }, WithHandlerPrefetch(2))
+	if err := router.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "topic") {
+		t.Fatalf("Run() = %v, want subscribe error", err)
+	}
+}
+
+func TestAddHandlerOptions(t *testing.T) {
+	testCases := []struct {
+		name         string
+		options      []HandlerOption
+		wantWorkers  int
+		wantPrefetch int
+	}{
+		{name: "default", wantWorkers: 1, wantPrefetch: 1},
+		{name: "workers", options: []HandlerOption{WithHandlerWorkers(4)}, wantWorkers: 4, wantPrefetch: 4},
+		{name: "prefetch", options: []HandlerOption{WithHandlerWorkers(2), WithHandlerPrefetch(8)}, wantWorkers: 2, wantPrefetch: 8},
+		{name: "prefetch less than workers", options: []HandlerOption{WithHandlerWorkers(4), WithHandlerPrefetch(2)}, wantWorkers: 4, wantPrefetch: 4},
+		{name: "invalid", options: []HandlerOption{WithHandlerWorkers(0), WithHandlerPrefetch(-1)}, wantWorkers: 1, wantPrefetch: 1},
+	}
+
+	for _, tc := range testCases {
+		router := NewRouter(&testLogger{})
+		router.AddHandler(newTestSubscriber(), "topic", nil, tc.options...)
+		h := router.handlers[0]
+		if h.workers != tc.wantWorkers || h.prefetch != tc.wantPrefetch {
+			t.Errorf("%s: workers = %d, prefetch = %d, want %d, %d", tc.name, h.workers, h.prefetch, tc.wantWorkers, tc.wantPrefetch)
+		}
+	}
+}
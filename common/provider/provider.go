@@ -14,6 +14,14 @@ type Capability struct {
 	Category Category
 	Name     string
 	Module   fx.Option
+	Config   *ConfigSchema // 能力使用的配置, 用于启动时统一校验所有配置
+}
+
+// ConfigSchema 能力使用的配置段落和配置结构
+type ConfigSchema struct {
+	Section  string     // 配置段落, 例如: sdk.redis
+	New      func() any // 创建配置结构, 返回结构体指针, 可以预先填充缺省值
+	Optional bool       // 配置段落是否可以不存在
 }
 
 type Category int
@@ -25,4 +33,10 @@ const (
 	CategoryDb
 	CategoryRedis
 	CategoryMq
+	CategoryOss
+	CategoryLock
+	CategoryCache
 )
+
+// CategoryCustom 自定义能力类别的起始值, 自定义类别需要通过sdk.RegisterCategory绑定接口类型
+const CategoryCustom Category = 100
@@ -5,4 +5,6 @@ type Config interface {
 	Provider
 	Unmarshal(configVar any, key ...string) error // 读取配置到变量configVar
 	Get(key string) any                           // 获取配置项的值
+	// Watch 监听配置项或配置段落的变化, 值发生变化时调用fn
+	Watch(key string, fn func(oldValue, newValue any)) error
 }
@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 // TxCtxKey 用于在 context 中传递事务状态
@@ -19,10 +20,14 @@ type Database interface {
 	Slave(i int) DbClient
 	// Named 指定名称的数据库
 	Named(name string) DbClient
-	// Read 返回用于读操作的数据库客户端（自动从 slave 中轮询选择）
+	// Read 返回用于读操作的数据库客户端（自动从可用的 slave 中轮询选择）
 	Read() DbClient
+	// ReadContext 同Read, ctx中有事务或者通过dbkit.WithPrimary标记时返回主库
+	ReadContext(ctx context.Context) DbClient
 	// Write 返回用于写操作的数据库客户端（返回 master 或 default）
 	Write() DbClient
+	// Stats 各数据库客户端的连接池统计信息, key为default, master, slaves.<序号>和items.<名字>
+	Stats() map[string]sql.DBStats
 }
 
 type DbExecutor interface {
@@ -48,7 +53,40 @@ type DbClient interface {
 	Close() error
 	// RunInTransaction 在事务中执行函数，支持嵌套事务（通过 SAVEPOINT 实现）
 	// fn 的参数 ctx 包含事务信息，用于嵌套事务检测
+	// 通过 dbkit.WithTxOptions 指定隔离级别和只读, 通过 dbkit.AfterCommit 注册提交后的回调
 	RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 	// Db returns the underlying *sql.DB for direct access
 	Db() *sql.DB
+	// AddHook 添加钩子, 通过客户端执行的操作和事务都会依次调用
+	AddHook(hooks ...DbHook)
+}
+
+// DbOp 数据库操作类型
+type DbOp string
+
+const (
+	DbOpExec     DbOp = "exec"
+	DbOpQuery    DbOp = "query"
+	DbOpQueryRow DbOp = "query_row"
+	DbOpBegin    DbOp = "begin"
+	DbOpCommit   DbOp = "commit"
+	DbOpRollback DbOp = "rollback"
+)
+
+// DbEvent 一次数据库操作, 事务中的SAVEPOINT等语句作为exec操作
+type DbEvent struct {
+	Op       DbOp
+	Query    string
+	Args     []any
+	Start    time.Time
+	Duration time.Duration // After中有效, 查询操作不包含读取结果集的时间
+	Err      error         // After中有效
+}
+
+// DbHook 数据库操作的钩子, Before按添加顺序调用, After按相反顺序调用
+type DbHook interface {
+	// Before 执行前调用, 可以修改event.Query, 返回的ctx会传给After
+	Before(ctx context.Context, event *DbEvent) context.Context
+	// After 执行后调用
+	After(ctx context.Context, event *DbEvent)
 }
@@ -1,6 +1,9 @@
 package provider
 
-import "log"
+import (
+	"context"
+	"log"
+)
 
 // Logger provider
 type Logger interface {
@@ -14,4 +17,10 @@ type Logger interface {
 	Error(msg string, keyvals ...interface{})
 	Fatal(msg string, keyvals ...interface{})
 	Panic(msg string, keyvals ...interface{})
+	// With 返回带有keyvals字段的子日志, 子日志输出的每条日志都包含这些字段
+	With(keyvals ...interface{}) Logger
+	// Named 返回指定名字的子日志, 日志中包含logger字段, 子日志的级别可以单独设置
+	Named(name string) Logger
+	// Ctx 返回带有ctx中bizctx元数据的子日志, 包括tid, uid, client_ip和trace_id, 用于关联同一请求的日志
+	Ctx(ctx context.Context) Logger
 }
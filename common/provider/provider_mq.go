@@ -1,6 +1,11 @@
 package provider
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
 
 // MessageQueue provider
 // 相同name的多个订阅者如果订阅同一个topic,则只有一个订阅者会收到消息
@@ -13,10 +18,53 @@ type MessageQueue interface {
 
 type PublisherOption struct {
 	PublishDelayMessage bool
+	// Mandatory 消息无法路由到任何队列时发布失败, 缺省情况下这样的消息会被broker丢弃
+	Mandatory bool
+	// ExchangeKind 交换机类型: fanout, direct, topic, headers, 为空时使用配置中topic对应的类型, 缺省为fanout
+	ExchangeKind string
+	// RoutingKey 缺省的路由键, 可以通过Metadata[MetadataKeyRoutingKey]为每条消息单独指定
+	RoutingKey string
+}
+
+// PublishError 部分消息发布失败, 调用方可以只重新发布失败的消息
+type PublishError struct {
+	Failed []*Message
+	Err    error
 }
 
 type SubscriberOption struct {
 	SubscribeDelayMessage bool
+	// RetryPolicy 消息处理失败(Nack)后的重试策略, 为空时不限制重试次数
+	RetryPolicy *RetryPolicy
+	// ExchangeKind 交换机类型, 必须和发布者一致, 为空时使用配置中topic对应的类型, 缺省为fanout
+	ExchangeKind string
+	// BindingKeys 队列绑定的路由键, topic交换机支持通配符: *匹配一个单词, #匹配零个或多个单词
+	BindingKeys []string
+	// BindingHeaders headers交换机匹配的消息头, 消息头来自消息的Metadata
+	BindingHeaders map[string]string
+	// MatchAnyHeader headers交换机匹配任意一个消息头即可, 缺省需要匹配所有消息头
+	MatchAnyHeader bool
+	// Queue 队列参数, 为空时使用配置中的队列参数
+	Queue *QueueOption
+}
+
+// QueueOption 队列参数, 队列已经存在时参数必须和之前声明的一致
+type QueueOption struct {
+	Type           string        // 队列类型: classic, quorum, 为空时使用broker的缺省类型
+	MessageTTL     time.Duration // 消息过期时间, 精确到毫秒, 为0时不过期
+	MaxLength      int64         // 最多保留的消息数量, 为0时不限制
+	MaxLengthBytes int64         // 最多保留的消息字节数, 为0时不限制
+	Overflow       string        // 超过长度限制后的处理方式: drop-head, reject-publish
+	MaxPriority    int           // 最大优先级, 大于0时启用优先级队列, 消息优先级通过Metadata[MetadataKeyPriority]指定
+}
+
+// RetryPolicy 重试策略, 第n次重试前等待InitialInterval * Multiplier^(n-1), 最长不超过MaxInterval
+// 投递MaxAttempts次仍然失败的消息会进入死信队列
+type RetryPolicy struct {
+	MaxAttempts     int           // 最多投递次数, 包括第一次投递
+	InitialInterval time.Duration // 第一次重试前的等待时间, 为0时立即重试
+	MaxInterval     time.Duration // 最长等待时间, 为0时不限制
+	Multiplier      float64       // 等待时间的增长倍数, 小于1时按1处理
 }
 
 var (
@@ -27,8 +75,40 @@ var (
 	DefaultSubscriberOption = &SubscriberOption{
 		SubscribeDelayMessage: false,
 	}
+
+	DefaultRetryPolicy = &RetryPolicy{
+		MaxAttempts:     5,
+		InitialInterval: time.Second,
+		MaxInterval:     time.Minute,
+		Multiplier:      2,
+	}
 )
 
+func (e *PublishError) Error() string {
+	return fmt.Sprintf("publish %d messages failed: %v", len(e.Failed), e.Err)
+}
+
+func (e *PublishError) Unwrap() error {
+	return e.Err
+}
+
+// Backoff 第attempt次重试前需要等待的时间, attempt从1开始
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 || p.InitialInterval <= 0 {
+		return 0
+	}
+
+	multiplier := math.Max(p.Multiplier, 1)
+	interval := float64(p.InitialInterval) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxInterval > 0 && interval > float64(p.MaxInterval) {
+		return p.MaxInterval
+	}
+	if interval >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(interval)
+}
+
 type MessageQueuePublisher interface {
 	// Publish publishes provided messages to given topic.
 	//
@@ -39,6 +119,9 @@ type MessageQueuePublisher interface {
 	//
 	// Publish must be thread safe.
 	Publish(topic string, messages [][]byte, delaySeconds ...int64) error
+	// PublishMessage publishes messages with their uuid and metadata, metadata is delivered as message headers.
+	// If some messages are not confirmed by the broker, a *PublishError with the failed messages is returned.
+	PublishMessage(topic string, messages []*Message, delaySeconds ...int64) error
 	// Close should flush unsent messages, if publisher is async.
 	Close() error
 }
@@ -57,3 +140,12 @@ type MessageQueueSubscriber interface {
 	// Close closes all subscriptions with their output channels and flush offsets etc. when needed.
 	Close() error
 }
+
+// MessageQueueDeadLetter 死信队列管理, 由支持死信队列的MessageQueue实现
+// 使用时通过类型断言获取, 例如: mq.(provider.MessageQueueDeadLetter)
+type MessageQueueDeadLetter interface {
+	// ListDeadLetters 查看订阅者name在topic上的死信消息, 消息仍然保留在死信队列中, limit小于等于0时返回所有消息
+	ListDeadLetters(topic, name string, limit int) ([]*Message, error)
+	// RepublishDeadLetters 将死信消息重新投递给订阅者, 重试次数重新计算, 返回重新投递的消息数量
+	RepublishDeadLetters(topic, name string, limit int) (int, error)
+}
@@ -3,11 +3,25 @@ package provider
 import (
 	"context"
 	"sync"
+
+	"github.com/google/uuid"
 )
 
 // Payload is the Message's payload.
 type Payload []byte
 
+// Metadata is sent with every message to provide extra context without unmarshalling the message payload.
+type Metadata map[string]string
+
+// 常用的Metadata key, 消息队列实现会将其映射到对应的消息属性上
+const (
+	MetadataKeyCorrelationID = "correlation_id"
+	MetadataKeyContentType   = "content_type"
+	MetadataKeyTimestamp     = "timestamp"   // 发布时间, unix毫秒
+	MetadataKeyRoutingKey    = "routing_key" // 路由键, 覆盖发布者缺省的路由键
+	MetadataKeyPriority      = "priority"    // 消息优先级, 需要队列启用优先级
+)
+
 type ackType int
 
 const (
@@ -25,6 +39,12 @@ func init() {
 // Message is the basic transfer unit.
 // Messages are emitted by Publishers and received by Subscribers.
 type Message struct {
+	// UUID is a unique identifier of the message, it can be used for deduplication.
+	UUID string
+
+	// Metadata contains the message metadata, e.g. correlation id, content type.
+	Metadata Metadata
+
 	// Payload is the message's payload.
 	Payload Payload
 
@@ -39,15 +59,37 @@ type Message struct {
 	ctx context.Context
 }
 
-// NewMessage creates a new Message with payload.
+// NewMessage creates a new Message with payload and a random UUID.
 func NewMessage(payload Payload) *Message {
+	return NewMessageWithUUID(NewUUID(), payload)
+}
+
+// NewUUID returns a new random UUID string.
+func NewUUID() string {
+	return uuid.NewString()
+}
+
+// NewMessageWithUUID creates a new Message with given uuid and payload.
+func NewMessageWithUUID(uuid string, payload Payload) *Message {
 	return &Message{
-		Payload: payload,
-		ack:     make(chan struct{}),
-		noAck:   make(chan struct{}),
+		UUID:     uuid,
+		Metadata: make(Metadata),
+		Payload:  payload,
+		ack:      make(chan struct{}),
+		noAck:    make(chan struct{}),
 	}
 }
 
+// Copy copies all message without Acks/Nacks.
+// The context is not propagated to the copy.
+func (m *Message) Copy() *Message {
+	msg := NewMessageWithUUID(m.UUID, m.Payload)
+	for k, v := range m.Metadata {
+		msg.Metadata.Set(k, v)
+	}
+	return msg
+}
+
 // Ack sends message's acknowledgement.
 //
 // Ack is not blocking.
@@ -145,3 +187,16 @@ func (m *Message) Context() context.Context {
 func (m *Message) SetContext(ctx context.Context) {
 	m.ctx = ctx
 }
+
+// Get returns the metadata value for the key. If the key is not found, an empty string is returned.
+func (m Metadata) Get(key string) string {
+	if v, ok := m[key]; ok {
+		return v
+	}
+	return ""
+}
+
+// Set sets the metadata key to value.
+func (m Metadata) Set(key, value string) {
+	m[key] = value
+}
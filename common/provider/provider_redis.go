@@ -1,6 +1,9 @@
 package provider
 
 import (
+	"context"
+	"time"
+
 	"github.com/hdget/sdk/common/protobuf"
 )
 
@@ -15,7 +18,23 @@ type RedisCommand struct {
 	Args []interface{}
 }
 
+// RedisStreamMessage stream中的消息
+type RedisStreamMessage struct {
+	ID     string
+	Values map[string]string
+}
+
+// RedisMessage 订阅收到的消息
+type RedisMessage struct {
+	Channel string
+	Pattern string // 通过PSubscribe订阅时匹配的模式
+	Data    []byte
+}
+
 type RedisClient interface {
+	// WithContext 返回使用ctx执行命令的客户端, 获取连接和执行命令都会遵循ctx的超时和取消
+	WithContext(ctx context.Context) RedisClient
+
 	// general purpose
 	Del(key string) error
 	Dels(keys []string) error
@@ -27,6 +46,8 @@ type RedisClient interface {
 	Ttl(key string) (int64, error)
 	Pipeline(commands []*RedisCommand) (map[int]any, error)
 	Ping() error
+	// Scan 按游标遍历key, cursor为0时开始遍历, 返回的cursor为0时遍历结束, 集群模式下只遍历其中一个节点
+	Scan(cursor uint64, match string, count int) (uint64, []string, error)
 
 	// Set operations
 	Set(key string, value interface{}) error
@@ -52,6 +73,7 @@ type RedisClient interface {
 	HDel(key string, field interface{}) (int, error)
 	HDels(key string, fields []interface{}) (int, error)
 	HLen(key string) (int, error)
+	HScan(key string, cursor uint64, match string, count int) (uint64, map[string]string, error)
 
 	// set
 	SIsMember(key string, member interface{}) (bool, error)
@@ -61,6 +83,7 @@ type RedisClient interface {
 	SUnion(keys []string) ([]string, error)
 	SDiff(keys []string) ([]string, error)
 	SMembers(key string) ([]string, error)
+	SScan(key string, cursor uint64, match string, count int) (uint64, []string, error)
 
 	// zset
 	ZAdd(key string, score int64, member interface{}) error
@@ -72,6 +95,10 @@ type RedisClient interface {
 	ZInterstore(newKey string, keys ...interface{}) (int64, error)
 	ZIncrBy(key string, increment int64, member interface{}) error
 	ZRem(destKey string, members ...interface{}) (int64, error)
+	ZAddFloat64(key string, score float64, member interface{}) error
+	ZScoreFloat64(key string, member interface{}) (float64, error)
+	ZIncrByFloat64(key string, increment float64, member interface{}) (float64, error)
+	ZScan(key string, cursor uint64, match string, count int) (uint64, map[string]float64, error)
 
 	// list
 	LPush(key string, values ...any) error
@@ -82,6 +109,24 @@ type RedisClient interface {
 	LLen(key string) (int64, error)
 	Eval(scriptContent string, keys []interface{}, args []interface{}) (interface{}, error)
 
+	// stream
+	// XAdd 添加消息, id为空时由redis生成, 返回消息id
+	XAdd(key string, id string, values map[string]any) (string, error)
+	XLen(key string) (int64, error)
+	XDel(key string, ids ...string) (int64, error)
+	// XGroupCreate 创建消费组, stream不存在时自动创建, 消费组已存在时不报错
+	XGroupCreate(key, group, start string) error
+	// XReadGroup 读取消费组中未投递过的消息, block大于0时最多阻塞block, 超时返回空
+	XReadGroup(key, group, consumer string, count int, block time.Duration) ([]*RedisStreamMessage, error)
+	XAck(key, group string, ids ...string) (int64, error)
+
+	// pub/sub
+	Publish(channel string, message any) (int64, error)
+	// Subscribe 订阅频道, ctx取消或连接断开后返回的channel会被关闭
+	Subscribe(ctx context.Context, channels ...string) (<-chan *RedisMessage, error)
+	// PSubscribe 按模式订阅频道, 例如: news.*
+	PSubscribe(ctx context.Context, patterns ...string) (<-chan *RedisMessage, error)
+
 	// redis bloom
 	BfExists(key string, item string) (exists bool, err error)
 	BfAdd(key string, item string) (exists bool, err error)
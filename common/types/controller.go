@@ -25,11 +25,20 @@ type UpdateRequest[TUpdateModel any] struct {
 type QueryRequest[TFilter any] struct {
 	Filters TFilter             `json:"filters,omitempty"`
 	List    *protobuf.ListParam `json:"list,omitempty"`
+	Cursor  *CursorParam        `json:"cursor,omitempty"` // 按游标分页, 指定时忽略List
 }
 
 type QueryResponse[TResult any] struct {
-	Total int64     `json:"total"`
-	Items []TResult `json:"items"`
+	Total      int64     `json:"total"`
+	Items      []TResult `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"` // 按游标分页时下一页的游标
+	HasMore    bool      `json:"has_more,omitempty"`    // 按游标分页时是否还有下一页
+}
+
+// CursorParam 按游标分页, Cursor为上一页返回的next_cursor, 查询第一页时为空
+type CursorParam struct {
+	Cursor string `json:"cursor,omitempty"`
+	Limit  int64  `json:"limit,omitempty"` // 每页数量
 }
 
 // ============================================================
@@ -60,4 +69,5 @@ type ScopedQueryRequest[TScopedKey KeyType, TFilter any] struct {
 	ScopedKey TScopedKey          `json:"scoped_key"`
 	Filters   TFilter             `json:"filters,omitempty"`
 	List      *protobuf.ListParam `json:"list,omitempty"`
+	Cursor    *CursorParam        `json:"cursor,omitempty"` // 按游标分页, 指定时忽略List
 }
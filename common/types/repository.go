@@ -27,6 +27,11 @@ type RepoList[TFilter any, TModel any] interface {
 	List(ctx context.Context, filter TFilter, list ...*protobuf.ListParam) ([]TModel, error)
 }
 
+// RepoCursorList 按游标分页, 返回下一页的游标, 没有下一页时为空
+type RepoCursorList[TFilter any, TModel any] interface {
+	ListByCursor(ctx context.Context, filter TFilter, cursor *CursorParam) ([]TModel, string, error)
+}
+
 type RepoQuery[TFilter any, TModel any] interface {
 	RepoCount[TFilter]
 	RepoList[TFilter, TModel]
@@ -3,9 +3,12 @@ module github.com/hdget/sdk
 go 1.24.0
 
 require (
+	github.com/alicebob/miniredis/v2 v2.35.0
+	github.com/gomodule/redigo v1.9.2
 	github.com/hdget/sdk/common v0.1.21
 	github.com/hdget/sdk/providers/config/koanf v0.0.13
 	github.com/hdget/sdk/providers/logger/zerolog v0.0.6
+	github.com/hdget/sdk/providers/redis/redigo v0.0.0-00010101000000-000000000000
 	github.com/hdget/utils v0.2.3
 	github.com/pkg/errors v0.9.1
 	go.uber.org/fx v1.24.0
@@ -14,6 +17,7 @@ require (
 require (
 	github.com/fsnotify/fsnotify v1.9.0 // indirect
 	github.com/go-viper/mapstructure/v2 v2.4.0 // indirect
+	github.com/hdget/utils/paginator v0.0.1 // indirect
 	github.com/knadh/koanf/maps v0.1.2 // indirect
 	github.com/knadh/koanf/parsers/toml/v2 v2.2.0 // indirect
 	github.com/knadh/koanf/providers/env/v2 v2.0.0 // indirect
@@ -27,6 +31,7 @@ require (
 	github.com/natefinch/lumberjack v2.0.0+incompatible // indirect
 	github.com/pelletier/go-toml/v2 v2.2.4 // indirect
 	github.com/rs/zerolog v1.33.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 	go.uber.org/dig v1.19.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.27.1 // indirect
@@ -39,3 +44,5 @@ retract v1.0.90
 replace github.com/hdget/sdk/providers/config/koanf => ./providers/config/koanf
 
 replace github.com/hdget/sdk/providers/logger/zerolog => ./providers/logger/zerolog
+
+replace github.com/hdget/sdk/providers/redis/redigo => ./providers/redis/redigo
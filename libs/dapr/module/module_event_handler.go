@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/dapr/go-sdk/service/common"
+	"github.com/hdget/sdk/common/bizctx"
 	"github.com/hdget/sdk/common/provider"
 	panicUtils "github.com/hdget/utils/panic"
 	"github.com/hdget/utils/text"
@@ -62,13 +63,15 @@ func (h eventHandlerImpl) GetEventFunction(logger provider.Logger) common.TopicE
 			fnResult.retry, fnResult.err = h.fn(ctx, event.RawData)
 		}()
 
+		// 日志中包含dapr传递的trace id等元数据
+		ctxLogger := logger.Ctx(bizctx.NewFromIncomingGrpcContext(ctx))
 		select {
 		case <-ctxWithTimeout.Done(): // 统一用context控制
-			logger.Error("event processing timeout, discard message", "data", text.Truncate(event.RawData, 100))
+			ctxLogger.Error("event processing timeout, discard message", "data", text.Truncate(event.RawData, 100))
 			return false, ctxWithTimeout.Err()
 		case quitResult := <-quit:
 			if quitResult.err != nil {
-				logger.Error("event processing", "data", text.Truncate(event.RawData, 100), "err", quitResult.err)
+				ctxLogger.Error("event processing", "data", text.Truncate(event.RawData, 100), "err", quitResult.err)
 			}
 			return quitResult.retry, quitResult.err
 		}
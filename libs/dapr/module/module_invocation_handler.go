@@ -58,10 +58,11 @@ func (h invocationHandlerImpl) GetInvokeFunction(logger provider.Logger) common.
 			}
 		}()
 
-		result, err := h.fn(bizctx.NewFromIncomingGrpcContext(ctx), event.Data)
+		bizCtx := bizctx.NewFromIncomingGrpcContext(ctx)
+		result, err := h.fn(bizCtx, event.Data)
 		if err != nil {
 			mInfo := h.module.GetInfo()
-			logger.Error("service invoke", "dir", mInfo.Dir, "module", mInfo.Name, "handler", reflectUtils.GetFuncName(h.fn), "err", err, "req", text.Truncate(event.Data, 100))
+			logger.Ctx(bizCtx).Error("service invoke", "dir", mInfo.Dir, "module", mInfo.Name, "handler", reflectUtils.GetFuncName(h.fn), "err", err, "req", text.Truncate(event.Data, 100))
 			return h.replyError(err)
 		}
 
@@ -0,0 +1,170 @@
+package sqlboiler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aarondl/sqlboiler/v4/queries/qm"
+	"github.com/hdget/sdk/common/types"
+	"github.com/pkg/errors"
+)
+
+// cursorValue 游标中的一个排序字段值, time.Time单独保存, 解码后仍为time.Time
+type cursorValue struct {
+	V any        `json:"v"`
+	T *time.Time `json:"t,omitempty"`
+}
+
+const (
+	defaultCursorLimit = 20
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// GetCursorQueryMods 按游标分页的QueryMods, 包括游标之后的查询条件, 排序和limit
+// 游标之后的条件按orderBy中的排序字段生成, 例如: WHERE (col1, col2) > (?, ?), 排序字段不能为NULL, 最后一个排序字段需要唯一, 例如: id
+// limit多取一条用于判断是否还有下一页, 查询结果通过CursorResult截取
+func GetCursorQueryMods(orderBy *OrderByHelper, cursor *types.CursorParam) ([]qm.QueryMod, error) {
+	if orderBy == nil || len(orderBy.columns) == 0 {
+		return nil, errors.New("cursor pagination requires order by columns")
+	}
+
+	mods := make([]qm.QueryMod, 0, 3)
+	if cursor != nil && cursor.Cursor != "" {
+		values, err := DecodeCursor(cursor.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(values) != len(orderBy.columns) {
+			return nil, errors.Wrapf(errInvalidCursor, "expect %d values, got %d", len(orderBy.columns), len(values))
+		}
+
+		clause, args := orderBy.after(values)
+		mods = append(mods, qm.Where(clause, args...))
+	}
+
+	return append(mods, orderBy.Output(), qm.Limit(getCursorLimit(cursor)+1)), nil
+}
+
+// CursorResult 截取GetCursorQueryMods多取的一条记录, 返回当前页的记录, 下一页的游标和是否还有下一页
+// values返回记录的排序字段值, 顺序和OrderByHelper中的一致, 例如: func(item *models.Order) []any { return []any{item.CreatedAt, item.ID} }
+func CursorResult[T any](items []T, cursor *types.CursorParam, values func(item T) []any) ([]T, string, bool) {
+	limit := getCursorLimit(cursor)
+	if len(items) <= limit {
+		return items, "", false
+	}
+
+	items = items[:limit]
+	return items, EncodeCursor(values(items[limit-1])...), true
+}
+
+// EncodeCursor 将排序字段值编码为不透明的游标
+func EncodeCursor(values ...any) string {
+	cursorValues := make([]cursorValue, len(values))
+	for i, v := range values {
+		if t, ok := v.(time.Time); ok {
+			cursorValues[i].T = &t
+		} else {
+			cursorValues[i].V = v
+		}
+	}
+
+	data, _ := json.Marshal(cursorValues)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor 解码游标中的排序字段值, 整数解码为int64
+func DecodeCursor(cursor string) ([]any, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.Wrap(errInvalidCursor, err.Error())
+	}
+
+	var cursorValues []cursorValue
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&cursorValues); err != nil {
+		return nil, errors.Wrap(errInvalidCursor, err.Error())
+	}
+
+	values := make([]any, len(cursorValues))
+	for i, cv := range cursorValues {
+		switch v := cv.V.(type) {
+		case json.Number:
+			if n, e := v.Int64(); e == nil {
+				values[i] = n
+			} else if f, e := v.Float64(); e == nil {
+				values[i] = f
+			} else {
+				return nil, errors.Wrapf(errInvalidCursor, "invalid number: %s", v)
+			}
+		case nil:
+			if cv.T == nil {
+				return nil, errors.Wrapf(errInvalidCursor, "empty value at %d", i)
+			}
+			values[i] = *cv.T
+		default:
+			values[i] = v
+		}
+	}
+	return values, nil
+}
+
+// after 游标之后的查询条件, 排序方向一致时使用行比较, 否则展开为: c1 > ? OR (c1 = ? AND c2 < ?)
+func (o OrderByHelper) after(values []any) (string, []any) {
+	sameDirection := true
+	for _, col := range o.columns[1:] {
+		if col.desc != o.columns[0].desc {
+			sameDirection = false
+			break
+		}
+	}
+
+	if sameDirection {
+		names := make([]string, len(o.columns))
+		for i, col := range o.columns {
+			names[i] = col.name
+		}
+		if len(o.columns) == 1 {
+			return fmt.Sprintf("%s %s ?", names[0], o.columns[0].operator()), values
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(names, ", "), o.columns[0].operator(), placeholders), values
+	}
+
+	var (
+		conditions []string
+		args       []any
+	)
+	for i, col := range o.columns {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, fmt.Sprintf("%s = ?", o.columns[j].name))
+			args = append(args, values[j])
+		}
+		parts = append(parts, fmt.Sprintf("%s %s ?", col.name, col.operator()))
+		args = append(args, values[i])
+		conditions = append(conditions, fmt.Sprintf("(%s)", strings.Join(parts, " AND ")))
+	}
+	return fmt.Sprintf("(%s)", strings.Join(conditions, " OR ")), args
+}
+
+// operator 游标之后的记录和游标的比较方式
+func (c orderColumn) operator() string {
+	if c.desc {
+		return "<"
+	}
+	return ">"
+}
+
+func getCursorLimit(cursor *types.CursorParam) int {
+	if cursor == nil || cursor.Limit <= 0 {
+		return defaultCursorLimit
+	}
+	return int(cursor.Limit)
+}
@@ -0,0 +1,185 @@
+package sqlboiler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aarondl/sqlboiler/v4/queries"
+	"github.com/hdget/sdk/common/types"
+	"github.com/pkg/errors"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 8, 30, 0, 123456789, time.FixedZone("CST", 8*3600))
+	testCases := []struct {
+		name   string
+		values []any
+		want   []any
+	}{
+		{name: "int64", values: []any{int64(42)}, want: []any{int64(42)}},
+		{name: "int", values: []any{7}, want: []any{int64(7)}},
+		{name: "negative", values: []any{int64(-1)}, want: []any{int64(-1)}},
+		{name: "float", values: []any{1.5}, want: []any{1.5}},
+		{name: "string", values: []any{"a,b"}, want: []any{"a,b"}},
+		{name: "time", values: []any{ts}, want: []any{ts}},
+		{name: "mixed", values: []any{ts, "a", int64(1)}, want: []any{ts, "a", int64(1)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DecodeCursor(EncodeCursor(tc.values...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("DecodeCursor() = %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if want, ok := tc.want[i].(time.Time); ok {
+					if v, ok := got[i].(time.Time); !ok || !v.Equal(want) {
+						t.Fatalf("value %d = %#v, want %v", i, got[i], want)
+					}
+					continue
+				}
+				if !reflect.DeepEqual(got[i], tc.want[i]) {
+					t.Fatalf("value %d = %#v, want %#v", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, cursor := range []string{
+		"!!!",
+		EncodeCursor() + "!",
+		"bm90IGpzb24",                 // not json
+		"W3t9XQ",                      // [{}]
+		"eyJ2IjoxfQ",                  // {"v":1}
+		"W3sidiI6MWUxMDAwfV0",         // [{"v":1e1000}]
+		"W3sidCI6Im5vdCBhIHRpbWUifV0", // [{"t":"not a time"}]
+	} {
+		if _, err := DecodeCursor(cursor); !errors.Is(err, errInvalidCursor) {
+			t.Errorf("DecodeCursor(%q) err = %v, want %v", cursor, err, errInvalidCursor)
+		}
+	}
+}
+
+func TestGetCursorQueryMods(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		orderBy  *OrderByHelper
+		cursor   *types.CursorParam
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:    "first page",
+			orderBy: Psql().OrderBy().Desc("id"),
+			wantSQL: `SELECT * FROM "orders" ORDER BY "id" DESC LIMIT 21;`,
+		},
+		{
+			name:     "single column",
+			orderBy:  Psql().OrderBy().Asc("id"),
+			cursor:   &types.CursorParam{Cursor: EncodeCursor(int64(10)), Limit: 5},
+			wantSQL:  `SELECT * FROM "orders" WHERE ("id" > $1) ORDER BY "id" ASC LIMIT 6;`,
+			wantArgs: []any{int64(10)},
+		},
+		{
+			name:     "same direction",
+			orderBy:  Psql().OrderBy().Desc("o.created_at").Desc("o.id"),
+			cursor:   &types.CursorParam{Cursor: EncodeCursor(ts, int64(10))},
+			wantSQL:  `SELECT * FROM "orders" WHERE (("o"."created_at", "o"."id") < ($1, $2)) ORDER BY "o"."created_at" DESC,"o"."id" DESC LIMIT 21;`,
+			wantArgs: []any{ts, int64(10)},
+		},
+		{
+			name:     "mixed directions",
+			orderBy:  Psql().OrderBy().Desc("score").Asc("name").Asc("id"),
+			cursor:   &types.CursorParam{Cursor: EncodeCursor(1.5, "a", int64(10))},
+			wantSQL:  `SELECT * FROM "orders" WHERE ((("score" < $1) OR ("score" = $2 AND "name" > $3) OR ("score" = $4 AND "name" = $5 AND "id" > $6))) ORDER BY "score" DESC,"name" ASC,"id" ASC LIMIT 21;`,
+			wantArgs: []any{1.5, 1.5, "a", 1.5, "a", int64(10)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mods, err := GetCursorQueryMods(tc.orderBy, tc.cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sql, args := queries.BuildQuery(newTestQuery(mods...))
+			if sql != tc.wantSQL {
+				t.Fatalf("query = %s, want %s", sql, tc.wantSQL)
+			}
+			if len(args) != len(tc.wantArgs) {
+				t.Fatalf("args = %v, want %v", args, tc.wantArgs)
+			}
+			for i := range args {
+				if want, ok := tc.wantArgs[i].(time.Time); ok {
+					if v, ok := args[i].(time.Time); !ok || !v.Equal(want) {
+						t.Fatalf("arg %d = %#v, want %v", i, args[i], want)
+					}
+				} else if !reflect.DeepEqual(args[i], tc.wantArgs[i]) {
+					t.Fatalf("arg %d = %#v, want %#v", i, args[i], tc.wantArgs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetCursorQueryModsInvalid(t *testing.T) {
+	if _, err := GetCursorQueryMods(nil, nil); err == nil {
+		t.Fatal("GetCursorQueryMods() without order by, want error")
+	}
+
+	orderBy := Psql().OrderBy().Desc("created_at").Desc("id")
+	for _, cursor := range []string{"!!!", EncodeCursor(int64(1)), EncodeCursor(int64(1), int64(2), int64(3))} {
+		_, err := GetCursorQueryMods(orderBy, &types.CursorParam{Cursor: cursor})
+		if !errors.Is(err, errInvalidCursor) {
+			t.Errorf("GetCursorQueryMods(%q) err = %v, want %v", cursor, err, errInvalidCursor)
+		}
+	}
+}
+
+func TestCursorResult(t *testing.T) {
+	type item struct {
+		id   int64
+		name string
+	}
+	values := func(i item) []any { return []any{i.name, i.id} }
+
+	newItems := func(n int) []item {
+		items := make([]item, n)
+		for i := range items {
+			items[i] = item{id: int64(i + 1), name: string(rune('a' + i%26))}
+		}
+		return items
+	}
+
+	testCases := []struct {
+		name       string
+		items      []item
+		cursor     *types.CursorParam
+		wantLen    int
+		wantMore   bool
+		wantCursor string
+	}{
+		{name: "empty", items: nil, cursor: &types.CursorParam{Limit: 2}, wantLen: 0},
+		{name: "less than limit", items: newItems(1), cursor: &types.CursorParam{Limit: 2}, wantLen: 1},
+		{name: "equal to limit", items: newItems(2), cursor: &types.CursorParam{Limit: 2}, wantLen: 2},
+		{name: "has more", items: newItems(3), cursor: &types.CursorParam{Limit: 2}, wantLen: 2, wantMore: true, wantCursor: EncodeCursor("b", int64(2))},
+		{name: "default limit", items: newItems(defaultCursorLimit + 1), wantLen: defaultCursorLimit, wantMore: true, wantCursor: EncodeCursor("t", int64(20))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			items, next, more := CursorResult(tc.items, tc.cursor, values)
+			if len(items) != tc.wantLen || more != tc.wantMore || next != tc.wantCursor {
+				t.Fatalf("CursorResult() = %d items, %q, %v, want %d items, %q, %v", len(items), next, more, tc.wantLen, tc.wantCursor, tc.wantMore)
+			}
+		})
+	}
+}
@@ -5,11 +5,13 @@ import (
 
 	"github.com/aarondl/sqlboiler/v4/boil"
 	"github.com/hdget/sdk/common/bizctx"
+	"github.com/hdget/sdk/common/provider"
 )
 
 type Db interface {
 	Copier() DbCopier
 	Executor() boil.Executor
+	Repository(options ...RepositoryOption) Repository // 乐观锁和软删除
 }
 
 type dbImpl struct {
@@ -17,7 +19,11 @@ type dbImpl struct {
 	copier DbCopier
 }
 
+// Executor 依次使用ctx中DbClient的事务, NewTransactor的事务和全局的数据库
 func (impl *dbImpl) Executor() boil.Executor {
+	if tx, ok := impl.ctx.Value(provider.TxCtxKey{}).(boil.Executor); ok {
+		return tx
+	}
 	if tx, ok := bizctx.GetTransactor(impl.ctx).GetTx().(boil.Executor); ok {
 		return tx
 	}
@@ -27,3 +33,7 @@ func (impl *dbImpl) Executor() boil.Executor {
 func (impl *dbImpl) Copier() DbCopier {
 	return impl.copier
 }
+
+func (impl *dbImpl) Repository(options ...RepositoryOption) Repository {
+	return newRepository(impl.ctx, impl.Executor(), options...)
+}
@@ -3,6 +3,7 @@ module github.com/hdget/sdk/libs/sqlboiler
 go 1.24.0
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/aarondl/sqlboiler/v4 v4.19.5
 	github.com/elliotchance/pie/v2 v2.9.1
 	github.com/hdget/sdk/common v0.1.21
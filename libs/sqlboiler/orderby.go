@@ -7,17 +7,27 @@ import (
 )
 
 type OrderByHelper struct {
-	tokens []string
-	quote  string
+	tokens  []string
+	columns []orderColumn // 排序字段, 用于按游标分页
+	quote   string
+}
+
+type orderColumn struct {
+	name string // 已经quote的字段名
+	desc bool
 }
 
 func (o *OrderByHelper) Desc(col string) *OrderByHelper {
-	o.tokens = append(o.tokens, fmt.Sprintf("%s DESC", escape(col, o.quote, true)))
+	name := escape(col, o.quote, true)
+	o.tokens = append(o.tokens, fmt.Sprintf("%s DESC", name))
+	o.columns = append(o.columns, orderColumn{name: name, desc: true})
 	return o
 }
 
 func (o *OrderByHelper) Asc(col string) *OrderByHelper {
-	o.tokens = append(o.tokens, fmt.Sprintf("%s ASC", escape(col, o.quote, true)))
+	name := escape(col, o.quote, true)
+	o.tokens = append(o.tokens, fmt.Sprintf("%s ASC", name))
+	o.columns = append(o.columns, orderColumn{name: name})
 	return o
 }
 
@@ -0,0 +1,167 @@
+package sqlboiler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/aarondl/sqlboiler/v4/boil"
+	"github.com/aarondl/sqlboiler/v4/queries"
+	"github.com/aarondl/sqlboiler/v4/queries/qm"
+	"github.com/pkg/errors"
+)
+
+// Repository 基于约定字段的通用写操作, q为生成代码的查询对象, 例如: models.Orders(mods...).Query
+// 所有操作缺省同时更新updated_at, 可以通过WithUpdatedAt指定其他字段或者不更新
+// 生成代码中的查询不会自动排除已删除的记录, 需要通过NotDeleted或者Scoped添加条件
+type Repository interface {
+	// UpdateWithVersion 乐观锁更新, 只更新id和version都匹配的记录, version自动加1, 没有记录被更新时返回*VersionConflictError
+	UpdateWithVersion(q *queries.Query, id, version int64, cols map[string]any) error
+	// SoftDelete 将未删除记录的deleted_at设置为当前时间, 返回删除的记录数
+	SoftDelete(q *queries.Query) (int64, error)
+	// Restore 将已删除记录的deleted_at设置为NULL, 返回恢复的记录数
+	Restore(q *queries.Query) (int64, error)
+}
+
+// VersionConflictError 乐观锁冲突, 记录已被修改或者不存在
+type VersionConflictError struct {
+	Id      int64
+	Version int64
+}
+
+// RepositoryOption 仓库选项
+type RepositoryOption func(impl *repositoryImpl)
+
+type repositoryImpl struct {
+	ctx       context.Context
+	executor  boil.Executor
+	updatedAt string // 写操作同时更新的更新时间字段, 为空时不更新
+}
+
+// 约定的字段名, 和DbCopier中忽略的字段一致
+const (
+	columnId        = "id"
+	columnVersion   = "version"
+	columnDeletedAt = "deleted_at"
+	columnUpdatedAt = "updated_at"
+)
+
+// ErrVersionConflict 可以通过errors.Is判断是否为乐观锁冲突
+var ErrVersionConflict = errors.New("version conflict")
+
+func newRepository(ctx context.Context, executor boil.Executor, options ...RepositoryOption) Repository {
+	impl := &repositoryImpl{ctx: ctx, executor: executor, updatedAt: columnUpdatedAt}
+	for _, option := range options {
+		option(impl)
+	}
+	return impl
+}
+
+// WithUpdatedAt 指定写操作同时更新的更新时间字段, 为空时不更新, 用于没有updated_at字段的表
+func WithUpdatedAt(column string) RepositoryOption {
+	return func(impl *repositoryImpl) {
+		impl.updatedAt = column
+	}
+}
+
+// NotDeleted 只查询未删除的记录, 连表查询时需要指定表名
+func NotDeleted(table ...string) qm.QueryMod {
+	return qm.Where(fmt.Sprintf("%s IS NULL", qualify(columnDeletedAt, table...)))
+}
+
+// Scoped 在mods之前加上NotDeleted, 例如: models.Orders(sqlboiler.Scoped(mods...)...)
+// 连表查询时需要使用NotDeleted指定表名
+func Scoped(mods ...qm.QueryMod) []qm.QueryMod {
+	return append([]qm.QueryMod{NotDeleted()}, mods...)
+}
+
+// OnlyDeleted 只查询已删除的记录, 连表查询时需要指定表名
+func OnlyDeleted(table ...string) qm.QueryMod {
+	return qm.Where(fmt.Sprintf("%s IS NOT NULL", qualify(columnDeletedAt, table...)))
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("version conflict, id: %d, version: %d", e.Id, e.Version)
+}
+
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+func (impl *repositoryImpl) UpdateWithVersion(q *queries.Query, id, version int64, cols map[string]any) error {
+	updateCols := make(map[string]any, len(cols)+1)
+	for k, v := range cols {
+		updateCols[k] = v
+	}
+	updateCols[columnVersion] = version + 1
+	impl.withUpdateTime(updateCols, time.Now())
+
+	qm.Apply(q, qm.Where(fmt.Sprintf("%s = ? AND %s = ?", columnId, columnVersion), id, version))
+	affected, err := impl.updateAll(q, updateCols)
+	if err != nil {
+		return errors.Wrap(err, "update with version")
+	}
+
+	if affected == 0 {
+		return &VersionConflictError{Id: id, Version: version}
+	}
+	return nil
+}
+
+func (impl *repositoryImpl) SoftDelete(q *queries.Query) (int64, error) {
+	// 删除时间和更新时间相同
+	now := time.Now()
+	cols := impl.withUpdateTime(map[string]any{columnDeletedAt: now.In(boil.GetLocation())}, now)
+
+	qm.Apply(q, NotDeleted())
+	affected, err := impl.updateAll(q, cols)
+	if err != nil {
+		return 0, errors.Wrap(err, "soft delete")
+	}
+	return affected, nil
+}
+
+func (impl *repositoryImpl) Restore(q *queries.Query) (int64, error) {
+	qm.Apply(q, OnlyDeleted())
+	affected, err := impl.updateAll(q, impl.withUpdateTime(map[string]any{columnDeletedAt: nil}, time.Now()))
+	if err != nil {
+		return 0, errors.Wrap(err, "restore")
+	}
+	return affected, nil
+}
+
+// withUpdateTime 设置了更新时间字段时和WithUpdateTime一样把now加入cols
+func (impl *repositoryImpl) withUpdateTime(cols map[string]any, now time.Time) map[string]any {
+	if impl.updatedAt != "" {
+		cols[impl.updatedAt] = now.In(boil.GetLocation())
+	}
+	return cols
+}
+
+// updateAll 和生成代码中的UpdateAll一致, 执行器支持context时使用ctx
+func (impl *repositoryImpl) updateAll(q *queries.Query, cols map[string]any) (int64, error) {
+	queries.SetUpdate(q, cols)
+
+	var (
+		result sql.Result
+		err    error
+	)
+	if exec, ok := impl.executor.(boil.ContextExecutor); ok {
+		result, err = q.ExecContext(impl.ctx, exec)
+	} else {
+		result, err = q.Exec(impl.executor)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func qualify(column string, table ...string) string {
+	if len(table) > 0 && table[0] != "" {
+		return table[0] + "." + column
+	}
+	return column
+}
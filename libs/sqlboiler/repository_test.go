@@ -0,0 +1,195 @@
+package sqlboiler
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aarondl/sqlboiler/v4/drivers"
+	"github.com/aarondl/sqlboiler/v4/queries"
+	"github.com/aarondl/sqlboiler/v4/queries/qm"
+	"github.com/pkg/errors"
+)
+
+// recentTime 匹配刚刚生成的时间参数, 记录匹配到的值
+type recentTime struct {
+	value *time.Time
+}
+
+func (a recentTime) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	if !ok || time.Since(t) > time.Minute {
+		return false
+	}
+	if a.value != nil {
+		*a.value = t
+	}
+	return true
+}
+
+// newTestQuery 和生成代码中的查询对象一致, 例如: models.Orders(mods...).Query
+func newTestQuery(mods ...qm.QueryMod) *queries.Query {
+	q := &queries.Query{}
+	queries.SetDialect(q, &drivers.Dialect{LQ: '"', RQ: '"', UseIndexPlaceholders: true})
+	queries.SetFrom(q, `"orders"`)
+	qm.Apply(q, mods...)
+	return q
+}
+
+func newTestRepository(t *testing.T, options ...RepositoryOption) (Repository, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		_ = db.Close()
+	})
+	return newRepository(context.Background(), db, options...), mock
+}
+
+func TestUpdateWithVersion(t *testing.T) {
+	repo, mock := newTestRepository(t)
+	mock.ExpectExec(`UPDATE "orders" SET "name" = $1, "updated_at" = $2, "version" = $3 WHERE (id = $4 AND version = $5);`).
+		WithArgs("a", recentTime{}, int64(3), int64(1), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	cols := map[string]any{"name": "a"}
+	if err := repo.UpdateWithVersion(newTestQuery(), 1, 2, cols); err != nil {
+		t.Fatal(err)
+	}
+
+	// 不修改传入的cols
+	if !reflect.DeepEqual(cols, map[string]any{"name": "a"}) {
+		t.Fatalf("cols modified: %v", cols)
+	}
+}
+
+func TestUpdateWithVersionConflict(t *testing.T) {
+	repo, mock := newTestRepository(t)
+	mock.ExpectExec(`UPDATE "orders" SET "name" = $1, "updated_at" = $2, "version" = $3 WHERE (tenant_id = $4) AND (id = $5 AND version = $6);`).
+		WithArgs("a", recentTime{}, int64(3), int64(10), int64(1), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := repo.UpdateWithVersion(newTestQuery(qm.Where("tenant_id = ?", 10)), 1, 2, map[string]any{"name": "a"})
+	if !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("UpdateWithVersion err = %v, want %v", err, ErrVersionConflict)
+	}
+
+	var conflictErr *VersionConflictError
+	if !errors.As(err, &conflictErr) || conflictErr.Id != 1 || conflictErr.Version != 2 {
+		t.Fatalf("UpdateWithVersion err = %#v, want id 1 version 2", err)
+	}
+}
+
+// 执行出错不是乐观锁冲突
+func TestUpdateWithVersionError(t *testing.T) {
+	repo, mock := newTestRepository(t)
+	mock.ExpectExec(`UPDATE "orders" SET "updated_at" = $1, "version" = $2 WHERE (id = $3 AND version = $4);`).
+		WillReturnError(errors.New("connection reset"))
+
+	err := repo.UpdateWithVersion(newTestQuery(), 1, 2, nil)
+	if err == nil || errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("UpdateWithVersion err = %v, want exec error", err)
+	}
+}
+
+func TestSoftDelete(t *testing.T) {
+	repo, mock := newTestRepository(t)
+
+	var deletedAt, updatedAt time.Time
+	mock.ExpectExec(`UPDATE "orders" SET "deleted_at" = $1, "updated_at" = $2 WHERE (id = $3) AND (deleted_at IS NULL);`).
+		WithArgs(recentTime{value: &deletedAt}, recentTime{value: &updatedAt}, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	affected, err := repo.SoftDelete(newTestQuery(qm.Where("id = ?", int64(1))))
+	if err != nil || affected != 1 {
+		t.Fatalf("SoftDelete() = %d, %v, want 1", affected, err)
+	}
+	if !deletedAt.Equal(updatedAt) {
+		t.Fatalf("deleted_at = %v, updated_at = %v, want same time", deletedAt, updatedAt)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	repo, mock := newTestRepository(t)
+	mock.ExpectExec(`UPDATE "orders" SET "deleted_at" = $1, "updated_at" = $2 WHERE (id = $3) AND (deleted_at IS NOT NULL);`).
+		WithArgs(nil, recentTime{}, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	affected, err := repo.Restore(newTestQuery(qm.Where("id = ?", int64(1))))
+	if err != nil || affected != 0 {
+		t.Fatalf("Restore() = %d, %v, want 0", affected, err)
+	}
+}
+
+// 没有更新时间字段的表不更新, 也可以指定其他字段
+func TestWithUpdatedAt(t *testing.T) {
+	repo, mock := newTestRepository(t, WithUpdatedAt(""))
+	mock.ExpectExec(`UPDATE "orders" SET "name" = $1, "version" = $2 WHERE (id = $3 AND version = $4);`).
+		WithArgs("a", int64(3), int64(1), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "orders" SET "deleted_at" = $1 WHERE (id = $2) AND (deleted_at IS NULL);`).
+		WithArgs(recentTime{}, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE "orders" SET "deleted_at" = $1 WHERE (id = $2) AND (deleted_at IS NOT NULL);`).
+		WithArgs(nil, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.UpdateWithVersion(newTestQuery(), 1, 2, map[string]any{"name": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.SoftDelete(newTestQuery(qm.Where("id = ?", int64(1)))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Restore(newTestQuery(qm.Where("id = ?", int64(1)))); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, mock = newTestRepository(t, WithUpdatedAt("modified_at"))
+	mock.ExpectExec(`UPDATE "orders" SET "deleted_at" = $1, "modified_at" = $2 WHERE (id = $3) AND (deleted_at IS NULL);`).
+		WithArgs(recentTime{}, recentTime{}, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if _, err := repo.SoftDelete(newTestQuery(qm.Where("id = ?", int64(1)))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScoped(t *testing.T) {
+	testCases := []struct {
+		name string
+		mods []qm.QueryMod
+		want string
+	}{
+		{
+			name: "scoped",
+			mods: Scoped(qm.Where("id = ?", 1)),
+			want: `SELECT * FROM "orders" WHERE (deleted_at IS NULL) AND (id = $1);`,
+		},
+		{
+			name: "qualified",
+			mods: []qm.QueryMod{NotDeleted("o"), qm.Where("o.id = ?", 1)},
+			want: `SELECT * FROM "orders" WHERE (o.deleted_at IS NULL) AND (o.id = $1);`,
+		},
+		{
+			name: "only deleted",
+			mods: []qm.QueryMod{OnlyDeleted()},
+			want: `SELECT * FROM "orders" WHERE (deleted_at IS NOT NULL);`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, _ := queries.BuildQuery(newTestQuery(tc.mods...)); got != tc.want {
+				t.Fatalf("query = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
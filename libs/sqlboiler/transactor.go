@@ -2,66 +2,163 @@ package sqlboiler
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 
 	"github.com/aarondl/sqlboiler/v4/boil"
 	"github.com/hdget/sdk/common/bizctx"
+	"github.com/hdget/sdk/common/dbkit"
 	"github.com/hdget/sdk/common/provider"
 	loggerUtils "github.com/hdget/utils/logger"
+	"github.com/pkg/errors"
 )
 
 type Transactor interface {
 	Finalize(err error)
 }
 
+// TxOption 事务选项, 只对最外层事务有效
+type TxOption func(opts *sql.TxOptions)
+
 type trans struct {
-	tx     boil.Transactor
-	ctx    context.Context
-	errLog func(msg string, kvs ...any)
+	tx      *boundTx
+	counter bizctx.Transactor // NewTransactor时获取的引用计数, ctx不是bizctx时每次获取的都是新的计数
+	owner   bool              // 是否由当前Transactor开始的事务, 加入DbClient的事务时由DbClient负责提交
+	errLog  func(msg string, kvs ...any)
+}
+
+// boundTx NewTransactor通过DbClient.RunInTransaction开始的事务, fn一直等到Finalize时才返回
+type boundTx struct {
+	boil.ContextExecutor
+	ctx    context.Context // RunInTransaction传给fn的ctx, 用于注册提交后的回调
+	done   chan error      // Finalize的错误, 不为空时回滚
+	result chan error      // RunInTransaction的返回值
+}
+
+// WithIsolation 指定事务的隔离级别
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(opts *sql.TxOptions) {
+		opts.Isolation = level
+	}
+}
+
+// WithReadOnly 只读事务
+func WithReadOnly() TxOption {
+	return func(opts *sql.TxOptions) {
+		opts.ReadOnly = true
+	}
+}
+
+// RunInTransaction 在db的事务中执行fn, fn中通过NewGdb(ctx)或者NewTdb(ctx)获取的Executor都使用该事务
+// ctx已在事务中时通过SAVEPOINT嵌套, 事务提交后执行的回调通过AfterCommit注册
+func RunInTransaction(ctx context.Context, db provider.DbClient, fn func(ctx context.Context) error, options ...TxOption) error {
+	if len(options) > 0 {
+		opts := &sql.TxOptions{}
+		for _, option := range options {
+			option(opts)
+		}
+		ctx = dbkit.WithTxOptions(ctx, opts)
+	}
+	return db.RunInTransaction(ctx, fn)
 }
 
-func NewTransactor(ctx context.Context, logger provider.Logger) (Transactor, error) {
+// AfterCommit 注册最外层事务提交后执行的回调, 例如: 清除缓存, 发布事件, 事务回滚时不执行
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	if _, ok := ctx.Value(provider.TxCtxKey{}).(boil.Executor); !ok {
+		if tx, ok := bizctx.GetTransactor(ctx).GetTx().(*boundTx); ok {
+			ctx = tx.ctx
+		}
+	}
+	dbkit.AfterCommit(ctx, fn)
+}
+
+// NewTransactor 通过全局数据库的DbClient.RunInTransaction开始事务, 通过Finalize提交或者回滚,
+// ctx已在RunInTransaction的事务中时加入该事务. 嵌套的NewTransactor需要使用bizctx.New()创建的ctx才能共用事务
+//
+// Deprecated: 使用RunInTransaction
+func NewTransactor(ctx context.Context, logger provider.Logger, options ...TxOption) (Transactor, error) {
 	errLog := loggerUtils.Error
 	if logger != nil {
 		errLog = logger.Error
 	}
 
-	t := bizctx.GetTransactor(ctx)
-	var transactor boil.Transactor
-	if v, ok := t.GetTx().(boil.Transactor); ok {
-		transactor = v
-	} else {
-		transactor, err := boil.BeginTx(context.Background(), nil)
+	if _, ok := ctx.Value(provider.TxCtxKey{}).(boil.Executor); ok {
+		return &trans{errLog: errLog}, nil
+	}
+
+	counter := bizctx.GetTransactor(ctx)
+	tx, ok := counter.GetTx().(*boundTx)
+	if !ok {
+		db, ok := boil.GetDB().(provider.DbClient)
+		if !ok {
+			return nil, errors.New("boil db is not a provider.DbClient, use RunInTransaction instead")
+		}
+
+		var err error
+		tx, err = beginTx(ctx, db, options...)
 		if err != nil {
 			return nil, err
 		}
-		t.Ref(transactor)
 	}
+	counter.Ref(tx)
 
-	return &trans{tx: transactor, ctx: ctx, errLog: errLog}, nil
+	return &trans{tx: tx, counter: counter, owner: true, errLog: errLog}, nil
 }
 
 func (t *trans) Finalize(err error) {
-	tx := bizctx.GetTransactor(t.ctx)
+	if !t.owner {
+		return
+	}
+
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("panic: %v", r)
 		}
-		tx.Unref()
+		t.counter.Unref()
 	}()
 
-	if needFinalize := tx.ReachRoot(); !needFinalize {
+	if needFinalize := t.counter.ReachRoot(); !needFinalize {
 		return
 	}
 
+	t.tx.done <- err
+	e := <-t.tx.result
 	if err != nil {
-		e := t.tx.Rollback()
-		t.errLog("db roll back", "err", err, "rollback", e)
+		t.errLog("db roll back", "err", err)
 		return
 	}
 
-	e := t.tx.Commit()
 	if e != nil {
 		t.errLog("db commit", "err", e)
 	}
 }
+
+// beginTx 在新的goroutine中执行RunInTransaction, 事务开始后返回, 钩子, 事务选项和提交后的回调都和RunInTransaction一致
+func beginTx(ctx context.Context, db provider.DbClient, options ...TxOption) (*boundTx, error) {
+	started := make(chan *boundTx, 1)
+	tx := &boundTx{done: make(chan error, 1), result: make(chan error, 1)}
+	go func() {
+		tx.result <- RunInTransaction(ctx, db, func(txCtx context.Context) error {
+			executor, ok := txCtx.Value(provider.TxCtxKey{}).(boil.ContextExecutor)
+			if !ok {
+				started <- nil
+				return errors.New("transaction executor not found")
+			}
+
+			tx.ContextExecutor, tx.ctx = executor, txCtx
+			started <- tx
+			return <-tx.done
+		}, options...)
+	}()
+
+	select {
+	case bound := <-started:
+		if bound == nil {
+			return nil, <-tx.result
+		}
+		return bound, nil
+	case err := <-tx.result:
+		// 开始事务失败时fn没有执行
+		return nil, err
+	}
+}
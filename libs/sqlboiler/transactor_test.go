@@ -0,0 +1,194 @@
+package sqlboiler
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aarondl/sqlboiler/v4/boil"
+	"github.com/hdget/sdk/common/bizctx"
+	"github.com/hdget/sdk/common/dbkit"
+	"github.com/pkg/errors"
+)
+
+// newTestDB 使用DbClient设置boil的全局数据库, 测试结束后检查是否执行了所有预期的语句
+func newTestDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := boil.GetDB()
+	boil.SetDB(dbkit.NewClient(db, nil))
+	t.Cleanup(func() {
+		boil.SetDB(old)
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		_ = db.Close()
+	})
+	return mock
+}
+
+// 嵌套的NewTransactor使用同一个事务, 最外层Finalize时才提交
+func TestNewTransactorNested(t *testing.T) {
+	mock := newTestDB(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders SET a = 1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE orders SET a = 2").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx := bizctx.New()
+	outer, err := NewTransactor(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewGdb(ctx).Executor().Exec("UPDATE orders SET a = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	inner, err := NewTransactor(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewTdb(ctx).Executor().Exec("UPDATE orders SET a = 2"); err != nil {
+		t.Fatal(err)
+	}
+	inner.Finalize(nil)
+
+	if bizctx.GetTransactor(ctx).GetTx() == nil {
+		t.Fatal("transaction released by inner transactor")
+	}
+
+	outer.Finalize(nil)
+	if tx := bizctx.GetTransactor(ctx).GetTx(); tx != nil {
+		t.Fatalf("transaction not released: %v", tx)
+	}
+}
+
+func TestNewTransactorRollback(t *testing.T) {
+	mock := newTestDB(t)
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	ctx := bizctx.New()
+	outer, err := NewTransactor(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inner, err := NewTransactor(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner.Finalize(nil)
+	outer.Finalize(errors.New("failed"))
+
+	// 事务结束后重新开始新的事务
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	next, err := NewTransactor(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next.Finalize(nil)
+}
+
+// ctx不是bizctx时也能提交或者回滚事务
+func TestNewTransactorBackground(t *testing.T) {
+	mock := newTestDB(t)
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	for _, err := range []error{nil, errors.New("failed")} {
+		transactor, e := NewTransactor(context.Background(), nil)
+		if e != nil {
+			t.Fatal(e)
+		}
+		transactor.Finalize(err)
+	}
+}
+
+// 事务选项和提交后的回调和RunInTransaction一致
+func TestNewTransactorOptions(t *testing.T) {
+	mock := newTestDB(t)
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	ctx := bizctx.New()
+	transactor, err := NewTransactor(ctx, nil, WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	committed := false
+	AfterCommit(ctx, func(context.Context) { committed = true })
+	if committed {
+		t.Fatal("AfterCommit() should run after commit")
+	}
+	transactor.Finalize(nil)
+	if !committed {
+		t.Fatal("AfterCommit() callback not run after commit")
+	}
+
+	// 开始事务失败时返回错误
+	mock.ExpectBegin().WillReturnError(errors.New("connection refused"))
+	if _, err = NewTransactor(bizctx.New(), nil); err == nil {
+		t.Fatal("NewTransactor() with begin error, want error")
+	}
+}
+
+// 全局数据库不是DbClient时不能开始事务
+func TestNewTransactorNotDbClient(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := boil.GetDB()
+	boil.SetDB(db)
+	defer func() {
+		boil.SetDB(old)
+		_ = db.Close()
+	}()
+
+	if _, err = NewTransactor(bizctx.New(), nil); err == nil {
+		t.Fatal("NewTransactor() without DbClient, want error")
+	}
+}
+
+// ctx已在RunInTransaction的事务中时加入该事务, 由RunInTransaction提交
+func TestNewTransactorJoin(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE orders SET a = 1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = RunInTransaction(bizctx.New(), dbkit.NewClient(db, nil), func(ctx context.Context) error {
+		transactor, err := NewTransactor(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer transactor.Finalize(nil)
+
+		_, err = NewGdb(ctx).Executor().Exec("UPDATE orders SET a = 1")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,3 +3,10 @@ package loader
 type Loader interface {
 	Load() error
 }
+
+// Watcher 可以监听配置源变化的Loader
+type Watcher interface {
+	Watch(onChange func()) error // 配置源变化时调用onChange
+	WatchLoaded(l Loader) error  // 同时监听l重新加载时新增的配置源, 例如: 修改后新引用的配置文件
+	Unwatch() error              // 停止监听
+}
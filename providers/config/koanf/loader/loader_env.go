@@ -32,6 +32,11 @@ func (l *envLoader) Load() error {
 	return l.reader.Load(env.Provider(".", env.Opt{
 		Prefix: defaultEnvPrefix,
 		TransformFunc: func(k, v string) (string, any) {
+			// 密钥只用于解密配置, 不能出现在配置中
+			if k == EnvKeySecretKey {
+				return "", nil
+			}
+
 			// Transform the key.
 			k = strings.ReplaceAll(strings.ToLower(strings.TrimPrefix(k, defaultEnvPrefix)), "_", ".")
 
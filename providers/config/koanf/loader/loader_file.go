@@ -2,26 +2,40 @@ package loader
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
+	"github.com/knadh/koanf/parsers/json"
 	"github.com/knadh/koanf/parsers/toml/v2"
+	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/file"
 	"github.com/knadh/koanf/v2"
+	"github.com/pkg/errors"
 )
 
 type fileConfigLoader struct {
-	reader     *koanf.Koanf
-	app        string
-	env        string
-	configFile string
+	reader      *koanf.Koanf
+	app         string
+	env         string
+	configFile  string
+	loadedFiles []string              // 按加载顺序排列的所有配置文件
+	mutex       sync.Mutex            // 保护watchers
+	watchers    map[string]*file.File // 配置文件 => 监听
+	onChange    func()
 }
 
-const (
-	defaultConfigType = "toml"
-)
-
 var (
+	// supportedConfigTypes 支持的配置文件类型, 同时存在时按此顺序优先
+	supportedConfigTypes = []string{"toml", "yaml", "yml", "json"}
+
+	// includeKeys 配置文件中引用其他配置文件的配置项, 路径相对于当前配置文件
+	// 顶层的include/import为保留配置项, 加载完成后会被删除, 不能用于保存业务配置
+	//
+	//	include = ["../common/sdk.toml"]
+	includeKeys = []string{"include", "import"}
+
 	// the default config file search pattern
 	//
 	//	./config/app/<app>/<app>.test.toml
@@ -45,39 +59,172 @@ func NewFileConfigLoader(reader *koanf.Koanf, app, env, configFile string) Loade
 	}
 }
 
+// Load 按层次加载配置文件, 后加载的覆盖先加载的:
+//  1. 基础配置文件<app>.<ext>, 仅在指定了env时加载
+//  2. 环境配置文件<app>.<env>.<ext>或指定的配置文件
+//
+// 每个配置文件中include/import引用的文件在该文件之前加载
 func (l *fileConfigLoader) Load() error {
-	var err error
-	configFile := l.configFile
-	if configFile == "" {
-		configFile, err = l.findConfigFile()
+	layers := make([]string, 0)
+	if l.configFile != "" {
+		layers = append(layers, l.configFile)
+	} else {
+		dir, err := l.findConfigDir()
 		if err != nil {
 			return err
 		}
+
+		if l.env != "" {
+			if baseFile := findFile(dir, l.app); baseFile != "" {
+				layers = append(layers, baseFile)
+			}
+		}
+		layers = append(layers, findFile(dir, l.getDefaultConfigName()))
+	}
+
+	l.loadedFiles = make([]string, 0)
+	for _, layer := range layers {
+		if err := l.loadFile(layer, make(map[string]struct{})); err != nil {
+			return err
+		}
+	}
+
+	// include配置项只用于加载, 不保留在最终的配置中
+	for _, key := range includeKeys {
+		l.reader.Delete(key)
+	}
+
+	return nil
+}
+
+// Watch 监听所有已加载配置文件的变化, 文件被删除或者监听出错后停止监听该文件
+func (l *fileConfigLoader) Watch(onChange func()) error {
+	if len(l.loadedFiles) == 0 {
+		return errors.New("config file not loaded")
+	}
+
+	l.mutex.Lock()
+	l.onChange = onChange
+	l.watchers = make(map[string]*file.File)
+	l.mutex.Unlock()
+
+	if err := l.watchFiles(l.loadedFiles); err != nil {
+		_ = l.Unwatch()
+		return err
+	}
+	return nil
+}
+
+// WatchLoaded 监听重新加载时新引用的配置文件, 不再引用的配置文件仍然保持监听
+func (l *fileConfigLoader) WatchLoaded(loader Loader) error {
+	fl, ok := loader.(*fileConfigLoader)
+	if !ok {
+		return errors.New("not a file config loader")
 	}
+	return l.watchFiles(fl.loadedFiles)
+}
+
+func (l *fileConfigLoader) Unwatch() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
 
-	return l.reader.Load(file.Provider(configFile), toml.Parser())
+	for _, w := range l.watchers {
+		_ = w.Unwatch()
+	}
+	l.watchers = nil
+	return nil
 }
 
-// getDefaultConfigFilename 缺省的配置文件名: <app>.<env>.toml
-func (l *fileConfigLoader) getDefaultConfigFileName() string {
+// watchFiles 监听还没有监听的配置文件
+func (l *fileConfigLoader) watchFiles(files []string) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.watchers == nil {
+		return nil
+	}
+
+	for _, f := range files {
+		if _, exists := l.watchers[f]; exists {
+			continue
+		}
+
+		w := file.Provider(f)
+		err := w.Watch(func(event any, err error) {
+			if err == nil {
+				l.onChange()
+			}
+		})
+		if err != nil {
+			return errors.Wrapf(err, "watch config file: %s", f)
+		}
+		l.watchers[f] = w
+	}
+	return nil
+}
+
+// loadFile 先加载配置文件中引用的文件, 再加载配置文件本身
+func (l *fileConfigLoader) loadFile(path string, visiting map[string]struct{}) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	if _, exists := visiting[absPath]; exists {
+		return fmt.Errorf("circular include of config file: %s", path)
+	}
+	visiting[absPath] = struct{}{}
+	defer delete(visiting, absPath)
+
+	parser, err := getParser(absPath)
+	if err != nil {
+		return err
+	}
+
+	// 单独解析一次以获取include配置项
+	current := koanf.New(".")
+	if err = current.Load(file.Provider(absPath), parser); err != nil {
+		return errors.Wrapf(err, "load config file: %s", path)
+	}
+
+	for _, key := range includeKeys {
+		for _, include := range current.Strings(key) {
+			if !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(absPath), include)
+			}
+
+			if err = l.loadFile(include, visiting); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = l.reader.Merge(current); err != nil {
+		return errors.Wrapf(err, "merge config file: %s", path)
+	}
+
+	l.loadedFiles = append(l.loadedFiles, absPath)
+	return nil
+}
+
+// getDefaultConfigName 缺省的配置文件名(不含扩展名): <app>.<env>
+func (l *fileConfigLoader) getDefaultConfigName() string {
 	if l.env != "" {
-		return strings.Join([]string{l.app, l.env, defaultConfigType}, ".")
+		return strings.Join([]string{l.app, l.env}, ".")
 	}
-	return strings.Join([]string{l.app, defaultConfigType}, ".")
+	return l.app
 }
 
-// findConfigDirs 缺省的配置文件名: <app>.<env>
-func (l *fileConfigLoader) findConfigFile() (string, error) {
+// findConfigDir 向上逐级查找包含配置文件<app>.<env>.<ext>的目录
+func (l *fileConfigLoader) findConfigDir() (string, error) {
 	// iter to root directory
 	absStartPath, err := filepath.Abs(".")
 	if err != nil {
 		return "", err
 	}
 
-	var foundDir string
-	matchFile := l.getDefaultConfigFileName()
+	matchName := l.getDefaultConfigName()
 	currPath := absStartPath
-LOOP:
 	for {
 		for _, rootDir := range defaultConfigRootDirs {
 			// possible parent dir name
@@ -86,11 +233,9 @@ LOOP:
 				dirName = filepath.Join(dirName, l.app)
 			}
 
-			checkDir := filepath.Join(currPath, dirName, matchFile)
-			matches, err := filepath.Glob(checkDir)
-			if err == nil && len(matches) > 0 {
-				foundDir = filepath.Join(currPath, dirName)
-				break LOOP
+			checkDir := filepath.Join(currPath, dirName)
+			if findFile(checkDir, matchName) != "" {
+				return checkDir, nil
 			}
 		}
 
@@ -105,9 +250,29 @@ LOOP:
 		currPath = filepath.Dir(currPath)
 	}
 
-	if foundDir == "" {
-		return "", fmt.Errorf(`config file "%s" not found`, matchFile)
+	return "", fmt.Errorf(`config file "%s.{%s}" not found`, matchName, strings.Join(supportedConfigTypes, ","))
+}
+
+// findFile 在目录中查找name.<ext>, 返回第一个存在的文件
+func findFile(dir, name string) string {
+	for _, configType := range supportedConfigTypes {
+		path := filepath.Join(dir, name+"."+configType)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
 	}
+	return ""
+}
 
-	return filepath.Join(foundDir, matchFile), nil
+// getParser 根据扩展名获取解析器
+func getParser(path string) (koanf.Parser, error) {
+	switch strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")) {
+	case "toml":
+		return toml.Parser(), nil
+	case "yaml", "yml":
+		return yaml.Parser(), nil
+	case "json":
+		return json.Parser(), nil
+	}
+	return nil, fmt.Errorf("unsupported config file type: %s", path)
 }
@@ -0,0 +1,247 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/knadh/koanf/v2"
+)
+
+func TestFileConfigLoader(t *testing.T) {
+	testCases := []struct {
+		name       string
+		files      map[string]string // 相对于临时目录的路径 => 文件内容
+		env        string
+		configFile string // 为空时从临时目录查找<app>.<env>.<ext>
+		want       map[string]any
+		wantErr    string
+	}{
+		{
+			name:       "toml",
+			files:      map[string]string{"app.toml": "[app]\nname = \"x\"\nport = 80"},
+			configFile: "app.toml",
+			want:       map[string]any{"app.name": "x", "app.port": int64(80)},
+		},
+		{
+			name:       "yaml",
+			files:      map[string]string{"app.yaml": "app:\n  name: x\n  tags: [a, b]"},
+			configFile: "app.yaml",
+			want:       map[string]any{"app.name": "x", "app.tags": []any{"a", "b"}},
+		},
+		{
+			name:       "yml",
+			files:      map[string]string{"app.yml": "app:\n  name: x"},
+			configFile: "app.yml",
+			want:       map[string]any{"app.name": "x"},
+		},
+		{
+			name:       "json",
+			files:      map[string]string{"app.json": `{"app": {"name": "x", "port": 80}}`},
+			configFile: "app.json",
+			want:       map[string]any{"app.name": "x", "app.port": float64(80)},
+		},
+		{
+			name:       "unsupported type",
+			files:      map[string]string{"app.ini": "name = x"},
+			configFile: "app.ini",
+			wantErr:    "unsupported config file type",
+		},
+		{
+			name:       "invalid content",
+			files:      map[string]string{"app.json": `{"app":`},
+			configFile: "app.json",
+			wantErr:    "load config file",
+		},
+		{
+			name:  "find default config",
+			files: map[string]string{"app.toml": "[app]\nname = \"x\""},
+			want:  map[string]any{"app.name": "x"},
+		},
+		{
+			name: "toml preferred",
+			files: map[string]string{
+				"app.toml": "[app]\nname = \"toml\"",
+				"app.yaml": "app:\n  name: yaml",
+			},
+			want: map[string]any{"app.name": "toml"},
+		},
+		{
+			name: "find in config dir",
+			files: map[string]string{
+				"config/app/app/app.yaml": "app:\n  name: x",
+			},
+			want: map[string]any{"app.name": "x"},
+		},
+		{
+			name: "env overrides base",
+			files: map[string]string{
+				"app.toml":      "[app]\nname = \"base\"\nport = 80",
+				"app.test.yaml": "app:\n  name: test",
+			},
+			env:  "test",
+			want: map[string]any{"app.name": "test", "app.port": int64(80)},
+		},
+		{
+			name: "base ignored without env",
+			files: map[string]string{
+				"app.toml":      "[app]\nname = \"base\"",
+				"app.test.toml": "[app]\nname = \"test\"",
+			},
+			want: map[string]any{"app.name": "base"},
+		},
+		{
+			name: "base optional",
+			files: map[string]string{
+				"app.test.toml": "[app]\nname = \"test\"",
+			},
+			env:  "test",
+			want: map[string]any{"app.name": "test"},
+		},
+		{
+			name:    "config not found",
+			files:   map[string]string{"other.toml": "[app]\nname = \"x\""},
+			env:     "test",
+			wantErr: "not found",
+		},
+		{
+			name: "include relative path",
+			files: map[string]string{
+				"app/app.toml":    "include = [\"../common/sdk.yaml\"]\n[app]\nname = \"app\"",
+				"common/sdk.yaml": "app:\n  name: common\nsdk:\n  log:\n    level: debug",
+			},
+			configFile: "app/app.toml",
+			want:       map[string]any{"app.name": "app", "sdk.log.level": "debug", "include": nil},
+		},
+		{
+			name: "import and include",
+			files: map[string]string{
+				"app.toml": "include = [\"a.toml\"]\nimport = [\"b.json\"]\n[app]\nname = \"app\"",
+				"a.toml":   "[sdk]\na = \"a\"\nb = \"a\"",
+				"b.json":   `{"sdk": {"b": "b"}}`,
+			},
+			configFile: "app.toml",
+			want:       map[string]any{"app.name": "app", "sdk.a": "a", "sdk.b": "b", "include": nil, "import": nil},
+		},
+		{
+			name:       "include not reserved in section",
+			files:      map[string]string{"app.toml": "[app]\ninclude = [\"a.toml\"]"},
+			configFile: "app.toml",
+			want:       map[string]any{"app.include": []any{"a.toml"}},
+		},
+		{
+			name: "nested include",
+			files: map[string]string{
+				"app.toml":   "include = [\"a/a.toml\"]",
+				"a/a.toml":   "include = [\"b/b.toml\"]\n[sdk]\na = \"a\"\nb = \"a\"",
+				"a/b/b.toml": "[sdk]\nb = \"b\"\nc = \"b\"",
+			},
+			configFile: "app.toml",
+			want:       map[string]any{"sdk.a": "a", "sdk.b": "a", "sdk.c": "b"},
+		},
+		{
+			name: "include from each layer",
+			files: map[string]string{
+				"app.toml":      "include = [\"base.toml\"]\n[sdk]\nb = \"app\"",
+				"base.toml":     "[sdk]\na = \"base\"\nb = \"base\"\nc = \"base\"",
+				"app.test.toml": "include = [\"test.toml\"]\n[sdk]\nc = \"app.test\"",
+				"test.toml":     "[sdk]\nb = \"test\"\nc = \"test\"",
+			},
+			env:  "test",
+			want: map[string]any{"sdk.a": "base", "sdk.b": "test", "sdk.c": "app.test"},
+		},
+		{
+			name: "same file included twice",
+			files: map[string]string{
+				"app.toml": "include = [\"a.toml\", \"b.toml\"]",
+				"a.toml":   "include = [\"c.toml\"]",
+				"b.toml":   "include = [\"c.toml\"]",
+				"c.toml":   "[sdk]\nc = \"c\"",
+			},
+			configFile: "app.toml",
+			want:       map[string]any{"sdk.c": "c"},
+		},
+		{
+			name:       "include self",
+			files:      map[string]string{"app.toml": "include = [\"app.toml\"]"},
+			configFile: "app.toml",
+			wantErr:    "circular include",
+		},
+		{
+			name: "circular include",
+			files: map[string]string{
+				"app.toml":   "include = [\"a.toml\"]",
+				"a.toml":     "include = [\"sub/b.toml\"]",
+				"sub/b.toml": "include = [\"../a.toml\"]",
+			},
+			configFile: "app.toml",
+			wantErr:    "circular include",
+		},
+		{
+			name:       "include not found",
+			files:      map[string]string{"app.toml": "include = [\"missing.toml\"]"},
+			configFile: "app.toml",
+			wantErr:    "missing.toml",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Chdir(dir)
+
+			reader := koanf.New(".")
+			err := NewFileConfigLoader(reader, "app", tc.env, tc.configFile).Load()
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Load err = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for key, want := range tc.want {
+				if got := reader.Get(key); !reflect.DeepEqual(got, want) {
+					t.Errorf("%s = %#v, want %#v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+// 加载的配置文件按加载顺序记录, 用于监听
+func TestFileConfigLoaderLoadedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"app.toml": "include = [\"a.toml\"]",
+		"a.toml":   "import = [\"b.toml\"]",
+		"b.toml":   "",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l := NewFileConfigLoader(koanf.New("."), "app", "", filepath.Join(dir, "app.toml")).(*fileConfigLoader)
+	if err := l.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{filepath.Join(dir, "b.toml"), filepath.Join(dir, "a.toml"), filepath.Join(dir, "app.toml")}
+	if !reflect.DeepEqual(l.loadedFiles, want) {
+		t.Fatalf("loaded files = %v, want %v", l.loadedFiles, want)
+	}
+}
@@ -0,0 +1,248 @@
+package loader
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/knadh/koanf/v2"
+	"github.com/pkg/errors"
+)
+
+// SecretResolver 解析配置值中引用的密文
+// 配置值中的${<scheme>:<ref>}会被替换为Resolve(ref)的结果, enc:<ref>整体被替换为scheme为enc的解析结果
+type SecretResolver interface {
+	Scheme() string
+	Resolve(ref string) (string, error)
+}
+
+type secretLoader struct {
+	reader    *koanf.Koanf
+	resolvers map[string]SecretResolver
+}
+
+type envSecretResolver struct{}
+
+type fileSecretResolver struct{}
+
+type encSecretResolver struct{}
+
+const (
+	// EnvKeySecretKey 解密enc:<base64>配置值的AES密钥, 支持base64编码或者原始的16/24/32字节
+	EnvKeySecretKey = "HD_SECRET_KEY"
+	encScheme       = "enc"
+	encPrefix       = encScheme + ":"
+)
+
+var (
+	regexSecretRef = regexp.MustCompile(`\$\{(\w+):([^}]*)}`)
+)
+
+// NewSecretLoader 解析已加载配置中的密文引用, 需要在所有其他配置源加载之后执行
+// 内置env, file, enc三种解析器, resolvers中相同scheme的解析器会覆盖内置的
+func NewSecretLoader(reader *koanf.Koanf, resolvers ...SecretResolver) Loader {
+	l := &secretLoader{
+		reader:    reader,
+		resolvers: make(map[string]SecretResolver),
+	}
+
+	for _, r := range append([]SecretResolver{&envSecretResolver{}, &fileSecretResolver{}, &encSecretResolver{}}, resolvers...) {
+		l.resolvers[r.Scheme()] = r
+	}
+	return l
+}
+
+func (l *secretLoader) Load() error {
+	for key, value := range l.reader.All() {
+		var (
+			resolved any
+			changed  bool
+			err      error
+		)
+
+		switch v := value.(type) {
+		case string:
+			resolved, changed, err = l.resolve(v)
+		case []any:
+			items := make([]any, len(v))
+			for i, item := range v {
+				items[i] = item
+				if s, ok := item.(string); ok {
+					var itemChanged bool
+					items[i], itemChanged, err = l.resolve(s)
+					if err != nil {
+						break
+					}
+					changed = changed || itemChanged
+				}
+			}
+			resolved = items
+		case []string:
+			items := make([]string, len(v))
+			for i, item := range v {
+				var itemChanged bool
+				items[i], itemChanged, err = l.resolve(item)
+				if err != nil {
+					break
+				}
+				changed = changed || itemChanged
+			}
+			resolved = items
+		}
+
+		// 错误信息中不能包含配置值
+		if err != nil {
+			return errors.Wrapf(err, "resolve secret, key: %s", key)
+		}
+
+		if changed {
+			if err = l.reader.Set(key, resolved); err != nil {
+				return errors.Wrapf(err, "set resolved secret, key: %s", key)
+			}
+		}
+	}
+	return nil
+}
+
+// resolve 替换value中的密文引用, 未注册的scheme保持原样
+func (l *secretLoader) resolve(value string) (string, bool, error) {
+	if strings.HasPrefix(value, encPrefix) {
+		if r, exists := l.resolvers[encScheme]; exists {
+			resolved, err := r.Resolve(strings.TrimPrefix(value, encPrefix))
+			return resolved, true, err
+		}
+	}
+
+	if !strings.Contains(value, "${") {
+		return value, false, nil
+	}
+
+	var (
+		changed bool
+		lastErr error
+	)
+	resolved := regexSecretRef.ReplaceAllStringFunc(value, func(s string) string {
+		matches := regexSecretRef.FindStringSubmatch(s)
+		r, exists := l.resolvers[matches[1]]
+		if !exists {
+			return s
+		}
+
+		v, err := r.Resolve(matches[2])
+		if err != nil {
+			lastErr = err
+			return s
+		}
+		changed = true
+		return v
+	})
+	if lastErr != nil {
+		return "", false, lastErr
+	}
+	return resolved, changed, nil
+}
+
+func (envSecretResolver) Scheme() string {
+	return "env"
+}
+
+func (envSecretResolver) Resolve(ref string) (string, error) {
+	v, exists := os.LookupEnv(ref)
+	if !exists {
+		return "", fmt.Errorf("environment variable not set: %s", ref)
+	}
+	return v, nil
+}
+
+func (fileSecretResolver) Scheme() string {
+	return "file"
+}
+
+// Resolve 读取文件内容, 去掉末尾的换行符, 例如: /run/secrets/pg
+func (fileSecretResolver) Resolve(ref string) (string, error) {
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		return "", errors.Wrap(err, "read secret file")
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func (encSecretResolver) Scheme() string {
+	return encScheme
+}
+
+// Resolve 使用环境变量HD_SECRET_KEY中的密钥AES-GCM解密, ref为base64编码的nonce+密文
+func (encSecretResolver) Resolve(ref string) (string, error) {
+	aead, err := newSecretCipher()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := base64.StdEncoding.DecodeString(ref)
+	if err != nil {
+		return "", errors.Wrap(err, "decode encrypted value")
+	}
+
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("invalid encrypted value")
+	}
+
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.Wrap(err, "decrypt value")
+	}
+	return string(plaintext), nil
+}
+
+// EncryptSecret 使用环境变量HD_SECRET_KEY中的密钥加密, 返回可以直接写入配置的enc:<base64>
+func EncryptSecret(plaintext string) (string, error) {
+	aead, err := newSecretCipher()
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return encPrefix + base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(plaintext), nil)), nil
+}
+
+func newSecretCipher() (cipher.AEAD, error) {
+	key, err := getSecretKey()
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "new aes cipher")
+	}
+	return cipher.NewGCM(block)
+}
+
+func getSecretKey() ([]byte, error) {
+	v := os.Getenv(EnvKeySecretKey)
+	if v == "" {
+		return nil, fmt.Errorf("secret key not found in environment variable: %s", EnvKeySecretKey)
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(v); err == nil && isValidAesKeySize(len(key)) {
+		return key, nil
+	}
+
+	if isValidAesKeySize(len(v)) {
+		return []byte(v), nil
+	}
+	return nil, errors.New("invalid secret key, key size must be 16, 24 or 32 bytes")
+}
+
+func isValidAesKeySize(size int) bool {
+	return size == 16 || size == 24 || size == 32
+}
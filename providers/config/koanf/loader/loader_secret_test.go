@@ -0,0 +1,190 @@
+package loader
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/knadh/koanf/v2"
+)
+
+type upperSecretResolver struct {
+	scheme string
+}
+
+func (r upperSecretResolver) Scheme() string {
+	return r.scheme
+}
+
+func (r upperSecretResolver) Resolve(ref string) (string, error) {
+	return strings.ToUpper(ref), nil
+}
+
+func newTestReader(t *testing.T, values map[string]any) *koanf.Koanf {
+	t.Helper()
+
+	reader := koanf.New(".")
+	for key, value := range values {
+		if err := reader.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return reader
+}
+
+func TestSecretLoader(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "pg")
+	if err := os.WriteFile(secretFile, []byte("file-pass\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_PG_USER", "env-user")
+	t.Setenv("TEST_PG_PASS", "env-pass")
+
+	testCases := []struct {
+		name      string
+		value     any
+		resolvers []SecretResolver
+		want      any
+	}{
+		{name: "plain", value: "plain", want: "plain"},
+		{name: "env", value: "${env:TEST_PG_PASS}", want: "env-pass"},
+		{name: "env in value", value: "postgres://${env:TEST_PG_USER}:${env:TEST_PG_PASS}@db", want: "postgres://env-user:env-pass@db"},
+		{name: "file", value: "${file:" + secretFile + "}", want: "file-pass"},
+		{name: "unknown scheme", value: "${vault:pg}", want: "${vault:pg}"},
+		{name: "not a reference", value: "${TEST_PG_PASS}", want: "${TEST_PG_PASS}"},
+		{name: "non string", value: 80, want: 80},
+		{name: "list", value: []any{"${env:TEST_PG_USER}", 1, "b"}, want: []any{"env-user", 1, "b"}},
+		{name: "string list", value: []string{"a", "${env:TEST_PG_PASS}"}, want: []string{"a", "env-pass"}},
+		{
+			name:      "custom resolver",
+			value:     "${vault:pg}",
+			resolvers: []SecretResolver{upperSecretResolver{scheme: "vault"}},
+			want:      "PG",
+		},
+		{
+			name:      "override builtin resolver",
+			value:     "${env:TEST_PG_PASS}",
+			resolvers: []SecretResolver{upperSecretResolver{scheme: "env"}},
+			want:      "TEST_PG_PASS",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := newTestReader(t, map[string]any{"sdk.db.value": tc.value})
+			if err := NewSecretLoader(reader, tc.resolvers...).Load(); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := reader.Get("sdk.db.value"); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("value = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+// 解析失败时错误信息包含配置项, 但不包含配置值
+func TestSecretLoaderError(t *testing.T) {
+	t.Setenv(EnvKeySecretKey, "0123456789abcdef")
+
+	testCases := []struct {
+		name    string
+		value   any
+		wantErr string
+	}{
+		{name: "env not set", value: "password-${env:TEST_NOT_SET}", wantErr: "environment variable not set: TEST_NOT_SET"},
+		{name: "env not set in list", value: []any{"password", "${env:TEST_NOT_SET}"}, wantErr: "TEST_NOT_SET"},
+		{name: "file not found", value: "${file:" + filepath.Join(t.TempDir(), "missing") + "}", wantErr: "read secret file"},
+		{name: "invalid base64", value: "enc:password!", wantErr: "decode encrypted value"},
+		{name: "too short", value: "enc:" + base64.StdEncoding.EncodeToString([]byte("password")), wantErr: "invalid encrypted value"},
+		{name: "not encrypted", value: "enc:" + base64.StdEncoding.EncodeToString([]byte("password-0123456789")), wantErr: "decrypt value"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reader := newTestReader(t, map[string]any{"sdk.db.password": tc.value})
+			err := NewSecretLoader(reader).Load()
+			if err == nil {
+				t.Fatal("Load err = nil")
+			}
+
+			if msg := err.Error(); !strings.Contains(msg, "sdk.db.password") || !strings.Contains(msg, tc.wantErr) {
+				t.Fatalf("Load err = %q, want key and %q", msg, tc.wantErr)
+			}
+			if strings.Contains(err.Error(), "password-") {
+				t.Fatalf("Load err = %q contains config value", err)
+			}
+		})
+	}
+}
+
+func TestEncryptSecret(t *testing.T) {
+	testCases := []struct {
+		name string
+		key  string
+	}{
+		{name: "raw 16 bytes", key: "0123456789abcdef"},
+		{name: "raw 32 bytes", key: "0123456789abcdef0123456789abcdef"},
+		{name: "base64 24 bytes", key: base64.StdEncoding.EncodeToString([]byte("0123456789abcdef01234567"))},
+		{name: "base64 32 bytes", key: base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvKeySecretKey, tc.key)
+
+			encrypted, err := EncryptSecret("pg-pass")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(encrypted, encPrefix) || strings.Contains(encrypted, "pg-pass") {
+				t.Fatalf("encrypted = %q", encrypted)
+			}
+
+			// 每次加密使用不同的nonce
+			if again, _ := EncryptSecret("pg-pass"); again == encrypted {
+				t.Fatal("encrypt twice got same value")
+			}
+
+			reader := newTestReader(t, map[string]any{"sdk.db.password": encrypted})
+			if err = NewSecretLoader(reader).Load(); err != nil {
+				t.Fatal(err)
+			}
+			if got := reader.String("sdk.db.password"); got != "pg-pass" {
+				t.Fatalf("password = %q, want pg-pass", got)
+			}
+		})
+	}
+}
+
+func TestEncryptSecretKey(t *testing.T) {
+	t.Setenv(EnvKeySecretKey, "0123456789abcdef")
+	encrypted, err := EncryptSecret("pg-pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name    string
+		key     string
+		wantErr string
+	}{
+		{name: "key not set", key: "", wantErr: "secret key not found"},
+		{name: "invalid key size", key: "0123456789", wantErr: "invalid secret key"},
+		{name: "wrong key", key: "fedcba9876543210", wantErr: "decrypt value"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvKeySecretKey, tc.key)
+
+			reader := newTestReader(t, map[string]any{"sdk.db.password": encrypted})
+			if err := NewSecretLoader(reader).Load(); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Load err = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
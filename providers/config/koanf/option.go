@@ -1,5 +1,7 @@
 package koanf
 
+import "github.com/hdget/sdk/providers/config/koanf/loader"
+
 type Option func(provider *koanfConfigProvider)
 
 func WithConfigFile(configFile string) Option {
@@ -13,3 +15,10 @@ func WithConfigContent(configContent []byte) Option {
 		p.configContent = configContent
 	}
 }
+
+// WithSecretResolver 添加自定义的密文解析器, 相同scheme的解析器会覆盖内置的env, file, enc解析器
+func WithSecretResolver(resolvers ...loader.SecretResolver) Option {
+	return func(p *koanfConfigProvider) {
+		p.resolvers = append(p.resolvers, resolvers...)
+	}
+}
@@ -1,7 +1,10 @@
 package koanf
 
 import (
+	"log"
 	"os"
+	"reflect"
+	"sync"
 
 	"github.com/hdget/sdk/common/constant"
 	"github.com/hdget/sdk/common/provider"
@@ -15,15 +18,33 @@ type koanfConfigProvider struct {
 	reader        *koanf.Koanf
 	app           string
 	env           string
-	configFile    string // 指定的配置文件
-	configContent []byte // 指定的配置内容
+	configFile    string                  // 指定的配置文件
+	configContent []byte                  // 指定的配置内容
+	resolvers     []loader.SecretResolver // 自定义的密文解析器
+
+	mutex       sync.RWMutex    // 保护reader, fileLoader, logger和watchers
+	fileLoader  loader.Loader   // 文件配置加载器, 用于监听配置文件变化
+	logger      provider.Logger // 记录重新加载配置的错误, 没有设置时使用标准库的log
+	watchers    []*watcher
+	watchOnce   sync.Once
+	watchErr    error
+	reloadMutex sync.Mutex
+}
 
+type watcher struct {
+	key   string
+	fn    func(oldValue, newValue any)
+	value any // 最近一次通知时的值
 }
 
 const (
 	defaultStructTag = "mapstructure"
 )
 
+var (
+	errWatchContent = errors.New("config loaded from content can not be watched")
+)
+
 // New 初始化config provider
 func New(app string, options ...Option) (provider.Config, error) {
 	if app == "" {
@@ -52,37 +73,64 @@ func New(app string, options ...Option) (provider.Config, error) {
 // - configFile: 文件配置(低)
 // - input: 命令行参数配置(最高)
 // - env: 环境变量配置(高)
+// 最后解析配置值中的密文引用, 例如: ${env:PG_PASS}, ${file:/run/secrets/pg}, enc:<base64>
 func (p *koanfConfigProvider) Load() error {
+	reader := koanf.New(".")
+	fileLoader, err := p.loadTo(reader)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.reader = reader
+	if p.fileLoader == nil {
+		p.fileLoader = fileLoader
+	}
+	return nil
+}
+
+// loadTo 加载所有配置源到reader中, 返回本次加载使用的文件配置加载器
+func (p *koanfConfigProvider) loadTo(reader *koanf.Koanf) (loader.Loader, error) {
 	// minimal config
-	if err := loader.NewMinimalConfigLoader(p.reader, p.app).Load(); err != nil {
-		return errors.Wrap(err, "load minimal config")
+	if err := loader.NewMinimalConfigLoader(reader, p.app).Load(); err != nil {
+		return nil, errors.Wrap(err, "load minimal config")
 	}
 
 	// 如果指定了configContent则不从文件读取配置信息
+	var fileLoader loader.Loader
 	if p.configContent != nil {
-		if err := loader.NewContentConfigLoader(p.reader, p.configContent).Load(); err != nil {
-			return errors.Wrap(err, "load minimal config")
+		if err := loader.NewContentConfigLoader(reader, p.configContent).Load(); err != nil {
+			return nil, errors.Wrap(err, "load minimal config")
 		}
 	} else {
-		if err := loader.NewFileConfigLoader(p.reader, p.app, p.env, p.configFile).Load(); err != nil {
-			return errors.Wrap(err, "load config from file")
+		fileLoader = loader.NewFileConfigLoader(reader, p.app, p.env, p.configFile)
+		if err := fileLoader.Load(); err != nil {
+			return nil, errors.Wrap(err, "load config from file")
 		}
+	}
 
+	if err := loader.NewCliConfigLoader(reader, p.configContent).Load(); err != nil {
+		return nil, errors.Wrap(err, "load config from cli")
 	}
 
-	if err := loader.NewCliConfigLoader(p.reader, p.configContent).Load(); err != nil {
-		return errors.Wrap(err, "load config from cli")
+	if err := loader.NewEnvConfigLoader(reader).Load(); err != nil {
+		return nil, errors.Wrap(err, "load config from env")
 	}
 
-	if err := loader.NewEnvConfigLoader(p.reader).Load(); err != nil {
-		return errors.Wrap(err, "load config from env")
+	// 所有配置源加载完成后再解析密文引用, 环境变量中的配置值也可以引用密文
+	if err := loader.NewSecretLoader(reader, p.resolvers...).Load(); err != nil {
+		return nil, errors.Wrap(err, "load config secrets")
 	}
 
-	return nil
+	return fileLoader, nil
 }
 
 // Unmarshal 解析配置
 func (p *koanfConfigProvider) Unmarshal(configVar any, args ...string) error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
 	if len(args) > 0 {
 		return p.reader.UnmarshalWithConf(args[0], configVar, koanf.UnmarshalConf{Tag: defaultStructTag})
 	}
@@ -90,9 +138,109 @@ func (p *koanfConfigProvider) Unmarshal(configVar any, args ...string) error {
 }
 
 func (p *koanfConfigProvider) Get(key string) any {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
 	return p.reader.Get(key)
 }
 
+// Watch 监听key对应配置值的变化, 配置文件修改后重新加载所有配置源, 值发生变化时调用fn
+// key可以是单个配置项, 也可以是配置段落, 例如: sdk.log.level, sdk.redis
+func (p *koanfConfigProvider) Watch(key string, fn func(oldValue, newValue any)) error {
+	p.watchOnce.Do(func() {
+		p.watchErr = p.startWatch()
+	})
+	if p.watchErr != nil {
+		return p.watchErr
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.watchers = append(p.watchers, &watcher{key: key, fn: fn, value: p.reader.Get(key)})
+	return nil
+}
+
+// Close 停止监听配置文件
+func (p *koanfConfigProvider) Close() error {
+	if w, ok := p.getFileLoader().(loader.Watcher); ok {
+		return w.Unwatch()
+	}
+	return nil
+}
+
+// SetLogger 设置记录重新加载配置错误的日志, logger在config之后初始化, 由sdk在logger初始化完成后设置
+func (p *koanfConfigProvider) SetLogger(logger provider.Logger) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.logger = logger
+}
+
 func (p *koanfConfigProvider) GetCapability() provider.Capability {
 	return Capability
 }
+
+func (p *koanfConfigProvider) startWatch() error {
+	if p.configContent != nil {
+		return errWatchContent
+	}
+
+	w, ok := p.getFileLoader().(loader.Watcher)
+	if !ok {
+		return errors.New("config loader does not support watching")
+	}
+
+	return errors.Wrap(w.Watch(p.reload), "watch config file")
+}
+
+// reload 重新加载配置, 加载失败时保留原有配置
+func (p *koanfConfigProvider) reload() {
+	p.reloadMutex.Lock()
+	defer p.reloadMutex.Unlock()
+
+	reader := koanf.New(".")
+	fileLoader, err := p.loadTo(reader)
+	if err != nil {
+		p.logError("reload config, keep the previous config", "err", err)
+		return
+	}
+
+	// 修改后的配置文件可能引用了新的配置文件
+	if w, ok := p.getFileLoader().(loader.Watcher); ok && fileLoader != nil {
+		if err = w.WatchLoaded(fileLoader); err != nil {
+			p.logError("watch included config files", "err", err)
+		}
+	}
+
+	p.mutex.Lock()
+	p.reader = reader
+	watchers := append([]*watcher(nil), p.watchers...)
+	p.mutex.Unlock()
+
+	for _, w := range watchers {
+		newValue := reader.Get(w.key)
+		if reflect.DeepEqual(w.value, newValue) {
+			continue
+		}
+
+		oldValue := w.value
+		w.value = newValue
+		w.fn(oldValue, newValue)
+	}
+}
+
+func (p *koanfConfigProvider) getFileLoader() loader.Loader {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.fileLoader
+}
+
+func (p *koanfConfigProvider) logError(msg string, kvs ...any) {
+	p.mutex.RLock()
+	logger := p.logger
+	p.mutex.RUnlock()
+
+	if logger != nil {
+		logger.Error(msg, kvs...)
+		return
+	}
+	log.Println(append([]any{msg}, kvs...)...)
+}
@@ -0,0 +1,154 @@
+package koanf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+)
+
+type change struct {
+	key      string
+	oldValue any
+	newValue any
+}
+
+// writeFile 先写入临时文件再改名, 避免监听到只写了一半的文件
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// errorLogger 把Error的日志发送到errors
+type errorLogger struct {
+	provider.Logger
+	errors chan string
+}
+
+func (l *errorLogger) Error(msg string, _ ...any) {
+	l.errors <- msg
+}
+
+func waitChange(t *testing.T, changes <-chan change, want change) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-changes:
+			if got == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("change %+v not received", want)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "app.toml")
+	writeFile(t, configFile, `[sdk.redis.default]
+host = "a"`)
+
+	p, err := New("app", WithConfigFile(configFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = p.(*koanfConfigProvider).Close()
+	}()
+
+	changes := make(chan change, 16)
+	for _, key := range []string{"sdk.redis.default.host", "app.name"} {
+		err = p.Watch(key, func(oldValue, newValue any) {
+			changes <- change{key: key, oldValue: oldValue, newValue: newValue}
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(t, configFile, `[sdk.redis.default]
+host = "b"`)
+	waitChange(t, changes, change{key: "sdk.redis.default.host", oldValue: "a", newValue: "b"})
+	if got := p.Get("sdk.redis.default.host"); got != "b" {
+		t.Fatalf("host = %v, want b", got)
+	}
+
+	// 修改后新引用的配置文件也会被监听
+	includeFile := filepath.Join(dir, "include.toml")
+	writeFile(t, includeFile, `[app]
+name = "x"`)
+	writeFile(t, configFile, `include = ["include.toml"]
+[sdk.redis.default]
+host = "b"`)
+	waitChange(t, changes, change{key: "app.name", oldValue: nil, newValue: "x"})
+
+	writeFile(t, includeFile, `[app]
+name = "y"`)
+	waitChange(t, changes, change{key: "app.name", oldValue: "x", newValue: "y"})
+}
+
+// 配置文件有错误时保留原有配置并记录错误, 修正后继续监听
+func TestWatchReloadError(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "app.toml")
+	writeFile(t, configFile, `[app]
+name = "a"`)
+
+	p, err := New("app", WithConfigFile(configFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = p.(*koanfConfigProvider).Close()
+	}()
+
+	logger := &errorLogger{errors: make(chan string, 16)}
+	p.(*koanfConfigProvider).SetLogger(logger)
+
+	changes := make(chan change, 16)
+	if err = p.Watch("app.name", func(oldValue, newValue any) {
+		changes <- change{key: "app.name", oldValue: oldValue, newValue: newValue}
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, configFile, `[app`)
+	select {
+	case msg := <-logger.errors:
+		if msg != "reload config, keep the previous config" {
+			t.Fatalf("unexpected error log: %s", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload error not logged")
+	}
+	if got := p.Get("app.name"); got != "a" {
+		t.Fatalf("app.name = %v, want a", got)
+	}
+
+	writeFile(t, configFile, `[app]
+name = "b"`)
+	waitChange(t, changes, change{key: "app.name", oldValue: "a", newValue: "b"})
+}
+
+func TestWatchContent(t *testing.T) {
+	p, err := New("app", WithConfigContent([]byte(`[app]
+name = "x"`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.Watch("app.name", func(_, _ any) {}); err != errWatchContent {
+		t.Fatalf("Watch err = %v, want %v", err, errWatchContent)
+	}
+}
@@ -2,7 +2,10 @@ package viper
 
 import (
 	"os"
+	"reflect"
+	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/hdget/sdk/common/constant"
 	"github.com/hdget/sdk/common/provider"
 	"github.com/hdget/sdk/providers/config/viper/loader"
@@ -13,10 +16,20 @@ import (
 
 // viperConfigProvider 命令行配置
 type viperConfigProvider struct {
-	app   string
-	env   string
-	local *viper.Viper
-	param *param.Param
+	app         string
+	env         string
+	local       *viper.Viper
+	param       *param.Param
+	mutex       sync.Mutex // 保护watchers
+	watchers    []*watcher
+	watchOnce   sync.Once
+	notifyMutex sync.Mutex
+}
+
+type watcher struct {
+	key   string
+	fn    func(oldValue, newValue any)
+	value any // 最近一次通知时的值
 }
 
 // New 初始化config provider
@@ -37,6 +50,17 @@ func New(app string, options ...Option) (provider.Config, error) {
 		option(p.param)
 	}
 
+	// 远程配置变化时同时通知watchers
+	if p.param.Remote != nil {
+		remoteCallback := p.param.Remote.WatchCallback
+		p.param.Remote.WatchCallback = func() {
+			if remoteCallback != nil {
+				remoteCallback()
+			}
+			p.notify()
+		}
+	}
+
 	err := p.Load()
 	if err != nil {
 		return nil, errors.Wrap(err, "load config")
@@ -91,6 +115,41 @@ func (p *viperConfigProvider) Get(key string) any {
 	return p.local.Get(key)
 }
 
+// Watch 监听配置项或配置段落的变化, 配置文件或远程配置变化后值不同时调用fn
+func (p *viperConfigProvider) Watch(key string, fn func(oldValue, newValue any)) error {
+	p.watchOnce.Do(func() {
+		p.local.OnConfigChange(func(fsnotify.Event) {
+			p.notify()
+		})
+		p.local.WatchConfig()
+	})
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.watchers = append(p.watchers, &watcher{key: key, fn: fn, value: p.local.Get(key)})
+	return nil
+}
+
 func (p *viperConfigProvider) GetCapability() provider.Capability {
 	return Capability
 }
+
+func (p *viperConfigProvider) notify() {
+	p.notifyMutex.Lock()
+	defer p.notifyMutex.Unlock()
+
+	p.mutex.Lock()
+	watchers := append([]*watcher(nil), p.watchers...)
+	p.mutex.Unlock()
+
+	for _, w := range watchers {
+		newValue := p.local.Get(w.key)
+		if reflect.DeepEqual(w.value, newValue) {
+			continue
+		}
+
+		oldValue := w.value
+		w.value = newValue
+		w.fn(oldValue, newValue)
+	}
+}
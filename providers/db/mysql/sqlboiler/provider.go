@@ -1,9 +1,11 @@
 package sqlboiler
 
 import (
-	"sync/atomic"
+	"context"
+	"database/sql"
 
 	"github.com/aarondl/sqlboiler/v4/boil"
+	"github.com/hdget/sdk/common/dbkit"
 	"github.com/hdget/sdk/common/provider"
 )
 
@@ -12,7 +14,7 @@ type mysqlProvider struct {
 	masterDb  provider.DbClient
 	slaveDbs  []provider.DbClient
 	extraDbs  map[string]provider.DbClient
-	slaveIdx  uint64 // 用于轮询选择 slave
+	replicas  *dbkit.ReplicaSet // 检查 slave 的可用状态
 }
 
 func New(configProvider provider.Config, logger provider.Logger) (provider.Database, error) {
@@ -21,13 +23,14 @@ func New(configProvider provider.Config, logger provider.Logger) (provider.Datab
 		return nil, err
 	}
 
+	hooks := config.getHooks(logger)
 	p := &mysqlProvider{
 		slaveDbs: make([]provider.DbClient, len(config.Slaves)),
 		extraDbs: make(map[string]provider.DbClient),
 	}
 
 	if config.Default != nil {
-		p.defaultDb, err = newClient(config.Default, logger)
+		p.defaultDb, err = newClient(config.Default, logger, hooks...)
 		if err != nil {
 			logger.Fatal("init mysql default connection", "err", err)
 		}
@@ -38,7 +41,7 @@ func New(configProvider provider.Config, logger provider.Logger) (provider.Datab
 	}
 
 	if config.Master != nil {
-		p.masterDb, err = newClient(config.Master, logger)
+		p.masterDb, err = newClient(config.Master, logger, hooks...)
 		if err != nil {
 			logger.Fatal("init mysql master connection", "err", err)
 		}
@@ -46,7 +49,7 @@ func New(configProvider provider.Config, logger provider.Logger) (provider.Datab
 	}
 
 	for i, slaveConf := range config.Slaves {
-		p.slaveDbs[i], err = newClient(slaveConf, logger)
+		p.slaveDbs[i], err = newClient(slaveConf, logger, hooks...)
 		if err != nil {
 			logger.Fatal("init mysql slave connection", "slave", i, "err", err)
 		}
@@ -55,7 +58,7 @@ func New(configProvider provider.Config, logger provider.Logger) (provider.Datab
 	}
 
 	for _, extraConf := range config.Items {
-		p.extraDbs[extraConf.Name], err = newClient(extraConf, logger)
+		p.extraDbs[extraConf.Name], err = newClient(extraConf, logger, hooks...)
 		if err != nil {
 			logger.Fatal("new mysql extra connection", "name", extraConf.Name, "err", err)
 		}
@@ -63,6 +66,15 @@ func New(configProvider provider.Config, logger provider.Logger) (provider.Datab
 		logger.Debug("init mysql extra", "name", extraConf.Name, "host", extraConf.Host)
 	}
 
+	// 配置中的连接池大小修改后立即生效, 配置不支持监听时忽略
+	_ = configProvider.Watch(configSection, func(_, _ any) {
+		if err := p.reloadPool(configProvider); err != nil {
+			logger.Error("reload mysql pool config", "err", err)
+		}
+	})
+
+	p.replicas = dbkit.NewReplicaSet(logger, p.slaveDbs, config.getReplicaOptions()...)
+
 	return p, nil
 }
 
@@ -89,11 +101,17 @@ func (p *mysqlProvider) Named(name string) provider.DbClient {
 	return p.extraDbs[name]
 }
 
-// Read 返回用于读操作的数据库客户端（从 slave 中轮询选择，无 slave 则返回 master 或 default）
+// Read 返回用于读操作的数据库客户端（从可用的 slave 中轮询选择，无可用 slave 则返回 master 或 default）
 func (p *mysqlProvider) Read() provider.DbClient {
-	if len(p.slaveDbs) > 0 {
-		idx := atomic.AddUint64(&p.slaveIdx, 1) - 1
-		return p.slaveDbs[idx%uint64(len(p.slaveDbs))]
+	return p.ReadContext(context.Background())
+}
+
+// ReadContext 同Read，ctx 中有事务或者通过 dbkit.WithPrimary 标记时返回 master 或 default
+func (p *mysqlProvider) ReadContext(ctx context.Context) provider.DbClient {
+	if !dbkit.UsePrimary(ctx) {
+		if client := p.replicas.Next(); client != nil {
+			return client
+		}
 	}
 	return p.Write()
 }
@@ -105,3 +123,43 @@ func (p *mysqlProvider) Write() provider.DbClient {
 	}
 	return p.defaultDb
 }
+
+// reloadPool 重新读取配置并调整各连接的连接池参数
+func (p *mysqlProvider) reloadPool(configProvider provider.Config) error {
+	config, err := newConfig(configProvider)
+	if err != nil {
+		return err
+	}
+
+	pairs := map[provider.DbClient]*mysqlConfig{
+		p.defaultDb: config.Default,
+		p.masterDb:  config.Master,
+	}
+	for i, slaveConf := range config.Slaves {
+		if i < len(p.slaveDbs) {
+			pairs[p.slaveDbs[i]] = slaveConf
+		}
+	}
+	for _, extraConf := range config.Items {
+		pairs[p.extraDbs[extraConf.Name]] = extraConf
+	}
+
+	for client, c := range pairs {
+		if client != nil && c != nil {
+			c.ApplyPool(client.Db())
+		}
+	}
+	return nil
+}
+
+// Stats 各数据库客户端的连接池统计信息
+func (p *mysqlProvider) Stats() map[string]sql.DBStats {
+	return dbkit.Stats(p.defaultDb, p.masterDb, p.slaveDbs, p.extraDbs)
+}
+
+// Close 关闭所有数据库连接
+func (p *mysqlProvider) Close() error {
+	p.replicas.Close()
+
+	return dbkit.Close(p.defaultDb, p.masterDb, p.slaveDbs, p.extraDbs)
+}
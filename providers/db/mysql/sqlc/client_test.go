@@ -3,6 +3,8 @@ package sqlc
 import (
 	"database/sql"
 	"testing"
+
+	"github.com/hdget/sdk/common/dbkit"
 )
 
 // TestMysqlClientDb_MethodExists 测试 Db 方法存在且返回正确类型
@@ -24,4 +26,28 @@ func TestMysqlClientImplementsDbClient(t *testing.T) {
 		Close() error
 		Db() *sql.DB
 	} = &mysqlClient{}
-}
\ No newline at end of file
+}
+
+func TestGetDsn(t *testing.T) {
+	c := &mysqlConfig{
+		User:             "root",
+		Password:         "123456",
+		Host:             "localhost",
+		Port:             3306,
+		Database:         "test",
+		Timeout:          3,
+		StatementTimeout: 1000,
+		Params:           map[string]string{"loc": "UTC"},
+		TLSConfig:        dbkit.TLSConfig{TLSMode: dbkit.TLSModeDisable},
+	}
+
+	dsn, err := getDsn(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "root:123456@tcp(localhost:3306)/test?charset=utf8mb4&loc=UTC&max_execution_time=1000&parseTime=true&timeout=3s&tls=false"
+	if dsn != want {
+		t.Fatalf("getDsn() = %s, want %s", dsn, want)
+	}
+}
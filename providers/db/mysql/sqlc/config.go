@@ -1,6 +1,10 @@
 package sqlc
 
 import (
+	"time"
+
+	"github.com/hdget/sdk/common/config"
+	"github.com/hdget/sdk/common/dbkit"
 	"github.com/hdget/sdk/common/provider"
 	"github.com/pkg/errors"
 )
@@ -10,16 +14,33 @@ type mysqlProviderConfig struct {
 	Master  *mysqlConfig   `mapstructure:"master"`
 	Slaves  []*mysqlConfig `mapstructure:"slaves"`
 	Items   []*mysqlConfig `mapstructure:"items"`
+	// HealthCheckInterval 从库健康检查间隔, 单位: 秒, 小于0时不检查
+	HealthCheckInterval int `mapstructure:"health_check_interval" default:"5"`
+	// MaxReplicationLag 从库最大复制延迟, 单位: 秒, 超过后不再从该从库读取, 小于0时不限制并且不查询延迟
+	// 查询延迟需要REPLICATION CLIENT权限, 没有权限时只检查连通性
+	MaxReplicationLag int `mapstructure:"max_replication_lag" default:"10"`
+	// SlowQueryThreshold 慢查询阈值, 单位: 毫秒, 超过后记录warn日志, 小于0时不记录
+	SlowQueryThreshold int `mapstructure:"slow_query_threshold" default:"1000"`
+	// SqlComment 是否在语句后添加包含tid和uid的注释
+	SqlComment bool `mapstructure:"sql_comment"`
 }
 
 type mysqlConfig struct {
 	Name     string `mapstructure:"name"`
 	User     string `mapstructure:"user"`
 	Password string `mapstructure:"password"`
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
+	Host     string `mapstructure:"host" validate:"required"`
+	Port     int    `mapstructure:"port" default:"3306"`
 	Database string `mapstructure:"database"`
-	Timeout  int    `mapstructure:"timeout"`
+	// Timeout 建立连接的超时时间, 单位: 秒
+	Timeout int `mapstructure:"timeout"`
+	// StatementTimeout SELECT语句的最长执行时间, 单位: 毫秒, 通过max_execution_time设置
+	StatementTimeout int `mapstructure:"statement_timeout"`
+	// Params 额外的DSN参数, 会覆盖缺省参数, 参考: https://github.com/go-sql-driver/mysql#parameters
+	Params map[string]string `mapstructure:"params"`
+
+	dbkit.PoolConfig `mapstructure:",squash"`
+	dbkit.TLSConfig  `mapstructure:",squash"`
 }
 
 const (
@@ -28,7 +49,10 @@ const (
 
 var (
 	errInvalidConfig = errors.New("invalid mysql provider config")
-	errEmptyConfig   = errors.New("empty mysql provider config")
+	configSchema     = &provider.ConfigSchema{
+		Section: configSection,
+		New:     func() any { return &mysqlProviderConfig{} },
+	}
 )
 
 func newConfig(configProvider provider.Config) (*mysqlProviderConfig, error) {
@@ -36,77 +60,56 @@ func newConfig(configProvider provider.Config) (*mysqlProviderConfig, error) {
 		return nil, errInvalidConfig
 	}
 
-	var c *mysqlProviderConfig
-	err := configProvider.Unmarshal(&c, configSection)
+	c := configSchema.New().(*mysqlProviderConfig)
+	err := config.Parse(configProvider, configSection, c)
 	if err != nil {
-		return nil, err
-	}
-
-	if c == nil {
-		return nil, errEmptyConfig
-	}
-
-	err = c.validate()
-	if err != nil {
-		return nil, errors.Wrap(err, "validate mysql provider config")
+		return nil, errors.Wrap(err, "parse mysql provider config")
 	}
 
 	return c, nil
 }
 
-func (c *mysqlProviderConfig) validate() error {
-	if c.Default != nil {
-		err := c.validateInstance(c.Default)
-		if err != nil {
-			return err
-		}
+// getReplicaOptions 从库健康检查的参数
+func (c *mysqlProviderConfig) getReplicaOptions() []dbkit.ReplicaOption {
+	options := []dbkit.ReplicaOption{
+		dbkit.WithCheckInterval(time.Duration(c.HealthCheckInterval) * time.Second),
 	}
 
-	if c.Master != nil {
-		err := c.validateInstance(c.Master)
-		if err != nil {
-			return err
-		}
+	// 不限制复制延迟时只检查连通性
+	if c.MaxReplicationLag > 0 {
+		options = append(options,
+			dbkit.WithMaxLag(time.Duration(c.MaxReplicationLag)*time.Second),
+			dbkit.WithLagProbe(dbkit.MysqlReplicationLag),
+		)
 	}
+	return options
+}
 
-	for _, slave := range c.Slaves {
-		err := c.validateInstance(slave)
-		if err != nil {
-			return err
+// Validate 主库和从库必须指定用户, 额外的数据库必须指定名字
+func (c *mysqlProviderConfig) Validate() error {
+	for _, ic := range append([]*mysqlConfig{c.Default, c.Master}, c.Slaves...) {
+		if ic != nil && ic.User == "" {
+			return errors.New("mysql user is required")
 		}
 	}
 
 	for _, item := range c.Items {
-		err := c.validateExtraInstance(item)
-		if err != nil {
-			return err
+		if item != nil && item.Name == "" {
+			return errors.New("mysql extra instance name is required")
 		}
 	}
-
 	return nil
 }
 
-func (c *mysqlProviderConfig) validateInstance(ic *mysqlConfig) error {
-	if ic == nil || ic.Host == "" || ic.User == "" {
-		return errEmptyConfig
-	}
-
-	// setup default config value
-	if ic.Port == 0 {
-		ic.Port = 3306
-	}
-
-	return nil
+// SetDefaults 连接池的缺省值
+func (ic *mysqlConfig) SetDefaults() {
+	ic.MergeDefaults(dbkit.PoolConfig{
+		MaxOpenConns:    100,
+		ConnMaxLifetime: 180, // 需要小于MySQL的wait_timeout
+	})
 }
 
-func (c *mysqlProviderConfig) validateExtraInstance(ic *mysqlConfig) error {
-	if ic == nil || ic.Host == "" || ic.Name == "" {
-		return errEmptyConfig
-	}
-
-	// setup default config value
-	if ic.Port == 0 {
-		ic.Port = 3306
-	}
-	return nil
-}
\ No newline at end of file
+// getHooks 根据配置创建客户端的钩子
+func (c *mysqlProviderConfig) getHooks(logger provider.Logger) []provider.DbHook {
+	return dbkit.NewHooks(logger, time.Duration(c.SlowQueryThreshold)*time.Millisecond, c.SqlComment)
+}
@@ -16,4 +16,5 @@ var Capability = provider.Capability{
 		providerName,
 		fx.Provide(newProvider),
 	),
+	Config: configSchema,
 }
@@ -1,6 +1,10 @@
 package sqlboiler
 
 import (
+	"time"
+
+	"github.com/hdget/sdk/common/config"
+	"github.com/hdget/sdk/common/dbkit"
 	"github.com/hdget/sdk/common/provider"
 	"github.com/pkg/errors"
 )
@@ -10,19 +14,36 @@ type psqlProviderConfig struct {
 	Master  *psqlConfig   `mapstructure:"master"`
 	Slaves  []*psqlConfig `mapstructure:"slaves"`
 	Items   []*psqlConfig `mapstructure:"items"`
+	// HealthCheckInterval 从库健康检查间隔, 单位: 秒, 小于0时不检查
+	HealthCheckInterval int `mapstructure:"health_check_interval" default:"5"`
+	// MaxReplicationLag 从库最大复制延迟, 单位: 秒, 超过后不再从该从库读取, 小于0时不限制并且不查询延迟
+	MaxReplicationLag int `mapstructure:"max_replication_lag" default:"10"`
+	// SlowQueryThreshold 慢查询阈值, 单位: 毫秒, 超过后记录warn日志, 小于0时不记录
+	SlowQueryThreshold int `mapstructure:"slow_query_threshold" default:"1000"`
+	// SqlComment 是否在语句后添加包含tid和uid的注释
+	SqlComment bool `mapstructure:"sql_comment"`
 }
 
 type psqlConfig struct {
-	Name            string `mapstructure:"name"`
-	User            string `mapstructure:"user"`
-	Password        string `mapstructure:"password"`
-	Host            string `mapstructure:"host"`
-	Port            int    `mapstructure:"port"`
-	Database        string `mapstructure:"database"`
-	Schema          string `mapstructure:"schema"`
-	UsePgBouncer    bool   `mapstructure:"use_pg_bouncer"`
-	MaxOpenConn     int    `mapstructure:"max_client_conn"`
-	ConnMaxLifetime int    `mapstructure:"conn_max_lifetime"` // unit: seconds
+	Name         string `mapstructure:"name"`
+	User         string `mapstructure:"user"`
+	Password     string `mapstructure:"password"`
+	Host         string `mapstructure:"host" validate:"required"`
+	Port         int    `mapstructure:"port"` // 缺省值取决于是否使用pgbouncer
+	Database     string `mapstructure:"database"`
+	Schema       string `mapstructure:"schema" default:"public"`
+	UsePgBouncer bool   `mapstructure:"use_pg_bouncer"`
+	// MaxOpenConn Deprecated: 使用max_open_conns
+	MaxOpenConn int `mapstructure:"max_client_conn"`
+	// Timeout 建立连接的超时时间, 单位: 秒
+	Timeout int `mapstructure:"timeout"`
+	// StatementTimeout 语句的最长执行时间, 单位: 毫秒, 通过statement_timeout设置
+	StatementTimeout int `mapstructure:"statement_timeout"`
+	// Params 额外的DSN参数, 会覆盖缺省参数, 参考: https://www.postgresql.org/docs/current/libpq-connect.html#LIBPQ-PARAMKEYWORDS
+	Params map[string]string `mapstructure:"params"`
+
+	dbkit.PoolConfig `mapstructure:",squash"`
+	dbkit.TLSConfig  `mapstructure:",squash"`
 }
 
 const (
@@ -31,7 +52,10 @@ const (
 
 var (
 	errInvalidConfig = errors.New("invalid postgresql provider config")
-	errEmptyConfig   = errors.New("empty postgresql provider config")
+	configSchema     = &provider.ConfigSchema{
+		Section: configSection,
+		New:     func() any { return &psqlProviderConfig{} },
+	}
 )
 
 func newConfig(configProvider provider.Config) (*psqlProviderConfig, error) {
@@ -39,66 +63,48 @@ func newConfig(configProvider provider.Config) (*psqlProviderConfig, error) {
 		return nil, errInvalidConfig
 	}
 
-	var c *psqlProviderConfig
-	err := configProvider.Unmarshal(&c, configSection)
-	if err != nil {
-		return nil, err
-	}
-
-	if c == nil {
-		return nil, errEmptyConfig
-	}
-
-	err = c.validate()
+	c := configSchema.New().(*psqlProviderConfig)
+	err := config.Parse(configProvider, configSection, c)
 	if err != nil {
-		return nil, errors.Wrap(err, "validate postgresql provider config")
+		return nil, errors.Wrap(err, "parse postgresql provider config")
 	}
 
 	return c, nil
 }
 
-func (c *psqlProviderConfig) validate() error {
-	if c.Default != nil {
-		err := c.validateInstance(c.Default)
-		if err != nil {
-			return err
-		}
+// getReplicaOptions 从库健康检查的参数
+func (c *psqlProviderConfig) getReplicaOptions() []dbkit.ReplicaOption {
+	options := []dbkit.ReplicaOption{
+		dbkit.WithCheckInterval(time.Duration(c.HealthCheckInterval) * time.Second),
 	}
 
-	if c.Master != nil {
-		err := c.validateInstance(c.Master)
-		if err != nil {
-			return err
-		}
+	// 不限制复制延迟时只检查连通性
+	if c.MaxReplicationLag > 0 {
+		options = append(options,
+			dbkit.WithMaxLag(time.Duration(c.MaxReplicationLag)*time.Second),
+			dbkit.WithLagProbe(dbkit.PostgresReplicationLag),
+		)
 	}
+	return options
+}
 
-	for _, slave := range c.Slaves {
-		err := c.validateInstance(slave)
-		if err != nil {
-			return err
+// Validate 主库和从库必须指定用户, 额外的数据库必须指定名字
+func (c *psqlProviderConfig) Validate() error {
+	for _, ic := range append([]*psqlConfig{c.Default, c.Master}, c.Slaves...) {
+		if ic != nil && ic.User == "" {
+			return errors.New("postgresql user is required")
 		}
 	}
 
 	for _, item := range c.Items {
-		err := c.validateExtraInstance(item)
-		if err != nil {
-			return err
+		if item != nil && item.Name == "" {
+			return errors.New("postgresql extra instance name is required")
 		}
 	}
-
 	return nil
 }
 
-func (c *psqlProviderConfig) validateInstance(ic *psqlConfig) error {
-	if ic == nil || ic.Host == "" || ic.User == "" {
-		return errEmptyConfig
-	}
-
-	if ic.Schema == "" {
-		ic.Schema = "public"
-	}
-
-	// setup default config value
+func (ic *psqlConfig) SetDefaults() {
 	if ic.Port == 0 {
 		if ic.UsePgBouncer {
 			ic.Port = 6432
@@ -107,25 +113,21 @@ func (c *psqlProviderConfig) validateInstance(ic *psqlConfig) error {
 		}
 	}
 
-	if ic.MaxOpenConn == 0 {
-		ic.MaxOpenConn = 100
+	if ic.MaxOpenConns == 0 {
+		ic.MaxOpenConns = ic.MaxOpenConn
 	}
 
-	if ic.ConnMaxLifetime == 0 {
-		ic.ConnMaxLifetime = 50 * 60
+	defaults := dbkit.PoolConfig{
+		MaxOpenConns:    100,  // 使用PgBouncer时对应max_client_conn
+		ConnMaxLifetime: 3000, // 应小于PostgreSQL的idle_in_transaction_session_timeout, 默认为60m
 	}
-
-	return nil
-}
-
-func (c *psqlProviderConfig) validateExtraInstance(ic *psqlConfig) error {
-	if ic == nil || ic.Host == "" || ic.Name == "" {
-		return errEmptyConfig
+	if ic.UsePgBouncer {
+		defaults.ConnMaxLifetime = -1 // 由PgBouncer管理服务端连接
 	}
+	ic.MergeDefaults(defaults)
+}
 
-	// setup default config value
-	if ic.Port == 0 {
-		ic.Port = 5432
-	}
-	return nil
+// getHooks 根据配置创建客户端的钩子
+func (c *psqlProviderConfig) getHooks(logger provider.Logger) []provider.DbHook {
+	return dbkit.NewHooks(logger, time.Duration(c.SlowQueryThreshold)*time.Millisecond, c.SqlComment)
 }
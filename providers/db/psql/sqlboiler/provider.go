@@ -1,9 +1,11 @@
 package sqlboiler
 
 import (
-	"sync/atomic"
+	"context"
+	"database/sql"
 
 	"github.com/aarondl/sqlboiler/v4/boil"
+	"github.com/hdget/sdk/common/dbkit"
 	"github.com/hdget/sdk/common/provider"
 	"github.com/pkg/errors"
 )
@@ -13,7 +15,7 @@ type sqlboilerProvider struct {
 	masterDb  provider.DbClient
 	slaveDbs  []provider.DbClient
 	extraDbs  map[string]provider.DbClient
-	slaveIdx  uint64 // 用于轮询选择 slave
+	replicas  *dbkit.ReplicaSet // 检查 slave 的可用状态
 }
 
 func newProvider(configProvider provider.Config, logger provider.Logger) (provider.Database, error) {
@@ -22,13 +24,14 @@ func newProvider(configProvider provider.Config, logger provider.Logger) (provid
 		return nil, err
 	}
 
+	hooks := config.getHooks(logger)
 	p := &sqlboilerProvider{
 		slaveDbs: make([]provider.DbClient, len(config.Slaves)),
 		extraDbs: make(map[string]provider.DbClient),
 	}
 
 	if config.Default != nil {
-		p.defaultDb, err = newClient(config.Default, logger)
+		p.defaultDb, err = newClient(config.Default, logger, hooks...)
 		if err != nil {
 			logger.Fatal("init postgresql default db connection", "err", err)
 		}
@@ -39,7 +42,7 @@ func newProvider(configProvider provider.Config, logger provider.Logger) (provid
 	}
 
 	if config.Master != nil {
-		p.masterDb, err = newClient(config.Master, logger)
+		p.masterDb, err = newClient(config.Master, logger, hooks...)
 		if err != nil {
 			logger.Fatal("init postgresql master db connection", "err", err)
 		}
@@ -48,7 +51,7 @@ func newProvider(configProvider provider.Config, logger provider.Logger) (provid
 	}
 
 	for i, slaveConf := range config.Slaves {
-		p.slaveDbs[i], err = newClient(slaveConf, logger)
+		p.slaveDbs[i], err = newClient(slaveConf, logger, hooks...)
 		if err != nil {
 			logger.Fatal("init postgresql slave db connection", "slave", i, "err", err)
 		}
@@ -57,7 +60,7 @@ func newProvider(configProvider provider.Config, logger provider.Logger) (provid
 	}
 
 	for _, extraConf := range config.Items {
-		p.extraDbs[extraConf.Name], err = newClient(extraConf, logger)
+		p.extraDbs[extraConf.Name], err = newClient(extraConf, logger, hooks...)
 		if err != nil {
 			logger.Fatal("new postgresql extra db connection", "name", extraConf.Name, "err", err)
 		}
@@ -65,6 +68,15 @@ func newProvider(configProvider provider.Config, logger provider.Logger) (provid
 		logger.Debug("init postgresql extra db connection", "name", extraConf.Name, "host", extraConf.Host)
 	}
 
+	// 配置中的连接池大小修改后立即生效, 配置不支持监听时忽略
+	_ = configProvider.Watch(configSection, func(_, _ any) {
+		if err := p.reloadPool(configProvider); err != nil {
+			logger.Error("reload postgresql pool config", "err", err)
+		}
+	})
+
+	p.replicas = dbkit.NewReplicaSet(logger, p.slaveDbs, config.getReplicaOptions()...)
+
 	return p, nil
 }
 
@@ -89,7 +101,7 @@ func NewClient(configProvider provider.Config, logger provider.Logger, database
 		c.Database = database[0]
 	}
 
-	client, err := newClient(c, logger)
+	client, err := newClient(c, logger, config.getHooks(logger)...)
 	if err != nil {
 		return nil, errors.Wrap(err, "init postgresql sys db connection")
 	}
@@ -119,11 +131,17 @@ func (p *sqlboilerProvider) Named(name string) provider.DbClient {
 	return p.extraDbs[name]
 }
 
-// Read 返回用于读操作的数据库客户端（从 slave 中轮询选择，无 slave 则返回 master 或 default）
+// Read 返回用于读操作的数据库客户端（从可用的 slave 中轮询选择，无可用 slave 则返回 master 或 default）
 func (p *sqlboilerProvider) Read() provider.DbClient {
-	if len(p.slaveDbs) > 0 {
-		idx := atomic.AddUint64(&p.slaveIdx, 1) - 1
-		return p.slaveDbs[idx%uint64(len(p.slaveDbs))]
+	return p.ReadContext(context.Background())
+}
+
+// ReadContext 同Read，ctx 中有事务或者通过 dbkit.WithPrimary 标记时返回 master 或 default
+func (p *sqlboilerProvider) ReadContext(ctx context.Context) provider.DbClient {
+	if !dbkit.UsePrimary(ctx) {
+		if client := p.replicas.Next(); client != nil {
+			return client
+		}
 	}
 	return p.Write()
 }
@@ -135,3 +153,43 @@ func (p *sqlboilerProvider) Write() provider.DbClient {
 	}
 	return p.defaultDb
 }
+
+// reloadPool 重新读取配置并调整各连接的连接池参数
+func (p *sqlboilerProvider) reloadPool(configProvider provider.Config) error {
+	config, err := newConfig(configProvider)
+	if err != nil {
+		return err
+	}
+
+	pairs := map[provider.DbClient]*psqlConfig{
+		p.defaultDb: config.Default,
+		p.masterDb:  config.Master,
+	}
+	for i, slaveConf := range config.Slaves {
+		if i < len(p.slaveDbs) {
+			pairs[p.slaveDbs[i]] = slaveConf
+		}
+	}
+	for _, extraConf := range config.Items {
+		pairs[p.extraDbs[extraConf.Name]] = extraConf
+	}
+
+	for client, c := range pairs {
+		if client != nil && c != nil {
+			c.ApplyPool(client.Db())
+		}
+	}
+	return nil
+}
+
+// Stats 各数据库客户端的连接池统计信息
+func (p *sqlboilerProvider) Stats() map[string]sql.DBStats {
+	return dbkit.Stats(p.defaultDb, p.masterDb, p.slaveDbs, p.extraDbs)
+}
+
+// Close 关闭所有数据库连接
+func (p *sqlboilerProvider) Close() error {
+	p.replicas.Close()
+
+	return dbkit.Close(p.defaultDb, p.masterDb, p.slaveDbs, p.extraDbs)
+}
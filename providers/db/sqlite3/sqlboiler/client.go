@@ -1,44 +1,53 @@
 package sqlboiler
 
 import (
-	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
-	"time"
+	"sort"
+	"strconv"
+	"sync/atomic"
 
+	"github.com/hdget/sdk/common/dbkit"
 	"github.com/hdget/sdk/common/provider"
 	_ "modernc.org/sqlite"
 )
 
-type sqlite3Client struct {
-	*sql.DB
-	logger provider.Logger
-}
+// sqlite3Client 使用dbkit.Client统一实现钩子和事务
+type sqlite3Client = dbkit.Client
+
+// connRole 连接的用途
+type connRole int
+
+const (
+	roleReadWrite connRole = iota // 读写连接池
+	roleWriter                    // 只有一个连接的写连接
+	roleReader                    // 只读连接池
+)
 
 const (
-	// 这里设置解析时间类型https://github.com/go-sql-driver/mysql#timetime-support
-	// DSN (Data Type NickName): username:password@protocol(address)/dbname?param=value
-	dsnTemplate = "file:%s?_loc=Local"
+	dsnTemplate = "file:%s?%s"
+	memoryDb    = ":memory:"
+)
+
+var (
+	defaultDsnParams = map[string]string{
+		"_loc": "Local",
+	}
+	memoryDbSeq atomic.Int64
 )
 
-func newClient(c *sqliteProviderConfig, logger provider.Logger, args ...string) (provider.DbClient, error) {
-	var absDbFile string
-	if len(args) > 0 {
-		absDbFile = args[0]
-	} else {
-		if !filepath.IsAbs(c.DbPath) {
-			workDir, _ := os.Getwd()
-			absDbFile = filepath.Join(workDir, c.DbPath)
-		} else {
-			absDbFile = c.DbPath
-		}
-	}
-
-	// 构造连接参数
-	dsn := fmt.Sprintf(dsnTemplate, absDbFile)
-	db, err := sql.Open("sqlite", dsn)
+// newClient 创建缺省数据库的连接
+func newClient(c *sqliteProviderConfig, logger provider.Logger) (provider.DbClient, error) {
+	return openClient(c, c.getDefault(), c.getRole(), logger)
+}
+
+// openClient 按role创建ic对应数据库的连接, 每个连接建立时执行配置的pragma
+func openClient(c *sqliteProviderConfig, ic *sqliteConfig, role connRole, logger provider.Logger) (provider.DbClient, error) {
+	dbPath, memory := resolvePath(ic.DbPath)
+	db, err := sql.Open("sqlite", getDsn(c, ic, dbPath, memory, role))
 	if err != nil {
 		return nil, err
 	}
@@ -47,91 +56,92 @@ func newClient(c *sqliteProviderConfig, logger provider.Logger, args ...string)
 	err = db.QueryRow("PRAGMA user_version").Scan(&userVersion)
 	if err != nil {
 		_ = db.Close()
-		return nil, fmt.Errorf("fail connect db: %s", absDbFile)
+		return nil, fmt.Errorf("fail connect db: %s, err: %v", dbPath, err)
 	}
 
-	// https://www.alexedwards.net/blog/configuring-sqldb
-	// https://making.pusher.com/production-ready-connection-pooling-in-go
-	// Avoid issue:
-	// packets.go:123: closing bad idle connection: EOF
-	// connection.go:173: driver: bad connection
-	db.SetConnMaxLifetime(3 * time.Minute)
+	pool := ic.PoolConfig
+	if memory {
+		// 所有连接都关闭后内存数据库会被销毁, 需要一直保留空闲连接
+		pool.MaxIdleConns = max(pool.MaxIdleConns, 0)
+		pool.ConnMaxLifetime, pool.ConnMaxIdleTime = -1, -1
+	}
+	if role == roleWriter {
+		pool.MaxOpenConns, pool.MaxIdleConns = 1, 1
+	}
+	pool.ApplyPool(db)
 
-	return &sqlite3Client{DB: db, logger: logger}, nil
+	return dbkit.NewClient(db, logger, c.getHooks(logger)...), nil
 }
 
-func (m *sqlite3Client) Close() error {
-	return m.DB.Close()
+// resolvePath 相对路径基于工作目录, :memory:转换为进程内唯一的共享缓存内存数据库
+func resolvePath(dbPath string) (string, bool) {
+	if dbPath == memoryDb {
+		return "sdk_memory_" + strconv.FormatInt(memoryDbSeq.Add(1), 10), true
+	}
+
+	if !filepath.IsAbs(dbPath) {
+		workDir, _ := os.Getwd()
+		return filepath.Join(workDir, dbPath), false
+	}
+	return dbPath, false
 }
 
-func (m *sqlite3Client) Db() *sql.DB {
-	return m.DB
+// getDsn 构造连接参数, pragma通过_pragma参数在每个连接建立时执行
+func getDsn(c *sqliteProviderConfig, ic *sqliteConfig, dbPath string, memory bool, role connRole) string {
+	params := make(map[string]string)
+	for k, v := range defaultDsnParams {
+		params[k] = v
+	}
+	if memory {
+		params["mode"] = "memory"
+		params["cache"] = "shared"
+	}
+	if role == roleWriter {
+		params["_txlock"] = "immediate"
+	}
+
+	query := dbkit.EncodeParams(params, ic.Params)
+	if pragmas := c.getPragmas(memory, role); len(pragmas) > 0 {
+		query += "&" + url.Values{"_pragma": pragmas}.Encode()
+	}
+	return fmt.Sprintf(dsnTemplate, dbPath, query)
 }
 
-// RunInTransaction 在事务中执行函数，支持嵌套事务（通过 SAVEPOINT 实现）
-func (m *sqlite3Client) RunInTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
-	// 检查是否已在事务中
-	if tx, ok := ctx.Value(provider.TxCtxKey{}).(*sql.Tx); ok {
-		// 已在事务中，创建 SAVEPOINT 实现嵌套事务
-		spName := fmt.Sprintf("sp_%d", time.Now().UnixNano())
-
-		// 创建 SAVEPOINT
-		_, err := tx.ExecContext(ctx, fmt.Sprintf("SAVEPOINT %s", spName))
-		if err != nil {
-			// SAVEPOINT 创建失败，说明事务可能已 aborted
-			// 记录详细错误信息，帮助定位问题
-			m.logger.Error("create savepoint failed",
-				"savepoint", spName,
-				"error", err,
-				"hint", "transaction may be aborted by previous SQL error")
-			return fmt.Errorf("create savepoint %s failed: %w", spName, err)
-		}
-
-		err = fn(ctx)
-		if err != nil {
-			// 回滚到 SAVEPOINT
-			_, rbErr := tx.ExecContext(ctx, fmt.Sprintf("ROLLBACK TO SAVEPOINT %s", spName))
-			if rbErr != nil {
-				m.logger.Error("rollback to savepoint failed",
-					"savepoint", spName,
-					"error", rbErr,
-					"original_error", err)
-				// 返回原始错误，但记录回滚失败信息
-				return fmt.Errorf("rollback to savepoint %s failed: %w (original: %v)", spName, rbErr, err)
-			}
-			return err
-		}
-
-		// 释放 SAVEPOINT
-		_, relErr := tx.ExecContext(ctx, fmt.Sprintf("RELEASE SAVEPOINT %s", spName))
-		if relErr != nil {
-			m.logger.Warn("release savepoint failed",
-				"savepoint", spName,
-				"error", relErr,
-				"hint", "savepoint will be released at transaction commit")
-		}
-		return nil
-	}
-
-	// 开始新事务
-	tx, err := m.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("begin transaction failed: %w", err)
+// getPragmas 每个连接建立时执行的pragma, busy_timeout需要最先设置, 保证修改日志模式时可以等待锁
+func (c *sqliteProviderConfig) getPragmas(memory bool, role connRole) []string {
+	var pragmas []string
+	if c.BusyTimeout > 0 {
+		pragmas = append(pragmas, fmt.Sprintf("busy_timeout(%d)", c.BusyTimeout))
+	}
+	if c.JournalMode != "" && !memory && role != roleReader {
+		pragmas = append(pragmas, fmt.Sprintf("journal_mode(%s)", c.JournalMode))
+	}
+	if c.Synchronous != "" {
+		pragmas = append(pragmas, fmt.Sprintf("synchronous(%s)", c.Synchronous))
+	}
+	if c.ForeignKeys {
+		pragmas = append(pragmas, "foreign_keys(1)")
 	}
 
-	defer func() {
-		if p := recover(); p != nil {
-			_ = tx.Rollback()
-			panic(p)
-		}
-	}()
+	names := make([]string, 0, len(c.Pragmas))
+	for name := range c.Pragmas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pragmas = append(pragmas, fmt.Sprintf("%s(%s)", name, c.Pragmas[name]))
+	}
 
-	// 将事务放入 context，支持嵌套事务检测
-	txCtx := context.WithValue(ctx, provider.TxCtxKey{}, tx)
-	err = fn(txCtx)
-	if err != nil {
-		_ = tx.Rollback()
-		return err
+	if role == roleReader {
+		pragmas = append(pragmas, "query_only(1)")
+	}
+	return pragmas
+}
+
+// getRole 缺省数据库, 主库和额外数据库的连接用途
+func (c *sqliteProviderConfig) getRole() connRole {
+	if c.SingleWriter {
+		return roleWriter
 	}
-	return tx.Commit()
+	return roleReadWrite
 }
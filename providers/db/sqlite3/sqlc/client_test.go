@@ -1,10 +1,14 @@
 package sqlc
 
 import (
+	"context"
 	"database/sql"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
+
+	"github.com/hdget/sdk/common/provider"
 )
 
 func TestSqlDB(t *testing.T) {
@@ -83,3 +87,198 @@ func TestSqlite3ClientImplementsDbClient(t *testing.T) {
 		Db() *sql.DB
 	} = &sqlite3Client{}
 }
+
+func TestGetDsn(t *testing.T) {
+	cfg, err := newFileConfig("test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.SingleWriter = true
+	cfg.ForeignKeys = true
+	cfg.Pragmas = map[string]string{"cache_size": "-20000"}
+
+	want := "file:/data/test.db?_loc=Local&_txlock=immediate&_pragma=busy_timeout%285000%29&_pragma=journal_mode%28WAL%29" +
+		"&_pragma=synchronous%28NORMAL%29&_pragma=foreign_keys%281%29&_pragma=cache_size%28-20000%29"
+	if dsn := getDsn(cfg, cfg.getDefault(), "/data/test.db", false, cfg.getRole()); dsn != want {
+		t.Fatalf("getDsn() = %s, want %s", dsn, want)
+	}
+
+	// 内存数据库不设置日志模式, 只读连接池只允许查询
+	want = "file:sdk_memory_1?_loc=Local&cache=shared&mode=memory&_pragma=busy_timeout%285000%29" +
+		"&_pragma=synchronous%28NORMAL%29&_pragma=foreign_keys%281%29&_pragma=cache_size%28-20000%29&_pragma=query_only%281%29"
+	if dsn := getDsn(cfg, cfg.getDefault(), "sdk_memory_1", true, roleReader); dsn != want {
+		t.Fatalf("getDsn() = %s, want %s", dsn, want)
+	}
+}
+
+// newTestProvider 使用缺省配置创建provider, update用于修改配置
+func newTestProvider(t *testing.T, dbPath string, update func(c *sqliteProviderConfig)) *sqlite3Provider {
+	t.Helper()
+
+	c, err := newFileConfig(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update != nil {
+		update(c)
+	}
+
+	p, err := newProvider(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func execTest(t *testing.T, client provider.DbClient, queries ...string) {
+	t.Helper()
+	for _, query := range queries {
+		if _, err := client.Exec(query); err != nil {
+			t.Fatalf("exec %s: %v", query, err)
+		}
+	}
+}
+
+func countTest(t *testing.T, client provider.DbClient, table string) int {
+	t.Helper()
+	var n int
+	if err := client.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+// 内存数据库的所有连接共享同一个数据库, 不同的客户端使用不同的数据库
+func TestMemoryDb(t *testing.T) {
+	p := newTestProvider(t, memoryDb, func(c *sqliteProviderConfig) { c.MaxOpenConns = 4 })
+	execTest(t, p.Write(), "CREATE TABLE t (id INTEGER)", "INSERT INTO t VALUES (1)")
+
+	// 同时占用多个连接, 每个连接都能读到写入的数据
+	ctx := context.Background()
+	conns := make([]*sql.Conn, 4)
+	for i := range conns {
+		conn, err := p.Write().Db().Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = conn.Close() }()
+		conns[i] = conn
+
+		var n int
+		if err = conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM t").Scan(&n); err != nil || n != 1 {
+			t.Fatalf("conn %d count = %d, %v, want 1", i, n, err)
+		}
+	}
+
+	// 所有连接关闭后数据仍然保留
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+	if n := countTest(t, p.Write(), "t"); n != 1 {
+		t.Fatalf("count = %d, want 1", n)
+	}
+
+	// 内存数据库不使用只读连接池
+	other := newTestProvider(t, memoryDb, func(c *sqliteProviderConfig) { c.SingleWriter = true })
+	if other.readerDb != nil || other.Read() != other.Write() {
+		t.Fatal("memory db should not use reader pool")
+	}
+	if _, err := other.Write().Exec("SELECT COUNT(*) FROM t"); err == nil {
+		t.Fatal("memory dbs of different clients should be isolated")
+	}
+}
+
+// 只有一个写连接并且事务以IMMEDIATE方式开始, 并发的读后写事务不会出现database is locked
+func TestSingleWriter(t *testing.T) {
+	p := newTestProvider(t, filepath.Join(t.TempDir(), "test.db"), func(c *sqliteProviderConfig) { c.SingleWriter = true })
+	execTest(t, p.Write(), "CREATE TABLE t (id INTEGER)")
+
+	const writers = 20
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, writers)
+	)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- p.Write().RunInTransaction(context.Background(), func(ctx context.Context) error {
+				tx := ctx.Value(provider.TxCtxKey{}).(provider.DbContextExecutor)
+				var n int
+				if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM t").Scan(&n); err != nil {
+					return err
+				}
+				_, err := tx.ExecContext(ctx, "INSERT INTO t VALUES (?)", n+1)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent write: %v", err)
+		}
+	}
+	if n := countTest(t, p.Read(), "t"); n != writers {
+		t.Fatalf("count = %d, want %d", n, writers)
+	}
+	if stats := p.Stats(); stats["default"].MaxOpenConnections != 1 {
+		t.Fatalf("writer max open conns = %d, want 1", stats["default"].MaxOpenConnections)
+	}
+}
+
+// 读操作使用只读连接池, 不能写入
+func TestReaderPool(t *testing.T) {
+	p := newTestProvider(t, filepath.Join(t.TempDir(), "test.db"), func(c *sqliteProviderConfig) { c.SingleWriter = true })
+	execTest(t, p.Write(), "CREATE TABLE t (id INTEGER)", "INSERT INTO t VALUES (1)")
+
+	reader := p.Read()
+	if reader == p.Write() || reader != p.readerDb {
+		t.Fatal("Read() should return reader pool")
+	}
+	if n := countTest(t, reader, "t"); n != 1 {
+		t.Fatalf("reader count = %d, want 1", n)
+	}
+	if _, err := reader.Exec("INSERT INTO t VALUES (2)"); err == nil {
+		t.Fatal("reader pool should be query only")
+	}
+
+	// 事务中读主库
+	ctx := context.WithValue(context.Background(), provider.TxCtxKey{}, struct{}{})
+	if p.ReadContext(ctx) != p.Write() {
+		t.Fatal("ReadContext() in transaction should return writer")
+	}
+	if _, exists := p.Stats()["reader"]; !exists {
+		t.Fatal("Stats() should contain reader pool")
+	}
+}
+
+// items中的数据库通过Named获取
+func TestNamed(t *testing.T) {
+	dir := t.TempDir()
+	p := newTestProvider(t, filepath.Join(dir, "default.db"), func(c *sqliteProviderConfig) {
+		c.Items = []*sqliteConfig{{Name: "items", DbPath: filepath.Join(dir, "items.db")}}
+	})
+
+	items := p.Named("items")
+	if items == nil || items == p.Default() {
+		t.Fatal("Named() should return items db")
+	}
+	if p.Named("unknown") != nil {
+		t.Fatal("Named() with unknown name should return nil")
+	}
+
+	execTest(t, items, "CREATE TABLE t (id INTEGER)", "INSERT INTO t VALUES (1)")
+	if n := countTest(t, items, "t"); n != 1 {
+		t.Fatalf("count = %d, want 1", n)
+	}
+	if _, err := p.Default().Exec("SELECT COUNT(*) FROM t"); err == nil {
+		t.Fatal("items db should be separated from default db")
+	}
+	if _, exists := p.Stats()["items.items"]; !exists {
+		t.Fatal("Stats() should contain items db")
+	}
+}
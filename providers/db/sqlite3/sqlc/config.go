@@ -1,12 +1,50 @@
 package sqlc
 
 import (
+	"strings"
+	"time"
+
+	"github.com/hdget/sdk/common/config"
+	"github.com/hdget/sdk/common/dbkit"
 	"github.com/hdget/sdk/common/provider"
 	"github.com/pkg/errors"
 )
 
 type sqliteProviderConfig struct {
-	DbPath string `mapstructure:"db"`
+	// DbPath 缺省数据库文件, 相对路径基于工作目录, 为:memory:时使用共享缓存的内存数据库
+	DbPath string          `mapstructure:"db"`
+	Master *sqliteConfig   `mapstructure:"master"`
+	Slaves []*sqliteConfig `mapstructure:"slaves"`
+	Items  []*sqliteConfig `mapstructure:"items"`
+	// JournalMode 日志模式, 内存数据库忽略该配置
+	JournalMode string `mapstructure:"journal_mode" default:"WAL" validate:"oneof=DELETE TRUNCATE PERSIST MEMORY WAL OFF"`
+	// BusyTimeout 等待数据库锁的最长时间, 单位: 毫秒, 小于0时不等待
+	BusyTimeout int `mapstructure:"busy_timeout" default:"5000"`
+	// ForeignKeys 是否启用外键约束
+	ForeignKeys bool `mapstructure:"foreign_keys"`
+	// Synchronous 同步模式, WAL模式下NORMAL可以保证数据库不损坏
+	Synchronous string `mapstructure:"synchronous" default:"NORMAL" validate:"oneof=OFF NORMAL FULL EXTRA"`
+	// Pragmas 其他的pragma, 每个连接建立时执行, 例如: cache_size = "-20000"
+	Pragmas map[string]string `mapstructure:"pragmas"`
+	// SingleWriter 每个数据库只使用一个连接写入, 事务以IMMEDIATE方式开始, 避免并发写入时出现database is locked
+	// 缺省数据库和主库另外使用只读的连接池处理Read
+	SingleWriter bool `mapstructure:"single_writer"`
+	// SlowQueryThreshold 慢查询阈值, 单位: 毫秒, 超过后记录warn日志, 小于0时不记录
+	SlowQueryThreshold int `mapstructure:"slow_query_threshold" default:"1000"`
+	// SqlComment 是否在语句后添加包含tid和uid的注释
+	SqlComment bool `mapstructure:"sql_comment"`
+	// Params 额外的DSN参数, 会覆盖缺省参数, 参考: https://pkg.go.dev/modernc.org/sqlite#Driver.Open
+	Params map[string]string `mapstructure:"params"`
+
+	dbkit.PoolConfig `mapstructure:",squash"`
+}
+
+type sqliteConfig struct {
+	Name   string            `mapstructure:"name"`
+	DbPath string            `mapstructure:"db" validate:"required"`
+	Params map[string]string `mapstructure:"params"`
+
+	dbkit.PoolConfig `mapstructure:",squash"`
 }
 
 const (
@@ -14,8 +52,14 @@ const (
 )
 
 var (
+	defaultPoolConfig = dbkit.PoolConfig{
+		ConnMaxLifetime: 180,
+	}
 	errInvalidConfig = errors.New("invalid config")
-	errEmptyConfig   = errors.New("empty config")
+	configSchema     = &provider.ConfigSchema{
+		Section: configSection,
+		New:     func() any { return &sqliteProviderConfig{} },
+	}
 )
 
 func newConfig(configProvider provider.Config) (*sqliteProviderConfig, error) {
@@ -23,27 +67,55 @@ func newConfig(configProvider provider.Config) (*sqliteProviderConfig, error) {
 		return nil, errInvalidConfig
 	}
 
-	var c *sqliteProviderConfig
-	err := configProvider.Unmarshal(&c, configSection)
+	c := configSchema.New().(*sqliteProviderConfig)
+	err := config.Parse(configProvider, configSection, c)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "parse sqlite3 config")
 	}
 
-	if c == nil {
-		return nil, errEmptyConfig
-	}
+	return c, nil
+}
 
-	err = c.validate()
-	if err != nil {
-		return nil, errors.Wrap(err, "validate sqlite3 config")
+// newFileConfig 只连接dbFile的配置, 其他配置使用缺省值
+func newFileConfig(dbFile string) (*sqliteProviderConfig, error) {
+	c := &sqliteProviderConfig{DbPath: dbFile}
+	if err := config.SetDefaults(c); err != nil {
+		return nil, errors.Wrap(err, "set sqlite3 config defaults")
 	}
-
 	return c, nil
 }
 
-func (c *sqliteProviderConfig) validate() error {
-	if c == nil || c.DbPath == "" {
-		return errInvalidConfig
+// SetDefaults 连接池的缺省值, 模式统一为大写
+func (c *sqliteProviderConfig) SetDefaults() {
+	c.MergeDefaults(defaultPoolConfig)
+	c.JournalMode = strings.ToUpper(c.JournalMode)
+	c.Synchronous = strings.ToUpper(c.Synchronous)
+}
+
+// Validate 缺省数据库和主库至少配置一个, 额外的数据库必须指定名字
+func (c *sqliteProviderConfig) Validate() error {
+	if c.DbPath == "" && c.Master == nil {
+		return errors.New("sqlite3 db or master is required")
+	}
+
+	for _, item := range c.Items {
+		if item != nil && item.Name == "" {
+			return errors.New("sqlite3 extra db name is required")
+		}
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+func (ic *sqliteConfig) SetDefaults() {
+	ic.MergeDefaults(defaultPoolConfig)
+}
+
+// getDefault 缺省数据库的连接配置
+func (c *sqliteProviderConfig) getDefault() *sqliteConfig {
+	return &sqliteConfig{DbPath: c.DbPath, Params: c.Params, PoolConfig: c.PoolConfig}
+}
+
+// getHooks 根据配置创建客户端的钩子
+func (c *sqliteProviderConfig) getHooks(logger provider.Logger) []provider.DbHook {
+	return dbkit.NewHooks(logger, time.Duration(c.SlowQueryThreshold)*time.Millisecond, c.SqlComment)
+}
@@ -1,12 +1,21 @@
 package sqlc
 
 import (
+	"context"
+	"database/sql"
+
+	"github.com/hdget/sdk/common/dbkit"
 	"github.com/hdget/sdk/common/provider"
 	"github.com/pkg/errors"
 )
 
 type sqlite3Provider struct {
-	client provider.DbClient
+	defaultDb provider.DbClient
+	masterDb  provider.DbClient
+	slaveDbs  []provider.DbClient
+	extraDbs  map[string]provider.DbClient
+	readerDb  provider.DbClient // single_writer时处理Read的只读连接池
+	replicas  *dbkit.ReplicaSet
 }
 
 func New(configProvider provider.Config, logger provider.Logger) (provider.Database, error) {
@@ -15,50 +24,157 @@ func New(configProvider provider.Config, logger provider.Logger) (provider.Datab
 		return nil, err
 	}
 
-	client, err := newClient(config, logger)
+	p, err := newProvider(config, logger)
 	if err != nil {
-		return nil, errors.Wrap(err, "new sqlite3 client")
+		return nil, err
 	}
 
-	logger.Debug("init sqlite3 provider", "db", config.DbPath)
-	return &sqlite3Provider{client: client}, nil
+	logger.Debug("init sqlite3 provider", "db", config.DbPath, "single_writer", config.SingleWriter)
+	return p, nil
 }
 
 // NewClient 从指定的文件创建创建数据库连接
 func NewClient(dbFile string, logger provider.Logger) (provider.DbClient, error) {
-	client, err := newClient(nil, logger, dbFile)
+	c, err := newFileConfig(dbFile)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := newClient(c, logger)
 	if err != nil {
 		return nil, errors.Wrapf(err, "connect sqlite3: %s", dbFile)
 	}
 	return client, nil
 }
 
+func newProvider(c *sqliteProviderConfig, logger provider.Logger) (p *sqlite3Provider, err error) {
+	p = &sqlite3Provider{
+		slaveDbs: make([]provider.DbClient, len(c.Slaves)),
+		extraDbs: make(map[string]provider.DbClient),
+	}
+
+	defer func() {
+		if err != nil {
+			_ = p.Close()
+		}
+	}()
+
+	if c.DbPath != "" {
+		p.defaultDb, err = newClient(c, logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "new sqlite3 default client")
+		}
+	}
+
+	if c.Master != nil {
+		p.masterDb, err = openClient(c, c.Master, c.getRole(), logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "new sqlite3 master client")
+		}
+	}
+
+	for i, slaveConf := range c.Slaves {
+		p.slaveDbs[i], err = openClient(c, slaveConf, roleReader, logger)
+		if err != nil {
+			return nil, errors.Wrapf(err, "new sqlite3 slave client, index: %d", i)
+		}
+	}
+
+	for _, extraConf := range c.Items {
+		p.extraDbs[extraConf.Name], err = openClient(c, extraConf, c.getRole(), logger)
+		if err != nil {
+			return nil, errors.Wrapf(err, "new sqlite3 extra client, name: %s", extraConf.Name)
+		}
+	}
+
+	// 只有一个写连接时, 读操作使用单独的只读连接池, 内存数据库的只读连接会被写事务阻塞, 不使用只读连接池
+	writeConf := c.getDefault()
+	if c.Master != nil {
+		writeConf = c.Master
+	}
+	if c.SingleWriter && writeConf.DbPath != memoryDb {
+		p.readerDb, err = openClient(c, writeConf, roleReader, logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "new sqlite3 reader client")
+		}
+	}
+
+	p.replicas = dbkit.NewReplicaSet(logger, p.slaveDbs)
+	return p, nil
+}
+
 func (p *sqlite3Provider) GetCapability() provider.Capability {
 	return Capability
 }
 
 func (p *sqlite3Provider) Default() provider.DbClient {
-	return p.client
+	return p.defaultDb
 }
 
+// Master 返回主库, 没有配置主库时返回缺省数据库
 func (p *sqlite3Provider) Master() provider.DbClient {
-	return p.client
+	return p.Write()
 }
 
 func (p *sqlite3Provider) Slave(i int) provider.DbClient {
-	return p.client
+	if i < 0 || i >= len(p.slaveDbs) {
+		return nil
+	}
+	return p.slaveDbs[i]
 }
 
 func (p *sqlite3Provider) Named(name string) provider.DbClient {
-	return p.client
+	return p.extraDbs[name]
 }
 
-// Read 返回用于读操作的数据库客户端（SQLite3 无读写分离，返回同一客户端）
+// Read 返回用于读操作的数据库客户端（从 slave 中轮询选择，没有 slave 时返回只读连接池或者 master/default）
 func (p *sqlite3Provider) Read() provider.DbClient {
-	return p.client
+	return p.ReadContext(context.Background())
+}
+
+// ReadContext 同Read，ctx 中有事务或者通过 dbkit.WithPrimary 标记时返回 master 或 default
+func (p *sqlite3Provider) ReadContext(ctx context.Context) provider.DbClient {
+	if dbkit.UsePrimary(ctx) {
+		return p.Write()
+	}
+
+	if client := p.replicas.Next(); client != nil {
+		return client
+	}
+	if p.readerDb != nil {
+		return p.readerDb
+	}
+	return p.Write()
 }
 
-// Write 返回用于写操作的数据库客户端（SQLite3 无读写分离，返回同一客户端）
+// Write 返回用于写操作的数据库客户端（返回 master 或 default）
 func (p *sqlite3Provider) Write() provider.DbClient {
-	return p.client
+	if p.masterDb != nil {
+		return p.masterDb
+	}
+	return p.defaultDb
+}
+
+// Stats 各数据库客户端的连接池统计信息, 只读连接池的key为reader
+func (p *sqlite3Provider) Stats() map[string]sql.DBStats {
+	stats := dbkit.Stats(p.defaultDb, p.masterDb, p.slaveDbs, p.extraDbs)
+	if p.readerDb != nil {
+		stats["reader"] = p.readerDb.Db().Stats()
+	}
+	return stats
+}
+
+// Close 关闭所有数据库连接
+func (p *sqlite3Provider) Close() error {
+	if p.replicas != nil {
+		p.replicas.Close()
+	}
+
+	err := dbkit.Close(p.defaultDb, p.masterDb, p.slaveDbs, p.extraDbs)
+	if p.readerDb != nil {
+		if closeErr := p.readerDb.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
@@ -2,11 +2,13 @@ package zerolog
 
 import (
 	"fmt"
-	"github.com/hdget/sdk/common/provider"
-	"github.com/pkg/errors"
 	"os"
 	"path/filepath"
 	"runtime"
+
+	"github.com/hdget/sdk/common/config"
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
 )
 
 type zerologProviderConfig struct {
@@ -14,6 +16,12 @@ type zerologProviderConfig struct {
 	Dir      string        `mapstructure:"dir"`      // 日志目录
 	Filename string        `mapstructure:"filename"` // 日志文件名
 	Level    string        `mapstructure:"level"`    // 默认日志级别
+	// Output 输出方式: file为文件和控制台, stdout为只输出JSON到标准输出, console为只输出到控制台
+	Output string `mapstructure:"output" default:"file" validate:"oneof=file stdout console"`
+	// Levels 按名字设置子日志的级别, 没有设置的子日志使用上一级或者默认日志级别, 例如: redis = "warn"
+	Levels map[string]string `mapstructure:"levels"`
+	// Sampling 日志采样, 不设置时不采样
+	Sampling *samplingConfig `mapstructure:"sampling"`
 }
 
 type rotateConfig struct {
@@ -23,9 +31,19 @@ type rotateConfig struct {
 	Compress  bool `mapstructure:"compress"`   // 是否压缩日志文件
 }
 
+// samplingConfig 每个周期内每个级别先输出burst条日志, 之后每thereafter条输出一条, warn及以上级别不采样
+type samplingConfig struct {
+	Burst      int `mapstructure:"burst"`              // 每个周期内不采样的日志条数
+	Period     int `mapstructure:"period" default:"1"` // 周期, 单位: 秒
+	Thereafter int `mapstructure:"thereafter"`         // 超过burst后每多少条输出一条, 为0时丢弃
+}
+
 const (
 	configSection      = "sdk.log"
 	linuxDefaultDir    = "/var/log"
+	outputFile         = "file"
+	outputStdout       = "stdout"
+	outputConsole      = "console"
 	nonLinuxDefaultDir = "logs"
 )
 
@@ -37,33 +55,38 @@ var (
 		Dir:      "logs",
 		Filename: "app.log",
 		Level:    "debug",
+		Output:   outputFile,
 	}
 
 	errInvalidConfig = errors.New("invalid config")
+	configSchema     = &provider.ConfigSchema{
+		Section:  configSection,
+		New:      func() any { return &zerologProviderConfig{} },
+		Optional: true, // 没有配置时使用缺省配置
+	}
 )
 
-// NewConfig 解析Config
+// NewConfig 解析Config, 没有配置时使用缺省配置, 配置有误时返回错误
 func newConfig(configProvider provider.Config) (*zerologProviderConfig, error) {
-	if configProvider == nil {
+	if configProvider == nil || configProvider.Get(configSection) == nil {
 		return getDefaultConfig(), nil
 	}
 
-	var c *zerologProviderConfig
-	err := configProvider.Unmarshal(&c, configSection)
+	c := configSchema.New().(*zerologProviderConfig)
+	err := config.Parse(configProvider, configSection, c)
 	if err != nil {
-		return getDefaultConfig(), nil
+		return nil, errors.Wrap(err, "parse zerolog provider config")
 	}
 
-	if c == nil {
-		return getDefaultConfig(), nil
-	}
+	return c, nil
+}
 
-	// validate sdkConfig
-	if c.Filename == "" || c.Rotate == nil {
-		return nil, errInvalidConfig
+// Validate 输出到文件时必须设置文件名和截断设置
+func (c *zerologProviderConfig) Validate() error {
+	if c.Output == outputFile && (c.Filename == "" || c.Rotate == nil) {
+		return errors.New("filename and rotate are required when output to file")
 	}
-
-	return c, nil
+	return nil
 }
 
 func getDefaultConfig() *zerologProviderConfig {
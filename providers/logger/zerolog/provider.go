@@ -6,21 +6,41 @@
 package zerolog
 
 import (
+	"context"
+	"io"
 	"log"
+	"os"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/hdget/sdk/common/bizctx"
 	"github.com/hdget/sdk/common/provider"
 	"github.com/hdget/utils/logger"
 	"github.com/rs/zerolog"
 )
 
 type zerologLoggerProvider struct {
-	logger zerolog.Logger
+	base    zerolog.Logger // 不包含logger字段, 用于创建子日志
+	logger  zerolog.Logger
+	name    string
+	levels  *levelSwitch
+	closers []io.Closer // 需要在退出时关闭的输出, 子日志为空
+}
+
+// levelSwitch 根日志和所有子日志共享的日志级别, 配置修改后立即生效
+type levelSwitch struct {
+	state atomic.Pointer[levelState]
+}
+
+type levelState struct {
+	root  zerolog.Level
+	named map[string]zerolog.Level
 }
 
 const (
 	defaultCallerSkipFrameCount = 1 // 缺省的忽略帧数目
-
+	loggerNameField             = "logger"
 )
 
 // New initialize zerolog instance
@@ -30,79 +50,166 @@ func New(configProvider provider.Config) (provider.Logger, error) {
 		return nil, err
 	}
 
-	// 设置日志级别
-	switch strings.ToLower(c.Level) {
+	// 由levelSwitch按日志名字过滤级别, zerolog的全局级别不再过滤
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	levels := &levelSwitch{}
+	levels.set(c)
+
+	// 配置文件中的日志级别修改后立即生效, 配置不支持监听时忽略
+	if configProvider != nil {
+		_ = configProvider.Watch(configSection, func(_, _ any) {
+			if nc, err := newConfig(configProvider); err == nil {
+				levels.set(nc)
+			}
+		})
+	}
+
+	w, closers, err := newWriter(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// 给zerorlogger和stdlogger实例赋值
+	l := zerolog.New(w).With().Timestamp().Logger()
+	if sampler := newSampler(c.Sampling); sampler != nil {
+		l = l.Sample(sampler)
+	}
+
+	return &zerologLoggerProvider{base: l, logger: l, levels: levels, closers: closers}, nil
+}
+
+// newWriter 根据输出方式创建日志输出
+func newWriter(c *zerologProviderConfig) (io.Writer, []io.Closer, error) {
+	switch c.Output {
+	case outputStdout:
+		return os.Stdout, nil, nil
+	case outputConsole:
+		return newConsoleLogger(), nil, nil
+	}
+
+	// 设置多个输出, 输出到rotateLogs和stdout
+	rotateLogger, err := newRotateLogger(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var closers []io.Closer
+	if closer, ok := rotateLogger.(io.Closer); ok {
+		closers = append(closers, closer)
+	}
+	return zerolog.MultiLevelWriter(rotateLogger, newConsoleLogger()), closers, nil
+}
+
+// newSampler trace, debug和info级别的日志先输出burst条, 之后每thereafter条输出一条
+func newSampler(c *samplingConfig) zerolog.Sampler {
+	if c == nil {
+		return nil
+	}
+
+	newBurstSampler := func() zerolog.Sampler {
+		s := &zerolog.BurstSampler{Burst: uint32(max(c.Burst, 0)), Period: time.Duration(c.Period) * time.Second}
+		if c.Thereafter > 0 {
+			s.NextSampler = &zerolog.BasicSampler{N: uint32(c.Thereafter)}
+		}
+		return s
+	}
+
+	return zerolog.LevelSampler{
+		TraceSampler: newBurstSampler(),
+		DebugSampler: newBurstSampler(),
+		InfoSampler:  newBurstSampler(),
+	}
+}
+
+// parseLevel 解析日志级别, 无法识别时为debug
+func parseLevel(level string) zerolog.Level {
+	switch strings.ToLower(level) {
+	case "trace":
+		return zerolog.TraceLevel
 	case "debug":
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		return zerolog.DebugLevel
 	case "info":
-		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+		return zerolog.InfoLevel
 	case "warn":
-		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+		return zerolog.WarnLevel
 	case "error":
-		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+		return zerolog.ErrorLevel
 	case "fatal":
-		zerolog.SetGlobalLevel(zerolog.FatalLevel)
+		return zerolog.FatalLevel
 	case "panic":
-		zerolog.SetGlobalLevel(zerolog.PanicLevel)
+		return zerolog.PanicLevel
 	default:
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		return zerolog.DebugLevel
 	}
+}
 
-	// new console logger
-	consoleLogger := newConsoleLogger()
-
-	// 设置多个输出, 输出到rotateLogs和stdout
-	rotateLogger, err := newRotateLogger(c)
-	if err != nil {
-		return nil, err
+func (s *levelSwitch) set(c *zerologProviderConfig) {
+	state := &levelState{root: parseLevel(c.Level), named: make(map[string]zerolog.Level)}
+	for name, level := range c.Levels {
+		state.named[name] = parseLevel(level)
 	}
+	s.state.Store(state)
+}
 
-	// 多个日志通道输出
-	multi := zerolog.MultiLevelWriter(rotateLogger, consoleLogger)
-
-	// 给zerorlogger和stdlogger实例赋值
-	provider := &zerologLoggerProvider{logger: zerolog.New(multi).With().Timestamp().Logger()}
+// enabled 子日志依次使用自己, 上一级和默认日志级别, 例如: redis.pool, redis
+func (s *levelSwitch) enabled(name string, level zerolog.Level) bool {
+	state := s.state.Load()
+	for name != "" {
+		if l, exists := state.named[name]; exists {
+			return level >= l
+		}
 
-	return provider, nil
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return level >= state.root
 }
 
 func (p *zerologLoggerProvider) GetCapability() provider.Capability {
 	return Capability
 }
 
+// Close 关闭日志文件, 写入是同步的, 关闭前无需额外flush
+func (p *zerologLoggerProvider) Close() error {
+	for _, closer := range p.closers {
+		if err := closer.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *zerologLoggerProvider) GetStdLogger() *log.Logger {
 	return log.New(p.logger, "stdlog: ", log.Lshortfile|log.Ldate|log.Ltime)
 }
 
 func (p *zerologLoggerProvider) Log(keyvals ...interface{}) error {
-	msgValue, fields, errValue := logger.ParseArgs(keyvals...)
-	p.logger.Trace().Caller(defaultCallerSkipFrameCount).Err(errValue).Fields(fields).Msg(msgValue)
+	msgValue, _, _ := logger.ParseArgs(keyvals...)
+	p.log(zerolog.TraceLevel, msgValue, keyvals)
 	return nil
 }
 
 func (p *zerologLoggerProvider) Trace(msg string, keyvals ...interface{}) {
-	_, fields, errValue := logger.ParseArgs(keyvals...)
-	p.logger.Trace().Caller(defaultCallerSkipFrameCount).Err(errValue).Fields(fields).Msg(msg)
+	p.log(zerolog.TraceLevel, msg, keyvals)
 }
 
 func (p *zerologLoggerProvider) Debug(msg string, keyvals ...interface{}) {
-	_, fields, errValue := logger.ParseArgs(keyvals...)
-	p.logger.Debug().Caller(defaultCallerSkipFrameCount).Err(errValue).Fields(fields).Msg(msg)
+	p.log(zerolog.DebugLevel, msg, keyvals)
 }
 
 func (p *zerologLoggerProvider) Info(msg string, keyvals ...interface{}) {
-	_, fields, errValue := logger.ParseArgs(keyvals...)
-	p.logger.Info().Caller(defaultCallerSkipFrameCount).Err(errValue).Fields(fields).Msg(msg)
+	p.log(zerolog.InfoLevel, msg, keyvals)
 }
 
 func (p *zerologLoggerProvider) Warn(msg string, keyvals ...interface{}) {
-	_, fields, errValue := logger.ParseArgs(keyvals...)
-	p.logger.Warn().Caller(defaultCallerSkipFrameCount).Err(errValue).Fields(fields).Msg(msg)
+	p.log(zerolog.WarnLevel, msg, keyvals)
 }
 
 func (p *zerologLoggerProvider) Error(msg string, keyvals ...interface{}) {
-	_, fields, errValue := logger.ParseArgs(keyvals...)
-	p.logger.Error().Caller(defaultCallerSkipFrameCount).Err(errValue).Fields(fields).Msg(msg)
+	p.log(zerolog.ErrorLevel, msg, keyvals)
 }
 
 func (p *zerologLoggerProvider) Fatal(msg string, keyvals ...interface{}) {
@@ -114,3 +221,48 @@ func (p *zerologLoggerProvider) Panic(msg string, keyvals ...interface{}) {
 	_, fields, errValue := logger.ParseArgs(keyvals...)
 	p.logger.Panic().Caller(defaultCallerSkipFrameCount).Err(errValue).Fields(fields).Msg(msg)
 }
+
+// With 返回带有keyvals字段的子日志, 和当前日志共享输出和级别设置
+func (p *zerologLoggerProvider) With(keyvals ...interface{}) provider.Logger {
+	_, fields, errValue := logger.ParseArgs(keyvals...)
+	ctx := p.base.With().Fields(fields)
+	if errValue != nil {
+		ctx = ctx.AnErr(zerolog.ErrorFieldName, errValue)
+	}
+	return p.child(ctx.Logger(), p.name)
+}
+
+// Named 返回指定名字的子日志, 多级名字用.连接, 例如: Named("redis").Named("pool")的名字为redis.pool
+func (p *zerologLoggerProvider) Named(name string) provider.Logger {
+	if p.name != "" {
+		name = p.name + "." + name
+	}
+	return p.child(p.base, name)
+}
+
+// Ctx 返回带有ctx中bizctx元数据的子日志, ctx中没有元数据时返回当前日志
+func (p *zerologLoggerProvider) Ctx(ctx context.Context) provider.Logger {
+	keyvals := bizctx.LogFields(ctx)
+	if len(keyvals) == 0 {
+		return p
+	}
+	return p.With(keyvals...)
+}
+
+func (p *zerologLoggerProvider) child(base zerolog.Logger, name string) *zerologLoggerProvider {
+	l := base
+	if name != "" {
+		l = base.With().Str(loggerNameField, name).Logger()
+	}
+	return &zerologLoggerProvider{base: base, logger: l, name: name, levels: p.levels}
+}
+
+// log 按日志名字对应的级别过滤, Fatal和Panic不经过这里, 因为WithLevel不会退出或者panic
+func (p *zerologLoggerProvider) log(level zerolog.Level, msg string, keyvals []any) {
+	if !p.levels.enabled(p.name, level) {
+		return
+	}
+
+	_, fields, errValue := logger.ParseArgs(keyvals...)
+	p.logger.WithLevel(level).Caller(defaultCallerSkipFrameCount + 1).Err(errValue).Fields(fields).Msg(msg)
+}
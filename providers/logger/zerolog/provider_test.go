@@ -0,0 +1,135 @@
+package zerolog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hdget/sdk/common/bizctx"
+	"github.com/rs/zerolog"
+)
+
+func newTestLogger(c *zerologProviderConfig) (*zerologLoggerProvider, *bytes.Buffer) {
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	levels := &levelSwitch{}
+	levels.set(c)
+
+	buf := &bytes.Buffer{}
+	l := zerolog.New(buf)
+	if sampler := newSampler(c.Sampling); sampler != nil {
+		l = l.Sample(sampler)
+	}
+	return &zerologLoggerProvider{base: l, logger: l, levels: levels}, buf
+}
+
+func readLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid json line: %s", line)
+		}
+		lines = append(lines, m)
+	}
+	buf.Reset()
+	return lines
+}
+
+func TestNamedLevels(t *testing.T) {
+	p, buf := newTestLogger(&zerologProviderConfig{
+		Level:  "info",
+		Levels: map[string]string{"redis": "warn", "redis.pool": "debug"},
+	})
+
+	p.Debug("root debug")
+	p.Info("root info")
+	redis := p.Named("redis")
+	redis.Info("redis info")
+	redis.Warn("redis warn")
+	redis.Named("pool").Debug("pool debug")
+	p.Named("mq").Info("mq info")
+
+	var got []string
+	for _, line := range readLines(t, buf) {
+		got = append(got, line["message"].(string))
+	}
+	want := "root info,redis warn,pool debug,mq info"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("got %v, want %s", got, want)
+	}
+}
+
+func TestWith(t *testing.T) {
+	p, buf := newTestLogger(&zerologProviderConfig{Level: "debug"})
+
+	p.With("tid", 1).Named("redis").With("db", 0).Info("hello", "key", "k")
+
+	lines := readLines(t, buf)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1", len(lines))
+	}
+	line := lines[0]
+	if line["tid"] != float64(1) || line["db"] != float64(0) || line["key"] != "k" || line[loggerNameField] != "redis" {
+		t.Fatalf("unexpected fields: %v", line)
+	}
+}
+
+func TestCtx(t *testing.T) {
+	p, buf := newTestLogger(&zerologProviderConfig{Level: "debug"})
+
+	ctx := bizctx.New(
+		bizctx.MetaKeyTid, int64(1),
+		bizctx.MetaKeyUid, int64(2),
+		bizctx.MetaKeyClientIP, "127.0.0.1",
+		bizctx.MetaKeyTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	)
+	p.Ctx(ctx).Named("redis").Info("with trace")
+	p.Ctx(bizctx.New(bizctx.MetaKeyRequestId, "req-1")).Info("with request id")
+	if p.Ctx(context.Background()) != p {
+		t.Fatal("logger without bizctx metadata should not be copied")
+	}
+
+	lines := readLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	line := lines[0]
+	if line["tid"] != float64(1) || line["uid"] != float64(2) || line["client_ip"] != "127.0.0.1" ||
+		line["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || line[loggerNameField] != "redis" {
+		t.Fatalf("unexpected fields: %v", line)
+	}
+	if line = lines[1]; line["trace_id"] != "req-1" || line["tid"] != nil {
+		t.Fatalf("unexpected fields: %v", line)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	p, buf := newTestLogger(&zerologProviderConfig{
+		Level:    "debug",
+		Sampling: &samplingConfig{Burst: 2, Period: 60, Thereafter: 5},
+	})
+
+	for i := 0; i < 12; i++ {
+		p.Info("hot path")
+		p.Error("error path")
+	}
+
+	infos, errs := 0, 0
+	for _, line := range readLines(t, buf) {
+		switch line["level"] {
+		case "info":
+			infos++
+		case "error":
+			errs++
+		}
+	}
+	// 前2条全部输出, 之后的10条每5条输出1条
+	if infos != 4 || errs != 12 {
+		t.Fatalf("got %d info and %d error lines, want 4 and 12", infos, errs)
+	}
+}
@@ -0,0 +1,19 @@
+package memory
+
+import (
+	"github.com/hdget/sdk/common/provider"
+	"go.uber.org/fx"
+)
+
+const (
+	providerName = "mq-memory"
+)
+
+var Capability = provider.Capability{
+	Category: provider.CategoryMq,
+	Name:     providerName,
+	Module: fx.Module(
+		providerName,
+		fx.Provide(New),
+	),
+}
@@ -0,0 +1,16 @@
+module github.com/hdget/sdk/providers/mq/memory
+
+go 1.24.0
+
+require (
+	github.com/hdget/sdk/common v0.1.21
+	github.com/pkg/errors v0.9.1
+	go.uber.org/fx v1.24.0
+)
+
+require (
+	go.uber.org/dig v1.19.0 // indirect
+	go.uber.org/multierr v1.11.0 // indirect
+	go.uber.org/zap v1.27.1 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+)
@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"io"
+	"sync"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// memoryProvider 进程内的消息队列, 用于测试和不需要持久化消息的场景
+// 每个topic上每个订阅者name对应一个队列, 队列在第一次订阅时创建, 之后即使没有订阅者也会保留消息
+// 发布时还没有任何订阅者的topic, 消息会被丢弃
+type memoryProvider struct {
+	logger      provider.Logger
+	mutex       sync.Mutex
+	queues      map[string]map[string]*queue   // topic => subscriber name => queue
+	deadLetters map[string][]*provider.Message // topic@name => messages
+	closers     []io.Closer                    // 已创建的publisher和subscriber, 退出时统一关闭
+}
+
+func New(logger provider.Logger) (provider.MessageQueue, error) {
+	return &memoryProvider{
+		logger:      logger,
+		queues:      make(map[string]map[string]*queue),
+		deadLetters: make(map[string][]*provider.Message),
+	}, nil
+}
+
+func (m *memoryProvider) GetCapability() provider.Capability {
+	return Capability
+}
+
+func (m *memoryProvider) NewPublisher(name string, args ...*provider.PublisherOption) (provider.MessageQueuePublisher, error) {
+	option := provider.DefaultPublisherOption
+	if len(args) > 0 {
+		option = args[0]
+	}
+
+	p := newPublisher(m, option.PublishDelayMessage)
+	m.track(p)
+	return p, nil
+}
+
+func (m *memoryProvider) NewSubscriber(name string, args ...*provider.SubscriberOption) (provider.MessageQueueSubscriber, error) {
+	option := provider.DefaultSubscriberOption
+	if len(args) > 0 {
+		option = args[0]
+	}
+
+	if option.RetryPolicy != nil && option.RetryPolicy.MaxAttempts < 1 {
+		return nil, errors.New("retry policy max attempts must be greater than 0")
+	}
+
+	s := newSubscriber(m, name, option.RetryPolicy)
+	m.track(s)
+	return s, nil
+}
+
+// ListDeadLetters 查看死信消息, 消息仍然保留在死信队列中
+func (m *memoryProvider) ListDeadLetters(topic, name string, limit int) ([]*provider.Message, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	messages := m.deadLetters[getDeadLetterKey(topic, name)]
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+
+	result := make([]*provider.Message, len(messages))
+	for i, msg := range messages {
+		result[i] = msg.Copy()
+	}
+	return result, nil
+}
+
+// RepublishDeadLetters 将死信消息投递回订阅者name的队列, 不会被其他订阅者收到
+func (m *memoryProvider) RepublishDeadLetters(topic, name string, limit int) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := getDeadLetterKey(topic, name)
+	messages := m.deadLetters[key]
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+
+	q := m.queues[topic][name]
+	if q == nil {
+		return 0, errors.Errorf("queue not found, topic: %s, name: %s", topic, name)
+	}
+
+	m.deadLetters[key] = m.deadLetters[key][len(messages):]
+	for _, msg := range messages {
+		q.push(&envelope{msg: msg})
+	}
+	return len(messages), nil
+}
+
+// Close 关闭所有由该provider创建的publisher和subscriber
+func (m *memoryProvider) Close() error {
+	m.mutex.Lock()
+	closers := m.closers
+	m.closers = nil
+	m.mutex.Unlock()
+
+	var firstErr error
+	for _, closer := range closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (m *memoryProvider) track(closer io.Closer) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.closers = append(m.closers, closer)
+}
+
+// publish 将消息放入topic上所有订阅者name的队列
+func (m *memoryProvider) publish(topic string, msg *provider.Message) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, q := range m.queues[topic] {
+		q.push(&envelope{msg: msg})
+	}
+}
+
+// getQueue 获取订阅者name在topic上的队列, 不存在时创建
+func (m *memoryProvider) getQueue(topic, name string) *queue {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.queues[topic] == nil {
+		m.queues[topic] = make(map[string]*queue)
+	}
+
+	q := m.queues[topic][name]
+	if q == nil {
+		q = newQueue()
+		m.queues[topic][name] = q
+	}
+	return q
+}
+
+func (m *memoryProvider) deadLetter(topic, name string, msg *provider.Message) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := getDeadLetterKey(topic, name)
+	m.deadLetters[key] = append(m.deadLetters[key], msg)
+}
+
+func getDeadLetterKey(topic, name string) string {
+	return topic + "@" + name
+}
@@ -0,0 +1,25 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/hdget/sdk/common/mq/mqtest"
+	"github.com/hdget/sdk/common/provider"
+)
+
+type nopLogger struct {
+	provider.Logger
+}
+
+func (nopLogger) Error(string, ...any) {}
+
+func TestConformance(t *testing.T) {
+	mqtest.Run(t, func(t *testing.T) provider.MessageQueue {
+		mq, err := New(nopLogger{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = mq.(*memoryProvider).Close() })
+		return mq
+	}, mqtest.Features{DelayMessage: true, DeadLetter: true})
+}
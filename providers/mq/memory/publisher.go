@@ -0,0 +1,58 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+type memoryPublisher struct {
+	provider         *memoryProvider
+	useDelayTopology bool
+}
+
+func newPublisher(p *memoryProvider, useDelayTopology bool) *memoryPublisher {
+	return &memoryPublisher{
+		provider:         p,
+		useDelayTopology: useDelayTopology,
+	}
+}
+
+func (p *memoryPublisher) Publish(topic string, messages [][]byte, delaySeconds ...int64) error {
+	msgs := make([]*provider.Message, len(messages))
+	for i, payload := range messages {
+		msgs[i] = provider.NewMessage(payload)
+	}
+	return p.PublishMessage(topic, msgs, delaySeconds...)
+}
+
+// PublishMessage 和rabbitmq保持一致, 只有发布延迟消息的发布者才使用delaySeconds
+func (p *memoryPublisher) PublishMessage(topic string, messages []*provider.Message, delaySeconds ...int64) error {
+	var delay time.Duration
+	if p.useDelayTopology {
+		if len(delaySeconds) == 0 {
+			return errors.New("no delay seconds specified")
+		}
+		delay = time.Duration(delaySeconds[0]) * time.Second
+	}
+
+	for _, msg := range messages {
+		if msg.UUID == "" {
+			msg.UUID = provider.NewUUID()
+		}
+
+		// 发布后调用方修改消息不影响已发布的消息
+		published := msg.Copy()
+		if delay > 0 {
+			time.AfterFunc(delay, func() { p.provider.publish(topic, published) })
+			continue
+		}
+		p.provider.publish(topic, published)
+	}
+	return nil
+}
+
+func (p *memoryPublisher) Close() error {
+	return nil
+}
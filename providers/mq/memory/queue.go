@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/hdget/sdk/common/provider"
+)
+
+// queue 订阅者name在topic上的消息队列, 相同name的订阅竞争消费
+type queue struct {
+	mutex  sync.Mutex
+	items  []*envelope
+	signal chan struct{} // 有新消息时通知等待的订阅
+}
+
+type envelope struct {
+	msg      *provider.Message
+	attempts int // 已经失败的投递次数
+}
+
+func newQueue() *queue {
+	return &queue{signal: make(chan struct{}, 1)}
+}
+
+func (q *queue) push(e *envelope) {
+	q.mutex.Lock()
+	q.items = append(q.items, e)
+	q.mutex.Unlock()
+	q.notify()
+}
+
+// pushFront 未确认的消息放回队列头部, 保持投递顺序
+func (q *queue) pushFront(e *envelope) {
+	q.mutex.Lock()
+	q.items = append([]*envelope{e}, q.items...)
+	q.mutex.Unlock()
+	q.notify()
+}
+
+// pop 取出队列头部的消息, 队列为空时等待, ctx取消或closing关闭时返回nil
+func (q *queue) pop(ctx context.Context, closing <-chan struct{}) *envelope {
+	for {
+		q.mutex.Lock()
+		if len(q.items) > 0 {
+			e := q.items[0]
+			q.items = q.items[1:]
+			remains := len(q.items)
+			q.mutex.Unlock()
+
+			// 还有消息时继续通知其他等待的订阅
+			if remains > 0 {
+				q.notify()
+			}
+			return e
+		}
+		q.mutex.Unlock()
+
+		select {
+		case <-q.signal:
+		case <-ctx.Done():
+			return nil
+		case <-closing:
+			return nil
+		}
+	}
+}
+
+func (q *queue) notify() {
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+type memorySubscriber struct {
+	provider    *memoryProvider
+	name        string
+	retryPolicy *provider.RetryPolicy
+	closing     chan struct{}
+	closeOnce   sync.Once
+	wg          sync.WaitGroup
+}
+
+func newSubscriber(p *memoryProvider, name string, retryPolicy *provider.RetryPolicy) *memorySubscriber {
+	return &memorySubscriber{
+		provider:    p,
+		name:        name,
+		retryPolicy: retryPolicy,
+		closing:     make(chan struct{}),
+	}
+}
+
+// Subscribe 逐条投递消息, 收到Ack或Nack后才投递下一条
+func (s *memorySubscriber) Subscribe(ctx context.Context, topic string) (<-chan *provider.Message, error) {
+	select {
+	case <-s.closing:
+		return nil, errors.New("subscriber is closed")
+	default:
+	}
+
+	q := s.provider.getQueue(topic, s.name)
+	out := make(chan *provider.Message)
+
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			close(out)
+			s.wg.Done()
+		}()
+		s.run(ctx, topic, q, out)
+	}()
+
+	return out, nil
+}
+
+// Close 关闭所有订阅, 正在处理的消息放回队列
+func (s *memorySubscriber) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	s.wg.Wait()
+	return nil
+}
+
+func (s *memorySubscriber) run(ctx context.Context, topic string, q *queue, out chan *provider.Message) {
+	for {
+		e := q.pop(ctx, s.closing)
+		if e == nil {
+			return
+		}
+
+		msg := e.msg.Copy()
+		msg.SetContext(ctx)
+
+		select {
+		case out <- msg:
+		case <-ctx.Done():
+			q.pushFront(e)
+			return
+		case <-s.closing:
+			q.pushFront(e)
+			return
+		}
+
+		select {
+		case <-msg.Acked():
+		case <-msg.Nacked():
+			s.nack(topic, q, e)
+		case <-ctx.Done():
+			q.pushFront(e)
+			return
+		case <-s.closing:
+			q.pushFront(e)
+			return
+		}
+	}
+}
+
+// nack 没有重试策略时立即重新投递, 否则等待退避时间后重新投递, 超过最多投递次数后进入死信队列
+func (s *memorySubscriber) nack(topic string, q *queue, e *envelope) {
+	e.attempts++
+
+	if s.retryPolicy == nil {
+		q.pushFront(e)
+		return
+	}
+
+	if e.attempts >= s.retryPolicy.MaxAttempts {
+		s.provider.logger.Error("message exceeds max attempts, move to dead letter", "topic", topic, "name", s.name, "uuid", e.msg.UUID)
+		s.provider.deadLetter(topic, s.name, e.msg)
+		return
+	}
+
+	backoff := s.retryPolicy.Backoff(e.attempts)
+	if backoff <= 0 {
+		q.push(e)
+		return
+	}
+	time.AfterFunc(backoff, func() { q.push(e) })
+}
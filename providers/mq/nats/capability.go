@@ -0,0 +1,20 @@
+package nats
+
+import (
+	"github.com/hdget/sdk/common/provider"
+	"go.uber.org/fx"
+)
+
+const (
+	providerName = "mq-nats"
+)
+
+var Capability = provider.Capability{
+	Category: provider.CategoryMq,
+	Name:     providerName,
+	Module: fx.Module(
+		providerName,
+		fx.Provide(New),
+	),
+	Config: configSchema,
+}
@@ -0,0 +1,73 @@
+package nats
+
+import (
+	"time"
+
+	"github.com/hdget/sdk/common/config"
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+type NatsConfig struct {
+	// Url nats服务器地址, 多个地址用逗号分隔, 例如: nats://127.0.0.1:4222
+	Url      string `mapstructure:"url" validate:"required"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Token    string `mapstructure:"token"`
+	// Prefix topic的stream名为<PREFIX>_<topic>, subject为<prefix>.<topic>
+	Prefix string `mapstructure:"prefix"`
+	// Replicas stream的副本数量
+	Replicas int `mapstructure:"replicas"`
+	// MaxAge stream中消息的最长保留时间, 单位: 秒, 0表示不限制
+	MaxAge int64 `mapstructure:"max_age"`
+	// AckWait 消息投递后超过该时间未确认会被重新投递, 单位: 秒
+	// 需要大于消息处理时间, 否则消息会被重复处理
+	AckWait int `mapstructure:"ack_wait"`
+}
+
+const (
+	configSection = "sdk.nats"
+)
+
+var (
+	// defaultConfig 零值有意义的配置项的缺省值, 需要在解析配置之前预先填充
+	defaultConfig = NatsConfig{
+		Prefix:   "mq",
+		Replicas: 1,
+		AckWait:  30,
+	}
+	errInvalidConfig = errors.New("invalid config")
+	configSchema     = &provider.ConfigSchema{
+		Section: configSection,
+		New: func() any {
+			c := defaultConfig
+			return &c
+		},
+	}
+)
+
+func newConfig(configProvider provider.Config) (*NatsConfig, error) {
+	if configProvider == nil {
+		return nil, errInvalidConfig
+	}
+
+	c := configSchema.New().(*NatsConfig)
+	err := config.Parse(configProvider, configSection, c)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse nats provider config")
+	}
+
+	if c.Prefix == "" || c.Replicas <= 0 || c.AckWait <= 0 {
+		return nil, errors.Wrap(errInvalidConfig, "prefix, replicas and ack_wait must be specified")
+	}
+
+	return c, nil
+}
+
+func (c *NatsConfig) getMaxAge() time.Duration {
+	return time.Duration(c.MaxAge) * time.Second
+}
+
+func (c *NatsConfig) getAckWait() time.Duration {
+	return time.Duration(c.AckWait) * time.Second
+}
@@ -0,0 +1,107 @@
+package nats
+
+import (
+	"context"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/pkg/errors"
+)
+
+// deadLetter 死信stream中的消息
+type deadLetter struct {
+	seq uint64
+	msg *provider.Message
+}
+
+// ListDeadLetters 查看死信消息, 直接读取死信stream, 不会删除消息
+func (n *natsProvider) ListDeadLetters(topic, name string, limit int) ([]*provider.Message, error) {
+	js, err := n.jetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	deadLetters, err := n.getDeadLetters(ctx, js, topic, name, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*provider.Message, len(deadLetters))
+	for i, d := range deadLetters {
+		messages[i] = d.msg
+	}
+	return messages, nil
+}
+
+// RepublishDeadLetters 将死信消息发布到订阅者单独的subject, 发布确认后才从死信stream中删除
+func (n *natsProvider) RepublishDeadLetters(topic, name string, limit int) (int, error) {
+	js, err := n.jetStream()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTimeout)
+	defer cancel()
+
+	deadLetters, err := n.getDeadLetters(ctx, js, topic, name, limit)
+	if err != nil || len(deadLetters) == 0 {
+		return 0, err
+	}
+
+	if _, err = n.ensureStream(ctx, js, topic); err != nil {
+		return 0, err
+	}
+
+	stream, err := js.Stream(ctx, getDeadLetterStreamName(n.config.Prefix, topic))
+	if err != nil {
+		return 0, errors.Wrapf(err, "get dead letter stream, topic: %s", topic)
+	}
+
+	subject := getRepublishSubject(n.config.Prefix, topic, getName(name))
+	count := 0
+	for _, d := range deadLetters {
+		// 不设置Nats-Msg-Id, 重试次数重新计算
+		if _, err = js.PublishMsg(ctx, newNatsMsg(subject, d.msg, time.Time{})); err != nil {
+			return count, errors.Wrapf(err, "republish dead letter, uuid: %s", d.msg.UUID)
+		}
+
+		if err = stream.DeleteMsg(ctx, d.seq); err != nil {
+			return count, errors.Wrapf(err, "delete dead letter, uuid: %s", d.msg.UUID)
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// getDeadLetters 按顺序读取订阅者name的死信消息, limit小于等于0时读取所有消息
+func (n *natsProvider) getDeadLetters(ctx context.Context, js jetstream.JetStream, topic, name string, limit int) ([]*deadLetter, error) {
+	stream, err := js.Stream(ctx, getDeadLetterStreamName(n.config.Prefix, topic))
+	if err != nil {
+		// 订阅者没有设置重试策略时不会创建死信stream
+		if errors.Is(err, jetstream.ErrStreamNotFound) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "get dead letter stream, topic: %s", topic)
+	}
+
+	subject := getDeadLetterSubject(n.config.Prefix, topic, getName(name))
+	deadLetters := make([]*deadLetter, 0)
+	for seq := uint64(1); limit <= 0 || len(deadLetters) < limit; {
+		raw, err := stream.GetMsg(ctx, seq, jetstream.WithGetMsgSubject(subject))
+		if err != nil {
+			if errors.Is(err, jetstream.ErrMsgNotFound) {
+				break
+			}
+			return nil, errors.Wrap(err, "get dead letter")
+		}
+
+		deadLetters = append(deadLetters, &deadLetter{seq: raw.Sequence, msg: newMessageWithHeader(raw.Header, raw.Data)})
+		seq = raw.Sequence + 1
+	}
+	return deadLetters, nil
+}
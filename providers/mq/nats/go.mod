@@ -0,0 +1,21 @@
+module github.com/hdget/sdk/providers/mq/nats
+
+go 1.24.0
+
+require (
+	github.com/hdget/sdk/common v0.1.21
+	github.com/nats-io/nats.go v1.48.0
+	github.com/pkg/errors v0.9.1
+	go.uber.org/fx v1.24.0
+)
+
+require (
+	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/nats-io/nkeys v0.4.11 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	go.uber.org/dig v1.19.0 // indirect
+	go.uber.org/multierr v1.11.0 // indirect
+	go.uber.org/zap v1.27.1 // indirect
+	golang.org/x/crypto v0.37.0 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+)
@@ -0,0 +1,69 @@
+package nats
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	// headerDeliverAt 延迟消息的到期时间, unix毫秒
+	headerDeliverAt = "Mq-Deliver-At"
+	// headerUUID 消息的UUID, 重新投递的死信消息不能使用原来的Nats-Msg-Id, 否则会被jetstream去重
+	headerUUID = "Mq-Uuid"
+	// jetstream保留的消息头前缀
+	reservedHeaderPrefix = "Nats-"
+)
+
+// newNatsMsg 消息的UUID通过Mq-Uuid发送, 发布时同时作为Nats-Msg-Id用于jetstream去重, Metadata作为消息头发送
+func newNatsMsg(subject string, msg *provider.Message, deliverAt time.Time) *nats.Msg {
+	m := nats.NewMsg(subject)
+	m.Data = msg.Payload
+	for k, v := range msg.Metadata {
+		m.Header.Set(k, v)
+	}
+	m.Header.Set(headerUUID, msg.UUID)
+	if !deliverAt.IsZero() {
+		m.Header.Set(headerDeliverAt, strconv.FormatInt(deliverAt.UnixMilli(), 10))
+	}
+	return m
+}
+
+func newMessage(m jetstream.Msg) *provider.Message {
+	return newMessageWithHeader(m.Headers(), m.Data())
+}
+
+// newMessageWithHeader 没有Mq-Uuid时使用Nats-Msg-Id作为UUID
+func newMessageWithHeader(headers nats.Header, data []byte) *provider.Message {
+	uuid := headers.Get(headerUUID)
+	if uuid == "" {
+		uuid = headers.Get(jetstream.MsgIDHeader)
+	}
+
+	msg := provider.NewMessageWithUUID(uuid, data)
+	for k, v := range headers {
+		if len(v) == 0 || k == headerDeliverAt || k == headerUUID || strings.HasPrefix(k, reservedHeaderPrefix) {
+			continue
+		}
+		msg.Metadata.Set(k, v[0])
+	}
+	return msg
+}
+
+// getDeliverAt 延迟消息的到期时间, 不是延迟消息时返回零值
+func getDeliverAt(m jetstream.Msg) time.Time {
+	v := m.Headers().Get(headerDeliverAt)
+	if v == "" {
+		return time.Time{}
+	}
+
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
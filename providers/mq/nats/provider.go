@@ -0,0 +1,228 @@
+package nats
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/pkg/errors"
+)
+
+// natsProvider 基于nats jetstream的消息队列
+// 每个topic对应一个stream, 每个订阅者name对应stream上的一个durable consumer:
+// 相同name的订阅者共享consumer竞争消费, 不同name的订阅者使用不同的consumer, 都会收到消息
+type natsProvider struct {
+	config  *NatsConfig
+	logger  provider.Logger
+	mutex   sync.Mutex
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	streams map[string]struct{} // 已经创建的stream
+	closers []io.Closer         // 已创建的publisher和subscriber, 退出时统一关闭
+}
+
+const (
+	// 创建stream和consumer等管理操作的超时时间
+	apiTimeout = 10 * time.Second
+)
+
+var (
+	// stream和consumer名字中不能包含的字符
+	invalidNameChars = regexp.MustCompile(`[.*>\s/\\]+`)
+)
+
+func New(configProvider provider.Config, logger provider.Logger) (provider.MessageQueue, error) {
+	config, err := newConfig(configProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	return &natsProvider{config: config, logger: logger, streams: make(map[string]struct{})}, nil
+}
+
+func (n *natsProvider) GetCapability() provider.Capability {
+	return Capability
+}
+
+func (n *natsProvider) NewPublisher(name string, args ...*provider.PublisherOption) (provider.MessageQueuePublisher, error) {
+	option := provider.DefaultPublisherOption
+	if len(args) > 0 {
+		option = args[0]
+	}
+
+	js, err := n.jetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	p := newPublisher(n, js, option.PublishDelayMessage)
+	n.track(p)
+	return p, nil
+}
+
+func (n *natsProvider) NewSubscriber(name string, args ...*provider.SubscriberOption) (provider.MessageQueueSubscriber, error) {
+	option := provider.DefaultSubscriberOption
+	if len(args) > 0 {
+		option = args[0]
+	}
+
+	if option.RetryPolicy != nil && option.RetryPolicy.MaxAttempts < 1 {
+		return nil, errors.New("retry policy max attempts must be greater than 0")
+	}
+
+	js, err := n.jetStream()
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSubscriber(n, js, name, option.RetryPolicy)
+	n.track(s)
+	return s, nil
+}
+
+// Close 关闭所有publisher和subscriber后断开连接
+func (n *natsProvider) Close() error {
+	n.mutex.Lock()
+	closers := n.closers
+	n.closers = nil
+	conn := n.conn
+	n.conn, n.js = nil, nil
+	n.mutex.Unlock()
+
+	var firstErr error
+	for _, closer := range closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if conn != nil {
+		conn.Close()
+	}
+	return firstErr
+}
+
+// jetStream 第一次使用时才连接nats服务器, 所有publisher和subscriber共享同一个连接
+func (n *natsProvider) jetStream() (jetstream.JetStream, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.js != nil {
+		return n.js, nil
+	}
+
+	options := []nats.Option{nats.MaxReconnects(-1)}
+	if n.config.Username != "" {
+		options = append(options, nats.UserInfo(n.config.Username, n.config.Password))
+	}
+	if n.config.Token != "" {
+		options = append(options, nats.Token(n.config.Token))
+	}
+
+	conn, err := nats.Connect(n.config.Url, options...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect nats, url: %s", n.config.Url)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, errors.Wrap(err, "new jetstream")
+	}
+
+	n.conn, n.js = conn, js
+	return js, nil
+}
+
+// ensureStream 创建topic对应的stream, 已经存在时更新配置
+// stream使用interest保留策略, 所有consumer都确认后删除消息, 没有consumer时发布的消息会被丢弃
+// 重新投递的死信消息发布到订阅者单独的subject, 只有该订阅者的consumer会收到
+func (n *natsProvider) ensureStream(ctx context.Context, js jetstream.JetStream, topic string) (string, error) {
+	stream := getStreamName(n.config.Prefix, topic)
+	err := n.createStream(ctx, js, jetstream.StreamConfig{
+		Name:      stream,
+		Subjects:  []string{getSubject(n.config.Prefix, topic), getRepublishSubject(n.config.Prefix, topic, "*")},
+		Retention: jetstream.InterestPolicy,
+		Replicas:  n.config.Replicas,
+		MaxAge:    n.config.getMaxAge(),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "create stream, topic: %s", topic)
+	}
+	return stream, nil
+}
+
+// ensureDeadLetterStream 创建topic对应的死信stream, 死信消息一直保留, 直到被重新投递
+func (n *natsProvider) ensureDeadLetterStream(ctx context.Context, js jetstream.JetStream, topic string) (string, error) {
+	stream := getDeadLetterStreamName(n.config.Prefix, topic)
+	err := n.createStream(ctx, js, jetstream.StreamConfig{
+		Name:     stream,
+		Subjects: []string{getDeadLetterSubject(n.config.Prefix, topic, "*")},
+		Replicas: n.config.Replicas,
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "create dead letter stream, topic: %s", topic)
+	}
+	return stream, nil
+}
+
+// createStream 每个stream只创建一次
+func (n *natsProvider) createStream(ctx context.Context, js jetstream.JetStream, config jetstream.StreamConfig) error {
+	n.mutex.Lock()
+	_, exists := n.streams[config.Name]
+	n.mutex.Unlock()
+	if exists {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, apiTimeout)
+	defer cancel()
+
+	if _, err := js.CreateOrUpdateStream(ctx, config); err != nil {
+		return err
+	}
+
+	n.mutex.Lock()
+	n.streams[config.Name] = struct{}{}
+	n.mutex.Unlock()
+	return nil
+}
+
+func (n *natsProvider) track(closer io.Closer) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.closers = append(n.closers, closer)
+}
+
+func getStreamName(prefix, topic string) string {
+	return strings.ToUpper(prefix) + "_" + getName(topic)
+}
+
+func getSubject(prefix, topic string) string {
+	return prefix + "." + topic
+}
+
+func getDeadLetterStreamName(prefix, topic string) string {
+	return getStreamName(prefix, topic) + "_DLQ"
+}
+
+// getDeadLetterSubject 订阅者name的死信消息的subject, name为*时匹配所有订阅者
+func getDeadLetterSubject(prefix, topic, name string) string {
+	return prefix + "_dlq." + topic + "." + name
+}
+
+// getRepublishSubject 重新投递给订阅者name的死信消息的subject, name为*时匹配所有订阅者
+func getRepublishSubject(prefix, topic, name string) string {
+	return prefix + "_republish." + topic + "." + name
+}
+
+// getName 替换stream和consumer名字中不能包含的字符
+func getName(s string) string {
+	return invalidNameChars.ReplaceAllString(s, "_")
+}
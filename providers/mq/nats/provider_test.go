@@ -0,0 +1,70 @@
+package nats
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hdget/sdk/common/mq/mqtest"
+	"github.com/hdget/sdk/common/provider"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+type nopLogger struct {
+	provider.Logger
+}
+
+func (nopLogger) Error(string, ...any) {}
+
+// TestConformance 需要开启jetstream的nats服务器, 通过NATS_URL指定, 例如: nats-server -js
+func TestConformance(t *testing.T) {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		t.Skip("NATS_URL not set")
+	}
+
+	mqtest.Run(t, func(t *testing.T) provider.MessageQueue {
+		c := defaultConfig
+		c.Url = url
+		p := &natsProvider{config: &c, logger: nopLogger{}, streams: make(map[string]struct{})}
+		t.Cleanup(func() { _ = p.Close() })
+		return p
+	}, mqtest.Features{DelayMessage: true, DeadLetter: true})
+}
+
+func TestGetName(t *testing.T) {
+	if got := getStreamName("mq", "order.created"); got != "MQ_order_created" {
+		t.Fatalf("getStreamName() = %s, want MQ_order_created", got)
+	}
+	if got := getName("a b>*/c"); got != "a_b_c" {
+		t.Fatalf("getName() = %s, want a_b_c", got)
+	}
+	if got := getDeadLetterStreamName("mq", "order.created"); got != "MQ_order_created_DLQ" {
+		t.Fatalf("getDeadLetterStreamName() = %s, want MQ_order_created_DLQ", got)
+	}
+	if got := getDeadLetterSubject("mq", "order.created", "*"); got != "mq_dlq.order.created.*" {
+		t.Fatalf("getDeadLetterSubject() = %s, want mq_dlq.order.created.*", got)
+	}
+	if got := getRepublishSubject("mq", "order.created", "test"); got != "mq_republish.order.created.test" {
+		t.Fatalf("getRepublishSubject() = %s, want mq_republish.order.created.test", got)
+	}
+}
+
+// 死信消息和重新投递的消息通过Mq-Uuid保留原来的UUID
+func TestNewMessage(t *testing.T) {
+	msg := provider.NewMessage([]byte("hello"))
+	msg.Metadata.Set("key", "value")
+
+	m := newNatsMsg("mq.test", msg, time.Now())
+	if got := newMessageWithHeader(m.Header, m.Data); got.UUID != msg.UUID || got.Metadata.Get("key") != "value" || len(got.Metadata) != 1 {
+		t.Fatalf("newMessageWithHeader() = %s %v, want %s with key", got.UUID, got.Metadata, msg.UUID)
+	}
+
+	// 没有Mq-Uuid时使用Nats-Msg-Id
+	m = nats.NewMsg("mq.test")
+	m.Header.Set(jetstream.MsgIDHeader, "id")
+	if got := newMessageWithHeader(m.Header, m.Data); got.UUID != "id" || len(got.Metadata) != 0 {
+		t.Fatalf("newMessageWithHeader() = %s %v, want id", got.UUID, got.Metadata)
+	}
+}
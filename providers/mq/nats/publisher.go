@@ -0,0 +1,66 @@
+package nats
+
+import (
+	"context"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/pkg/errors"
+)
+
+type natsPublisher struct {
+	provider         *natsProvider
+	js               jetstream.JetStream
+	useDelayTopology bool
+}
+
+func newPublisher(p *natsProvider, js jetstream.JetStream, useDelayTopology bool) *natsPublisher {
+	return &natsPublisher{
+		provider:         p,
+		js:               js,
+		useDelayTopology: useDelayTopology,
+	}
+}
+
+func (p *natsPublisher) Publish(topic string, messages [][]byte, delaySeconds ...int64) error {
+	msgs := make([]*provider.Message, len(messages))
+	for i, payload := range messages {
+		msgs[i] = provider.NewMessage(payload)
+	}
+	return p.PublishMessage(topic, msgs, delaySeconds...)
+}
+
+// PublishMessage 和rabbitmq保持一致, 只有发布延迟消息的发布者才使用delaySeconds
+// 延迟消息立即写入stream, 订阅者在到期前收到时会延迟到到期时间再重新投递
+func (p *natsPublisher) PublishMessage(topic string, messages []*provider.Message, delaySeconds ...int64) error {
+	var deliverAt time.Time
+	if p.useDelayTopology {
+		if len(delaySeconds) == 0 {
+			return errors.New("no delay seconds specified")
+		}
+		deliverAt = time.Now().Add(time.Duration(delaySeconds[0]) * time.Second)
+	}
+
+	ctx := context.Background()
+	if _, err := p.provider.ensureStream(ctx, p.js, topic); err != nil {
+		return &provider.PublishError{Failed: messages, Err: err}
+	}
+
+	subject := getSubject(p.provider.config.Prefix, topic)
+	for i, msg := range messages {
+		if msg.UUID == "" {
+			msg.UUID = provider.NewUUID()
+		}
+
+		_, err := p.js.PublishMsg(ctx, newNatsMsg(subject, msg, deliverAt), jetstream.WithMsgID(msg.UUID))
+		if err != nil {
+			return &provider.PublishError{Failed: messages[i:], Err: errors.Wrapf(err, "publish message, uuid: %s", msg.UUID)}
+		}
+	}
+	return nil
+}
+
+func (p *natsPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,108 @@
+package nats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/pkg/errors"
+)
+
+type natsSubscriber struct {
+	provider    *natsProvider
+	js          jetstream.JetStream
+	name        string
+	retryPolicy *provider.RetryPolicy
+	closing     chan struct{}
+	closeOnce   sync.Once
+	wg          sync.WaitGroup
+}
+
+const (
+	// 读取消息出错后重试的间隔
+	readErrorInterval = time.Second
+)
+
+func newSubscriber(p *natsProvider, js jetstream.JetStream, name string, retryPolicy *provider.RetryPolicy) *natsSubscriber {
+	return &natsSubscriber{
+		provider:    p,
+		js:          js,
+		name:        name,
+		retryPolicy: retryPolicy,
+		closing:     make(chan struct{}),
+	}
+}
+
+// Subscribe 在topic的stream上创建durable consumer, 新建的consumer只消费创建之后的消息
+// 延迟消息写入stream时就已经生效, 不需要单独订阅延迟消息
+func (s *natsSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *provider.Message, error) {
+	select {
+	case <-s.closing:
+		return nil, errors.New("subscriber is closed")
+	default:
+	}
+
+	stream, err := s.provider.ensureStream(ctx, s.js, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.retryPolicy != nil {
+		if _, err = s.provider.ensureDeadLetterStream(ctx, s.js, topic); err != nil {
+			return nil, err
+		}
+	}
+
+	createCtx, cancel := context.WithTimeout(ctx, apiTimeout)
+	defer cancel()
+
+	prefix := s.provider.config.Prefix
+	consumer, err := s.js.CreateOrUpdateConsumer(createCtx, stream, jetstream.ConsumerConfig{
+		Durable:        getName(s.name),
+		FilterSubjects: []string{getSubject(prefix, topic), getRepublishSubject(prefix, topic, getName(s.name))},
+		DeliverPolicy: jetstream.DeliverNewPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       s.provider.config.getAckWait(),
+		MaxDeliver:    -1,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "create consumer, topic: %s, name: %s", topic, s.name)
+	}
+
+	// 每次只从服务器拉取一条消息, 避免其他订阅者空闲时消息积压在本地
+	iter, err := consumer.Messages(jetstream.PullMaxMessages(1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "consume messages, topic: %s, name: %s", topic, s.name)
+	}
+
+	sub := &subscription{
+		subscriber: s,
+		logger:     s.provider.logger,
+		topic:      topic,
+		iter:       iter,
+		out:        make(chan *provider.Message),
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			iter.Stop()
+			close(sub.out)
+			s.wg.Done()
+		}()
+		sub.run(ctx)
+	}()
+
+	return sub.out, nil
+}
+
+// Close 关闭所有订阅, 正在处理的消息会被重新投递
+func (s *natsSubscriber) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,158 @@
+package nats
+
+import (
+	"context"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/pkg/errors"
+)
+
+type subscription struct {
+	subscriber *natsSubscriber
+	logger     provider.Logger
+	topic      string
+	iter       jetstream.MessagesContext
+	out        chan *provider.Message
+}
+
+// run 循环读取消息并逐条投递, 收到Ack或Nack后才投递下一条
+func (s *subscription) run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 订阅关闭时取消正在等待的Next
+	go func() {
+		select {
+		case <-s.subscriber.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		m, err := s.iter.Next(jetstream.NextContext(ctx))
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+				return
+			}
+			s.logger.Error("read nats message", "topic", s.topic, "name", s.subscriber.name, "err", err)
+			if !s.sleep(ctx, readErrorInterval) {
+				return
+			}
+			continue
+		}
+
+		if !s.process(ctx, m) {
+			return
+		}
+	}
+}
+
+// process 投递消息直到被确认或者拒绝, 订阅关闭时返回false, 未确认的消息会被立即重新投递
+func (s *subscription) process(ctx context.Context, m jetstream.Msg) bool {
+	// 延迟消息到期前收到时, 到期后再重新投递
+	deliverAt := getDeliverAt(m)
+	if wait := time.Until(deliverAt); wait > 0 {
+		s.nak(m, wait)
+		return true
+	}
+
+	msg := newMessage(m)
+	msg.SetContext(ctx)
+
+	select {
+	case s.out <- msg:
+	case <-ctx.Done():
+		s.nak(m, 0)
+		return false
+	}
+
+	select {
+	case <-msg.Acked():
+		if err := m.Ack(); err != nil {
+			s.logger.Error("ack nats message", "topic", s.topic, "uuid", msg.UUID, "err", err)
+		}
+		return true
+	case <-msg.Nacked():
+	case <-ctx.Done():
+		s.nak(m, 0)
+		return false
+	}
+
+	policy := s.subscriber.retryPolicy
+	if policy == nil {
+		s.nak(m, 0)
+		return true
+	}
+
+	attempts := getAttempts(m, deliverAt)
+	if attempts >= policy.MaxAttempts {
+		s.deadLetter(ctx, m, msg, policy.Backoff(attempts))
+		return true
+	}
+
+	s.nak(m, policy.Backoff(attempts))
+	return true
+}
+
+// deadLetter 将消息的副本写入死信stream后终止投递, 写入失败时在delay之后重新投递
+func (s *subscription) deadLetter(ctx context.Context, m jetstream.Msg, msg *provider.Message, delay time.Duration) {
+	s.logger.Warn("message exceeds max attempts, move to dead letter stream", "topic", s.topic, "name", s.subscriber.name, "uuid", msg.UUID)
+
+	ctx, cancel := context.WithTimeout(ctx, apiTimeout)
+	defer cancel()
+
+	subject := getDeadLetterSubject(s.subscriber.provider.config.Prefix, s.topic, getName(s.subscriber.name))
+	if _, err := s.subscriber.js.PublishMsg(ctx, newNatsMsg(subject, msg, time.Time{})); err != nil {
+		s.logger.Error("publish dead letter, redeliver it", "topic", s.topic, "uuid", msg.UUID, "err", err)
+		s.nak(m, delay)
+		return
+	}
+
+	if err := m.Term(); err != nil {
+		s.logger.Error("terminate nats message", "topic", s.topic, "uuid", msg.UUID, "err", err)
+	}
+}
+
+// nak 拒绝消息, 服务器在delay之后重新投递
+func (s *subscription) nak(m jetstream.Msg, delay time.Duration) {
+	var err error
+	if delay > 0 {
+		err = m.NakWithDelay(delay)
+	} else {
+		err = m.Nak()
+	}
+	if err != nil {
+		s.logger.Error("nak nats message", "topic", s.topic, "err", err)
+	}
+}
+
+// sleep 等待d, 订阅关闭时返回false
+func (s *subscription) sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// getAttempts 消息已经处理失败的次数, 即服务器的投递次数
+// 延迟消息假定到期前投递过一次, 如果订阅者在到期后才收到, 会多重试一次
+func getAttempts(m jetstream.Msg, deliverAt time.Time) int {
+	meta, err := m.Metadata()
+	if err != nil {
+		return 1
+	}
+
+	attempts := int(meta.NumDelivered)
+	if !deliverAt.IsZero() && attempts > 1 {
+		attempts--
+	}
+	return attempts
+}
@@ -11,18 +11,22 @@ type channelManager interface {
 	Close()
 }
 
+// channel 发布使用的channel, 已经开启confirm模式
 type channel interface {
 	// AMQPChannel returns the underlying AMQP channel.
 	AMQPChannel() *amqp.Channel
-	// DeliveryConfirmationEnabled returns true if delivery confirmation of published messages is enabled.
-	DeliveryConfirmationEnabled() bool
-	// Delivered waits until confirmation of delivery has been received from the AMQP server and returns true if delivery
-	// was successful, otherwise false is returned. If delivery confirmation is not enabled then true is immediately returned.
-	Delivered() bool
+	// Returns returns the channel of mandatory messages returned by the broker because they are unroutable.
+	// The buffer size is publishConfirmBatchSize, so at most that many messages can be in flight.
+	Returns() <-chan amqp.Return
 	// Close closes the channel.
 	Close() error
 }
 
+const (
+	// 每批发布后等待broker确认的消息数量, 也是退回消息的缓冲大小
+	publishConfirmBatchSize = 64
+)
+
 func newChannelManager(logger provider.Logger, conn *connection, poolSize int) (channelManager, error) {
 	if poolSize == 0 {
 		return newDefaultChannelManager(conn), nil
@@ -26,9 +26,9 @@ func (m *defaultChannelManager) GetChannel() (channel, error) {
 		return nil, fmt.Errorf("set AMQP channel to confirmed mode: %w", err)
 	}
 
-	confirmChan := amqpChan.NotifyPublish(make(chan amqp.Confirmation, 1))
+	returnChan := amqpChan.NotifyReturn(make(chan amqp.Return, publishConfirmBatchSize))
 
-	return &defaultChannelImpl{amqpChan, confirmChan}, nil
+	return &defaultChannelImpl{amqpChan, returnChan}, nil
 }
 
 func (m *defaultChannelManager) CloseChannel(c channel) error {
@@ -41,24 +41,13 @@ func (m *defaultChannelManager) Close() {
 
 type defaultChannelImpl struct {
 	*amqp.Channel
-	confirmChan chan amqp.Confirmation
+	returnChan chan amqp.Return
 }
 
 func (c *defaultChannelImpl) AMQPChannel() *amqp.Channel {
 	return c.Channel
 }
 
-func (c *defaultChannelImpl) DeliveryConfirmationEnabled() bool {
-	return c.confirmChan != nil
-}
-
-func (c *defaultChannelImpl) Delivered() bool {
-	if c.confirmChan == nil {
-		// Delivery confirmation is not enabled. Simply return true.
-		return true
-	}
-
-	confirmed := <-c.confirmChan
-
-	return confirmed.Ack
+func (c *defaultChannelImpl) Returns() <-chan amqp.Return {
+	return c.returnChan
 }
@@ -106,11 +106,11 @@ func (m *pooledChannelManager) isClosed() bool {
 }
 
 type pooledChannelImpl struct {
-	logger      provider.Logger
-	connection  *connection
-	amqpChan    *amqp.Channel
-	closedChan  chan *amqp.Error
-	confirmChan chan amqp.Confirmation
+	logger     provider.Logger
+	connection *connection
+	amqpChan   *amqp.Channel
+	closedChan chan *amqp.Error
+	returnChan chan amqp.Return
 }
 
 func newPooledChannel(logger provider.Logger, conn *connection) (*pooledChannelImpl, error) {
@@ -133,20 +133,8 @@ func (c *pooledChannelImpl) AMQPChannel() *amqp.Channel {
 	return c.amqpChan
 }
 
-func (c *pooledChannelImpl) Delivered() bool {
-	if c.confirmChan == nil {
-		// Delivery confirmation is not enabled. Simply return true.
-		return true
-	}
-
-	confirmed := <-c.confirmChan
-
-	return confirmed.Ack
-}
-
-// DeliveryConfirmationEnabled returns true if delivery confirmation of published messages is enabled.
-func (c *pooledChannelImpl) DeliveryConfirmationEnabled() bool {
-	return c.confirmChan != nil
+func (c *pooledChannelImpl) Returns() <-chan amqp.Return {
+	return c.returnChan
 }
 
 func (c *pooledChannelImpl) Close() error {
@@ -180,7 +168,7 @@ func (c *pooledChannelImpl) openAMQPChannel() error {
 		return fmt.Errorf("set AMQP channel to confirmed mode: %w", err)
 	}
 
-	c.confirmChan = c.amqpChan.NotifyPublish(make(chan amqp.Confirmation, 1))
+	c.returnChan = c.amqpChan.NotifyReturn(make(chan amqp.Return, publishConfirmBatchSize))
 
 	return nil
 }
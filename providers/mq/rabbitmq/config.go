@@ -1,17 +1,19 @@
 package rabbitmq
 
 import (
+	"time"
+
+	"github.com/hdget/sdk/common/config"
 	"github.com/hdget/sdk/common/provider"
-	"github.com/jinzhu/copier"
 	"github.com/pkg/errors"
 )
 
 type RabbitMqConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	Username string `mapstructure:"username"`
-	Password string `mapstructure:"password"`
-	Vhost    string `mapstructure:"vhost"`
+	Host     string `mapstructure:"host" validate:"required"`
+	Port     int    `mapstructure:"port" default:"5672"`
+	Username string `mapstructure:"username" default:"guest"`
+	Password string `mapstructure:"password" default:"guest"`
+	Vhost    string `mapstructure:"vhost" default:"/"`
 
 	// Consumer: Whether or not to requeue when sending a negative acknowledgement in case of a failure.
 	RequeueInFailure bool `mapstructure:"requeue_in_failure"`
@@ -34,6 +36,25 @@ type RabbitMqConfig struct {
 	// If this value is set to 0 (default) then channels are not pooled and a new channel is opened/closed for every
 	// Publish operation.
 	ChannelPoolSize int `mapstructure:"channel_pool_size"`
+
+	// Exchanges 指定topic使用的交换机类型, 未指定的topic使用fanout交换机
+	Exchanges []*ExchangeConfig `mapstructure:"exchanges"`
+	// Queue 订阅者队列的缺省参数, SubscriberOption中指定了Queue时不使用
+	Queue *QueueConfig `mapstructure:"queue"`
+}
+
+type ExchangeConfig struct {
+	Topic string `mapstructure:"topic" validate:"required"`
+	Kind  string `mapstructure:"kind" validate:"required"` // fanout, direct, topic, headers
+}
+
+type QueueConfig struct {
+	Type           string `mapstructure:"type"`             // 队列类型: classic, quorum
+	MessageTTL     int64  `mapstructure:"message_ttl"`      // 消息过期时间, 单位: 秒
+	MaxLength      int64  `mapstructure:"max_length"`       // 最多保留的消息数量
+	MaxLengthBytes int64  `mapstructure:"max_length_bytes"` // 最多保留的消息字节数
+	Overflow       string `mapstructure:"overflow"`         // 超过长度限制后的处理方式: drop-head, reject-publish
+	MaxPriority    int    `mapstructure:"max_priority"`     // 最大优先级
 }
 
 type ConnectionConfig struct {
@@ -50,16 +71,20 @@ const (
 )
 
 var (
+	// defaultConfig 零值有意义的配置项的缺省值, 需要在解析配置之前预先填充
 	defaultConfig = RabbitMqConfig{
-		Port:             5672,
-		Username:         "guest",
-		Password:         "guest",
-		Vhost:            "/",
 		ChannelPoolSize:  10,
 		RequeueInFailure: true,
 		PrefetchCount:    2,
 	}
 	errInvalidConfig = errors.New("invalid config")
+	configSchema     = &provider.ConfigSchema{
+		Section: configSection,
+		New: func() any {
+			c := defaultConfig
+			return &c
+		},
+	}
 )
 
 func newConfig(configProvider provider.Config) (*RabbitMqConfig, error) {
@@ -67,44 +92,45 @@ func newConfig(configProvider provider.Config) (*RabbitMqConfig, error) {
 		return nil, errInvalidConfig
 	}
 
-	// copy default config
-	var c RabbitMqConfig
-	_ = copier.Copy(&c, &defaultConfig)
-
-	// unmarshal config
-	err := configProvider.Unmarshal(&c, configSection)
+	c := configSchema.New().(*RabbitMqConfig)
+	err := config.Parse(configProvider, configSection, c)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, "parse rabbitmq provider config")
 	}
 
-	err = c.validate()
-	if err != nil {
-		return nil, errors.Wrap(err, "validate rabbitmq provider config")
+	for _, exchange := range c.Exchanges {
+		if _, err = parseExchangeKind(exchange.Kind); err != nil {
+			return nil, errors.Wrapf(err, "parse exchange config, topic: %s", exchange.Topic)
+		}
 	}
 
-	return &c, nil
+	return c, nil
 }
 
-func (c *RabbitMqConfig) validate() error {
-	if c.Host == "" {
-		return errors.New("rabbitmq host is required")
-	}
-
-	if c.Port == 0 {
-		c.Port = defaultConfig.Port
-	}
-
-	if c.Username == "" {
-		c.Username = defaultConfig.Username
+// getExchangeKind 获取配置中topic对应的交换机类型, 未配置时为fanout
+func (c *RabbitMqConfig) getExchangeKind(topic string) ExchangeKind {
+	for _, exchange := range c.Exchanges {
+		if exchange.Topic == topic {
+			if kind, _ := parseExchangeKind(exchange.Kind); kind != "" {
+				return kind
+			}
+		}
 	}
+	return ExchangeKindFanout
+}
 
-	if c.Password == "" {
-		c.Password = defaultConfig.Password
+// getQueueOption 配置中的缺省队列参数
+func (c *RabbitMqConfig) getQueueOption() *provider.QueueOption {
+	if c.Queue == nil {
+		return nil
 	}
 
-	if c.Vhost == "" {
-		c.Vhost = defaultConfig.Vhost
+	return &provider.QueueOption{
+		Type:           c.Queue.Type,
+		MessageTTL:     time.Duration(c.Queue.MessageTTL) * time.Second,
+		MaxLength:      c.Queue.MaxLength,
+		MaxLengthBytes: c.Queue.MaxLengthBytes,
+		Overflow:       c.Queue.Overflow,
+		MaxPriority:    c.Queue.MaxPriority,
 	}
-
-	return nil
 }
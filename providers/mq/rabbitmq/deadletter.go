@@ -0,0 +1,134 @@
+package rabbitmq
+
+import (
+	"context"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ListDeadLetters 查看死信消息, 读取的消息在结束后全部放回死信队列
+func (r *rabbitmqProvider) ListDeadLetters(topic, name string, limit int) ([]*provider.Message, error) {
+	t, err := newDeadLetterTopology(name, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, amqpChannel, err := r.openDeadLetterChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = amqpChannel.Close()
+		_ = conn.Close()
+	}()
+
+	deliveries, err := getDeadLetters(amqpChannel, t, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*provider.Message, len(deliveries))
+	for i, d := range deliveries {
+		messages[i] = newMessage(d)
+	}
+
+	// 放回所有读取的消息, 保持原有顺序
+	if len(deliveries) > 0 {
+		if err = deliveries[len(deliveries)-1].Nack(true, true); err != nil {
+			return nil, errors.Wrap(err, "requeue dead letters")
+		}
+	}
+
+	return messages, nil
+}
+
+// RepublishDeadLetters 将死信消息投递回订阅者的队列, 投递确认后才从死信队列中删除
+func (r *rabbitmqProvider) RepublishDeadLetters(topic, name string, limit int) (int, error) {
+	t, err := newDeadLetterTopology(name, topic)
+	if err != nil {
+		return 0, err
+	}
+
+	conn, amqpChannel, err := r.openDeadLetterChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = amqpChannel.Close()
+		_ = conn.Close()
+	}()
+
+	if err = amqpChannel.Confirm(false); err != nil {
+		return 0, errors.Wrap(err, "set AMQP channel to confirmed mode")
+	}
+	confirmChan := amqpChannel.NotifyPublish(make(chan amqp.Confirmation, 1))
+
+	deliveries, err := getDeadLetters(amqpChannel, t, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, d := range deliveries {
+		publishing := newRepublishing(d)
+		delete(publishing.Headers, headerAttempts)
+
+		// 通过缺省交换机直接投递到订阅者队列, 不会被其他订阅者收到
+		err = amqpChannel.PublishWithContext(context.Background(), "", t.QueueName, false, false, publishing)
+		if err != nil {
+			return count, errors.Wrapf(err, "republish dead letter, uuid: %s", d.MessageId)
+		}
+
+		if confirmed := <-confirmChan; !confirmed.Ack {
+			return count, errors.Errorf("republish dead letter not confirmed, uuid: %s", d.MessageId)
+		}
+
+		if err = d.Ack(false); err != nil {
+			return count, errors.Wrap(err, "ack dead letter")
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+func (r *rabbitmqProvider) openDeadLetterChannel() (*connection, *amqp.Channel, error) {
+	conn, err := newConnection(r.logger, r.config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	amqpChannel, err := conn.AmqpConnection().Channel()
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, errors.Wrap(err, "cannot open channel")
+	}
+	return conn, amqpChannel, nil
+}
+
+func newDeadLetterTopology(name, topic string) (*Topology, error) {
+	t, err := newTopology(name, topic, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "new topology")
+	}
+	t.enableRetry()
+	return t, nil
+}
+
+// getDeadLetters 读取死信消息但不确认, limit小于等于0时读取所有消息
+func getDeadLetters(amqpChannel *amqp.Channel, t *Topology, limit int) ([]amqp.Delivery, error) {
+	deliveries := make([]amqp.Delivery, 0)
+	for limit <= 0 || len(deliveries) < limit {
+		d, ok, err := amqpChannel.Get(t.DeadLetterName, false)
+		if err != nil {
+			return nil, errors.Wrap(err, "get dead letter")
+		}
+		if !ok {
+			break
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
@@ -6,7 +6,6 @@ require (
 	github.com/cenkalti/backoff/v4 v4.3.0
 	github.com/hdget/sdk/common v0.1.21
 	github.com/hdget/utils/text v0.0.2
-	github.com/jinzhu/copier v0.4.0
 	github.com/pkg/errors v0.9.1
 	github.com/rabbitmq/amqp091-go v1.10.0
 	go.uber.org/fx v1.24.0
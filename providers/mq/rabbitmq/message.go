@@ -0,0 +1,119 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	// 消息已经失败的投递次数
+	headerAttempts = "x-attempts"
+)
+
+// newPublishing 将消息转换成amqp消息, Metadata保存在headers中, 常用的Metadata同时设置到对应的amqp属性
+func newPublishing(msg *provider.Message) amqp.Publishing {
+	headers := make(amqp.Table, len(msg.Metadata))
+	for k, v := range msg.Metadata {
+		headers[k] = v
+	}
+
+	var priority uint8
+	if v := msg.Metadata.Get(provider.MetadataKeyPriority); v != "" {
+		if p, err := strconv.ParseUint(v, 10, 8); err == nil {
+			priority = uint8(p)
+		}
+	}
+
+	timestamp := time.Now()
+	if v := msg.Metadata.Get(provider.MetadataKeyTimestamp); v != "" {
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			timestamp = time.UnixMilli(ms)
+		}
+	}
+
+	return amqp.Publishing{
+		Headers:       headers,
+		ContentType:   msg.Metadata.Get(provider.MetadataKeyContentType),
+		CorrelationId: msg.Metadata.Get(provider.MetadataKeyCorrelationID),
+		MessageId:     msg.UUID,
+		Timestamp:     timestamp,
+		Body:          msg.Payload,
+		DeliveryMode:  amqp.Persistent, // message always set to be persistent
+		Priority:      priority,
+	}
+}
+
+// newRepublishing 重新投递amqp消息时保留原消息的属性和headers, 去掉延迟插件设置的x-delay
+func newRepublishing(delivery amqp.Delivery) amqp.Publishing {
+	headers := make(amqp.Table, len(delivery.Headers))
+	for k, v := range delivery.Headers {
+		if k != "x-delay" {
+			headers[k] = v
+		}
+	}
+
+	return amqp.Publishing{
+		Headers:       headers,
+		ContentType:   delivery.ContentType,
+		CorrelationId: delivery.CorrelationId,
+		MessageId:     delivery.MessageId,
+		Timestamp:     delivery.Timestamp,
+		Body:          delivery.Body,
+		DeliveryMode:  amqp.Persistent,
+		Priority:      delivery.Priority,
+	}
+}
+
+// getAttempts 已经失败的投递次数
+func getAttempts(headers amqp.Table) int {
+	switch v := headers[headerAttempts].(type) {
+	case int64:
+		return int(v)
+	case int32:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+// newMessage 将amqp消息转换成消息, x-开头的header由broker和插件使用, 不放入Metadata
+func newMessage(delivery amqp.Delivery) *provider.Message {
+	msg := provider.NewMessageWithUUID(delivery.MessageId, delivery.Body)
+	for k, v := range delivery.Headers {
+		if strings.HasPrefix(k, "x-") {
+			continue
+		}
+		msg.Metadata.Set(k, headerToString(v))
+	}
+
+	setIfAbsent := func(key, value string) {
+		if value != "" && msg.Metadata.Get(key) == "" {
+			msg.Metadata.Set(key, value)
+		}
+	}
+	setIfAbsent(provider.MetadataKeyContentType, delivery.ContentType)
+	setIfAbsent(provider.MetadataKeyCorrelationID, delivery.CorrelationId)
+	if !delivery.Timestamp.IsZero() {
+		setIfAbsent(provider.MetadataKeyTimestamp, strconv.FormatInt(delivery.Timestamp.UnixMilli(), 10))
+	}
+
+	return msg
+}
+
+func headerToString(v any) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	case []byte:
+		return string(vv)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,191 @@
+package rabbitmq
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestNewPublishing(t *testing.T) {
+	msg := provider.NewMessage([]byte("hello"))
+	msg.Metadata.Set(provider.MetadataKeyContentType, "application/json")
+	msg.Metadata.Set(provider.MetadataKeyCorrelationID, "cid")
+	msg.Metadata.Set(provider.MetadataKeyTimestamp, "1700000000000")
+	msg.Metadata.Set(provider.MetadataKeyPriority, "5")
+	msg.Metadata.Set("key", "value")
+
+	p := newPublishing(msg)
+	if p.MessageId != msg.UUID || string(p.Body) != "hello" || p.DeliveryMode != amqp.Persistent {
+		t.Fatalf("publishing = %+v", p)
+	}
+	if p.ContentType != "application/json" || p.CorrelationId != "cid" || p.Priority != 5 {
+		t.Fatalf("publishing properties = %s %s %d", p.ContentType, p.CorrelationId, p.Priority)
+	}
+	if !p.Timestamp.Equal(time.UnixMilli(1700000000000)) {
+		t.Fatalf("publishing timestamp = %v", p.Timestamp)
+	}
+
+	wantHeaders := amqp.Table{}
+	for k, v := range msg.Metadata {
+		wantHeaders[k] = v
+	}
+	if !reflect.DeepEqual(p.Headers, wantHeaders) {
+		t.Fatalf("publishing headers = %v, want %v", p.Headers, wantHeaders)
+	}
+}
+
+// 无效的优先级和发布时间被忽略
+func TestNewPublishingInvalidMetadata(t *testing.T) {
+	msg := provider.NewMessage(nil)
+	msg.Metadata.Set(provider.MetadataKeyPriority, "256")
+	msg.Metadata.Set(provider.MetadataKeyTimestamp, "now")
+
+	before := time.Now()
+	p := newPublishing(msg)
+	if p.Priority != 0 {
+		t.Fatalf("publishing priority = %d, want 0", p.Priority)
+	}
+	if p.Timestamp.Before(before) {
+		t.Fatalf("publishing timestamp = %v, want now", p.Timestamp)
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	ts := time.UnixMilli(1700000000000)
+	testCases := []struct {
+		name     string
+		delivery amqp.Delivery
+		want     provider.Metadata
+	}{
+		{
+			name: "header types",
+			delivery: amqp.Delivery{Headers: amqp.Table{
+				"string": "a",
+				"bytes":  []byte("b"),
+				"int":    int64(1),
+				"bool":   true,
+				"nil":    nil,
+			}},
+			want: provider.Metadata{"string": "a", "bytes": "b", "int": "1", "bool": "true", "nil": ""},
+		},
+		{
+			name: "broker headers",
+			delivery: amqp.Delivery{Headers: amqp.Table{
+				"key":          "value",
+				headerAttempts: int64(2),
+				"x-delay":      int64(1000),
+				"x-death":      []any{amqp.Table{"count": int64(1)}},
+			}},
+			want: provider.Metadata{"key": "value"},
+		},
+		{
+			name: "properties",
+			delivery: amqp.Delivery{
+				ContentType:   "application/json",
+				CorrelationId: "cid",
+				Timestamp:     ts,
+			},
+			want: provider.Metadata{
+				provider.MetadataKeyContentType:   "application/json",
+				provider.MetadataKeyCorrelationID: "cid",
+				provider.MetadataKeyTimestamp:     "1700000000000",
+			},
+		},
+		{
+			name: "headers preferred",
+			delivery: amqp.Delivery{
+				Headers:       amqp.Table{provider.MetadataKeyCorrelationID: "header"},
+				CorrelationId: "property",
+			},
+			want: provider.Metadata{provider.MetadataKeyCorrelationID: "header"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.delivery.MessageId = "id"
+			tc.delivery.Body = []byte("hello")
+
+			msg := newMessage(tc.delivery)
+			if msg.UUID != "id" || string(msg.Payload) != "hello" {
+				t.Fatalf("message = %s %s, want id hello", msg.UUID, msg.Payload)
+			}
+			if !reflect.DeepEqual(msg.Metadata, tc.want) {
+				t.Fatalf("metadata = %v, want %v", msg.Metadata, tc.want)
+			}
+		})
+	}
+}
+
+// 发布的消息被接收后Metadata保持不变
+func TestMessageRoundTrip(t *testing.T) {
+	msg := provider.NewMessage([]byte("hello"))
+	msg.Metadata.Set(provider.MetadataKeyContentType, "text/plain")
+	msg.Metadata.Set(provider.MetadataKeyTimestamp, "1700000000000")
+	msg.Metadata.Set("key", "value")
+
+	p := newPublishing(msg)
+	got := newMessage(amqp.Delivery{
+		Headers:       p.Headers,
+		ContentType:   p.ContentType,
+		CorrelationId: p.CorrelationId,
+		MessageId:     p.MessageId,
+		Timestamp:     p.Timestamp,
+		Body:          p.Body,
+	})
+	if got.UUID != msg.UUID || string(got.Payload) != "hello" || !reflect.DeepEqual(got.Metadata, msg.Metadata) {
+		t.Fatalf("message = %s %s %v, want %s hello %v", got.UUID, got.Payload, got.Metadata, msg.UUID, msg.Metadata)
+	}
+}
+
+func TestNewRepublishing(t *testing.T) {
+	delivery := amqp.Delivery{
+		Headers:       amqp.Table{"key": "value", headerAttempts: int64(1), "x-delay": int64(1000)},
+		ContentType:   "text/plain",
+		CorrelationId: "cid",
+		MessageId:     "id",
+		Timestamp:     time.UnixMilli(1700000000000),
+		Body:          []byte("hello"),
+		Priority:      3,
+	}
+
+	p := newRepublishing(delivery)
+	if !reflect.DeepEqual(p.Headers, amqp.Table{"key": "value", headerAttempts: int64(1)}) {
+		t.Fatalf("republishing headers = %v", p.Headers)
+	}
+	if p.ContentType != "text/plain" || p.CorrelationId != "cid" || p.MessageId != "id" || p.Priority != 3 ||
+		!p.Timestamp.Equal(delivery.Timestamp) || string(p.Body) != "hello" || p.DeliveryMode != amqp.Persistent {
+		t.Fatalf("republishing = %+v", p)
+	}
+
+	// 不修改原消息的headers
+	if _, exists := delivery.Headers["x-delay"]; !exists {
+		t.Fatal("delivery headers modified")
+	}
+}
+
+func TestGetAttempts(t *testing.T) {
+	testCases := []struct {
+		value any
+		want  int
+	}{
+		{value: nil, want: 0},
+		{value: int64(3), want: 3},
+		{value: int32(2), want: 2},
+		{value: 1, want: 1},
+		{value: "3", want: 0},
+	}
+
+	for _, tc := range testCases {
+		headers := amqp.Table{}
+		if tc.value != nil {
+			headers[headerAttempts] = tc.value
+		}
+		if got := getAttempts(headers); got != tc.want {
+			t.Errorf("getAttempts(%#v) = %d, want %d", tc.value, got, tc.want)
+		}
+	}
+}
@@ -1,14 +1,20 @@
 package rabbitmq
 
 import (
+	"io"
+	"sync"
+
 	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
 )
 
 // rabbitmqProvider
 // Note: most codes comes from https://github.com/ThreeDotsLabs/watermill-amqp
 type rabbitmqProvider struct {
-	config *RabbitMqConfig
-	logger provider.Logger
+	config  *RabbitMqConfig
+	logger  provider.Logger
+	mutex   sync.Mutex
+	closers map[io.Closer]struct{} // 已创建并且没有关闭的publisher和subscriber, 退出时统一关闭
 }
 
 func New(configProvider provider.Config, logger provider.Logger) (provider.MessageQueue, error) {
@@ -20,13 +26,13 @@ func New(configProvider provider.Config, logger provider.Logger) (provider.Messa
 	return &rabbitmqProvider{config: config, logger: logger}, nil
 }
 
-func (r rabbitmqProvider) Init(args ...any) error {
+func (r *rabbitmqProvider) Init(args ...any) error {
 	// RabbitMQ provider不需要额外的初始化步骤
 	// 配置已在New()中完成验证
 	return nil
 }
 
-func (r rabbitmqProvider) NewPublisher(name string, args ...*provider.PublisherOption) (provider.MessageQueuePublisher, error) {
+func (r *rabbitmqProvider) NewPublisher(name string, args ...*provider.PublisherOption) (provider.MessageQueuePublisher, error) {
 	option := provider.DefaultPublisherOption
 	if len(args) > 0 {
 		option = args[0]
@@ -36,11 +42,25 @@ func (r rabbitmqProvider) NewPublisher(name string, args ...*provider.PublisherO
 	if option.PublishDelayMessage {
 		publisherOptions = append(publisherOptions, withPublisherDelayTopology())
 	}
+	if option.Mandatory {
+		publisherOptions = append(publisherOptions, withPublisherMandatory())
+	}
+
+	exchangeKind, err := parseExchangeKind(option.ExchangeKind)
+	if err != nil {
+		return nil, err
+	}
+	publisherOptions = append(publisherOptions, withPublisherExchange(exchangeKind, option.RoutingKey))
 
-	return newPublisher(name, r.config, r.logger, publisherOptions...)
+	p, err := newPublisher(name, r.config, r.logger, publisherOptions...)
+	if err != nil {
+		return nil, err
+	}
+	r.track(p, &p.closePublisher)
+	return p, nil
 }
 
-func (r rabbitmqProvider) NewSubscriber(name string, args ...*provider.SubscriberOption) (provider.MessageQueueSubscriber, error) {
+func (r *rabbitmqProvider) NewSubscriber(name string, args ...*provider.SubscriberOption) (provider.MessageQueueSubscriber, error) {
 	option := provider.DefaultSubscriberOption
 	if len(args) > 0 {
 		option = args[0]
@@ -51,9 +71,70 @@ func (r rabbitmqProvider) NewSubscriber(name string, args ...*provider.Subscribe
 		subscriberOptions = append(subscriberOptions, withSubscriberDelayTopology())
 	}
 
-	return newSubscriber(name, r.config, r.logger, subscriberOptions...)
+	if option.RetryPolicy != nil {
+		if option.RetryPolicy.MaxAttempts < 1 {
+			return nil, errors.New("retry policy max attempts must be greater than 0")
+		}
+		subscriberOptions = append(subscriberOptions, withSubscriberRetryPolicy(option.RetryPolicy))
+	}
+
+	exchangeKind, err := parseExchangeKind(option.ExchangeKind)
+	if err != nil {
+		return nil, err
+	}
+	subscriberOptions = append(subscriberOptions, withSubscriberExchange(exchangeKind, option.BindingKeys, newBindingHeaders(option.BindingHeaders, option.MatchAnyHeader)))
+
+	if option.Queue != nil {
+		subscriberOptions = append(subscriberOptions, withSubscriberQueueOption(option.Queue))
+	}
+
+	s, err := newSubscriber(name, r.config, r.logger, subscriberOptions...)
+	if err != nil {
+		return nil, err
+	}
+	r.track(s, &s.closeSubscriber)
+	return s, nil
 }
 
-func (r rabbitmqProvider) GetCapability() provider.Capability {
+func (r *rabbitmqProvider) GetCapability() provider.Capability {
 	return Capability
 }
+
+// Close 关闭所有由该provider创建的publisher和subscriber
+func (r *rabbitmqProvider) Close() error {
+	r.mutex.Lock()
+	closers := r.closers
+	r.closers = nil
+	r.mutex.Unlock()
+
+	var firstErr error
+	for closer := range closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// track 记录创建的publisher或者subscriber, 替换它们的关闭函数, 自行关闭后不再记录, 避免反复创建时泄漏
+func (r *rabbitmqProvider) track(closer io.Closer, closeFn *func() error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closers == nil {
+		r.closers = make(map[io.Closer]struct{})
+	}
+	r.closers[closer] = struct{}{}
+
+	fn := *closeFn
+	*closeFn = func() error {
+		r.untrack(closer)
+		return fn()
+	}
+}
+
+func (r *rabbitmqProvider) untrack(closer io.Closer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.closers, closer)
+}
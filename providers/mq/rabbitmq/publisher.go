@@ -21,6 +21,9 @@ type rmqPublisherImpl struct {
 	// new added
 	name             string
 	useDelayTopology bool
+	mandatory        bool         // 无法路由到任何队列的消息由broker退回, 认为发布失败
+	exchangeKind     ExchangeKind // 为空时使用配置中topic对应的交换机类型
+	routingKey       string
 }
 
 func newPublisher(name string, config *RabbitMqConfig, logger provider.Logger, options ...publisherOption) (*rmqPublisherImpl, error) {
@@ -36,6 +39,11 @@ func newPublisher(name string, config *RabbitMqConfig, logger provider.Logger, o
 
 	// Close the connection when the publisher is closed since this publisher owns the connection.
 	closePublisher := func() error {
+		if conn.IsClosed() {
+			// Already closed.
+			return nil
+		}
+
 		logger.Debug("closing publisher connection")
 
 		chanManager.Close()
@@ -70,7 +78,19 @@ func (s *rmqPublisherImpl) Close() error {
 // Publish publishes messages to AMQP broker.
 // Publish is blocking until the broker has received and saved the message.
 // Publish is always thread safe.
-func (p *rmqPublisherImpl) Publish(topic string, messages [][]byte, args ...int64) (err error) {
+func (p *rmqPublisherImpl) Publish(topic string, messages [][]byte, args ...int64) error {
+	msgs := make([]*provider.Message, len(messages))
+	for i, payload := range messages {
+		msgs[i] = provider.NewMessage(payload)
+	}
+	return p.PublishMessage(topic, msgs, args...)
+}
+
+// PublishMessage publishes messages with uuid and metadata, metadata is sent as AMQP headers.
+// Messages are published in batches, each batch waits for the broker confirms. If mandatory is enabled,
+// unroutable messages returned by the broker are treated as failed. Failed messages are reported
+// by *provider.PublishError in the original order.
+func (p *rmqPublisherImpl) PublishMessage(topic string, messages []*provider.Message, args ...int64) (err error) {
 	if p.connection.IsClosed() {
 		return errors.New("connection is closed while publish message")
 	}
@@ -97,6 +117,7 @@ func (p *rmqPublisherImpl) Publish(topic string, messages [][]byte, args ...int6
 	if err != nil {
 		return errors.Wrap(err, "new topology")
 	}
+	p.setupTopology(t)
 
 	err = p.preparePublishBindings(topic, theChannel.AMQPChannel(), t)
 	if err != nil {
@@ -104,14 +125,39 @@ func (p *rmqPublisherImpl) Publish(topic string, messages [][]byte, args ...int6
 	}
 
 	for _, msg := range messages {
-		if err = p.publishMessage(theChannel, t, msg, args...); err != nil {
-			return err
+		if msg.UUID == "" {
+			msg.UUID = provider.NewUUID()
+		}
+	}
+
+	var failed []*provider.Message
+	for i := 0; i < len(messages); i += publishConfirmBatchSize {
+		batch := messages[i:min(i+publishConfirmBatchSize, len(messages))]
+
+		batchFailed, batchErr := p.publishBatch(theChannel, t, batch, args...)
+		failed = append(failed, batchFailed...)
+		if batchErr != nil {
+			// 发布失败后channel可能已不可用, 剩余的消息都认为失败
+			failed = append(failed, messages[i+len(batch):]...)
+			return &provider.PublishError{Failed: failed, Err: batchErr}
 		}
 	}
 
+	if len(failed) > 0 {
+		return &provider.PublishError{Failed: failed, Err: errors.New("delivery not confirmed or returned by broker")}
+	}
 	return nil
 }
 
+// setupTopology 设置交换机类型和缺省路由键
+func (p *rmqPublisherImpl) setupTopology(t *Topology) {
+	t.ExchangeKind = p.exchangeKind
+	if t.ExchangeKind == "" {
+		t.ExchangeKind = p.config.getExchangeKind(t.ExchangeName)
+	}
+	t.RoutingKey = p.routingKey
+}
+
 func (p *rmqPublisherImpl) preparePublishBindings(topic string, amqpChannel *amqp.Channel, t *Topology) error {
 	p.publishBindingsLock.RLock()
 	_, prepared := p.publishBindingsPrepared[topic]
@@ -135,43 +181,99 @@ func (p *rmqPublisherImpl) preparePublishBindings(topic string, amqpChannel *amq
 	return nil
 }
 
-func (p *rmqPublisherImpl) publishMessage(channel channel, t *Topology, msgPayload []byte, args ...int64) error {
-	var headers amqp.Table
+// publishBatch 发布一批消息并等待broker确认, 返回未确认或者被退回的消息
+func (p *rmqPublisherImpl) publishBatch(channel channel, t *Topology, messages []*provider.Message, args ...int64) ([]*provider.Message, error) {
+	confirms := make([]*amqp.DeferredConfirmation, 0, len(messages))
+
+	var publishErr error
+	for _, msg := range messages {
+		confirm, err := p.publishMessage(channel, t, msg, args...)
+		if err != nil {
+			publishErr = err
+			break
+		}
+		confirms = append(confirms, confirm)
+	}
+
+	failed := make([]*provider.Message, 0)
+	unconfirmed := make(map[string]struct{})
+	for i, confirm := range confirms {
+		acked, err := confirm.WaitContext(context.Background())
+		if err != nil || !acked {
+			p.logger.Error("delivery not confirmed for message", "uuid", messages[i].UUID, "err", err)
+			unconfirmed[messages[i].UUID] = struct{}{}
+		}
+	}
+
+	// broker在确认之前发送退回的消息, 所以等待确认后退回的消息都已经到达
+	for returned := range p.drainReturns(channel) {
+		p.logger.Error("message returned by broker", "uuid", returned, "topology", t)
+		unconfirmed[returned] = struct{}{}
+	}
+
+	for _, msg := range messages[:len(confirms)] {
+		if _, exists := unconfirmed[msg.UUID]; exists {
+			failed = append(failed, msg)
+		}
+	}
+
+	if publishErr != nil {
+		failed = append(failed, messages[len(confirms):]...)
+	}
+	return failed, publishErr
+}
+
+// drainReturns 非阻塞的读取所有已退回消息的uuid
+func (p *rmqPublisherImpl) drainReturns(channel channel) map[string]struct{} {
+	returned := make(map[string]struct{})
+	for {
+		select {
+		case r, ok := <-channel.Returns():
+			if !ok {
+				return returned
+			}
+			returned[r.MessageId] = struct{}{}
+		default:
+			return returned
+		}
+	}
+}
+
+func (p *rmqPublisherImpl) publishMessage(channel channel, t *Topology, msg *provider.Message, args ...int64) (*amqp.DeferredConfirmation, error) {
+	publishing := newPublishing(msg)
 	if t.Kind == TopologyKindDelay {
 		if len(args) == 0 {
-			return errors.New("no delay seconds specified")
-		}
-		headers = map[string]interface{}{
-			"x-delay": args[0] * 1000, // message expire time in delay exchange, unit is mill seconds， provided by delay-message plugin
+			return nil, errors.New("no delay seconds specified")
 		}
+		publishing.Headers["x-delay"] = args[0] * 1000 // message expire time in delay exchange, unit is mill seconds， provided by delay-message plugin
+	}
+
+	// 路由键放入headers, 消费者可以通过Metadata获取, 重试时也不会丢失
+	routingKey := t.RoutingKey
+	if v := msg.Metadata.Get(provider.MetadataKeyRoutingKey); v != "" {
+		routingKey = v
+	}
+	if routingKey != "" {
+		publishing.Headers[provider.MetadataKeyRoutingKey] = routingKey
 	}
 
-	err := channel.AMQPChannel().PublishWithContext(
+	confirm, err := channel.AMQPChannel().PublishWithDeferredConfirmWithContext(
 		context.Background(),
 		t.ExchangeName,
-		t.RoutingKey,
-		false,
+		routingKey,
+		p.isMandatory(t),
 		false,
-		amqp.Publishing{
-			Body:         msgPayload,
-			Headers:      headers,
-			DeliveryMode: amqp.Persistent, // message always set to be persistent
-		},
+		publishing,
 	)
 	if err != nil {
-		return errors.Wrap(err, "cannot publish msg")
+		return nil, errors.Wrapf(err, "cannot publish msg, uuid: %s", msg.UUID)
 	}
 
-	if !channel.DeliveryConfirmationEnabled() {
-		p.logger.Trace("message published", "topology", t, "msg", string(msgPayload))
-		return nil
-	}
-
-	p.logger.Trace("message published, waiting for delivery confirmation", "topology", t, "msg", string(msgPayload))
-	if !channel.Delivered() {
-		return fmt.Errorf("delivery not confirmed for message [%s]", string(msgPayload))
-	}
+	p.logger.Trace("message published, waiting for delivery confirmation", "topology", t, "uuid", msg.UUID)
+	return confirm, nil
+}
 
-	p.logger.Trace("delivery confirmed for message", "msg", string(msgPayload))
-	return nil
+// isMandatory 延迟消息插件在投递前总是会退回mandatory消息, 所以延迟消息不使用mandatory
+func (p *rmqPublisherImpl) isMandatory(t *Topology) bool {
+	return p.mandatory && t.Kind != TopologyKindDelay
 }
@@ -7,3 +7,16 @@ func withPublisherDelayTopology() publisherOption {
 		impl.useDelayTopology = true
 	}
 }
+
+func withPublisherExchange(kind ExchangeKind, routingKey string) publisherOption {
+	return func(impl *rmqPublisherImpl) {
+		impl.exchangeKind = kind
+		impl.routingKey = routingKey
+	}
+}
+
+func withPublisherMandatory() publisherOption {
+	return func(impl *rmqPublisherImpl) {
+		impl.mandatory = true
+	}
+}
@@ -22,6 +22,11 @@ type rmpSubscriberImpl struct {
 	// new added
 	name             string
 	useDelayTopology bool
+	retryPolicy      *provider.RetryPolicy
+	exchangeKind     ExchangeKind // 为空时使用配置中topic对应的交换机类型
+	bindingKeys      []string
+	bindingHeaders   amqp.Table
+	queueOption      *provider.QueueOption // 为空时使用配置中的队列参数
 }
 
 func newSubscriber(name string, config *RabbitMqConfig, logger provider.Logger, options ...subscriberOption) (*rmpSubscriberImpl, error) {
@@ -93,6 +98,12 @@ func (s *rmpSubscriberImpl) Subscribe(ctx context.Context, topic string) (<-chan
 		return nil, errors.Wrap(err, "new topology")
 	}
 
+	s.setupTopology(t)
+
+	if s.retryPolicy != nil {
+		t.enableRetry()
+	}
+
 	if err := s.prepareConsume(t); err != nil {
 		return nil, errors.Wrap(err, "failed to prepare consume")
 	}
@@ -144,6 +155,22 @@ func (s *rmpSubscriberImpl) Subscribe(ctx context.Context, topic string) (<-chan
 	return out, nil
 }
 
+// setupTopology 设置交换机类型, 绑定的路由键或消息头以及队列参数
+func (s *rmpSubscriberImpl) setupTopology(t *Topology) {
+	t.ExchangeKind = s.exchangeKind
+	if t.ExchangeKind == "" {
+		t.ExchangeKind = s.config.getExchangeKind(t.ExchangeName)
+	}
+	t.BindingKeys = s.bindingKeys
+	t.BindingHeaders = s.bindingHeaders
+
+	queueOption := s.queueOption
+	if queueOption == nil {
+		queueOption = s.config.getQueueOption()
+	}
+	t.QueueArgs = newQueueArgs(queueOption)
+}
+
 func (s *rmpSubscriberImpl) prepareConsume(t *Topology) error {
 	amqpChannel, err := s.openSubscribeChannel()
 	if err != nil {
@@ -181,6 +208,21 @@ func (s *rmpSubscriberImpl) prepareConsumeBindings(amqpChannel *amqp.Channel, t
 		}
 	}
 
+	if s.retryPolicy != nil {
+		// 不需要等待时直接投递回队列, 不依赖延迟消息插件
+		if s.retryPolicy.InitialInterval > 0 {
+			err = t.DeclareRetry(amqpChannel)
+			if err != nil {
+				return errors.Wrap(err, "declare retry when prepare consume bindings")
+			}
+		}
+
+		err = t.DeclareDeadLetter(amqpChannel)
+		if err != nil {
+			return errors.Wrap(err, "declare dead letter when prepare consume bindings")
+		}
+	}
+
 	return nil
 }
 
@@ -196,13 +238,22 @@ func (s *rmpSubscriberImpl) runSubscriber(ctx context.Context, out chan *provide
 		}
 	}()
 
+	// 重试时通过当前channel重新发布消息, 需要等待broker确认后才能确认原消息
+	if s.retryPolicy != nil {
+		if err = amqpChannel.Confirm(false); err != nil {
+			s.logger.Error("failed to set channel to confirmed mode", "err", err)
+			return
+		}
+	}
+
 	notifyCloseChannel := amqpChannel.NotifyClose(make(chan *amqp.Error, 1))
 	sub := subscription{
 		out:                out,
 		logger:             s.logger,
 		notifyCloseChannel: notifyCloseChannel,
 		channel:            amqpChannel,
-		queueName:          t.QueueName,
+		topology:           t,
+		retryPolicy:        s.retryPolicy,
 		closing:            s.connection.Closing(),
 		closedChan:         s.closedChan,
 		config:             s.config,
@@ -1,5 +1,10 @@
 package rabbitmq
 
+import (
+	"github.com/hdget/sdk/common/provider"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
 type subscriberOption func(impl *rmpSubscriberImpl)
 
 func withSubscriberDelayTopology() subscriberOption {
@@ -7,3 +12,23 @@ func withSubscriberDelayTopology() subscriberOption {
 		impl.useDelayTopology = true
 	}
 }
+
+func withSubscriberRetryPolicy(policy *provider.RetryPolicy) subscriberOption {
+	return func(impl *rmpSubscriberImpl) {
+		impl.retryPolicy = policy
+	}
+}
+
+func withSubscriberExchange(kind ExchangeKind, bindingKeys []string, bindingHeaders amqp.Table) subscriberOption {
+	return func(impl *rmpSubscriberImpl) {
+		impl.exchangeKind = kind
+		impl.bindingKeys = bindingKeys
+		impl.bindingHeaders = bindingHeaders
+	}
+}
+
+func withSubscriberQueueOption(option *provider.QueueOption) subscriberOption {
+	return func(impl *rmpSubscriberImpl) {
+		impl.queueOption = option
+	}
+}
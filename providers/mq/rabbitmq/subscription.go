@@ -2,6 +2,7 @@ package rabbitmq
 
 import (
 	"context"
+
 	"github.com/hdget/sdk/common/provider"
 	"github.com/pkg/errors"
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -12,7 +13,8 @@ type subscription struct {
 	logger             provider.Logger
 	notifyCloseChannel chan *amqp.Error
 	channel            *amqp.Channel
-	queueName          string
+	topology           *Topology
+	retryPolicy        *provider.RetryPolicy
 	closing            chan struct{}
 	closedChan         chan struct{}
 	config             *RabbitMqConfig
@@ -36,7 +38,7 @@ func (s *subscription) createConsumer(queueName string, amqpChannel *amqp.Channe
 }
 
 func (s *subscription) ProcessMessages(ctx context.Context) {
-	amqpMsgs, err := s.createConsumer(s.queueName, s.channel)
+	amqpMsgs, err := s.createConsumer(s.topology.QueueName, s.channel)
 	if err != nil {
 		s.logger.Error("start consuming messages", "err", err)
 		return
@@ -48,7 +50,7 @@ ConsumingLoop:
 		case amqpMsg := <-amqpMsgs:
 			if err = s.processMessage(ctx, amqpMsg, s.out); err != nil {
 				s.logger.Error("processing message failed, sending nack", "err", err)
-				if err = s.nackMsg(amqpMsg); err != nil {
+				if err = s.nackMsg(ctx, amqpMsg); err != nil {
 					s.logger.Error("cannot nack message", "err", err)
 					// something went really wrong when we cannot nack, let's reconnect
 					break ConsumingLoop
@@ -76,7 +78,7 @@ ConsumingLoop:
 }
 
 func (s *subscription) processMessage(ctx context.Context, amqpMsg amqp.Delivery, out chan *provider.Message) error {
-	msg := provider.NewMessage(amqpMsg.Body)
+	msg := newMessage(amqpMsg)
 
 	ctx, cancelCtx := context.WithCancel(ctx)
 	msg.SetContext(ctx)
@@ -85,10 +87,10 @@ func (s *subscription) processMessage(ctx context.Context, amqpMsg amqp.Delivery
 	select {
 	case <-s.closing:
 		s.logger.Info("message not consumed, pub/sub is closing")
-		return s.nackMsg(amqpMsg)
+		return s.releaseMsg(amqpMsg)
 	case <-s.closedChan:
 		s.logger.Info("message not consumed, subscriber is closed")
-		return s.nackMsg(amqpMsg)
+		return s.releaseMsg(amqpMsg)
 	case out <- msg:
 		s.logger.Trace("message sent to consumer")
 	}
@@ -96,19 +98,64 @@ func (s *subscription) processMessage(ctx context.Context, amqpMsg amqp.Delivery
 	select {
 	case <-s.closing:
 		s.logger.Trace("closing pub/sub, message discarded before ack")
-		return s.nackMsg(amqpMsg)
+		return s.releaseMsg(amqpMsg)
 	case <-s.closedChan:
 		s.logger.Info("message not consumed, subscriber is closed")
-		return s.nackMsg(amqpMsg)
+		return s.releaseMsg(amqpMsg)
 	case <-msg.Acked():
 		s.logger.Trace("message acked")
 		return amqpMsg.Ack(false)
 	case <-msg.Nacked():
 		s.logger.Trace("message nacked")
-		return s.nackMsg(amqpMsg)
+		return s.nackMsg(ctx, amqpMsg)
+	}
+}
+
+// nackMsg 消息处理失败, 设置了重试策略时延迟重试或者进入死信队列
+func (s *subscription) nackMsg(ctx context.Context, amqpMsg amqp.Delivery) error {
+	if s.retryPolicy == nil {
+		return amqpMsg.Nack(false, s.config.RequeueInFailure)
 	}
+	return s.retryMsg(ctx, amqpMsg)
 }
 
-func (s *subscription) nackMsg(amqpMsg amqp.Delivery) error {
-	return amqpMsg.Nack(false, s.config.RequeueInFailure)
+// releaseMsg 订阅关闭导致消息没有被处理, 不计入重试次数
+func (s *subscription) releaseMsg(amqpMsg amqp.Delivery) error {
+	if s.retryPolicy == nil {
+		return amqpMsg.Nack(false, s.config.RequeueInFailure)
+	}
+	return amqpMsg.Nack(false, true)
+}
+
+// retryMsg 将消息的副本重新投递到当前队列或者死信队列, broker确认副本后再确认原消息
+// 发布失败或者没有确认时原消息重新入队, 此时副本可能已经投递, 消息可能重复
+func (s *subscription) retryMsg(ctx context.Context, amqpMsg amqp.Delivery) error {
+	attempts := getAttempts(amqpMsg.Headers) + 1
+
+	publishing := newRepublishing(amqpMsg)
+	publishing.Headers[headerAttempts] = int64(attempts)
+
+	// 缺省交换机按队列名投递
+	exchange, routingKey := "", s.topology.QueueName
+	if attempts >= s.retryPolicy.MaxAttempts {
+		exchange, routingKey = s.topology.DeadLetterName, ""
+		s.logger.Warn("message exceeds max attempts, move to dead letter queue", "queue", s.topology.DeadLetterName, "uuid", amqpMsg.MessageId, "attempts", attempts)
+	} else if delay := s.retryPolicy.Backoff(attempts); delay > 0 {
+		exchange = s.topology.RetryExchangeName
+		publishing.Headers["x-delay"] = delay.Milliseconds()
+	}
+
+	confirm, err := s.channel.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, false, false, publishing)
+	if err != nil {
+		s.logger.Error("republish failed message, requeue it", "err", err, "uuid", amqpMsg.MessageId)
+		return amqpMsg.Nack(false, true)
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil || !acked {
+		s.logger.Error("republished message not confirmed, requeue it", "err", err, "uuid", amqpMsg.MessageId)
+		return amqpMsg.Nack(false, true)
+	}
+
+	return amqpMsg.Ack(false)
 }
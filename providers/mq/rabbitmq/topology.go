@@ -2,6 +2,9 @@ package rabbitmq
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/hdget/sdk/common/provider"
 	"github.com/hdget/utils/text"
 	"github.com/pkg/errors"
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -25,16 +28,24 @@ const (
 type ExchangeKind string
 
 const (
-	ExchangeKindFanout ExchangeKind = "fanout"
+	ExchangeKindFanout  ExchangeKind = "fanout"
+	ExchangeKindDirect  ExchangeKind = "direct"
+	ExchangeKindTopic   ExchangeKind = "topic"
+	ExchangeKindHeaders ExchangeKind = "headers"
 )
 
 type Topology struct {
-	Kind         TopologyKind
-	ExchangeKind ExchangeKind
-	ExchangeName string
-	QueueName    string
-	RoutingKey   string
-	BindingKey   string
+	Kind           TopologyKind
+	ExchangeKind   ExchangeKind
+	ExchangeName   string
+	QueueName      string
+	QueueArgs      amqp.Table // 队列参数, 例如: x-queue-type, x-message-ttl
+	RoutingKey     string
+	BindingKeys    []string   // 为空时使用空的路由键绑定, headers交换机不使用
+	BindingHeaders amqp.Table // headers交换机的绑定参数, 包含x-match
+	// 启用重试后才会设置
+	RetryExchangeName string // 延迟重试的交换机, 只绑定到当前队列, 需要延迟消息插件
+	DeadLetterName    string // 死信交换机和死信队列的名字
 }
 
 // 相同name的多个订阅者如果订阅同一个topic,则只有一个订阅者会收到消息
@@ -63,6 +74,120 @@ func newTopology(name, topic string, useDelayTopology bool) (*Topology, error) {
 	}, nil
 }
 
+// parseExchangeKind 解析交换机类型, 为空时返回空值, 表示由配置决定
+func parseExchangeKind(kind string) (ExchangeKind, error) {
+	switch k := ExchangeKind(strings.ToLower(text.CleanString(kind))); k {
+	case "", ExchangeKindFanout, ExchangeKindDirect, ExchangeKindTopic, ExchangeKindHeaders:
+		return k, nil
+	}
+	return "", fmt.Errorf("invalid exchange kind, kind: %s", kind)
+}
+
+// newBindingHeaders headers交换机的绑定参数
+func newBindingHeaders(headers map[string]string, matchAny bool) amqp.Table {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	match := "all"
+	if matchAny {
+		match = "any"
+	}
+
+	table := amqp.Table{"x-match": match}
+	for k, v := range headers {
+		table[k] = v
+	}
+	return table
+}
+
+// newQueueArgs 将队列参数转换为声明队列时的参数
+func newQueueArgs(option *provider.QueueOption) amqp.Table {
+	if option == nil {
+		return nil
+	}
+
+	args := make(amqp.Table)
+	if option.Type != "" {
+		args["x-queue-type"] = option.Type
+	}
+	if option.MessageTTL > 0 {
+		args["x-message-ttl"] = option.MessageTTL.Milliseconds()
+	}
+	if option.MaxLength > 0 {
+		args["x-max-length"] = option.MaxLength
+	}
+	if option.MaxLengthBytes > 0 {
+		args["x-max-length-bytes"] = option.MaxLengthBytes
+	}
+	if option.Overflow != "" {
+		args["x-overflow"] = option.Overflow
+	}
+	if option.MaxPriority > 0 {
+		args["x-max-priority"] = int32(option.MaxPriority)
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+	return args
+}
+
+// enableRetry 设置重试和死信使用的交换机和队列: <topic>@<name>.retry, <topic>@<name>.dlq
+func (t *Topology) enableRetry() {
+	t.RetryExchangeName = t.QueueName + ".retry"
+	t.DeadLetterName = t.QueueName + ".dlq"
+}
+
+// DeclareRetry 声明延迟重试交换机, 只用于将重试的消息延迟投递回当前队列
+func (t *Topology) DeclareRetry(amqpChannel *amqp.Channel) error {
+	err := amqpChannel.ExchangeDeclare(
+		t.RetryExchangeName,
+		"x-delayed-message",
+		true,
+		false,
+		false,
+		false,
+		amqp.Table{"x-delayed-type": string(ExchangeKindFanout)},
+	)
+	if err != nil {
+		return errors.Wrap(err, "cannot declare retry exchange")
+	}
+
+	err = amqpChannel.QueueBind(t.QueueName, "", t.RetryExchangeName, false, nil)
+	if err != nil {
+		return errors.Wrap(err, "cannot bind retry exchange")
+	}
+	return nil
+}
+
+// DeclareDeadLetter 声明死信交换机和死信队列
+func (t *Topology) DeclareDeadLetter(amqpChannel *amqp.Channel) error {
+	err := amqpChannel.ExchangeDeclare(
+		t.DeadLetterName,
+		string(ExchangeKindFanout),
+		true,
+		false,
+		false,
+		false,
+		nil,
+	)
+	if err != nil {
+		return errors.Wrap(err, "cannot declare dead letter exchange")
+	}
+
+	_, err = amqpChannel.QueueDeclare(t.DeadLetterName, true, false, false, false, nil)
+	if err != nil {
+		return errors.Wrap(err, "cannot declare dead letter queue")
+	}
+
+	err = amqpChannel.QueueBind(t.DeadLetterName, "", t.DeadLetterName, false, nil)
+	if err != nil {
+		return errors.Wrap(err, "cannot bind dead letter queue")
+	}
+	return nil
+}
+
 func (t *Topology) DeclareExchange(amqpChannel *amqp.Channel) error {
 	var err error
 	if t.Kind == TopologyKindDelay {
@@ -100,7 +225,7 @@ func (t *Topology) DeclareQueue(amqpChannel *amqp.Channel) error {
 		false,
 		false,
 		false,
-		nil,
+		t.QueueArgs,
 	)
 	if err != nil {
 		return errors.Wrap(err, "cannot declare queue")
@@ -109,15 +234,31 @@ func (t *Topology) DeclareQueue(amqpChannel *amqp.Channel) error {
 	return nil
 }
 
+// BindQueue 将队列绑定到交换机, 每个路由键绑定一次, headers交换机按消息头绑定
 func (t *Topology) BindQueue(amqpChannel *amqp.Channel) error {
-	err := amqpChannel.QueueBind(
-		t.QueueName, // queue: exchangeName_key
-		t.BindingKey,
-		t.ExchangeName,
-		false,
-		nil)
-	if err != nil {
-		return errors.Wrap(err, "cannot bind queue")
+	if t.ExchangeKind == ExchangeKindHeaders {
+		err := amqpChannel.QueueBind(t.QueueName, "", t.ExchangeName, false, t.BindingHeaders)
+		if err != nil {
+			return errors.Wrap(err, "cannot bind queue")
+		}
+		return nil
+	}
+
+	bindingKeys := t.BindingKeys
+	if len(bindingKeys) == 0 {
+		bindingKeys = []string{""}
+	}
+
+	for _, bindingKey := range bindingKeys {
+		err := amqpChannel.QueueBind(
+			t.QueueName, // queue: exchangeName_key
+			bindingKey,
+			t.ExchangeName,
+			false,
+			nil)
+		if err != nil {
+			return errors.Wrapf(err, "cannot bind queue, binding key: %s", bindingKey)
+		}
 	}
 
 	return nil
@@ -0,0 +1,198 @@
+package rabbitmq
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestParseExchangeKind(t *testing.T) {
+	testCases := []struct {
+		kind    string
+		want    ExchangeKind
+		wantErr bool
+	}{
+		{kind: "", want: ""},
+		{kind: "fanout", want: ExchangeKindFanout},
+		{kind: "direct", want: ExchangeKindDirect},
+		{kind: "Topic", want: ExchangeKindTopic},
+		{kind: " headers ", want: ExchangeKindHeaders},
+		{kind: "x-delayed-message", wantErr: true},
+		{kind: "unknown", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		got, err := parseExchangeKind(tc.kind)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("parseExchangeKind(%q) = %q, %v, want %q, error %v", tc.kind, got, err, tc.want, tc.wantErr)
+		}
+	}
+}
+
+func TestNewTopology(t *testing.T) {
+	topology, err := newTopology("order", "created", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Topology{
+		Kind:         TopologyKindDelay,
+		ExchangeKind: ExchangeKindFanout,
+		ExchangeName: "created",
+		QueueName:    "created@order",
+	}
+	if !reflect.DeepEqual(topology, want) {
+		t.Fatalf("newTopology() = %+v, want %+v", topology, want)
+	}
+
+	topology.enableRetry()
+	if topology.RetryExchangeName != "created@order.retry" || topology.DeadLetterName != "created@order.dlq" {
+		t.Fatalf("retry topology = %s %s", topology.RetryExchangeName, topology.DeadLetterName)
+	}
+
+	if _, err = newTopology("", "created", false); err == nil {
+		t.Fatal("newTopology() with empty name, want error")
+	}
+	if _, err = newTopology("order", "", false); err == nil {
+		t.Fatal("newTopology() with empty topic, want error")
+	}
+}
+
+func TestNewBindingHeaders(t *testing.T) {
+	if got := newBindingHeaders(nil, true); got != nil {
+		t.Fatalf("newBindingHeaders(nil) = %v, want nil", got)
+	}
+
+	headers := map[string]string{"type": "order", "region": "cn"}
+	if got := newBindingHeaders(headers, false); !reflect.DeepEqual(got, amqp.Table{"x-match": "all", "type": "order", "region": "cn"}) {
+		t.Fatalf("newBindingHeaders(all) = %v", got)
+	}
+	if got := newBindingHeaders(headers, true); got["x-match"] != "any" {
+		t.Fatalf("newBindingHeaders(any) = %v", got)
+	}
+}
+
+func TestNewQueueArgs(t *testing.T) {
+	testCases := []struct {
+		name   string
+		option *provider.QueueOption
+		want   amqp.Table
+	}{
+		{name: "nil", option: nil, want: nil},
+		{name: "empty", option: &provider.QueueOption{}, want: nil},
+		{
+			name: "all",
+			option: &provider.QueueOption{
+				Type:           "quorum",
+				MessageTTL:     1500 * time.Millisecond,
+				MaxLength:      100,
+				MaxLengthBytes: 1024,
+				Overflow:       "reject-publish",
+				MaxPriority:    10,
+			},
+			want: amqp.Table{
+				"x-queue-type":       "quorum",
+				"x-message-ttl":      int64(1500),
+				"x-max-length":       int64(100),
+				"x-max-length-bytes": int64(1024),
+				"x-overflow":         "reject-publish",
+				"x-max-priority":     int32(10),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := newQueueArgs(tc.option)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("newQueueArgs() = %#v, want %#v", got, tc.want)
+			}
+
+			// 队列参数必须是amqp支持的类型
+			if err := got.Validate(); err != nil {
+				t.Fatalf("queue args invalid: %v", err)
+			}
+		})
+	}
+}
+
+// 订阅选项优先于配置, 都没有指定时使用fanout交换机和broker缺省的队列参数
+func TestSubscriberSetupTopology(t *testing.T) {
+	config := &RabbitMqConfig{
+		Exchanges: []*ExchangeConfig{{Topic: "created", Kind: "topic"}, {Topic: "invalid", Kind: "unknown"}},
+		Queue:     &QueueConfig{Type: "quorum", MessageTTL: 60},
+	}
+
+	testCases := []struct {
+		name       string
+		topic      string
+		subscriber *rmpSubscriberImpl
+		wantKind   ExchangeKind
+		wantArgs   amqp.Table
+	}{
+		{
+			name:       "config",
+			topic:      "created",
+			subscriber: &rmpSubscriberImpl{config: config},
+			wantKind:   ExchangeKindTopic,
+			wantArgs:   amqp.Table{"x-queue-type": "quorum", "x-message-ttl": int64(60000)},
+		},
+		{
+			name:       "default",
+			topic:      "invalid",
+			subscriber: &rmpSubscriberImpl{config: &RabbitMqConfig{}},
+			wantKind:   ExchangeKindFanout,
+		},
+		{
+			name:  "option",
+			topic: "created",
+			subscriber: &rmpSubscriberImpl{
+				config:         config,
+				exchangeKind:   ExchangeKindHeaders,
+				bindingHeaders: amqp.Table{"x-match": "all", "type": "order"},
+				queueOption:    &provider.QueueOption{MaxLength: 10},
+			},
+			wantKind: ExchangeKindHeaders,
+			wantArgs: amqp.Table{"x-max-length": int64(10)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			topology, err := newTopology("order", tc.topic, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tc.subscriber.setupTopology(topology)
+			if topology.ExchangeKind != tc.wantKind {
+				t.Fatalf("exchange kind = %s, want %s", topology.ExchangeKind, tc.wantKind)
+			}
+			if !reflect.DeepEqual(topology.QueueArgs, tc.wantArgs) {
+				t.Fatalf("queue args = %v, want %v", topology.QueueArgs, tc.wantArgs)
+			}
+			if !reflect.DeepEqual(topology.BindingHeaders, tc.subscriber.bindingHeaders) {
+				t.Fatalf("binding headers = %v, want %v", topology.BindingHeaders, tc.subscriber.bindingHeaders)
+			}
+		})
+	}
+}
+
+func TestPublisherSetupTopology(t *testing.T) {
+	config := &RabbitMqConfig{Exchanges: []*ExchangeConfig{{Topic: "created", Kind: "direct"}}}
+
+	topology, _ := newTopology("order", "created", false)
+	(&rmqPublisherImpl{config: config, routingKey: "cn"}).setupTopology(topology)
+	if topology.ExchangeKind != ExchangeKindDirect || topology.RoutingKey != "cn" {
+		t.Fatalf("topology = %s %s, want direct cn", topology.ExchangeKind, topology.RoutingKey)
+	}
+
+	topology, _ = newTopology("order", "created", false)
+	(&rmqPublisherImpl{config: config, exchangeKind: ExchangeKindTopic}).setupTopology(topology)
+	if topology.ExchangeKind != ExchangeKindTopic {
+		t.Fatalf("exchange kind = %s, want topic", topology.ExchangeKind)
+	}
+}
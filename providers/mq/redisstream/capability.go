@@ -0,0 +1,20 @@
+package redisstream
+
+import (
+	"github.com/hdget/sdk/common/provider"
+	"go.uber.org/fx"
+)
+
+const (
+	providerName = "mq-redisstream"
+)
+
+var Capability = provider.Capability{
+	Category: provider.CategoryMq,
+	Name:     providerName,
+	Module: fx.Module(
+		providerName,
+		fx.Provide(New),
+	),
+	Config: configSchema,
+}
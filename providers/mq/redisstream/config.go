@@ -0,0 +1,84 @@
+package redisstream
+
+import (
+	"time"
+
+	"github.com/hdget/sdk/common/config"
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+type RedisStreamConfig struct {
+	// Redis 使用的redis客户端名字, 为空时使用缺省客户端
+	Redis string `mapstructure:"redis"`
+	// KeyPrefix stream的key前缀, topic的stream为<prefix>{<topic>}
+	KeyPrefix string `mapstructure:"key_prefix"`
+	// BatchSize 每次读取的最多消息数量
+	BatchSize int `mapstructure:"batch_size"`
+	// BlockTimeout 读取消息时的最长阻塞时间, 单位: 毫秒, 也是关闭订阅时的最长等待时间
+	BlockTimeout int `mapstructure:"block_timeout"`
+	// DelayPollInterval 检查到期延迟消息的间隔, 单位: 毫秒
+	DelayPollInterval int `mapstructure:"delay_poll_interval"`
+	// ClaimIdleTimeout 其他消费者读取后超过该时间仍未确认的消息会被重新认领, 单位: 秒, 0表示不认领
+	// 需要大于消息处理时间和重试的最长等待时间, 否则消息会被重复处理
+	ClaimIdleTimeout int `mapstructure:"claim_idle_timeout"`
+}
+
+const (
+	configSection = "sdk.redisstream"
+)
+
+var (
+	// defaultConfig 零值有意义的配置项的缺省值, 需要在解析配置之前预先填充
+	defaultConfig = RedisStreamConfig{
+		KeyPrefix:         "mq:",
+		BatchSize:         10,
+		BlockTimeout:      2000,
+		DelayPollInterval: 500,
+		ClaimIdleTimeout:  300,
+	}
+	errInvalidConfig = errors.New("invalid config")
+	configSchema     = &provider.ConfigSchema{
+		Section: configSection,
+		New: func() any {
+			c := defaultConfig
+			return &c
+		},
+		Optional: true, // 没有配置时使用缺省配置
+	}
+)
+
+// newConfig 解析配置, 没有配置时使用缺省配置
+func newConfig(configProvider provider.Config) (*RedisStreamConfig, error) {
+	if configProvider == nil {
+		return nil, errInvalidConfig
+	}
+
+	c := configSchema.New().(*RedisStreamConfig)
+	if configProvider.Get(configSection) == nil {
+		return c, nil
+	}
+
+	err := config.Parse(configProvider, configSection, c)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse redis stream provider config")
+	}
+
+	if c.BatchSize <= 0 || c.BlockTimeout <= 0 || c.DelayPollInterval <= 0 {
+		return nil, errors.Wrap(errInvalidConfig, "batch_size, block_timeout and delay_poll_interval must be greater than 0")
+	}
+
+	return c, nil
+}
+
+func (c *RedisStreamConfig) getBlockTimeout() time.Duration {
+	return time.Duration(c.BlockTimeout) * time.Millisecond
+}
+
+func (c *RedisStreamConfig) getDelayPollInterval() time.Duration {
+	return time.Duration(c.DelayPollInterval) * time.Millisecond
+}
+
+func (c *RedisStreamConfig) getClaimIdleTimeout() time.Duration {
+	return time.Duration(c.ClaimIdleTimeout) * time.Second
+}
@@ -0,0 +1,24 @@
+module github.com/hdget/sdk/providers/mq/redisstream
+
+go 1.24.0
+
+require (
+	github.com/hdget/sdk/common v0.1.21
+	github.com/hdget/sdk/providers/redis/redigo v0.0.0-00010101000000-000000000000
+	github.com/pkg/errors v0.9.1
+	go.uber.org/fx v1.24.0
+)
+
+require (
+	github.com/gomodule/redigo v1.9.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/hdget/utils v0.2.3 // indirect
+	github.com/hdget/utils/paginator v0.0.1 // indirect
+	go.uber.org/dig v1.19.0 // indirect
+	go.uber.org/multierr v1.11.0 // indirect
+	go.uber.org/zap v1.27.1 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+	google.golang.org/protobuf v1.36.11 // indirect
+)
+
+replace github.com/hdget/sdk/providers/redis/redigo => ../../redis/redigo
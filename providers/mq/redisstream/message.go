@@ -0,0 +1,41 @@
+package redisstream
+
+import (
+	"encoding/json"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+const (
+	fieldMessage = "message" // 序列化后的消息
+	fieldGroup   = "group"   // 重新投递的死信消息只由该消费组处理
+)
+
+// messageData 保存在stream, 延迟消息集合和死信列表中的消息
+type messageData struct {
+	UUID     string            `json:"uuid"`
+	Payload  []byte            `json:"payload"`
+	Metadata provider.Metadata `json:"metadata,omitempty"`
+}
+
+func marshalMessage(msg *provider.Message) (string, error) {
+	data, err := json.Marshal(&messageData{UUID: msg.UUID, Payload: msg.Payload, Metadata: msg.Metadata})
+	if err != nil {
+		return "", errors.Wrapf(err, "marshal message, uuid: %s", msg.UUID)
+	}
+	return string(data), nil
+}
+
+func unmarshalMessage(data string) (*provider.Message, error) {
+	var m messageData
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		return nil, errors.Wrap(err, "unmarshal message")
+	}
+
+	msg := provider.NewMessageWithUUID(m.UUID, m.Payload)
+	for k, v := range m.Metadata {
+		msg.Metadata.Set(k, v)
+	}
+	return msg, nil
+}
@@ -0,0 +1,160 @@
+package redisstream
+
+import (
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// redisStreamProvider 基于redis stream消费组的消息队列
+// 每个topic对应一个stream, 每个订阅者name对应一个消费组:
+// 相同name的订阅者在同一个消费组中竞争消费, 不同name的订阅者属于不同的消费组, 都会收到消息
+type redisStreamProvider struct {
+	config        *RedisStreamConfig
+	logger        provider.Logger
+	redisProvider provider.Redis
+	mutex         sync.Mutex
+	closers       []io.Closer // 已创建的publisher和subscriber, 退出时统一关闭
+}
+
+func New(configProvider provider.Config, logger provider.Logger, redisProvider provider.Redis) (provider.MessageQueue, error) {
+	config, err := newConfig(configProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	if redisProvider == nil {
+		return nil, errors.New("redis provider not initialized")
+	}
+
+	return &redisStreamProvider{config: config, logger: logger, redisProvider: redisProvider}, nil
+}
+
+func (r *redisStreamProvider) GetCapability() provider.Capability {
+	return Capability
+}
+
+func (r *redisStreamProvider) NewPublisher(name string, args ...*provider.PublisherOption) (provider.MessageQueuePublisher, error) {
+	option := provider.DefaultPublisherOption
+	if len(args) > 0 {
+		option = args[0]
+	}
+
+	p := newPublisher(r, option.PublishDelayMessage)
+	r.track(p)
+	return p, nil
+}
+
+func (r *redisStreamProvider) NewSubscriber(name string, args ...*provider.SubscriberOption) (provider.MessageQueueSubscriber, error) {
+	option := provider.DefaultSubscriberOption
+	if len(args) > 0 {
+		option = args[0]
+	}
+
+	if option.RetryPolicy != nil && option.RetryPolicy.MaxAttempts < 1 {
+		return nil, errors.New("retry policy max attempts must be greater than 0")
+	}
+
+	s := newSubscriber(r, name, option.SubscribeDelayMessage, option.RetryPolicy)
+	r.track(s)
+	return s, nil
+}
+
+// ListDeadLetters 查看死信消息, 消息仍然保留在死信列表中
+func (r *redisStreamProvider) ListDeadLetters(topic, name string, limit int) ([]*provider.Message, error) {
+	end := int64(-1)
+	if limit > 0 {
+		end = int64(limit - 1)
+	}
+
+	items, err := r.client().LRangeString(getDeadLetterKey(r.config.KeyPrefix, topic, name), 0, end)
+	if err != nil {
+		return nil, errors.Wrap(err, "list dead letters")
+	}
+
+	messages := make([]*provider.Message, 0, len(items))
+	for _, item := range items {
+		msg, err := unmarshalMessage(item)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// RepublishDeadLetters 将死信消息重新添加到stream中, 只有消费组name会处理, 其他消费组直接确认
+func (r *redisStreamProvider) RepublishDeadLetters(topic, name string, limit int) (int, error) {
+	keys := []any{getDeadLetterKey(r.config.KeyPrefix, topic, name), getStreamKey(r.config.KeyPrefix, topic)}
+	reply, err := r.client().Eval(scriptRepublishDeadLetters, keys, []any{limit, name})
+	if err != nil {
+		return 0, errors.Wrap(err, "republish dead letters")
+	}
+
+	count, err := toInt64(reply)
+	if err != nil {
+		return 0, errors.Wrap(err, "republish dead letters")
+	}
+	return int(count), nil
+}
+
+// Close 关闭所有由该provider创建的publisher和subscriber
+func (r *redisStreamProvider) Close() error {
+	r.mutex.Lock()
+	closers := r.closers
+	r.closers = nil
+	r.mutex.Unlock()
+
+	var firstErr error
+	for _, closer := range closers {
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (r *redisStreamProvider) track(closer io.Closer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.closers = append(r.closers, closer)
+}
+
+func (r *redisStreamProvider) client() provider.RedisClient {
+	if r.config.Redis == "" {
+		return r.redisProvider.My()
+	}
+	return r.redisProvider.By(r.config.Redis)
+}
+
+// getStreamKey topic对应的stream, 使用hash tag保证集群模式下相关的key在同一个slot
+func getStreamKey(prefix, topic string) string {
+	return prefix + "{" + topic + "}"
+}
+
+// getDelayKey 延迟消息的有序集合, score为到期时间
+func getDelayKey(prefix, topic string) string {
+	return getStreamKey(prefix, topic) + ":delay"
+}
+
+// getDeadLetterKey 订阅者name的死信列表
+func getDeadLetterKey(prefix, topic, name string) string {
+	return getStreamKey(prefix, topic) + ":dlq:" + name
+}
+
+func toInt64(reply any) (int64, error) {
+	switch v := reply.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case []byte:
+		return strconv.ParseInt(string(v), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, errors.Errorf("unexpected reply type: %T", reply)
+}
@@ -0,0 +1,271 @@
+package redisstream
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hdget/sdk/common/mq/mqtest"
+	"github.com/hdget/sdk/common/provider"
+	"github.com/hdget/sdk/providers/redis/redigo"
+)
+
+// fakeRedis 只实现了stream消息队列使用的命令, Eval按脚本内容模拟执行
+type fakeRedis struct {
+	provider.Redis
+	provider.RedisClient
+	mutex   sync.Mutex
+	seq     int64
+	streams map[string]*fakeStream
+	zsets   map[string]map[string]int64
+	lists   map[string][]string
+}
+
+type fakeStream struct {
+	entries []*provider.RedisStreamMessage
+	groups  map[string]int // 消费组 => 下一条未投递消息的位置
+}
+
+type fakeConfig struct {
+	provider.Config
+}
+
+type nopLogger struct {
+	provider.Logger
+}
+
+// redisConfig 只包含sdk.redis配置的配置, 用于创建真实的redis客户端
+type redisConfig struct {
+	provider.Config
+	values map[string]any
+}
+
+// testLogger 初始化redis客户端失败时结束测试
+type testLogger struct {
+	nopLogger
+	t *testing.T
+}
+
+func (fakeConfig) Get(string) any { return nil }
+
+func (c redisConfig) Get(section string) any {
+	if section == "sdk.redis" {
+		return c.values
+	}
+	return nil
+}
+
+// Unmarshal 字段名大小写不敏感, 可以直接通过json转换
+func (c redisConfig) Unmarshal(configVar any, _ ...string) error {
+	data, err := json.Marshal(c.values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, configVar)
+}
+
+// Watch 配置不会变化, 不需要监听
+func (c redisConfig) Watch(string, func(oldValue, newValue any)) error {
+	return nil
+}
+
+func (nopLogger) Error(string, ...any) {}
+
+func (nopLogger) Debug(string, ...any) {}
+
+func (l testLogger) Fatal(msg string, kvs ...any) {
+	l.t.Fatal(append([]any{msg}, kvs...)...)
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{
+		streams: make(map[string]*fakeStream),
+		zsets:   make(map[string]map[string]int64),
+		lists:   make(map[string][]string),
+	}
+}
+
+func (r *fakeRedis) My() provider.RedisClient { return r }
+
+func (r *fakeRedis) By(string) provider.RedisClient { return r }
+
+func (r *fakeRedis) WithContext(context.Context) provider.RedisClient { return r }
+
+func (r *fakeRedis) XAdd(key string, _ string, values map[string]any) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.xadd(key, values), nil
+}
+
+func (r *fakeRedis) XGroupCreate(key, group, _ string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s := r.stream(key)
+	if _, exists := s.groups[group]; !exists {
+		s.groups[group] = len(s.entries)
+	}
+	return nil
+}
+
+func (r *fakeRedis) XReadGroup(key, group, _ string, count int, block time.Duration) ([]*provider.RedisStreamMessage, error) {
+	deadline := time.Now().Add(block)
+	for {
+		r.mutex.Lock()
+		s := r.stream(key)
+		next := s.groups[group]
+		end := min(next+count, len(s.entries))
+		messages := s.entries[next:end]
+		s.groups[group] = end
+		r.mutex.Unlock()
+
+		if len(messages) > 0 || time.Now().After(deadline) {
+			return messages, nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (r *fakeRedis) XAck(string, string, ...string) (int64, error) {
+	return 1, nil
+}
+
+func (r *fakeRedis) ZAdd(key string, score int64, member any) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.zsets[key] == nil {
+		r.zsets[key] = make(map[string]int64)
+	}
+	r.zsets[key][member.(string)] = score
+	return nil
+}
+
+func (r *fakeRedis) RPush(key string, values ...any) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, v := range values {
+		r.lists[key] = append(r.lists[key], v.(string))
+	}
+	return nil
+}
+
+func (r *fakeRedis) LRangeString(key string, start, end int64) ([]string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	items := r.lists[key]
+	if end < 0 || end >= int64(len(items)) {
+		end = int64(len(items)) - 1
+	}
+	return append([]string(nil), items[start:end+1]...), nil
+}
+
+func (r *fakeRedis) Eval(script string, keys []any, args []any) (any, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	switch script {
+	case scriptMoveDelayed:
+		zset := r.zsets[keys[0].(string)]
+		members := make([]string, 0)
+		for member, score := range zset {
+			if score <= args[0].(int64) {
+				members = append(members, member)
+			}
+		}
+		sort.Slice(members, func(i, j int) bool { return zset[members[i]] < zset[members[j]] })
+		for _, member := range members {
+			r.xadd(keys[1].(string), map[string]any{fieldMessage: member})
+			delete(zset, member)
+		}
+		return int64(len(members)), nil
+	case scriptRepublishDeadLetters:
+		key := keys[0].(string)
+		items := r.lists[key]
+		if limit := args[0].(int); limit > 0 && limit < len(items) {
+			items = items[:limit]
+		}
+		for _, item := range items {
+			r.xadd(keys[1].(string), map[string]any{fieldMessage: item, fieldGroup: args[1]})
+		}
+		r.lists[key] = r.lists[key][len(items):]
+		return int64(len(items)), nil
+	case scriptClaim:
+		return []any{}, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeRedis) stream(key string) *fakeStream {
+	s := r.streams[key]
+	if s == nil {
+		s = &fakeStream{groups: make(map[string]int)}
+		r.streams[key] = s
+	}
+	return s
+}
+
+func (r *fakeRedis) xadd(key string, values map[string]any) string {
+	r.seq++
+	id := strconv.FormatInt(r.seq, 10) + "-0"
+
+	fields := make(map[string]string, len(values))
+	for k, v := range values {
+		fields[k] = v.(string)
+	}
+
+	s := r.stream(key)
+	s.entries = append(s.entries, &provider.RedisStreamMessage{ID: id, Values: fields})
+	return id
+}
+
+func TestConformance(t *testing.T) {
+	mqtest.Run(t, func(t *testing.T) provider.MessageQueue {
+		mq, err := New(fakeConfig{}, nopLogger{}, newFakeRedis())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = mq.(*redisStreamProvider).Close() })
+		return mq
+	}, mqtest.Features{DelayMessage: true, DeadLetter: true})
+}
+
+// TestConformanceRedis 需要redis服务器, 通过REDIS_ADDR指定, 例如: 127.0.0.1:6379
+func TestConformanceRedis(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redisPort, _ := strconv.Atoi(port)
+
+	mqtest.Run(t, func(t *testing.T) provider.MessageQueue {
+		redisProvider, err := redigo.New(redisConfig{values: map[string]any{
+			"default": map[string]any{"host": host, "port": redisPort, "password": os.Getenv("REDIS_PASSWORD")},
+		}}, testLogger{t: t})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = redisProvider.(io.Closer).Close() })
+
+		mq, err := New(fakeConfig{}, nopLogger{}, redisProvider)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = mq.(*redisStreamProvider).Close() })
+		return mq
+	}, mqtest.Features{DelayMessage: true, DeadLetter: true})
+}
@@ -0,0 +1,67 @@
+package redisstream
+
+import (
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+type redisStreamPublisher struct {
+	provider         *redisStreamProvider
+	useDelayTopology bool
+}
+
+func newPublisher(p *redisStreamProvider, useDelayTopology bool) *redisStreamPublisher {
+	return &redisStreamPublisher{
+		provider:         p,
+		useDelayTopology: useDelayTopology,
+	}
+}
+
+func (p *redisStreamPublisher) Publish(topic string, messages [][]byte, delaySeconds ...int64) error {
+	msgs := make([]*provider.Message, len(messages))
+	for i, payload := range messages {
+		msgs[i] = provider.NewMessage(payload)
+	}
+	return p.PublishMessage(topic, msgs, delaySeconds...)
+}
+
+// PublishMessage 和rabbitmq保持一致, 只有发布延迟消息的发布者才使用delaySeconds
+// 延迟消息先保存在有序集合中, 到期后由订阅了延迟消息的订阅者移动到stream中
+func (p *redisStreamPublisher) PublishMessage(topic string, messages []*provider.Message, delaySeconds ...int64) error {
+	var delay time.Duration
+	if p.useDelayTopology {
+		if len(delaySeconds) == 0 {
+			return errors.New("no delay seconds specified")
+		}
+		delay = time.Duration(delaySeconds[0]) * time.Second
+	}
+
+	client := p.provider.client()
+	prefix := p.provider.config.KeyPrefix
+	for i, msg := range messages {
+		if msg.UUID == "" {
+			msg.UUID = provider.NewUUID()
+		}
+
+		data, err := marshalMessage(msg)
+		if err != nil {
+			return &provider.PublishError{Failed: messages[i:], Err: err}
+		}
+
+		if delay > 0 {
+			err = client.ZAdd(getDelayKey(prefix, topic), time.Now().Add(delay).UnixMilli(), data)
+		} else {
+			_, err = client.XAdd(getStreamKey(prefix, topic), "", map[string]any{fieldMessage: data})
+		}
+		if err != nil {
+			return &provider.PublishError{Failed: messages[i:], Err: errors.Wrapf(err, "publish message, uuid: %s", msg.UUID)}
+		}
+	}
+	return nil
+}
+
+func (p *redisStreamPublisher) Close() error {
+	return nil
+}
@@ -0,0 +1,51 @@
+package redisstream
+
+// scriptMoveDelayed 将到期的延迟消息原子的移动到stream中
+// KEYS[1]: 延迟消息有序集合, KEYS[2]: stream, ARGV[1]: 当前时间, ARGV[2]: 最多移动的消息数量
+const scriptMoveDelayed = `
+local items = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, item in ipairs(items) do
+	redis.call('XADD', KEYS[2], '*', 'message', item)
+	redis.call('ZREM', KEYS[1], item)
+end
+return #items
+`
+
+// scriptRepublishDeadLetters 将死信消息原子的重新添加到stream中, 只投递给指定的消费组
+// KEYS[1]: 死信列表, KEYS[2]: stream, ARGV[1]: 最多投递的消息数量, 小于等于0时投递所有消息, ARGV[2]: 消费组
+const scriptRepublishDeadLetters = `
+local last = tonumber(ARGV[1]) - 1
+if last < 0 then
+	last = -1
+end
+local items = redis.call('LRANGE', KEYS[1], 0, last)
+for _, item in ipairs(items) do
+	redis.call('XADD', KEYS[2], '*', 'message', item, 'group', ARGV[2])
+end
+redis.call('LTRIM', KEYS[1], #items, -1)
+return #items
+`
+
+// scriptClaim 认领其他消费者读取后长时间未确认的消息, 返回: [id, message, group, ...]
+// KEYS[1]: stream, ARGV[1]: 消费组, ARGV[2]: 消费者, ARGV[3]: 最短空闲时间(毫秒), ARGV[4]: 最多认领的消息数量
+const scriptClaim = `
+local reply = redis.call('XAUTOCLAIM', KEYS[1], ARGV[1], ARGV[2], ARGV[3], '0-0', 'COUNT', ARGV[4])
+local result = {}
+for _, entry in ipairs(reply[2]) do
+	local fields = entry and entry[2]
+	if fields then
+		local message, group = '', ''
+		for i = 1, #fields, 2 do
+			if fields[i] == 'message' then
+				message = fields[i + 1]
+			elseif fields[i] == 'group' then
+				group = fields[i + 1]
+			end
+		end
+		table.insert(result, entry[1])
+		table.insert(result, message)
+		table.insert(result, group)
+	end
+end
+return result
+`
@@ -0,0 +1,80 @@
+package redisstream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+type redisStreamSubscriber struct {
+	provider         *redisStreamProvider
+	name             string
+	useDelayTopology bool // 订阅延迟消息时负责将到期的延迟消息移动到stream中
+	retryPolicy      *provider.RetryPolicy
+	closing          chan struct{}
+	closeOnce        sync.Once
+	wg               sync.WaitGroup
+}
+
+const (
+	// 读取消息出错后重试的间隔
+	readErrorInterval = time.Second
+)
+
+func newSubscriber(p *redisStreamProvider, name string, useDelayTopology bool, retryPolicy *provider.RetryPolicy) *redisStreamSubscriber {
+	return &redisStreamSubscriber{
+		provider:         p,
+		name:             name,
+		useDelayTopology: useDelayTopology,
+		retryPolicy:      retryPolicy,
+		closing:          make(chan struct{}),
+	}
+}
+
+// Subscribe 在topic的stream上创建消费组name, 只消费创建消费组之后的消息
+func (s *redisStreamSubscriber) Subscribe(ctx context.Context, topic string) (<-chan *provider.Message, error) {
+	select {
+	case <-s.closing:
+		return nil, errors.New("subscriber is closed")
+	default:
+	}
+
+	sub := &subscription{
+		subscriber: s,
+		client:     s.provider.client(),
+		config:     s.provider.config,
+		logger:     s.provider.logger,
+		topic:      topic,
+		streamKey:  getStreamKey(s.provider.config.KeyPrefix, topic),
+		consumer:   s.name + "-" + provider.NewUUID(),
+		out:        make(chan *provider.Message),
+	}
+
+	err := sub.client.XGroupCreate(sub.streamKey, s.name, "$")
+	if err != nil {
+		return nil, errors.Wrapf(err, "create consumer group, topic: %s, name: %s", topic, s.name)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			close(sub.out)
+			s.wg.Done()
+		}()
+		sub.run(ctx)
+	}()
+
+	return sub.out, nil
+}
+
+// Close 关闭所有订阅, 最多等待block_timeout
+func (s *redisStreamSubscriber) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.closing)
+	})
+	s.wg.Wait()
+	return nil
+}
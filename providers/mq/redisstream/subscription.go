@@ -0,0 +1,212 @@
+package redisstream
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+)
+
+type subscription struct {
+	subscriber *redisStreamSubscriber
+	client     provider.RedisClient
+	config     *RedisStreamConfig
+	logger     provider.Logger
+	topic      string
+	streamKey  string
+	consumer   string
+	out        chan *provider.Message
+}
+
+// run 循环读取消息并逐条投递, 收到Ack或Nack后才投递下一条
+func (s *subscription) run(ctx context.Context) {
+	block := s.config.getBlockTimeout()
+	if s.subscriber.useDelayTopology {
+		block = min(block, s.config.getDelayPollInterval())
+	}
+
+	var lastClaim time.Time
+	for !s.isClosing(ctx) {
+		if s.subscriber.useDelayTopology {
+			s.moveDelayed()
+		}
+
+		var (
+			messages []*provider.RedisStreamMessage
+			err      error
+		)
+		if idle := s.config.getClaimIdleTimeout(); idle > 0 && time.Since(lastClaim) >= idle {
+			lastClaim = time.Now()
+			messages, err = s.claim(idle)
+		}
+		if err == nil && len(messages) == 0 {
+			messages, err = s.client.WithContext(ctx).XReadGroup(s.streamKey, s.subscriber.name, s.consumer, s.config.BatchSize, block)
+		}
+		if err != nil {
+			if s.isClosing(ctx) {
+				return
+			}
+			s.logger.Error("read redis stream", "topic", s.topic, "name", s.subscriber.name, "err", err)
+			s.sleep(ctx, readErrorInterval)
+			continue
+		}
+
+		for _, m := range messages {
+			if !s.process(ctx, m) {
+				return
+			}
+		}
+	}
+}
+
+// process 投递消息直到被确认或者进入死信列表, 订阅关闭时返回false, 未确认的消息之后会被其他消费者认领
+func (s *subscription) process(ctx context.Context, m *provider.RedisStreamMessage) bool {
+	// 重新投递的死信消息只由指定的消费组处理
+	if group := m.Values[fieldGroup]; group != "" && group != s.subscriber.name {
+		s.ack(m.ID)
+		return true
+	}
+
+	published, err := unmarshalMessage(m.Values[fieldMessage])
+	if err != nil {
+		s.logger.Error("invalid stream message, discard it", "topic", s.topic, "id", m.ID, "err", err)
+		s.ack(m.ID)
+		return true
+	}
+
+	for attempts := 1; ; attempts++ {
+		msg := published.Copy()
+		msg.SetContext(ctx)
+
+		select {
+		case s.out <- msg:
+		case <-ctx.Done():
+			return false
+		case <-s.subscriber.closing:
+			return false
+		}
+
+		select {
+		case <-msg.Acked():
+			s.ack(m.ID)
+			return true
+		case <-msg.Nacked():
+		case <-ctx.Done():
+			return false
+		case <-s.subscriber.closing:
+			return false
+		}
+
+		if s.subscriber.retryPolicy == nil {
+			continue
+		}
+
+		if attempts >= s.subscriber.retryPolicy.MaxAttempts {
+			s.deadLetter(m.ID, published)
+			return true
+		}
+
+		if !s.sleep(ctx, s.subscriber.retryPolicy.Backoff(attempts)) {
+			return false
+		}
+	}
+}
+
+// moveDelayed 将到期的延迟消息移动到stream中
+func (s *subscription) moveDelayed() {
+	prefix := s.config.KeyPrefix
+	keys := []any{getDelayKey(prefix, s.topic), s.streamKey}
+	_, err := s.client.Eval(scriptMoveDelayed, keys, []any{time.Now().UnixMilli(), s.config.BatchSize})
+	if err != nil {
+		s.logger.Error("move delayed messages", "topic", s.topic, "err", err)
+	}
+}
+
+// claim 认领其他消费者长时间未确认的消息, 例如消费者异常退出时未处理完的消息
+func (s *subscription) claim(idle time.Duration) ([]*provider.RedisStreamMessage, error) {
+	args := []any{s.subscriber.name, s.consumer, idle.Milliseconds(), s.config.BatchSize}
+	reply, err := s.client.Eval(scriptClaim, []any{s.streamKey}, args)
+	if err != nil {
+		return nil, err
+	}
+
+	items, ok := reply.([]any)
+	if !ok || len(items)%3 != 0 {
+		return nil, fmt.Errorf("unexpected claim reply: %v", reply)
+	}
+
+	messages := make([]*provider.RedisStreamMessage, 0, len(items)/3)
+	for i := 0; i < len(items); i += 3 {
+		messages = append(messages, &provider.RedisStreamMessage{
+			ID: toString(items[i]),
+			Values: map[string]string{
+				fieldMessage: toString(items[i+1]),
+				fieldGroup:   toString(items[i+2]),
+			},
+		})
+	}
+	return messages, nil
+}
+
+func (s *subscription) ack(id string) {
+	if _, err := s.client.XAck(s.streamKey, s.subscriber.name, id); err != nil {
+		s.logger.Error("ack stream message", "topic", s.topic, "id", id, "err", err)
+	}
+}
+
+// deadLetter 超过最多投递次数的消息放入死信列表后确认, 放入失败时不确认, 之后会被重新认领
+func (s *subscription) deadLetter(id string, msg *provider.Message) {
+	s.logger.Error("message exceeds max attempts, move to dead letter", "topic", s.topic, "name", s.subscriber.name, "uuid", msg.UUID)
+
+	data, err := marshalMessage(msg)
+	if err == nil {
+		err = s.client.RPush(getDeadLetterKey(s.config.KeyPrefix, s.topic, s.subscriber.name), data)
+	}
+	if err != nil {
+		s.logger.Error("move message to dead letter", "topic", s.topic, "uuid", msg.UUID, "err", err)
+		return
+	}
+
+	s.ack(id)
+}
+
+func (s *subscription) isClosing(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return true
+	case <-s.subscriber.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+// sleep 等待d, 订阅关闭时返回false
+func (s *subscription) sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-s.subscriber.closing:
+		return false
+	}
+}
+
+func toString(v any) string {
+	switch vv := v.(type) {
+	case []byte:
+		return string(vv)
+	case string:
+		return vv
+	}
+	return fmt.Sprint(v)
+}
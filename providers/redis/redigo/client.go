@@ -1,9 +1,8 @@
 package redigo
 
 import (
-	"fmt"
+	"context"
 	"strconv"
-	"time"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/hdget/sdk/common/protobuf"
@@ -14,50 +13,30 @@ import (
 )
 
 type redisClient struct {
-	pool *redis.Pool
+	pool *reloadablePool
+	ctx  context.Context // 执行命令使用的context, 为空时不限制
 }
 
 func newRedisClient(conf *redisClientConfig) (provider.RedisClient, error) {
-	// 建立连接池
-	p := &redis.Pool{
-		// 最大空闲连接数，有这么多个连接提前等待着，但过了超时时间也会关闭
-		MaxIdle: 256,
-		// 最大连接数，即最多的tcp连接数
-		MaxActive: 0,
-		// 空闲连接超时时间，但应该设置比redis服务器超时时间短。否则服务端超时了，客户端保持着连接也没用
-		IdleTimeout: 120 * time.Second,
-		// 超过最大连接，是报错，还是等待
-		Wait: true,
-		Dial: func() (redis.Conn, error) {
-			conn, err := redis.Dial("tcp", fmt.Sprintf("%s:%d", conf.Host, conf.Port),
-				redis.DialPassword(conf.Password),
-				redis.DialDatabase(conf.Db),
-				redis.DialConnectTimeout(1*time.Minute),
-				redis.DialReadTimeout(3*time.Second),
-				redis.DialWriteTimeout(30*time.Second),
-			)
-			if err != nil {
-				return nil, err
-			}
-			return conn, nil
-		},
-		TestOnBorrow: func(c redis.Conn, t time.Time) error {
-			if time.Since(t) < time.Minute {
-				return nil
-			}
-			_, err := c.Do("PING")
-			return err
-		},
-	}
-
-	// ping test
-	client := &redisClient{pool: p}
-	err := client.Ping()
+	pool, err := newReloadablePool(conf)
 	if err != nil {
 		return nil, err
 	}
+	return &redisClient{pool: pool}, nil
+}
+
+// WithContext 返回使用ctx执行命令的客户端, 与原客户端共享连接池
+func (r *redisClient) WithContext(ctx context.Context) provider.RedisClient {
+	return &redisClient{pool: r.pool, ctx: ctx}
+}
 
-	return client, nil
+// getConn 从连接池中获取连接, 获取失败时返回的连接所有操作都会返回错误
+func (r *redisClient) getConn() redis.Conn {
+	conn, err := r.pool.Get(r.ctx)
+	if err != nil {
+		return errorConn{err: err}
+	}
+	return conn
 }
 
 ///////////////////////////////////////////////////////////////////////
@@ -66,7 +45,7 @@ func newRedisClient(conf *redisClientConfig) (provider.RedisClient, error) {
 
 // Del 删除某个key
 func (r *redisClient) Del(key string) error {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -80,7 +59,7 @@ func (r *redisClient) Del(key string) error {
 
 // Dels 删除多个key
 func (r *redisClient) Dels(keys []string) error {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -97,7 +76,7 @@ func (r *redisClient) Dels(keys []string) error {
 
 // Exists 检查某个key是否存在
 func (r *redisClient) Exists(key string) (bool, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -106,7 +85,7 @@ func (r *redisClient) Exists(key string) (bool, error) {
 
 // Expire 使某个key过期
 func (r *redisClient) Expire(key string, expire int) error {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -117,7 +96,7 @@ func (r *redisClient) Expire(key string, expire int) error {
 
 // Ttl 获取某个key的过期时间
 func (r *redisClient) Ttl(key string) (int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -127,7 +106,7 @@ func (r *redisClient) Ttl(key string) (int64, error) {
 
 // Incr 将某个key中的值加1
 func (r *redisClient) Incr(key string) (int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -136,7 +115,7 @@ func (r *redisClient) Incr(key string) (int64, error) {
 }
 
 func (r *redisClient) IncrBy(key string, number int) (int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -145,7 +124,7 @@ func (r *redisClient) IncrBy(key string, number int) (int64, error) {
 }
 
 func (r *redisClient) DecrBy(key string, number int) (int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -155,7 +134,7 @@ func (r *redisClient) DecrBy(key string, number int) (int64, error) {
 
 // Ping 检查redis是否存活
 func (r *redisClient) Ping() error {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -165,7 +144,7 @@ func (r *redisClient) Ping() error {
 
 // Pipeline 批量提交命令
 func (r *redisClient) Pipeline(commands []*provider.RedisCommand) (map[int]any, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -200,6 +179,22 @@ func (r *redisClient) Pipeline(commands []*provider.RedisCommand) (map[int]any,
 	return results, nil
 }
 
+// Scan 按游标遍历key, 集群模式下只遍历其中一个节点
+func (r *redisClient) Scan(cursor uint64, match string, count int) (uint64, []string, error) {
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	next, items, err := parseScanReply(conn.Do("SCAN", getScanArgs(redis.Args{}, cursor, match, count)...))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	keys, err := redis.Strings(items, nil)
+	return next, keys, err
+}
+
 // Shutdown 关闭redis client
 func (r *redisClient) Shutdown() {
 	_ = r.pool.Close()
@@ -211,7 +206,7 @@ func (r *redisClient) Shutdown() {
 
 // HDel 删除某个field
 func (r *redisClient) HDel(key string, field interface{}) (int, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -221,7 +216,7 @@ func (r *redisClient) HDel(key string, field interface{}) (int, error) {
 
 // HDels 删除多个field
 func (r *redisClient) HDels(key string, fields []interface{}) (int, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -231,7 +226,7 @@ func (r *redisClient) HDels(key string, fields []interface{}) (int, error) {
 
 // HMGet 一次获取多个field的值,返回为二维[]byte
 func (r *redisClient) HMGet(key string, fields []string) ([][]byte, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -240,7 +235,7 @@ func (r *redisClient) HMGet(key string, fields []string) ([][]byte, error) {
 
 // HMSet 一次设置多个field的值
 func (r *redisClient) HMSet(key string, fieldvalues map[string]interface{}) error {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -250,7 +245,7 @@ func (r *redisClient) HMSet(key string, fieldvalues map[string]interface{}) erro
 
 // HGet 获取某个field的值
 func (r *redisClient) HGet(key string, field any) ([]byte, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -259,7 +254,7 @@ func (r *redisClient) HGet(key string, field any) ([]byte, error) {
 
 // HGetInt 获取某个field的int值
 func (r *redisClient) HGetInt(key string, field string) (int, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -268,7 +263,7 @@ func (r *redisClient) HGetInt(key string, field string) (int, error) {
 
 // HGetInt64 获取某个field的int64值
 func (r *redisClient) HGetInt64(key string, field string) (int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -277,7 +272,7 @@ func (r *redisClient) HGetInt64(key string, field string) (int64, error) {
 
 // HGetFloat64 获取某个field的float64值
 func (r *redisClient) HGetFloat64(key string, field string) (float64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -286,7 +281,7 @@ func (r *redisClient) HGetFloat64(key string, field string) (float64, error) {
 
 // HGetString 获取某个field的float64值
 func (r *redisClient) HGetString(key string, field string) (string, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -295,7 +290,7 @@ func (r *redisClient) HGetString(key string, field string) (string, error) {
 
 // HGetAll 获取所有fields的值
 func (r *redisClient) HGetAll(key string) (map[string]string, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -309,7 +304,7 @@ func (r *redisClient) HSet(key string, field interface{}, value interface{}) (in
 		return 0, err
 	}
 
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -319,7 +314,7 @@ func (r *redisClient) HSet(key string, field interface{}, value interface{}) (in
 
 // HLen 设置某个field的值
 func (r *redisClient) HLen(key string) (int, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -327,13 +322,29 @@ func (r *redisClient) HLen(key string) (int, error) {
 	return redis.Int(conn.Do("HLEN", key))
 }
 
+// HScan 按游标遍历hash中的字段
+func (r *redisClient) HScan(key string, cursor uint64, match string, count int) (uint64, map[string]string, error) {
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	next, items, err := parseScanReply(conn.Do("HSCAN", getScanArgs(redis.Args{key}, cursor, match, count)...))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	fields, err := redis.StringMap(items, nil)
+	return next, fields, err
+}
+
 // /////////////////////////////////////////////////////////////////////////
 // set
 // /////////////////////////////////////////////////////////////////////////
 
 // Get 获取某个key的值，返回为[]byte
 func (r *redisClient) Get(key string) ([]byte, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -341,7 +352,7 @@ func (r *redisClient) Get(key string) ([]byte, error) {
 }
 
 func (r *redisClient) GetInt(key string) (int, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -349,7 +360,7 @@ func (r *redisClient) GetInt(key string) (int, error) {
 }
 
 func (r *redisClient) GetInt64(key string) (int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -357,7 +368,7 @@ func (r *redisClient) GetInt64(key string) (int64, error) {
 }
 
 func (r *redisClient) GetFloat64(key string) (float64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -365,7 +376,7 @@ func (r *redisClient) GetFloat64(key string) (float64, error) {
 }
 
 func (r *redisClient) GetString(key string) (string, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -379,7 +390,7 @@ func (r *redisClient) Set(key string, value interface{}) error {
 		return err
 	}
 
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -394,7 +405,7 @@ func (r *redisClient) SetEx(key string, value interface{}, expire int) error {
 	if err != nil {
 		return err
 	}
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -408,7 +419,7 @@ func (r *redisClient) SetEx(key string, value interface{}, expire int) error {
 
 // SIsMember 检查中成员是否出现在key中
 func (r *redisClient) SIsMember(key string, member interface{}) (bool, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -417,7 +428,7 @@ func (r *redisClient) SIsMember(key string, member interface{}) (bool, error) {
 
 // SAdd 集合中添加一个成员
 func (r *redisClient) SAdd(key string, members interface{}) error {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -428,7 +439,7 @@ func (r *redisClient) SAdd(key string, members interface{}) error {
 
 // SRem 集合中删除一个成员
 func (r *redisClient) SRem(key string, members interface{}) error {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -439,7 +450,7 @@ func (r *redisClient) SRem(key string, members interface{}) error {
 
 // SInter 取不同keys中集合的交集
 func (r *redisClient) SInter(keys []string) ([]string, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -448,7 +459,7 @@ func (r *redisClient) SInter(keys []string) ([]string, error) {
 
 // SUnion 取不同keys中集合的并集
 func (r *redisClient) SUnion(keys []string) ([]string, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -457,7 +468,7 @@ func (r *redisClient) SUnion(keys []string) ([]string, error) {
 
 // SDiff 比较不同集合中的不同元素
 func (r *redisClient) SDiff(keys []string) ([]string, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -466,20 +477,36 @@ func (r *redisClient) SDiff(keys []string) ([]string, error) {
 
 // SMembers 取集合中的成员
 func (r *redisClient) SMembers(key string) ([]string, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
 	return redis.Strings(conn.Do("SMEMBERS", key))
 }
 
+// SScan 按游标遍历集合中的成员
+func (r *redisClient) SScan(key string, cursor uint64, match string, count int) (uint64, []string, error) {
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	next, items, err := parseScanReply(conn.Do("SSCAN", getScanArgs(redis.Args{key}, cursor, match, count)...))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	members, err := redis.Strings(items, nil)
+	return next, members, err
+}
+
 // /////////////////////////////////////////////////////////////////////////////////////
 // sorted set
 // /////////////////////////////////////////////////////////////////////////////////////
 
 // ZRemRangeByScore delete members by score
 func (r *redisClient) ZRemRangeByScore(key string, min, max interface{}) error {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -489,7 +516,7 @@ func (r *redisClient) ZRemRangeByScore(key string, min, max interface{}) error {
 
 // ZRangeByScore get members by score
 func (r *redisClient) ZRangeByScore(key string, min, max interface{}, withScores bool, list *protobuf.ListParam) ([]string, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -509,7 +536,7 @@ func (r *redisClient) ZRangeByScore(key string, min, max interface{}, withScores
 
 // ZRange get members
 func (r *redisClient) ZRange(key string, min, max int64) ([]string, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -518,7 +545,7 @@ func (r *redisClient) ZRange(key string, min, max int64) ([]string, error) {
 
 // ZAdd add a member
 func (r *redisClient) ZAdd(key string, score int64, member interface{}) error {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -528,7 +555,7 @@ func (r *redisClient) ZAdd(key string, score int64, member interface{}) error {
 
 // ZIncrBy add increment to member's score
 func (r *redisClient) ZIncrBy(key string, increment int64, member interface{}) error {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -538,7 +565,7 @@ func (r *redisClient) ZIncrBy(key string, increment int64, member interface{}) e
 
 // ZCard get members total
 func (r *redisClient) ZCard(key string) (int, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -547,7 +574,7 @@ func (r *redisClient) ZCard(key string) (int, error) {
 
 // ZScore get score of member
 func (r *redisClient) ZScore(key string, member interface{}) (int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -556,7 +583,7 @@ func (r *redisClient) ZScore(key string, member interface{}) (int64, error) {
 
 // ZInterstore get intersect of set
 func (r *redisClient) ZInterstore(destKey string, keys ...interface{}) (int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -565,19 +592,63 @@ func (r *redisClient) ZInterstore(destKey string, keys ...interface{}) (int64, e
 
 // ZRem delete members
 func (r *redisClient) ZRem(destKey string, members ...interface{}) (int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
 	return redis.Int64(conn.Do("ZREM", redis.Args{}.Add(destKey).AddFlat(members)...))
 }
 
+// ZAddFloat64 add a member with float score
+func (r *redisClient) ZAddFloat64(key string, score float64, member interface{}) error {
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+	_, err := conn.Do("ZADD", key, score, member)
+	return err
+}
+
+// ZScoreFloat64 get float score of member
+func (r *redisClient) ZScoreFloat64(key string, member interface{}) (float64, error) {
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+	return redis.Float64(conn.Do("ZSCORE", key, member))
+}
+
+// ZIncrByFloat64 add float increment to member's score, return the new score
+func (r *redisClient) ZIncrByFloat64(key string, increment float64, member interface{}) (float64, error) {
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+	return redis.Float64(conn.Do("ZINCRBY", key, increment, member))
+}
+
+// ZScan 按游标遍历有序集合, 返回成员和分数
+func (r *redisClient) ZScan(key string, cursor uint64, match string, count int) (uint64, map[string]float64, error) {
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	next, items, err := parseScanReply(conn.Do("ZSCAN", getScanArgs(redis.Args{key}, cursor, match, count)...))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	members, err := redis.Float64Map(items, nil)
+	return next, members, err
+}
+
 // ///////////////////////////////////////////////////////////
 // list
 // ///////////////////////////////////////////////////////////
 
 func (r *redisClient) LPush(key string, values ...any) error {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -586,7 +657,7 @@ func (r *redisClient) LPush(key string, values ...any) error {
 }
 
 func (r *redisClient) RPush(key string, values ...any) error {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -596,7 +667,7 @@ func (r *redisClient) RPush(key string, values ...any) error {
 
 // RPop 移除列表的最后一个元素，返回值为移除的元素
 func (r *redisClient) RPop(key string) ([]byte, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -604,7 +675,7 @@ func (r *redisClient) RPop(key string) ([]byte, error) {
 }
 
 func (r *redisClient) LRangeInt64(key string, start, end int64) ([]int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -612,7 +683,7 @@ func (r *redisClient) LRangeInt64(key string, start, end int64) ([]int64, error)
 }
 
 func (r *redisClient) LRangeString(key string, start, end int64) ([]string, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -620,7 +691,7 @@ func (r *redisClient) LRangeString(key string, start, end int64) ([]string, erro
 }
 
 func (r *redisClient) LLen(key string) (int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -630,7 +701,7 @@ func (r *redisClient) LLen(key string) (int64, error) {
 func (r *redisClient) Eval(scriptContent string, keys []interface{}, args []interface{}) (interface{}, error) {
 	script := redis.NewScript(len(keys), scriptContent)
 
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -656,7 +727,7 @@ func (r *redisClient) Eval(scriptContent string, keys []interface{}, args []inte
 // key - the name of the filter
 // item - the item to check for
 func (r *redisClient) BfExists(key string, item string) (exists bool, err error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -668,7 +739,7 @@ func (r *redisClient) BfExists(key string, item string) (exists bool, err error)
 // key - the name of the filter
 // item - the item to add
 func (r *redisClient) BfAdd(key string, item string) (exists bool, err error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -681,7 +752,7 @@ func (r *redisClient) BfAdd(key string, item string) (exists bool, err error) {
 // error_rate - the desired probability for false positives
 // capacity - the number of entries you intend to add to the filter
 func (r *redisClient) BfReserve(key string, errorRate float64, capacity uint64) (err error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -694,7 +765,7 @@ func (r *redisClient) BfReserve(key string, errorRate float64, capacity uint64)
 // key - the name of the filter
 // item - One or more items to add
 func (r *redisClient) BfAddMulti(key string, items []interface{}) ([]int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -708,7 +779,7 @@ func (r *redisClient) BfAddMulti(key string, items []interface{}) ([]int64, erro
 // key - the name of the filter
 // item - one or more items to check
 func (r *redisClient) BfExistsMulti(key string, items []interface{}) ([]int64, error) {
-	conn := r.pool.Get()
+	conn := r.getConn()
 	defer func(conn redis.Conn) {
 		_ = conn.Close()
 	}(conn)
@@ -716,3 +787,33 @@ func (r *redisClient) BfExistsMulti(key string, items []interface{}) ([]int64, e
 	result, err := conn.Do("BF.MEXISTS", args...)
 	return redis.Int64s(result, err)
 }
+
+// getScanArgs SCAN系列命令的参数
+func getScanArgs(args redis.Args, cursor uint64, match string, count int) redis.Args {
+	args = args.Add(cursor)
+	if match != "" {
+		args = args.Add("MATCH", match)
+	}
+	if count > 0 {
+		args = args.Add("COUNT", count)
+	}
+	return args
+}
+
+// parseScanReply 解析SCAN系列命令的返回值: [cursor, [item ...]]
+func parseScanReply(reply interface{}, err error) (uint64, interface{}, error) {
+	values, err := redis.Values(reply, err)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(values) != 2 {
+		return 0, nil, errors.New("invalid scan reply")
+	}
+
+	cursor, err := redis.Uint64(values[0], nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	return cursor, values[1], nil
+}
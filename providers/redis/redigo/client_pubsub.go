@@ -0,0 +1,110 @@
+package redigo
+
+import (
+	"context"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/hdget/sdk/common/provider"
+)
+
+const (
+	// 订阅期间定时发送PING检测连接是否可用
+	pubSubPingInterval = time.Minute
+)
+
+func (r *redisClient) Publish(channel string, message any) (int64, error) {
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+	return redis.Int64(conn.Do("PUBLISH", channel, message))
+}
+
+// Subscribe 订阅频道, 订阅期间独占一个连接, ctx取消后退订并关闭返回的channel
+func (r *redisClient) Subscribe(ctx context.Context, channels ...string) (<-chan *provider.RedisMessage, error) {
+	return r.subscribe(ctx, false, channels)
+}
+
+// PSubscribe 按模式订阅频道
+func (r *redisClient) PSubscribe(ctx context.Context, patterns ...string) (<-chan *provider.RedisMessage, error) {
+	return r.subscribe(ctx, true, patterns)
+}
+
+func (r *redisClient) subscribe(ctx context.Context, pattern bool, channels []string) (<-chan *provider.RedisMessage, error) {
+	conn, err := r.pool.GetPubSubConn()
+	if err != nil {
+		return nil, err
+	}
+
+	psc := redis.PubSubConn{Conn: conn}
+	args := redis.Args{}.AddFlat(channels)
+	if pattern {
+		err = psc.PSubscribe(args...)
+	} else {
+		err = psc.Subscribe(args...)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	messages := make(chan *provider.RedisMessage)
+	done := make(chan struct{})
+	writerDone := make(chan struct{})
+
+	// 所有写操作都在该goroutine中执行: 定时PING, ctx取消后退订
+	go func() {
+		defer close(writerDone)
+
+		ticker := time.NewTicker(pubSubPingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				if pattern {
+					_ = psc.PUnsubscribe()
+				} else {
+					_ = psc.Unsubscribe()
+				}
+				return
+			case <-ticker.C:
+				if err := psc.Ping(""); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// 退订完成或者连接出错后关闭连接和返回的channel
+	go func() {
+		defer close(messages)
+		defer func() {
+			close(done)
+			<-writerDone
+			_ = conn.Close()
+		}()
+
+		for {
+			switch v := psc.ReceiveWithTimeout(2 * pubSubPingInterval).(type) {
+			case redis.Message:
+				select {
+				case messages <- &provider.RedisMessage{Channel: v.Channel, Pattern: v.Pattern, Data: v.Data}:
+				case <-ctx.Done():
+					// 等待退订完成, 期间收到的消息直接丢弃
+				}
+			case redis.Subscription:
+				if v.Count == 0 {
+					return
+				}
+			case error:
+				return
+			}
+		}
+	}()
+
+	return messages, nil
+}
@@ -0,0 +1,173 @@
+package redigo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// XAdd 添加消息, id为空时由redis生成
+func (r *redisClient) XAdd(key string, id string, values map[string]any) (string, error) {
+	if id == "" {
+		id = "*"
+	}
+
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	args := redis.Args{key, id}
+	for _, field := range fields {
+		args = args.Add(field, values[field])
+	}
+
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+	return redis.String(conn.Do("XADD", args...))
+}
+
+func (r *redisClient) XLen(key string) (int64, error) {
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+	return redis.Int64(conn.Do("XLEN", key))
+}
+
+func (r *redisClient) XDel(key string, ids ...string) (int64, error) {
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+	return redis.Int64(conn.Do("XDEL", redis.Args{key}.AddFlat(ids)...))
+}
+
+// XGroupCreate 创建消费组, start为空时只消费创建之后的消息, 为0时从头消费
+func (r *redisClient) XGroupCreate(key, group, start string) error {
+	if start == "" {
+		start = "$"
+	}
+
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	_, err := conn.Do("XGROUP", "CREATE", key, group, start, "MKSTREAM")
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// XReadGroup 读取未投递过的消息, 阻塞时间不会超过ctx的截止时间
+func (r *redisClient) XReadGroup(key, group, consumer string, count int, block time.Duration) ([]*provider.RedisStreamMessage, error) {
+	args := redis.Args{"GROUP", group, consumer}
+	if count > 0 {
+		args = args.Add("COUNT", count)
+	}
+
+	if block > 0 {
+		if r.ctx != nil {
+			if deadline, ok := r.ctx.Deadline(); ok {
+				if remain := time.Until(deadline); remain < block {
+					block = remain
+				}
+			}
+		}
+
+		if block <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+		args = args.Add("BLOCK", max(block.Milliseconds(), 1))
+	}
+	args = args.Add("STREAMS", key, ">")
+
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	if err := conn.Err(); err != nil {
+		return nil, err
+	}
+
+	var (
+		reply interface{}
+		err   error
+	)
+	if block > 0 {
+		// 读超时需要大于阻塞时间, 否则阻塞期间没有消息时连接会超时
+		reply, err = redis.DoWithTimeout(conn, block+r.pool.getReadTimeout(), "XREADGROUP", args...)
+	} else {
+		reply, err = conn.Do("XREADGROUP", args...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 超时没有消息
+	if reply == nil {
+		return nil, nil
+	}
+	return parseStreamReply(reply)
+}
+
+func (r *redisClient) XAck(key, group string, ids ...string) (int64, error) {
+	conn := r.getConn()
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+	return redis.Int64(conn.Do("XACK", redis.Args{key, group}.AddFlat(ids)...))
+}
+
+// parseStreamReply 解析XREAD/XREADGROUP的返回值: [[key, [[id, [field, value ...]] ...]] ...]
+func parseStreamReply(reply interface{}) ([]*provider.RedisStreamMessage, error) {
+	streams, err := redis.Values(reply, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*provider.RedisStreamMessage, 0)
+	for _, stream := range streams {
+		items, err := redis.Values(stream, nil)
+		if err != nil || len(items) != 2 {
+			return nil, errors.New("invalid stream reply")
+		}
+
+		entries, err := redis.Values(items[1], nil)
+		if err != nil {
+			return nil, errors.New("invalid stream reply")
+		}
+
+		for _, entry := range entries {
+			values, err := redis.Values(entry, nil)
+			if err != nil || len(values) != 2 {
+				return nil, errors.New("invalid stream entry")
+			}
+
+			msg := &provider.RedisStreamMessage{Values: make(map[string]string)}
+			if msg.ID, err = redis.String(values[0], nil); err != nil {
+				return nil, err
+			}
+
+			// 已经被删除的消息内容为空
+			if values[1] != nil {
+				if msg.Values, err = redis.StringMap(values[1], nil); err != nil {
+					return nil, err
+				}
+			}
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
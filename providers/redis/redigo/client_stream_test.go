@@ -0,0 +1,101 @@
+package redigo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pkg/errors"
+)
+
+func newTestClient(t *testing.T, m *miniredis.Miniredis, mode string) *redisClient {
+	t.Helper()
+
+	conf := &redisClientConfig{
+		Mode:           mode,
+		Host:           m.Host(),
+		Port:           m.Server().Addr().Port,
+		Addrs:          []string{m.Addr()},
+		MaxIdle:        4,
+		IdleTimeout:    120,
+		WaitTimeout:    10,
+		ConnectTimeout: 5,
+		ReadTimeout:    3,
+		WriteTimeout:   5,
+	}
+	client, err := newRedisClient(conf)
+	if err != nil {
+		t.Fatalf("new %s client: %v", mode, err)
+	}
+	c := client.(*redisClient)
+	t.Cleanup(c.Shutdown)
+	return c
+}
+
+func TestXReadGroupBlock(t *testing.T) {
+	for _, mode := range []string{modeStandalone, modeCluster} {
+		t.Run(mode, func(t *testing.T) {
+			m := miniredis.RunT(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			client := newTestClient(t, m, mode).WithContext(ctx)
+
+			if err := client.XGroupCreate("stream", "group", ""); err != nil {
+				t.Fatalf("XGroupCreate: %v", err)
+			}
+
+			// 没有消息时阻塞到超时, 返回空结果
+			msgs, err := client.XReadGroup("stream", "group", "consumer", 10, 100*time.Millisecond)
+			if err != nil {
+				t.Fatalf("XReadGroup timeout: %v", err)
+			}
+			if len(msgs) != 0 {
+				t.Fatalf("XReadGroup timeout got %d messages", len(msgs))
+			}
+
+			// 阻塞期间添加的消息可以读取到
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				_, _ = m.XAdd("stream", "*", []string{"k", "v"})
+			}()
+			msgs, err = client.XReadGroup("stream", "group", "consumer", 10, 2*time.Second)
+			if err != nil {
+				t.Fatalf("XReadGroup: %v", err)
+			}
+			if len(msgs) != 1 || msgs[0].Values["k"] != "v" {
+				t.Fatalf("XReadGroup got %+v", msgs)
+			}
+		})
+	}
+}
+
+// 阻塞时间不超过ctx的截止时间, ctx已经结束时不再执行命令
+func TestXReadGroupContext(t *testing.T) {
+	for _, mode := range []string{modeStandalone, modeCluster} {
+		t.Run(mode, func(t *testing.T) {
+			m := miniredis.RunT(t)
+			client := newTestClient(t, m, mode)
+			if err := client.XGroupCreate("stream", "group", ""); err != nil {
+				t.Fatalf("XGroupCreate: %v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			msgs, err := client.WithContext(ctx).XReadGroup("stream", "group", "consumer", 10, 10*time.Second)
+			if err != nil || len(msgs) != 0 {
+				t.Fatalf("XReadGroup = %v, %v", msgs, err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Fatalf("XReadGroup blocked %s", elapsed)
+			}
+
+			<-ctx.Done()
+			if _, err = client.WithContext(ctx).XReadGroup("stream", "group", "consumer", 10, time.Second); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("XReadGroup err = %v, want deadline exceeded", err)
+			}
+		})
+	}
+}
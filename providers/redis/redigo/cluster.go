@@ -0,0 +1,557 @@
+package redigo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// cluster 集群模式的连接池, 按key所在的slot将命令路由到对应节点, 每个节点有独立的连接池
+type cluster struct {
+	conf    *redisClientConfig
+	options []redis.DialOption
+
+	mutex sync.RWMutex
+	seeds []string
+	slots []string             // slot => 主节点地址
+	pools map[string]*nodePool // 节点地址 => 连接池
+
+	refreshMutex sync.Mutex
+	refreshedAt  time.Time
+}
+
+// clusterConn 集群连接, 每条命令执行时才从对应节点的连接池中获取连接
+// Send缓存命令, Flush时按节点分组批量执行, Receive依次返回结果
+type clusterConn struct {
+	cluster *cluster
+	ctx     context.Context
+	pending []*clusterCommand
+	replies []*clusterCommand
+}
+
+type clusterCommand struct {
+	name  string
+	args  []interface{}
+	reply interface{}
+	err   error
+	sent  bool // 命令是否已经发往节点, 已发出的命令出现网络错误时无法确定是否执行过
+}
+
+const (
+	clusterSlots           = 16384
+	clusterMaxRedirects    = 16
+	clusterRefreshInterval = time.Second // 两次刷新slot分布的最小间隔
+	clusterRetryDelay      = 100 * time.Millisecond
+)
+
+var (
+	errTooManyRedirects = errors.New("too many redis cluster redirects")
+	errNoClusterNode    = errors.New("no available redis cluster node")
+
+	_ redis.ConnWithTimeout = (*clusterConn)(nil)
+)
+
+func newClusterPool(conf *redisClientConfig) (*cluster, error) {
+	c := &cluster{
+		conf:    conf,
+		options: append(getDialOptions(conf), redis.DialPassword(conf.Password)),
+		seeds:   append([]string(nil), conf.Addrs...),
+		slots:   make([]string, clusterSlots),
+		pools:   make(map[string]*nodePool),
+	}
+
+	if err := c.refresh(context.Background(), true); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *cluster) Get(ctx context.Context) (redis.Conn, error) {
+	return &clusterConn{cluster: c, ctx: ctx}, nil
+}
+
+// GetPubSubConn 订阅消息会广播到集群所有节点, 任意节点的连接都可以用于订阅
+func (c *cluster) GetPubSubConn() (redis.Conn, error) {
+	addr := c.getNodeAddr(-1)
+	if addr == "" {
+		return nil, errNoClusterNode
+	}
+	return c.getPool(addr).Get(nil)
+}
+
+// Close 关闭所有节点的连接池
+func (c *cluster) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, p := range c.pools {
+		_ = p.Close()
+	}
+	c.pools = make(map[string]*nodePool)
+	return nil
+}
+
+// do 在key所在的节点上执行命令, 处理MOVED/ASK重定向, timeout大于0时作为阻塞命令的读超时
+func (c *cluster) do(ctx context.Context, timeout time.Duration, cmd string, args []interface{}) (interface{}, error) {
+	slot := -1
+	if key, ok := getCommandKey(cmd, args); ok {
+		slot = getSlot(key)
+	}
+
+	addr := c.getNodeAddr(slot)
+	asking, refreshed := false, false
+	for i := 0; i < clusterMaxRedirects; i++ {
+		if addr == "" {
+			return nil, errNoClusterNode
+		}
+
+		reply, sent, err := c.doOnNode(ctx, addr, asking, timeout, cmd, args)
+		if err == nil {
+			return reply, nil
+		}
+
+		var redisErr redis.Error
+		if !errors.As(err, &redisErr) {
+			// 命令已经发出时不能确定是否执行过, 重试可能导致非幂等的命令执行两次
+			// 只有获取连接失败时才认为节点可能已经下线, 刷新slot分布后重试一次
+			if sent || refreshed || ctx != nil && ctx.Err() != nil {
+				return nil, err
+			}
+			refreshed = true
+			_ = c.refresh(ctx, false)
+			addr, asking = c.getNodeAddr(slot), false
+			continue
+		}
+
+		kind, redirectAddr := parseRedirect(redisErr)
+		switch kind {
+		case "MOVED":
+			// slot已经迁移, 更新路由并在后台刷新整个slot分布
+			c.setSlot(slot, redirectAddr)
+			go func() {
+				_ = c.refresh(context.Background(), false)
+			}()
+			addr, asking = redirectAddr, false
+		case "ASK":
+			// slot正在迁移, 仅本次命令发往目标节点
+			addr, asking = redirectAddr, true
+		case "TRYAGAIN", "CLUSTERDOWN":
+			if err = sleepContext(ctx, clusterRetryDelay); err != nil {
+				return nil, err
+			}
+			asking = false
+		default:
+			return reply, err
+		}
+	}
+
+	return nil, errTooManyRedirects
+}
+
+// doOnNode 在节点上执行命令, 同时返回命令是否已经发出
+func (c *cluster) doOnNode(ctx context.Context, addr string, asking bool, timeout time.Duration, cmd string, args []interface{}) (interface{}, bool, error) {
+	conn, err := c.getPool(addr).Get(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	if asking {
+		if _, err = conn.Do("ASKING"); err != nil {
+			return nil, false, err
+		}
+	}
+
+	var reply interface{}
+	if timeout > 0 {
+		reply, err = redis.DoWithTimeout(conn, timeout, cmd, args...)
+	} else {
+		reply, err = conn.Do(cmd, args...)
+	}
+	return reply, true, err
+}
+
+// getNodeAddr 获取slot所在的节点, slot小于0时返回任意节点
+func (c *cluster) getNodeAddr(slot int) string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if slot >= 0 && c.slots[slot] != "" {
+		return c.slots[slot]
+	}
+
+	for addr := range c.pools {
+		return addr
+	}
+
+	if len(c.seeds) > 0 {
+		return c.seeds[0]
+	}
+	return ""
+}
+
+func (c *cluster) setSlot(slot int, addr string) {
+	if slot < 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.slots[slot] = addr
+}
+
+func (c *cluster) getPool(addr string) *nodePool {
+	c.mutex.RLock()
+	p, exists := c.pools[addr]
+	c.mutex.RUnlock()
+	if exists {
+		return p
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if p, exists = c.pools[addr]; exists {
+		return p
+	}
+
+	p = newNodePool(c.conf, func(ctx context.Context) (redis.Conn, error) {
+		return redis.DialContext(ctx, "tcp", addr, c.options...)
+	})
+	c.pools[addr] = p
+	return p
+}
+
+// refresh 通过CLUSTER SLOTS获取slot分布, 依次尝试已知节点和种子节点
+func (c *cluster) refresh(ctx context.Context, force bool) error {
+	c.refreshMutex.Lock()
+	defer c.refreshMutex.Unlock()
+
+	if !force && time.Since(c.refreshedAt) < clusterRefreshInterval {
+		return nil
+	}
+
+	c.mutex.RLock()
+	addrs := append([]string(nil), c.seeds...)
+	for addr := range c.pools {
+		addrs = append(addrs, addr)
+	}
+	c.mutex.RUnlock()
+
+	lastErr := errNoClusterNode
+	for _, addr := range addrs {
+		slots, err := c.querySlots(ctx, addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		c.mutex.Lock()
+		c.slots = slots
+		c.mutex.Unlock()
+
+		c.refreshedAt = time.Now()
+		return nil
+	}
+
+	return errors.Wrap(lastErr, "refresh redis cluster slots")
+}
+
+func (c *cluster) querySlots(ctx context.Context, addr string) ([]string, error) {
+	conn, err := c.getPool(addr).Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	ranges, err := redis.Values(conn.Do("CLUSTER", "SLOTS"))
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+	slots := make([]string, clusterSlots)
+	for _, item := range ranges {
+		// [start, end, [host, port, id], replicas...]
+		values, err := redis.Values(item, nil)
+		if err != nil || len(values) < 3 {
+			return nil, errors.New("invalid cluster slots reply")
+		}
+
+		start, _ := redis.Int(values[0], nil)
+		end, _ := redis.Int(values[1], nil)
+		master, err := redis.Values(values[2], nil)
+		if err != nil || len(master) < 2 || start < 0 || end >= clusterSlots {
+			return nil, errors.New("invalid cluster slots reply")
+		}
+
+		masterHost, _ := redis.String(master[0], nil)
+		masterPort, _ := redis.Int(master[1], nil)
+		if masterHost == "" {
+			masterHost = host
+		}
+
+		masterAddr := net.JoinHostPort(masterHost, strconv.Itoa(masterPort))
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = masterAddr
+		}
+	}
+	return slots, nil
+}
+
+func (c *clusterConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	// redigo约定Do("")提交所有缓存的命令并返回最后一个结果
+	if commandName == "" {
+		if err := c.Flush(); err != nil {
+			return nil, err
+		}
+
+		var (
+			reply interface{}
+			err   error
+		)
+		for len(c.replies) > 0 {
+			reply, err = c.Receive()
+		}
+		return reply, err
+	}
+
+	return c.cluster.do(c.ctx, 0, commandName, args)
+}
+
+func (c *clusterConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	return c.cluster.do(c.ctx, timeout, commandName, args)
+}
+
+func (c *clusterConn) Send(commandName string, args ...interface{}) error {
+	c.pending = append(c.pending, &clusterCommand{name: commandName, args: args})
+	return nil
+}
+
+// Flush 按节点分组批量执行缓存的命令, 重定向的命令再单独执行
+func (c *clusterConn) Flush() error {
+	commands := c.pending
+	c.pending = nil
+
+	groups := make(map[string][]*clusterCommand)
+	for _, cmd := range commands {
+		slot := -1
+		if key, ok := getCommandKey(cmd.name, cmd.args); ok {
+			slot = getSlot(key)
+		}
+		addr := c.cluster.getNodeAddr(slot)
+		groups[addr] = append(groups[addr], cmd)
+	}
+
+	for addr, group := range groups {
+		c.pipeline(addr, group)
+	}
+
+	for _, cmd := range commands {
+		if cmd.err != nil && canRetry(cmd.err, cmd.sent) {
+			cmd.reply, cmd.err = c.cluster.do(c.ctx, 0, cmd.name, cmd.args)
+		}
+	}
+
+	c.replies = append(c.replies, commands...)
+	return nil
+}
+
+func (c *clusterConn) Receive() (interface{}, error) {
+	if len(c.replies) == 0 {
+		return nil, errors.New("no pending redis cluster reply")
+	}
+
+	cmd := c.replies[0]
+	c.replies = c.replies[1:]
+	return cmd.reply, cmd.err
+}
+
+// ReceiveWithTimeout 结果已经在Flush中读取, 不需要再等待
+func (c *clusterConn) ReceiveWithTimeout(_ time.Duration) (interface{}, error) {
+	return c.Receive()
+}
+
+func (c *clusterConn) Close() error {
+	c.pending, c.replies = nil, nil
+	return nil
+}
+
+func (c *clusterConn) Err() error {
+	return nil
+}
+
+// pipeline 在同一个节点上批量执行命令, 重定向和没有发出的命令在Flush中单独重试
+func (c *clusterConn) pipeline(addr string, commands []*clusterCommand) {
+	setErr := func(err error) {
+		for _, cmd := range commands {
+			if cmd.err == nil && cmd.reply == nil {
+				cmd.err = err
+			}
+		}
+	}
+
+	if addr == "" {
+		setErr(errNoClusterNode)
+		return
+	}
+
+	conn, err := c.cluster.getPool(addr).Get(c.ctx)
+	if err != nil {
+		setErr(err)
+		return
+	}
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	// Send可能已经将部分命令写入连接, 获取连接之后的错误都视为命令已经发出
+	for _, cmd := range commands {
+		cmd.sent = true
+	}
+
+	for _, cmd := range commands {
+		if err = conn.Send(cmd.name, cmd.args...); err != nil {
+			setErr(err)
+			return
+		}
+	}
+
+	if err = conn.Flush(); err != nil {
+		setErr(err)
+		return
+	}
+
+	for _, cmd := range commands {
+		cmd.reply, cmd.err = conn.Receive()
+	}
+}
+
+// parseRedirect 解析重定向错误, 例如: MOVED 3999 127.0.0.1:6381, ASK 3999 127.0.0.1:6381
+func parseRedirect(err redis.Error) (string, string) {
+	fields := strings.Fields(string(err))
+	if len(fields) == 0 {
+		return "", ""
+	}
+
+	switch fields[0] {
+	case "MOVED", "ASK":
+		if len(fields) == 3 {
+			return fields[0], fields[2]
+		}
+	case "TRYAGAIN", "CLUSTERDOWN":
+		return fields[0], ""
+	}
+	return "", ""
+}
+
+// canRetry 重定向的命令没有被执行, 可以重试, 网络错误只有命令还没有发出时才能重试
+func canRetry(err error, sent bool) bool {
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		kind, _ := parseRedirect(redisErr)
+		return kind != ""
+	}
+	return !sent
+}
+
+// sleepContext 等待一段时间, ctx结束时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if ctx == nil {
+		time.Sleep(d)
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getCommandKey 获取命令中用于路由的key, 多个key的命令要求所有key在同一个slot, 可以使用hash tag, 例如: {user}:1
+func getCommandKey(cmd string, args []interface{}) (string, bool) {
+	switch strings.ToUpper(cmd) {
+	case "PING", "INFO", "CLUSTER", "SCRIPT", "ASKING", "ROLE", "FLUSHALL", "FLUSHDB", "DBSIZE", "RANDOMKEY", "SCAN", "KEYS":
+		return "", false
+	case "XGROUP", "XINFO":
+		// XGROUP CREATE key group id
+		if len(args) < 2 {
+			return "", false
+		}
+		return toKey(args[1]), true
+	case "EVAL", "EVALSHA":
+		// EVAL script numkeys key [key ...] arg [arg ...]
+		if len(args) < 3 {
+			return "", false
+		}
+		if numKeys, err := strconv.Atoi(fmt.Sprint(args[1])); err != nil || numKeys == 0 {
+			return "", false
+		}
+		return toKey(args[2]), true
+	case "XREAD", "XREADGROUP":
+		// XREADGROUP GROUP group consumer ... STREAMS key [key ...] id [id ...]
+		for i, arg := range args {
+			if strings.EqualFold(toKey(arg), "STREAMS") && i+1 < len(args) {
+				return toKey(args[i+1]), true
+			}
+		}
+		return "", false
+	}
+
+	if len(args) == 0 {
+		return "", false
+	}
+	return toKey(args[0]), true
+}
+
+func toKey(arg interface{}) string {
+	switch v := arg.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return fmt.Sprint(arg)
+}
+
+// getSlot 计算key所在的slot, 存在hash tag时只计算hash tag部分
+func getSlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key) % clusterSlots)
+}
+
+// crc16 CRC16-CCITT(XMODEM)
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,169 @@
+package redigo
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/gomodule/redigo/redis"
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+func TestGetSlot(t *testing.T) {
+	tests := map[string]int{
+		"foo":            12182,
+		"123456789":      12739,
+		"{user1000}.a":   3443,
+		"{user1000}.b":   3443,
+		"{}.empty":       5271, // 空的hash tag计算整个key
+		"prefix{tag}end": 8338,
+	}
+
+	for key, want := range tests {
+		if got := getSlot(key); got != want {
+			t.Errorf("getSlot(%q) = %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestGetCommandKey(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		args []interface{}
+		want string
+		ok   bool
+	}{
+		{"GET", []interface{}{"a"}, "a", true},
+		{"PING", nil, "", false},
+		{"EVALSHA", []interface{}{"sha", 1, []byte("b"), "arg"}, "b", true},
+		{"EVAL", []interface{}{"script", 0, "arg"}, "", false},
+		{"XREADGROUP", []interface{}{"GROUP", "g", "c", "STREAMS", "s", ">"}, "s", true},
+		{"XGROUP", []interface{}{"CREATE", "s", "g", "$", "MKSTREAM"}, "s", true},
+		{"SCAN", []interface{}{0, "MATCH", "a*"}, "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := getCommandKey(tt.cmd, tt.args)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("getCommandKey(%s) = %s, %v, want %s, %v", tt.cmd, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestClusterRedirect(t *testing.T) {
+	m := miniredis.RunT(t)
+	client := newTestClient(t, m, modeCluster)
+	m.Set("a", "1")
+
+	var moved, ask, asking atomic.Int32
+	m.Server().SetPreHook(func(c *server.Peer, cmd string, args ...string) bool {
+		switch {
+		case cmd == "ASKING":
+			asking.Add(1)
+			c.WriteOK()
+			return true
+		case cmd == "GET" && moved.Add(1) == 1:
+			c.WriteError("MOVED 15495 " + m.Addr())
+			return true
+		case cmd == "GET" && ask.Add(1) == 1:
+			c.WriteError("ASK 15495 " + m.Addr())
+			return true
+		}
+		return false
+	})
+
+	value, err := redis.String(client.WithContext(context.Background()).Get("a"))
+	if err != nil || value != "1" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+	if asking.Load() != 1 {
+		t.Fatalf("ASKING sent %d times, want 1", asking.Load())
+	}
+}
+
+// 命令发出后连接断开时不能确定命令是否执行过, 不能重试
+func TestClusterNoRetryAfterSent(t *testing.T) {
+	m := miniredis.RunT(t)
+	client := newTestClient(t, m, modeCluster)
+
+	m.Server().SetPreHook(func(c *server.Peer, cmd string, args ...string) bool {
+		if cmd == "INCR" {
+			_, _ = m.Incr(args[0], 1)
+			c.Close()
+			return true
+		}
+		return false
+	})
+
+	if _, err := client.Incr("do"); err == nil {
+		t.Fatal("Incr expect error")
+	}
+	if got, _ := m.Get("do"); got != "1" {
+		t.Fatalf("Incr executed %s times, want 1", got)
+	}
+
+	_, err := client.Pipeline([]*provider.RedisCommand{{Name: "INCR", Args: []any{"pipeline"}}})
+	if err == nil {
+		t.Fatal("Pipeline expect error")
+	}
+	if got, _ := m.Get("pipeline"); got != "1" {
+		t.Fatalf("Pipeline executed %s times, want 1", got)
+	}
+}
+
+// 节点连接失败时命令还没有发出, 刷新slot分布后重试
+func TestClusterRetryDialError(t *testing.T) {
+	m := miniredis.RunT(t)
+	client := newTestClient(t, m, modeCluster)
+	m.Set("a", "1")
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := l.Addr().String()
+	_ = l.Close()
+
+	c := client.pool.getPool().(*cluster)
+	c.setSlot(getSlot("a"), deadAddr)
+	c.refreshedAt = time.Time{}
+
+	value, err := redis.String(client.Get("a"))
+	if err != nil || value != "1" {
+		t.Fatalf("Get = %q, %v", value, err)
+	}
+	if addr := c.getNodeAddr(getSlot("a")); addr != m.Addr() {
+		t.Fatalf("slot addr = %s, want %s", addr, m.Addr())
+	}
+}
+
+// TRYAGAIN的等待在ctx结束时提前返回
+func TestClusterTryAgainContext(t *testing.T) {
+	m := miniredis.RunT(t)
+	client := newTestClient(t, m, modeCluster)
+
+	m.Server().SetPreHook(func(c *server.Peer, cmd string, args ...string) bool {
+		if cmd == "GET" {
+			c.WriteError("TRYAGAIN Multiple keys request during rehashing of slot")
+			return true
+		}
+		return false
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.WithContext(ctx).Get("a")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get err = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Get returned after %s", elapsed)
+	}
+}
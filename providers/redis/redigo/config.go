@@ -1,6 +1,7 @@
 package redigo
 
 import (
+	"github.com/hdget/sdk/common/config"
 	"github.com/hdget/sdk/common/provider"
 	"github.com/pkg/errors"
 )
@@ -12,10 +13,25 @@ type redisProviderConfig struct {
 
 type redisClientConfig struct {
 	Name     string `mapstructure:"name"`
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
+	Mode     string `mapstructure:"mode" default:"standalone" validate:"oneof=standalone sentinel cluster"`
+	Host     string `mapstructure:"host"` // 单机模式的redis地址
+	Port     int    `mapstructure:"port" default:"6379"`
 	Db       int    `mapstructure:"db"`
 	Password string `mapstructure:"password"`
+
+	// 哨兵模式为哨兵地址, 集群模式为种子节点地址, 格式: host:port
+	Addrs            []string `mapstructure:"addrs"`
+	MasterName       string   `mapstructure:"master_name"`       // 哨兵模式的主节点名字
+	SentinelPassword string   `mapstructure:"sentinel_password"` // 哨兵的连接密码
+
+	// 连接池设置, 时间单位: 秒
+	MaxIdle        int `mapstructure:"max_idle" default:"256"`       // 最大空闲连接数
+	MaxActive      int `mapstructure:"max_active"`                   // 最大连接数, 0表示不限制
+	IdleTimeout    int `mapstructure:"idle_timeout" default:"120"`   // 空闲连接超时时间
+	WaitTimeout    int `mapstructure:"wait_timeout" default:"10"`    // 连接池满时等待可用连接的最长时间
+	ConnectTimeout int `mapstructure:"connect_timeout" default:"60"` // 建立连接超时时间
+	ReadTimeout    int `mapstructure:"read_timeout" default:"3"`     // 读超时时间
+	WriteTimeout   int `mapstructure:"write_timeout" default:"30"`   // 写超时时间
 }
 
 const (
@@ -24,7 +40,10 @@ const (
 
 var (
 	errInvalidConfig = errors.New("invalid redis config")
-	errEmptyConfig   = errors.New("empty redis config")
+	configSchema     = &provider.ConfigSchema{
+		Section: configSection,
+		New:     func() any { return &redisProviderConfig{} },
+	}
 )
 
 func newConfig(configProvider provider.Config) (*redisProviderConfig, error) {
@@ -32,64 +51,43 @@ func newConfig(configProvider provider.Config) (*redisProviderConfig, error) {
 		return nil, errInvalidConfig
 	}
 
-	var c *redisProviderConfig
-	err := configProvider.Unmarshal(&c, configSection)
-	if err != nil {
-		return nil, err
-	}
-
-	if c == nil {
-		return nil, errEmptyConfig
-	}
-
-	err = c.validate()
+	c := configSchema.New().(*redisProviderConfig)
+	err := config.Parse(configProvider, configSection, c)
 	if err != nil {
-		return nil, errors.Wrap(err, "validate redis provider config")
+		return nil, errors.Wrap(err, "parse redis provider config")
 	}
 
 	return c, nil
 }
 
-func (c *redisProviderConfig) validate() error {
-	if c.Default != nil {
-		err := c.validateInstanceConfig(c.Default)
-		if err != nil {
-			return err
-		}
-	}
-
+// Validate 额外的redis实例必须指定名字
+func (c *redisProviderConfig) Validate() error {
 	for _, item := range c.Items {
-		err := c.validateExtraInstanceConfig(item)
-		if err != nil {
-			return err
+		if item != nil && item.Name == "" {
+			return errors.New("redis extra instance name is required")
 		}
 	}
-
 	return nil
 }
 
-func (c *redisProviderConfig) validateInstanceConfig(conf *redisClientConfig) error {
-	if conf.Host == "" {
-		return errEmptyConfig
-	}
-
-	// setup default config value
-	if conf.Port == 0 {
-		conf.Port = 6379
-	}
-
-	return nil
-}
-
-func (c *redisProviderConfig) validateExtraInstanceConfig(conf *redisClientConfig) error {
-	if conf.Name == "" || conf.Host == "" {
-		return errInvalidConfig
-	}
-
-	// setup default config value
-	if conf.Port == 0 {
-		conf.Port = 6379
+// Validate 不同的部署模式需要不同的连接配置
+func (c *redisClientConfig) Validate() error {
+	switch c.Mode {
+	case modeSentinel:
+		if len(c.Addrs) == 0 || c.MasterName == "" {
+			return errors.New("sentinel addrs and master_name are required")
+		}
+	case modeCluster:
+		if len(c.Addrs) == 0 {
+			return errors.New("cluster addrs are required")
+		}
+		if c.Db != 0 {
+			return errors.New("cluster only supports db 0")
+		}
+	default:
+		if c.Host == "" {
+			return errors.New("host is required")
+		}
 	}
-
 	return nil
 }
@@ -3,6 +3,7 @@ module github.com/hdget/sdk/providers/redis/redigo
 go 1.24.0
 
 require (
+	github.com/alicebob/miniredis/v2 v2.35.0
 	github.com/gomodule/redigo v1.9.2
 	github.com/hdget/sdk/common v0.1.21
 	github.com/hdget/utils v0.2.3
@@ -12,6 +13,7 @@ require (
 )
 
 require (
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 	go.uber.org/dig v1.19.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.27.1 // indirect
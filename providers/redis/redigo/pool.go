@@ -0,0 +1,225 @@
+package redigo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// connPool 连接池, 不同的部署模式有不同的实现
+type connPool interface {
+	// Get 获取连接, ctx不为空时使用ctx执行命令
+	Get(ctx context.Context) (redis.Conn, error)
+	// GetPubSubConn 获取用于订阅的连接, 订阅期间独占该连接
+	GetPubSubConn() (redis.Conn, error)
+	Close() error
+}
+
+// nodePool 单个redis节点的连接池
+type nodePool struct {
+	*redis.Pool
+	waitTimeout time.Duration // 连接池满时等待可用连接的最长时间
+}
+
+// reloadablePool 配置变化时替换为新的连接池, WithContext返回的客户端共享同一个reloadablePool
+type reloadablePool struct {
+	mutex       sync.RWMutex
+	pool        connPool
+	readTimeout time.Duration // 阻塞命令的读超时为阻塞时间加上该值
+}
+
+// ctxConn 使用ctx执行命令的连接
+type ctxConn struct {
+	redis.Conn
+	ctx context.Context
+}
+
+// errorConn 获取连接失败时返回的连接, 所有操作都返回获取连接时的错误
+type errorConn struct {
+	err error
+}
+
+var (
+	_ redis.ConnWithTimeout = (*ctxConn)(nil)
+)
+
+const (
+	modeStandalone = "standalone"
+	modeSentinel   = "sentinel"
+	modeCluster    = "cluster"
+)
+
+func newReloadablePool(conf *redisClientConfig) (*reloadablePool, error) {
+	pool, err := newConnPool(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &reloadablePool{pool: pool, readTimeout: time.Duration(conf.ReadTimeout) * time.Second}, nil
+}
+
+// newConnPool 按部署模式建立连接池, 建立后ping检查是否可用
+func newConnPool(conf *redisClientConfig) (connPool, error) {
+	var (
+		pool connPool
+		err  error
+	)
+	switch conf.Mode {
+	case modeSentinel:
+		pool = newSentinelPool(conf)
+	case modeCluster:
+		pool, err = newClusterPool(conf)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		pool = newStandalonePool(conf)
+	}
+
+	conn, err := pool.Get(nil)
+	if err == nil {
+		_, err = conn.Do("PING")
+		_ = conn.Close()
+	}
+	if err != nil {
+		_ = pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+func newNodePool(conf *redisClientConfig, dial func(ctx context.Context) (redis.Conn, error)) *nodePool {
+	return &nodePool{
+		Pool: &redis.Pool{
+			// 最大空闲连接数，有这么多个连接提前等待着，但过了超时时间也会关闭
+			MaxIdle: conf.MaxIdle,
+			// 最大连接数，即最多的tcp连接数, 0表示不限制
+			MaxActive: conf.MaxActive,
+			// 空闲连接超时时间，但应该设置比redis服务器超时时间短。否则服务端超时了，客户端保持着连接也没用
+			IdleTimeout: time.Duration(conf.IdleTimeout) * time.Second,
+			// 超过最大连接，是报错，还是等待
+			Wait:        true,
+			DialContext: dial,
+			TestOnBorrow: func(c redis.Conn, t time.Time) error {
+				if time.Since(t) < time.Minute {
+					return nil
+				}
+				_, err := c.Do("PING")
+				return err
+			},
+		},
+		waitTimeout: time.Duration(conf.WaitTimeout) * time.Second,
+	}
+}
+
+// newStandalonePool 单机模式, 直接连接host:port
+func newStandalonePool(conf *redisClientConfig) *nodePool {
+	address := fmt.Sprintf("%s:%d", conf.Host, conf.Port)
+	options := append(getDialOptions(conf), redis.DialPassword(conf.Password), redis.DialDatabase(conf.Db))
+	return newNodePool(conf, func(ctx context.Context) (redis.Conn, error) {
+		return redis.DialContext(ctx, "tcp", address, options...)
+	})
+}
+
+// Get 从连接池中获取连接, 连接池满时最多等待waitTimeout
+func (p *nodePool) Get(ctx context.Context) (redis.Conn, error) {
+	getCtx := ctx
+	if getCtx == nil {
+		getCtx = context.Background()
+	}
+
+	if _, hasDeadline := getCtx.Deadline(); !hasDeadline && p.waitTimeout > 0 {
+		var cancel context.CancelFunc
+		getCtx, cancel = context.WithTimeout(getCtx, p.waitTimeout)
+		defer cancel()
+	}
+
+	conn, err := p.GetContext(getCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	if ctx == nil {
+		return conn, nil
+	}
+	return &ctxConn{Conn: conn, ctx: ctx}, nil
+}
+
+func (p *nodePool) GetPubSubConn() (redis.Conn, error) {
+	return p.Get(nil)
+}
+
+func (p *reloadablePool) Get(ctx context.Context) (redis.Conn, error) {
+	return p.getPool().Get(ctx)
+}
+
+func (p *reloadablePool) GetPubSubConn() (redis.Conn, error) {
+	return p.getPool().GetPubSubConn()
+}
+
+func (p *reloadablePool) Close() error {
+	return p.getPool().Close()
+}
+
+// reload 使用新的配置建立连接池并替换原有的连接池, 原有连接池中已借出的连接归还时关闭, 建立失败时保留原有连接池
+func (p *reloadablePool) reload(conf *redisClientConfig) error {
+	pool, err := newConnPool(conf)
+	if err != nil {
+		return err
+	}
+
+	p.mutex.Lock()
+	old := p.pool
+	p.pool, p.readTimeout = pool, time.Duration(conf.ReadTimeout)*time.Second
+	p.mutex.Unlock()
+
+	return old.Close()
+}
+
+func (p *reloadablePool) getPool() connPool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.pool
+}
+
+func (p *reloadablePool) getReadTimeout() time.Duration {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.readTimeout
+}
+
+// getDialOptions 连接的超时设置
+func getDialOptions(conf *redisClientConfig) []redis.DialOption {
+	return []redis.DialOption{
+		redis.DialConnectTimeout(time.Duration(conf.ConnectTimeout) * time.Second),
+		redis.DialReadTimeout(time.Duration(conf.ReadTimeout) * time.Second),
+		redis.DialWriteTimeout(time.Duration(conf.WriteTimeout) * time.Second),
+	}
+}
+
+func (c *ctxConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return redis.DoContext(c.Conn, c.ctx, commandName, args...)
+}
+
+// DoWithTimeout 执行阻塞命令, 调用方需要保证阻塞时间不超过ctx的截止时间
+func (c *ctxConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	return redis.DoWithTimeout(c.Conn, timeout, commandName, args...)
+}
+
+func (c *ctxConn) Receive() (interface{}, error) {
+	return redis.ReceiveContext(c.Conn, c.ctx)
+}
+
+// ReceiveWithTimeout 接收阻塞命令的结果, 和DoWithTimeout一起实现redis.ConnWithTimeout
+func (c *ctxConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
+}
+
+func (c errorConn) Close() error                                   { return nil }
+func (c errorConn) Err() error                                     { return c.err }
+func (c errorConn) Do(string, ...interface{}) (interface{}, error) { return nil, c.err }
+func (c errorConn) Send(string, ...interface{}) error              { return c.err }
+func (c errorConn) Flush() error                                   { return c.err }
+func (c errorConn) Receive() (interface{}, error)                  { return nil, c.err }
@@ -1,12 +1,15 @@
 package redigo
 
 import (
+	"reflect"
+
 	"github.com/hdget/sdk/common/provider"
 )
 
 type redigoProvider struct {
 	defaultClient provider.RedisClient            // 缺省redis
 	extraClients  map[string]provider.RedisClient // 额外的redis
+	config        *redisProviderConfig            // 当前使用的配置, 用于判断配置变化的客户端
 }
 
 func New(configProvider provider.Config, logger provider.Logger) (provider.Redis, error) {
@@ -17,6 +20,7 @@ func New(configProvider provider.Config, logger provider.Logger) (provider.Redis
 
 	p := &redigoProvider{
 		extraClients: make(map[string]provider.RedisClient),
+		config:       config,
 	}
 
 	if config.Default != nil {
@@ -24,7 +28,7 @@ func New(configProvider provider.Config, logger provider.Logger) (provider.Redis
 		if err != nil {
 			logger.Fatal("init redis default client", "err", err)
 		}
-		logger.Debug("init redis default client", "host", config.Default.Host)
+		logger.Debug("init redis default client", "mode", config.Default.Mode, "host", config.Default.Host)
 	}
 
 	for _, itemConf := range config.Items {
@@ -32,9 +36,14 @@ func New(configProvider provider.Config, logger provider.Logger) (provider.Redis
 		if err != nil {
 			logger.Fatal("new redis extra client", "name", itemConf.Name, "err", err)
 		}
-		logger.Debug("init redis extra client", "name", itemConf.Name, "host", itemConf.Host)
+		logger.Debug("init redis extra client", "name", itemConf.Name, "mode", itemConf.Mode, "host", itemConf.Host)
 	}
 
+	// 客户端的连接配置修改后重建连接池, 配置不支持监听时忽略
+	_ = configProvider.Watch(configSection, func(_, _ any) {
+		p.reload(configProvider, logger)
+	})
+
 	return p, nil
 }
 
@@ -49,3 +58,50 @@ func (p *redigoProvider) My() provider.RedisClient {
 func (p *redigoProvider) By(name string) provider.RedisClient {
 	return p.extraClients[name]
 }
+
+// Close 关闭所有redis连接池
+func (p *redigoProvider) Close() error {
+	clients := []provider.RedisClient{p.defaultClient}
+	for _, client := range p.extraClients {
+		clients = append(clients, client)
+	}
+
+	for _, client := range clients {
+		if c, ok := client.(*redisClient); ok && c != nil {
+			c.Shutdown()
+		}
+	}
+	return nil
+}
+
+// reload 重新读取配置, 配置发生变化的客户端重建连接池, 新增或删除的客户端需要重启后生效
+func (p *redigoProvider) reload(configProvider provider.Config, logger provider.Logger) {
+	config, err := newConfig(configProvider)
+	if err != nil {
+		logger.Error("reload redis config", "err", err)
+		return
+	}
+
+	oldConfigs := map[string]*redisClientConfig{"": p.config.Default}
+	for _, itemConf := range p.config.Items {
+		oldConfigs[itemConf.Name] = itemConf
+	}
+
+	reloadClient := func(name string, client provider.RedisClient, conf *redisClientConfig) {
+		c, ok := client.(*redisClient)
+		if !ok || c == nil || conf == nil || reflect.DeepEqual(oldConfigs[name], conf) {
+			return
+		}
+		if err := c.pool.reload(conf); err != nil {
+			logger.Error("reload redis client", "name", name, "err", err)
+			return
+		}
+		logger.Info("reload redis client", "name", name, "mode", conf.Mode, "host", conf.Host)
+	}
+
+	reloadClient("", p.defaultClient, config.Default)
+	for _, itemConf := range config.Items {
+		reloadClient(itemConf.Name, p.extraClients[itemConf.Name], itemConf)
+	}
+	p.config = config
+}
@@ -0,0 +1,95 @@
+package redigo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hdget/sdk/common/provider"
+)
+
+// testConfig sdk.redis的配置, Watch保存回调, 通过change修改配置并触发回调
+type testConfig struct {
+	provider.Config
+	values map[string]any
+	watch  func(oldValue, newValue any)
+}
+
+type testLogger struct {
+	provider.Logger
+	t *testing.T
+}
+
+func (c *testConfig) Get(key string) any {
+	if key == configSection {
+		return c.values
+	}
+	return nil
+}
+
+// Unmarshal 字段名大小写不敏感, 可以直接通过json转换
+func (c *testConfig) Unmarshal(configVar any, _ ...string) error {
+	data, err := json.Marshal(c.values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, configVar)
+}
+
+func (c *testConfig) Watch(_ string, fn func(oldValue, newValue any)) error {
+	c.watch = fn
+	return nil
+}
+
+func (c *testConfig) change(values map[string]any) {
+	old := c.values
+	c.values = values
+	c.watch(old, values)
+}
+
+func (l testLogger) Debug(string, ...any) {}
+
+func (l testLogger) Info(string, ...any) {}
+
+func (l testLogger) Error(msg string, kvs ...any) {
+	l.t.Error(append([]any{msg}, kvs...)...)
+}
+
+func (l testLogger) Fatal(msg string, kvs ...any) {
+	l.t.Fatal(append([]any{msg}, kvs...)...)
+}
+
+// 配置修改后重建连接池, 之前获取的客户端也使用新的连接池
+func TestReload(t *testing.T) {
+	m1, m2 := miniredis.RunT(t), miniredis.RunT(t)
+	conf := &testConfig{values: map[string]any{
+		"default": map[string]any{"host": m1.Host(), "port": m1.Server().Addr().Port},
+	}}
+
+	p, err := New(conf, testLogger{t: t})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = p.(*redigoProvider).Close()
+	}()
+
+	client := p.My()
+	if err = client.Set("k", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	conf.change(map[string]any{
+		"default": map[string]any{"host": m2.Host(), "port": m2.Server().Addr().Port},
+	})
+	if err = client.Set("k", "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := m1.Get("k"); got != "1" {
+		t.Fatalf("old redis k = %q, want 1", got)
+	}
+	if got, _ := m2.Get("k"); got != "2" {
+		t.Fatalf("new redis k = %q, want 2", got)
+	}
+}
@@ -0,0 +1,138 @@
+package redigo
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/pkg/errors"
+)
+
+// sentinel 通过哨兵发现主节点
+type sentinel struct {
+	mutex      sync.Mutex
+	addrs      []string // 哨兵地址, 可用的哨兵会被移到最前面
+	masterName string
+	options    []redis.DialOption
+}
+
+const (
+	// 空闲超过该时间的连接在使用前需要确认仍然连接的是主节点
+	roleCheckInterval = time.Second
+)
+
+var (
+	errNotMaster = errors.New("redis node is not master")
+)
+
+// newSentinelPool 哨兵模式, 每次建立连接时通过哨兵查询主节点地址
+// 主从切换后, 原主节点上的连接会在借出时的角色检查中被关闭, 新连接会连接到新的主节点
+func newSentinelPool(conf *redisClientConfig) *nodePool {
+	s := &sentinel{
+		addrs:      append([]string(nil), conf.Addrs...),
+		masterName: conf.MasterName,
+		options:    append(getDialOptions(conf), redis.DialPassword(conf.SentinelPassword)),
+	}
+
+	options := append(getDialOptions(conf), redis.DialPassword(conf.Password), redis.DialDatabase(conf.Db))
+	p := newNodePool(conf, func(ctx context.Context) (redis.Conn, error) {
+		addr, err := s.discoverMaster(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := redis.DialContext(ctx, "tcp", addr, options...)
+		if err != nil {
+			return nil, err
+		}
+
+		// 哨兵还没有完成切换时查询到的可能是旧的主节点
+		if err = checkMasterRole(conn); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	})
+
+	p.TestOnBorrow = func(c redis.Conn, t time.Time) error {
+		if time.Since(t) < roleCheckInterval {
+			return nil
+		}
+		return checkMasterRole(c)
+	}
+
+	return p
+}
+
+// discoverMaster 依次询问哨兵获取主节点地址
+func (s *sentinel) discoverMaster(ctx context.Context) (string, error) {
+	s.mutex.Lock()
+	addrs := append([]string(nil), s.addrs...)
+	s.mutex.Unlock()
+
+	var lastErr error
+	for _, addr := range addrs {
+		masterAddr, err := s.queryMaster(ctx, addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		s.promote(addr)
+		return masterAddr, nil
+	}
+
+	return "", errors.Wrapf(lastErr, "discover redis master from sentinels, master: %s", s.masterName)
+}
+
+func (s *sentinel) queryMaster(ctx context.Context, addr string) (string, error) {
+	conn, err := redis.DialContext(ctx, "tcp", addr, s.options...)
+	if err != nil {
+		return "", err
+	}
+	defer func(conn redis.Conn) {
+		_ = conn.Close()
+	}(conn)
+
+	reply, err := redis.Strings(redis.DoContext(conn, ctx, "SENTINEL", "get-master-addr-by-name", s.masterName))
+	if err != nil {
+		return "", err
+	}
+
+	if len(reply) != 2 {
+		return "", errors.New("invalid sentinel reply")
+	}
+	return net.JoinHostPort(reply[0], reply[1]), nil
+}
+
+// promote 将可用的哨兵移到最前面, 下次优先询问
+func (s *sentinel) promote(addr string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i, item := range s.addrs {
+		if item == addr {
+			copy(s.addrs[1:i+1], s.addrs[:i])
+			s.addrs[0] = addr
+			return
+		}
+	}
+}
+
+func checkMasterRole(c redis.Conn) error {
+	reply, err := redis.Values(c.Do("ROLE"))
+	if err != nil {
+		return err
+	}
+
+	if len(reply) == 0 {
+		return errNotMaster
+	}
+
+	if role, _ := redis.String(reply[0], nil); role != "master" {
+		return errNotMaster
+	}
+	return nil
+}
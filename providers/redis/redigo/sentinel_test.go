@@ -0,0 +1,125 @@
+package redigo
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/pkg/errors"
+)
+
+// newTestMaster 支持ROLE命令的节点, master为false时返回slave
+func newTestMaster(t *testing.T, master *atomic.Bool) *miniredis.Miniredis {
+	t.Helper()
+
+	m := miniredis.RunT(t)
+	err := m.Server().Register("ROLE", func(c *server.Peer, cmd string, args []string) {
+		role := "slave"
+		if master.Load() {
+			role = "master"
+		}
+		c.WriteLen(1)
+		c.WriteBulk(role)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// newTestSentinel 返回masterAddr中地址的哨兵
+func newTestSentinel(t *testing.T, masterAddr *atomic.Value) *miniredis.Miniredis {
+	t.Helper()
+
+	s := miniredis.RunT(t)
+	err := s.Server().Register("SENTINEL", func(c *server.Peer, cmd string, args []string) {
+		host, port, _ := net.SplitHostPort(masterAddr.Load().(string))
+		c.WriteStrings([]string{host, port})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSentinelFailover(t *testing.T) {
+	var isMaster1, isMaster2 atomic.Bool
+	isMaster1.Store(true)
+	m1 := newTestMaster(t, &isMaster1)
+	m2 := newTestMaster(t, &isMaster2)
+
+	var masterAddr atomic.Value
+	masterAddr.Store(m1.Addr())
+	s := newTestSentinel(t, &masterAddr)
+
+	// 第一个哨兵不可用时询问下一个
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := l.Addr().String()
+	_ = l.Close()
+
+	conf := &redisClientConfig{
+		Mode:           modeSentinel,
+		Addrs:          []string{deadAddr, s.Addr()},
+		MasterName:     "mymaster",
+		MaxIdle:        4,
+		IdleTimeout:    120,
+		WaitTimeout:    10,
+		ConnectTimeout: 5,
+		ReadTimeout:    3,
+		WriteTimeout:   5,
+	}
+	c, err := newRedisClient(conf)
+	if err != nil {
+		t.Fatalf("new sentinel client: %v", err)
+	}
+	client := c.(*redisClient)
+	defer client.Shutdown()
+
+	if err = client.Set("k", "1"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got, _ := m1.Get("k"); got != "1" {
+		t.Fatalf("master1 k = %q, want 1", got)
+	}
+
+	// 主从切换, 原主节点上空闲的连接在角色检查时被关闭
+	isMaster1.Store(false)
+	isMaster2.Store(true)
+	masterAddr.Store(m2.Addr())
+	time.Sleep(roleCheckInterval + 100*time.Millisecond)
+
+	if err = client.Set("k", "2"); err != nil {
+		t.Fatalf("Set after failover: %v", err)
+	}
+	if got, _ := m2.Get("k"); got != "2" {
+		t.Fatalf("master2 k = %q, want 2", got)
+	}
+	if got, _ := m1.Get("k"); got != "1" {
+		t.Fatalf("master1 k = %q, want 1", got)
+	}
+}
+
+// 哨兵返回的节点还不是主节点时建立连接失败
+func TestSentinelNotMaster(t *testing.T) {
+	var isMaster atomic.Bool
+	m := newTestMaster(t, &isMaster)
+
+	var masterAddr atomic.Value
+	masterAddr.Store(m.Addr())
+	s := newTestSentinel(t, &masterAddr)
+
+	p := newSentinelPool(&redisClientConfig{Addrs: []string{s.Addr()}, MasterName: "mymaster", ConnectTimeout: 5, ReadTimeout: 3, WriteTimeout: 5})
+	defer func() {
+		_ = p.Close()
+	}()
+
+	if _, err := p.Get(nil); !errors.Is(err, errNotMaster) {
+		t.Fatalf("Get err = %v, want %v", err, errNotMaster)
+	}
+}
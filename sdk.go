@@ -2,7 +2,13 @@ package sdk
 
 import (
 	"context"
+	"io"
+	"os"
+	"os/signal"
+	"reflect"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/hdget/sdk/common/provider"
 	"github.com/hdget/sdk/providers/config/koanf"
@@ -13,40 +19,55 @@ import (
 )
 
 type Instance struct {
-	configProvider provider.Config
-	loggerProvider provider.Logger
-	dbProvider     provider.Database
-	redisProvider  provider.Redis
-	mqProvider     provider.MessageQueue
-	app            string
-	debug          bool
-	configVar      any            // 配置变量
-	configOptions  []koanf.Option // 配置选项
+	providers       map[reflect.Type]any // 已初始化的provider, 以能力类别绑定的接口类型为键
+	providerTypes   []reflect.Type       // provider的初始化顺序
+	providerMutex   sync.RWMutex
+	app             string
+	debug           bool
+	configVar       any                   // 配置变量
+	configOptions   []koanf.Option        // 配置选项
+	fxApp           *fx.App               // fx应用, 用于管理各provider的生命周期
+	shutdownTimeout time.Duration         // 优雅退出的最长等待时间
+	capabilities    []provider.Capability // 已初始化的能力
 }
 
 var (
 	_instance                *Instance
 	once                     sync.Once
 	errUnsupportedCapability = errors.New("unsupported capability")
+	errNotInitialized        = errors.New("sdk instance not initialized")
 )
 
+const (
+	defaultShutdownTimeout = 15 * time.Second // 缺省的优雅退出等待时间
+)
+
+// New 创建全局的sdk实例, 多次调用只会返回第一次创建的实例
 func New(app string, options ...Option) *Instance {
 	once.Do(
 		func() {
-			_instance = &Instance{
-				app:           app,
-				configOptions: make([]koanf.Option, 0),
-			}
-
-			for _, apply := range options {
-				apply(_instance)
-			}
-
+			_instance = NewInstance(app, options...)
 		},
 	)
 	return _instance
 }
 
+// NewInstance 创建一个独立的sdk实例, 不影响全局实例, 可以在同一进程中创建多个不同配置的实例
+func NewInstance(app string, options ...Option) *Instance {
+	instance := &Instance{
+		app:             app,
+		providers:       make(map[reflect.Type]any),
+		configOptions:   make([]koanf.Option, 0),
+		shutdownTimeout: defaultShutdownTimeout,
+	}
+
+	for _, apply := range options {
+		apply(instance)
+	}
+
+	return instance
+}
+
 func HasInitialized() bool {
 	return _instance != nil
 }
@@ -56,7 +77,7 @@ func GetInstance() *Instance {
 }
 
 // UseConfig 加载配置信息到给定的配置变量中。
-// 该方法使用配置提供者（configProvider）将配置数据解析到配置变量中。
+// 该方法使用配置提供者（provider.Config）将配置数据解析到配置变量中。
 // 参数:
 //
 //	configVar - 一个配置变量的指针，用于接收解析后的配置数据。
@@ -69,42 +90,69 @@ func (i *Instance) UseConfig(configVar any) *Instance {
 // This function configures the SDK instance using dependency injection with fx.Options,
 // based on the provided capabilities, such as database, logging, and configuration providers.
 func (i *Instance) Initialize(capabilities ...provider.Capability) error {
-	// Prepare fxOptions for DI configuration
-	fxOptions := []fx.Option{
-		// Initialize configProvider
-		fx.Provide(func() (provider.Config, error) {
-			return koanf.New(i.app, i.configOptions...)
-		}),
-		fx.Populate(&_instance.configProvider),
-		// Initialize loggerProvider
-		zerolog.Capability.Module,
-		fx.Populate(&_instance.loggerProvider),
-	}
-
-	// Iterate through the provided capabilities and configure the corresponding providers
+	// config和logger能力可以被替换, 没有指定时使用koanf和zerolog
+	var configs, loggers, others []provider.Capability
 	for _, c := range capabilities {
 		switch c.Category {
-		case provider.CategoryDb:
-			fxOptions = append(fxOptions, c.Module, fx.Populate(&_instance.dbProvider))
-		case provider.CategoryRedis:
-			fxOptions = append(fxOptions, c.Module, fx.Populate(&_instance.redisProvider))
-		case provider.CategoryMq:
-			fxOptions = append(fxOptions, c.Module, fx.Populate(&_instance.mqProvider))
+		case provider.CategoryConfig:
+			configs = append(configs, c)
+		case provider.CategoryLogger:
+			loggers = append(loggers, c)
 		default:
+			others = append(others, c)
+		}
+	}
+
+	if len(configs) == 0 {
+		configs = append(configs, provider.Capability{
+			Category: provider.CategoryConfig,
+			Name:     "config-koanf",
+			Module: fx.Provide(func() (provider.Config, error) {
+				return koanf.New(i.app, i.configOptions...)
+			}),
+		})
+	}
+
+	if len(loggers) == 0 {
+		loggers = append(loggers, zerolog.Capability)
+	}
+
+	// Prepare fxOptions for DI configuration, config and logger must be initialized first
+	i.capabilities = append(append(configs, loggers...), others...)
+	fxOptions := make([]fx.Option, 0)
+	for index, c := range i.capabilities {
+		typ, exists := getCategoryType(c.Category)
+		if !exists {
 			return errors.Wrapf(errUnsupportedCapability, "capability: %s", c.Name)
 		}
+		fxOptions = append(fxOptions, c.Module, i.populate(typ))
+
+		// 配置加载完成后, 在初始化其他provider之前统一校验所有配置
+		if index == len(configs)-1 {
+			fxOptions = append(fxOptions, fx.Invoke(i.validateConfig))
+		}
+
+		// logger初始化完成后, 配置重新加载的错误通过logger记录
+		if index == len(configs)+len(loggers)-1 {
+			fxOptions = append(fxOptions, fx.Invoke(setConfigLogger))
+		}
 	}
 
+	// Register OnStop hooks after all providers have been populated
+	fxOptions = append(fxOptions, fx.Invoke(i.registerLifecycle))
+
 	// Disable fx internal logger in production mode
 	if !i.debug {
 		fxOptions = append(fxOptions, fx.NopLogger)
 	}
 
 	// Start the DI container and initialize all configured providers
-	err := fx.New(fxOptions...).Start(context.Background())
+	app := fx.New(fxOptions...)
+	err := app.Start(context.Background())
 	if err != nil {
 		return errors.Unwrap(err)
 	}
+	i.fxApp = app
 
 	// try load config to config var
 	i.unmarshalConfig()
@@ -112,19 +160,80 @@ func (i *Instance) Initialize(capabilities ...provider.Capability) error {
 	return nil
 }
 
+// Shutdown 停止所有provider, 按初始化的逆序依次关闭mq, redis, db和logger
+func (i *Instance) Shutdown(ctx context.Context) error {
+	if i.fxApp == nil {
+		return errNotInitialized
+	}
+	return i.fxApp.Stop(ctx)
+}
+
+// Run 阻塞直到收到SIGINT/SIGTERM信号, 然后在shutdownTimeout内优雅退出
+func (i *Instance) Run() error {
+	if i.fxApp == nil {
+		return errNotInitialized
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), i.shutdownTimeout)
+	defer cancel()
+	return i.Shutdown(ctx)
+}
+
+// registerLifecycle 为实现了io.Closer的provider注册OnStop钩子
+// fx按注册的逆序执行OnStop, logger最先注册, 保证其最后关闭
+func (i *Instance) registerLifecycle(lc fx.Lifecycle) {
+	i.providerMutex.RLock()
+	defer i.providerMutex.RUnlock()
+
+	for _, typ := range i.providerTypes {
+		if closer, ok := i.providers[typ].(io.Closer); ok {
+			lc.Append(fx.StopHook(closer.Close))
+		}
+	}
+}
+
+// setConfigLogger 为支持设置日志的配置provider设置logger
+func setConfigLogger(config provider.Config, logger provider.Logger) {
+	if setter, ok := config.(interface{ SetLogger(provider.Logger) }); ok {
+		setter.SetLogger(logger)
+	}
+}
+
+// populate 生成fx.Invoke选项, 将类型为typ的provider保存到实例中
+func (i *Instance) populate(typ reflect.Type) fx.Option {
+	fn := reflect.MakeFunc(reflect.FuncOf([]reflect.Type{typ}, nil, false), func(args []reflect.Value) []reflect.Value {
+		i.providerMutex.Lock()
+		defer i.providerMutex.Unlock()
+
+		if _, exists := i.providers[typ]; !exists {
+			i.providerTypes = append(i.providerTypes, typ)
+		}
+		i.providers[typ] = args[0].Interface()
+		return nil
+	})
+	return fx.Invoke(fn.Interface())
+}
+
 func (i *Instance) unmarshalConfig() {
 	var fatal, outputError func(msg string, keyvals ...interface{})
 
-	if i.loggerProvider != nil {
-		fatal = i.loggerProvider.Fatal
-		outputError = i.loggerProvider.Error
+	loggerProvider := get[provider.Logger](i)
+	if loggerProvider != nil {
+		fatal = loggerProvider.Fatal
+		outputError = loggerProvider.Error
 	} else {
 		fatal = logger.Fatal
 		outputError = logger.Error
 	}
 
 	// 检查配置提供者是否已初始化。
-	if i.configProvider == nil {
+	configProvider := get[provider.Config](i)
+	if configProvider == nil {
 		// 如果未初始化，则记录致命错误并终止程序。
 		fatal("config provider not initialized")
 	}
@@ -132,7 +241,7 @@ func (i *Instance) unmarshalConfig() {
 	// 如果没有赋值，则直接返回
 	if i.configVar != nil {
 		// 将配置数据解析到局部配置变量中
-		err := i.configProvider.Unmarshal(i.configVar)
+		err := configProvider.Unmarshal(i.configVar)
 		if err != nil {
 			// 如果解析失败，则记录致命错误并终止程序。
 			outputError("unmarshal to config variable", "err", err)
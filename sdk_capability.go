@@ -1,25 +1,111 @@
 package sdk
 
 import (
+	"reflect"
+	"sync"
+
 	"github.com/hdget/sdk/common/provider"
 )
 
+var (
+	// categoryTypes 能力类别与其对外提供的接口类型的绑定
+	categoryTypes = map[provider.Category]reflect.Type{
+		provider.CategoryConfig: reflect.TypeFor[provider.Config](),
+		provider.CategoryLogger: reflect.TypeFor[provider.Logger](),
+		provider.CategoryDb:     reflect.TypeFor[provider.Database](),
+		provider.CategoryRedis:  reflect.TypeFor[provider.Redis](),
+		provider.CategoryMq:     reflect.TypeFor[provider.MessageQueue](),
+	}
+	categoryTypesMutex sync.RWMutex
+)
+
+// RegisterCategory 将能力类别绑定到其对外提供的接口类型T
+// 绑定后该类别的能力可以通过Initialize加载, 并通过Get[T]获取, 例如:
+//
+//	sdk.RegisterCategory[oss.API](provider.CategoryOss)
+//	err := sdk.New(app).Initialize(ossCapability)
+//	url, err := sdk.Get[oss.API]().Upload(ctx, dir, filename, data)
+func RegisterCategory[T any](category provider.Category) {
+	categoryTypesMutex.Lock()
+	defer categoryTypesMutex.Unlock()
+	categoryTypes[category] = reflect.TypeFor[T]()
+}
+
+// Get 从全局实例中获取类型为T的provider, 没有找到则返回T的零值
+func Get[T any]() T {
+	return GetFrom[T](_instance)
+}
+
+// GetFrom 从指定实例中获取类型为T的provider, 没有找到则返回T的零值
+func GetFrom[T any](i *Instance) T {
+	return get[T](i)
+}
+
 func Logger() provider.Logger {
-	return _instance.loggerProvider
+	return Get[provider.Logger]()
 }
 
 func Db() provider.Database {
-	return _instance.dbProvider
+	return Get[provider.Database]()
 }
 
 func Redis() provider.Redis {
-	return _instance.redisProvider
+	return Get[provider.Redis]()
 }
 
 func Config() provider.Config {
-	return _instance.configProvider
+	return Get[provider.Config]()
 }
 
 func Mq() provider.MessageQueue {
-	return _instance.mqProvider
+	return Get[provider.MessageQueue]()
+}
+
+func (i *Instance) Logger() provider.Logger {
+	return get[provider.Logger](i)
+}
+
+func (i *Instance) Db() provider.Database {
+	return get[provider.Database](i)
+}
+
+func (i *Instance) Redis() provider.Redis {
+	return get[provider.Redis](i)
+}
+
+func (i *Instance) Config() provider.Config {
+	return get[provider.Config](i)
+}
+
+func (i *Instance) Mq() provider.MessageQueue {
+	return get[provider.MessageQueue](i)
+}
+
+func get[T any](i *Instance) T {
+	var zero T
+	if i == nil {
+		return zero
+	}
+
+	i.providerMutex.RLock()
+	defer i.providerMutex.RUnlock()
+
+	if p, ok := i.providers[reflect.TypeFor[T]()].(T); ok {
+		return p
+	}
+
+	// T不是绑定的接口类型时, 按初始化顺序查找第一个可以转换为T的provider
+	for _, typ := range i.providerTypes {
+		if p, ok := i.providers[typ].(T); ok {
+			return p
+		}
+	}
+	return zero
+}
+
+func getCategoryType(category provider.Category) (reflect.Type, bool) {
+	categoryTypesMutex.RLock()
+	defer categoryTypesMutex.RUnlock()
+	typ, exists := categoryTypes[category]
+	return typ, exists
 }
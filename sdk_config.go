@@ -0,0 +1,103 @@
+package sdk
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hdget/sdk/common/config"
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// DumpEffectiveConfig 返回合并了所有配置源和缺省值之后的配置, 密码等敏感配置值会被屏蔽
+func (i *Instance) DumpEffectiveConfig() (string, error) {
+	configProvider := get[provider.Config](i)
+	if configProvider == nil {
+		return "", errNotInitialized
+	}
+
+	effective := make(map[string]any)
+	if err := configProvider.Unmarshal(&effective); err != nil {
+		return "", errors.Wrap(err, "unmarshal config")
+	}
+
+	// 用设置了缺省值的配置结构覆盖原始配置
+	for _, schema := range i.getConfigSchemas() {
+		configVar := schema.New()
+		if err := config.Parse(configProvider, schema.Section, configVar); err != nil {
+			continue
+		}
+		mergeSection(effective, schema.Section, config.ToMap(configVar))
+	}
+
+	data, err := json.MarshalIndent(config.Mask(effective), "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "marshal config")
+	}
+	return string(data), nil
+}
+
+// validateConfig 校验所有能力的配置, 一次返回所有配置段落中的错误
+func (i *Instance) validateConfig(configProvider provider.Config) error {
+	var errs config.Errors
+	for _, schema := range i.getConfigSchemas() {
+		err := config.Parse(configProvider, schema.Section, schema.New())
+		if err == nil || (schema.Optional && errors.Is(err, config.ErrSectionNotFound)) {
+			continue
+		}
+
+		var fieldErrs config.Errors
+		if errors.As(err, &fieldErrs) {
+			errs = append(errs, fieldErrs...)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Wrap(errs, "invalid config")
+	}
+	return nil
+}
+
+func (i *Instance) getConfigSchemas() []*provider.ConfigSchema {
+	schemas := make([]*provider.ConfigSchema, 0)
+	for _, c := range i.capabilities {
+		if c.Config != nil {
+			schemas = append(schemas, c.Config)
+		}
+	}
+	return schemas
+}
+
+// mergeSection 将values合并到m中section对应的位置, 例如: sdk.redis
+func mergeSection(m map[string]any, section string, values map[string]any) {
+	keys := strings.Split(section, ".")
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := m[key].(map[string]any)
+		if !ok {
+			child = make(map[string]any)
+			m[key] = child
+		}
+		m = child
+	}
+
+	last := keys[len(keys)-1]
+	if existing, ok := m[last].(map[string]any); ok {
+		mergeMap(existing, values)
+		return
+	}
+	m[last] = values
+}
+
+func mergeMap(dst, src map[string]any) {
+	for k, v := range src {
+		if srcChild, ok := v.(map[string]any); ok {
+			if dstChild, ok := dst[k].(map[string]any); ok {
+				mergeMap(dstChild, srcChild)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
@@ -1,7 +1,10 @@
 package sdk
 
 import (
+	"time"
+
 	"github.com/hdget/sdk/providers/config/koanf"
+	"github.com/hdget/sdk/providers/config/koanf/loader"
 )
 
 type Option func(instance *Instance)
@@ -23,3 +26,17 @@ func WithConfigContent(configContent []byte) Option {
 		instance.configOptions = append(instance.configOptions, koanf.WithConfigContent(configContent))
 	}
 }
+
+// WithSecretResolver 添加自定义的配置密文解析器, 例如基于KMS的解析器
+func WithSecretResolver(resolvers ...loader.SecretResolver) Option {
+	return func(instance *Instance) {
+		instance.configOptions = append(instance.configOptions, koanf.WithSecretResolver(resolvers...))
+	}
+}
+
+// WithShutdownTimeout 设置Run收到退出信号后等待各provider关闭的最长时间
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(instance *Instance) {
+		instance.shutdownTimeout = timeout
+	}
+}
@@ -0,0 +1,279 @@
+package sdktest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/hdget/sdk/common/dbkit"
+	"github.com/hdget/sdk/common/provider"
+)
+
+// Database 内存数据库, 所有客户端共享同一个DbClient
+type Database struct {
+	client *DbClient
+}
+
+// DbClient 基于记录型驱动的数据库客户端, 钩子和事务的行为和各数据库provider一致
+// 执行的SQL都会被记录下来, 查询默认返回空结果集, 可以通过Stub为匹配的查询指定返回数据
+type DbClient struct {
+	*dbkit.Client
+	recorder *recorder
+}
+
+// Statement 一条已执行的SQL
+type Statement struct {
+	Query string
+	Args  []any
+}
+
+type recorder struct {
+	mutex      sync.Mutex
+	statements []Statement
+	stubs      []*stub
+}
+
+type stub struct {
+	contains string
+	columns  []string
+	rows     [][]any
+}
+
+const (
+	dbProviderName = "db-sdktest"
+)
+
+func NewDatabase() *Database {
+	return &Database{client: NewDbClient()}
+}
+
+func NewDbClient() *DbClient {
+	r := &recorder{}
+	return &DbClient{
+		Client:   dbkit.NewClient(sql.OpenDB(&connector{recorder: r}), nil),
+		recorder: r,
+	}
+}
+
+func (d *Database) GetCapability() provider.Capability {
+	return newCapability[provider.Database](provider.CategoryDb, dbProviderName, d)
+}
+
+// Client 返回底层的内存客户端, 用于检查已执行的SQL
+func (d *Database) Client() *DbClient {
+	return d.client
+}
+
+func (d *Database) Default() provider.DbClient {
+	return d.client
+}
+
+func (d *Database) Master() provider.DbClient {
+	return d.client
+}
+
+func (d *Database) Slave(i int) provider.DbClient {
+	return d.client
+}
+
+func (d *Database) Named(name string) provider.DbClient {
+	return d.client
+}
+
+func (d *Database) Read() provider.DbClient {
+	return d.client
+}
+
+func (d *Database) ReadContext(context.Context) provider.DbClient {
+	return d.client
+}
+
+func (d *Database) Write() provider.DbClient {
+	return d.client
+}
+
+func (d *Database) Stats() map[string]sql.DBStats {
+	return map[string]sql.DBStats{"default": d.client.Db().Stats()}
+}
+
+func (d *Database) Close() error {
+	return d.client.Close()
+}
+
+// Statements 返回已执行的SQL
+func (c *DbClient) Statements() []Statement {
+	c.recorder.mutex.Lock()
+	defer c.recorder.mutex.Unlock()
+	return append([]Statement(nil), c.recorder.statements...)
+}
+
+// Reset 清空已执行的SQL和所有预设结果
+func (c *DbClient) Reset() {
+	c.recorder.mutex.Lock()
+	defer c.recorder.mutex.Unlock()
+	c.recorder.statements = nil
+	c.recorder.stubs = nil
+}
+
+// Stub 为包含contains的查询预设返回的列和数据, 后设置的优先匹配
+func (c *DbClient) Stub(contains string, columns []string, rows ...[]any) {
+	c.recorder.mutex.Lock()
+	defer c.recorder.mutex.Unlock()
+	c.recorder.stubs = append([]*stub{{contains: contains, columns: columns, rows: rows}}, c.recorder.stubs...)
+}
+
+func (r *recorder) record(query string, args []driver.NamedValue) {
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.statements = append(r.statements, Statement{Query: query, Args: values})
+}
+
+func (r *recorder) match(query string) *stub {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, s := range r.stubs {
+		if strings.Contains(query, s.contains) {
+			return s
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////
+// database/sql driver
+///////////////////////////////////////////////////////////////////////
+
+type connector struct {
+	recorder *recorder
+}
+
+type conn struct {
+	recorder *recorder
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+type tx struct {
+	conn *conn
+}
+
+type rows struct {
+	columns []string
+	values  [][]any
+	index   int
+}
+
+type result struct{}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{recorder: c.recorder}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c
+}
+
+func (c *connector) Open(string) (driver.Conn, error) {
+	return &conn{recorder: c.recorder}, nil
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	c.recorder.record("BEGIN", nil)
+	return &tx{conn: c}, nil
+}
+
+func (c *conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.recorder.record(query, args)
+	return result{}, nil
+}
+
+func (c *conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.recorder.record(query, args)
+	r := &rows{}
+	if s := c.recorder.match(query); s != nil {
+		r.columns, r.values = s.columns, s.rows
+	}
+	return r, nil
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, toNamedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, toNamedValues(args))
+}
+
+func (t *tx) Commit() error {
+	t.conn.recorder.record("COMMIT", nil)
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	t.conn.recorder.record("ROLLBACK", nil)
+	return nil
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.index >= len(r.values) {
+		return io.EOF
+	}
+	for i, v := range r.values[r.index] {
+		if i < len(dest) {
+			dest[i] = v
+		}
+	}
+	r.index++
+	return nil
+}
+
+func (result) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+func (result) RowsAffected() (int64, error) {
+	return 0, nil
+}
+
+func toNamedValues(args []driver.Value) []driver.NamedValue {
+	values := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		values[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return values
+}
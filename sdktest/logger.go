@@ -0,0 +1,144 @@
+package sdktest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/hdget/sdk/common/bizctx"
+	"github.com/hdget/sdk/common/provider"
+)
+
+// Entry 一条日志记录
+type Entry struct {
+	Level   string
+	Name    string // 通过Named创建的子日志的名字
+	Msg     string
+	Keyvals []any
+}
+
+// Logger 内存日志, 所有日志都记录下来供测试检查
+// 注意: Fatal只记录日志不会退出进程, Panic记录日志后panic
+type Logger struct {
+	store   *logStore // 和子日志共享
+	name    string
+	keyvals []any
+}
+
+type logStore struct {
+	mutex   sync.Mutex
+	entries []Entry
+}
+
+const (
+	loggerProviderName = "logger-sdktest"
+)
+
+func NewLogger() *Logger {
+	return &Logger{store: &logStore{}}
+}
+
+func (l *Logger) GetCapability() provider.Capability {
+	return newCapability[provider.Logger](provider.CategoryLogger, loggerProviderName, l)
+}
+
+// Entries 返回已记录的日志
+func (l *Logger) Entries() []Entry {
+	l.store.mutex.Lock()
+	defer l.store.mutex.Unlock()
+	return append([]Entry(nil), l.store.entries...)
+}
+
+// Contains 检查是否记录过指定级别且包含msg的日志
+func (l *Logger) Contains(level, msg string) bool {
+	for _, entry := range l.Entries() {
+		if entry.Level == level && strings.Contains(entry.Msg, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset 清空已记录的日志
+func (l *Logger) Reset() {
+	l.store.mutex.Lock()
+	defer l.store.mutex.Unlock()
+	l.store.entries = nil
+}
+
+func (l *Logger) GetStdLogger() *log.Logger {
+	return log.New(stdWriter{logger: l}, "", 0)
+}
+
+func (l *Logger) Log(keyvals ...interface{}) error {
+	l.record("log", "", keyvals)
+	return nil
+}
+
+func (l *Logger) Trace(msg string, keyvals ...interface{}) {
+	l.record("trace", msg, keyvals)
+}
+
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.record("debug", msg, keyvals)
+}
+
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.record("info", msg, keyvals)
+}
+
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.record("warn", msg, keyvals)
+}
+
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.record("error", msg, keyvals)
+}
+
+func (l *Logger) Fatal(msg string, keyvals ...interface{}) {
+	l.record("fatal", msg, keyvals)
+}
+
+func (l *Logger) Panic(msg string, keyvals ...interface{}) {
+	l.record("panic", msg, keyvals)
+	panic(fmt.Sprint(msg, keyvals))
+}
+
+// With 返回带有keyvals字段的子日志, 子日志的记录中keyvals在前
+func (l *Logger) With(keyvals ...interface{}) provider.Logger {
+	return &Logger{store: l.store, name: l.name, keyvals: append(l.keyvals[:len(l.keyvals):len(l.keyvals)], keyvals...)}
+}
+
+// Named 返回指定名字的子日志, 多级名字用.连接
+func (l *Logger) Named(name string) provider.Logger {
+	if l.name != "" {
+		name = l.name + "." + name
+	}
+	return &Logger{store: l.store, name: name, keyvals: l.keyvals}
+}
+
+// Ctx 返回带有ctx中bizctx元数据的子日志
+func (l *Logger) Ctx(ctx context.Context) provider.Logger {
+	return l.With(bizctx.LogFields(ctx)...)
+}
+
+func (l *Logger) record(level, msg string, keyvals []any) {
+	if len(l.keyvals) > 0 {
+		keyvals = append(l.keyvals[:len(l.keyvals):len(l.keyvals)], keyvals...)
+	}
+
+	l.store.mutex.Lock()
+	defer l.store.mutex.Unlock()
+	l.store.entries = append(l.store.entries, Entry{Level: level, Name: l.name, Msg: msg, Keyvals: keyvals})
+}
+
+type stdWriter struct {
+	logger *Logger
+}
+
+func (w stdWriter) Write(p []byte) (int, error) {
+	w.logger.record("std", strings.TrimRight(string(p), "\n"), nil)
+	return len(p), nil
+}
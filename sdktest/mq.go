@@ -0,0 +1,336 @@
+package sdktest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hdget/sdk/common/provider"
+	"github.com/pkg/errors"
+)
+
+// MessageQueue 内存消息队列
+// 与rabbitmq的语义保持一致: 相同name的订阅者竞争消费, 不同name的订阅者都会收到消息, Nack的消息会重新投递
+// 发布时还没有订阅者的topic, 消息会被丢弃, 但仍然可以通过Published查看
+// 订阅者设置了重试策略时, 超过最多投递次数的消息进入死信队列
+type MessageQueue struct {
+	mutex       sync.Mutex
+	groups      map[string]map[string]*subscriberGroup // topic => subscriber name => group
+	published   map[string][]*provider.Message
+	deadLetters map[string][]*provider.Message // topic@name => messages
+}
+
+type subscriberGroup struct {
+	subscriptions []*memorySubscription
+	next          int
+}
+
+type memorySubscription struct {
+	mq          *MessageQueue
+	topic       string
+	name        string
+	retryPolicy *provider.RetryPolicy
+	inbox       chan *provider.Message
+	out         chan *provider.Message
+	closing     chan struct{}
+	once        sync.Once
+}
+
+type memoryPublisher struct {
+	mq *MessageQueue
+}
+
+type memorySubscriber struct {
+	mq            *MessageQueue
+	name          string
+	retryPolicy   *provider.RetryPolicy
+	mutex         sync.Mutex
+	subscriptions []*memorySubscription
+	closed        bool
+}
+
+const (
+	mqProviderName  = "mq-sdktest"
+	inboxBufferSize = 1024
+)
+
+var (
+	errSubscriberClosed = errors.New("subscriber is closed")
+)
+
+func NewMessageQueue() *MessageQueue {
+	return &MessageQueue{
+		groups:      make(map[string]map[string]*subscriberGroup),
+		published:   make(map[string][]*provider.Message),
+		deadLetters: make(map[string][]*provider.Message),
+	}
+}
+
+func (q *MessageQueue) GetCapability() provider.Capability {
+	return newCapability[provider.MessageQueue](provider.CategoryMq, mqProviderName, q)
+}
+
+func (q *MessageQueue) NewPublisher(name string, args ...*provider.PublisherOption) (provider.MessageQueuePublisher, error) {
+	return &memoryPublisher{mq: q}, nil
+}
+
+func (q *MessageQueue) NewSubscriber(name string, args ...*provider.SubscriberOption) (provider.MessageQueueSubscriber, error) {
+	s := &memorySubscriber{mq: q, name: name}
+	if len(args) > 0 && args[0] != nil {
+		s.retryPolicy = args[0].RetryPolicy
+	}
+	return s, nil
+}
+
+func (q *MessageQueue) ListDeadLetters(topic, name string, limit int) ([]*provider.Message, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	messages := q.deadLetters[getDeadLetterKey(topic, name)]
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+
+	result := make([]*provider.Message, len(messages))
+	for i, msg := range messages {
+		result[i] = msg.Copy()
+	}
+	return result, nil
+}
+
+// RepublishDeadLetters 死信消息只投递给名字为name的订阅者
+func (q *MessageQueue) RepublishDeadLetters(topic, name string, limit int) (int, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	key := getDeadLetterKey(topic, name)
+	messages := q.deadLetters[key]
+	if limit > 0 && len(messages) > limit {
+		messages = messages[:limit]
+	}
+	q.deadLetters[key] = q.deadLetters[key][len(messages):]
+
+	for _, msg := range messages {
+		if group := q.groups[topic][name]; group != nil {
+			q.deliver(group, msg)
+		}
+	}
+	return len(messages), nil
+}
+
+// Published 返回发布到topic的所有消息内容
+func (q *MessageQueue) Published(topic string) [][]byte {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	payloads := make([][]byte, len(q.published[topic]))
+	for i, msg := range q.published[topic] {
+		payloads[i] = msg.Payload
+	}
+	return payloads
+}
+
+// PublishedMessages 返回发布到topic的所有消息, 包括UUID和Metadata
+func (q *MessageQueue) PublishedMessages(topic string) []*provider.Message {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	messages := make([]*provider.Message, len(q.published[topic]))
+	for i, msg := range q.published[topic] {
+		messages[i] = msg.Copy()
+	}
+	return messages
+}
+
+func (q *MessageQueue) publish(topic string, msg *provider.Message) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.published[topic] = append(q.published[topic], msg)
+	for _, group := range q.groups[topic] {
+		q.deliver(group, msg)
+	}
+}
+
+// deliver 轮流投递给组内的订阅
+func (q *MessageQueue) deliver(group *subscriberGroup, msg *provider.Message) {
+	if len(group.subscriptions) == 0 {
+		return
+	}
+	s := group.subscriptions[group.next%len(group.subscriptions)]
+	group.next++
+	select {
+	case s.inbox <- msg:
+	case <-s.closing:
+	}
+}
+
+func (q *MessageQueue) deadLetter(topic, name string, msg *provider.Message) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	key := getDeadLetterKey(topic, name)
+	q.deadLetters[key] = append(q.deadLetters[key], msg)
+}
+
+func (q *MessageQueue) subscribe(topic, name string, s *memorySubscription) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.groups[topic] == nil {
+		q.groups[topic] = make(map[string]*subscriberGroup)
+	}
+	group := q.groups[topic][name]
+	if group == nil {
+		group = &subscriberGroup{}
+		q.groups[topic][name] = group
+	}
+	group.subscriptions = append(group.subscriptions, s)
+}
+
+func (q *MessageQueue) unsubscribe(s *memorySubscription) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, groups := range q.groups {
+		for _, group := range groups {
+			for i, item := range group.subscriptions {
+				if item == s {
+					group.subscriptions = append(group.subscriptions[:i], group.subscriptions[i+1:]...)
+					break
+				}
+			}
+		}
+	}
+}
+
+func (p *memoryPublisher) Publish(topic string, messages [][]byte, delaySeconds ...int64) error {
+	msgs := make([]*provider.Message, len(messages))
+	for i, payload := range messages {
+		msgs[i] = provider.NewMessage(payload)
+	}
+	return p.PublishMessage(topic, msgs, delaySeconds...)
+}
+
+func (p *memoryPublisher) PublishMessage(topic string, messages []*provider.Message, delaySeconds ...int64) error {
+	var delay time.Duration
+	if len(delaySeconds) > 0 {
+		delay = time.Duration(delaySeconds[0]) * time.Second
+	}
+
+	for _, msg := range messages {
+		// 发布后调用方修改消息不影响已发布的消息
+		msg = msg.Copy()
+		if delay > 0 {
+			time.AfterFunc(delay, func() { p.mq.publish(topic, msg) })
+			continue
+		}
+		p.mq.publish(topic, msg)
+	}
+	return nil
+}
+
+func (p *memoryPublisher) Close() error {
+	return nil
+}
+
+func (s *memorySubscriber) Subscribe(ctx context.Context, topic string) (<-chan *provider.Message, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil, errSubscriberClosed
+	}
+
+	sub := &memorySubscription{
+		mq:          s.mq,
+		topic:       topic,
+		name:        s.name,
+		retryPolicy: s.retryPolicy,
+		inbox:       make(chan *provider.Message, inboxBufferSize),
+		out:         make(chan *provider.Message),
+		closing:     make(chan struct{}),
+	}
+	s.subscriptions = append(s.subscriptions, sub)
+	s.mq.subscribe(topic, s.name, sub)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			sub.close()
+			s.mq.unsubscribe(sub)
+		case <-sub.closing:
+		}
+	}()
+
+	go sub.run(ctx)
+
+	return sub.out, nil
+}
+
+func (s *memorySubscriber) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	for _, sub := range s.subscriptions {
+		sub.close()
+		s.mq.unsubscribe(sub)
+	}
+	s.subscriptions = nil
+	return nil
+}
+
+func (s *memorySubscription) close() {
+	s.once.Do(func() {
+		close(s.closing)
+	})
+}
+
+// run 逐条投递消息, 收到Ack后投递下一条, 收到Nack则重新投递, 超过重试策略的最多投递次数后进入死信队列
+func (s *memorySubscription) run(ctx context.Context) {
+	defer close(s.out)
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		case published := <-s.inbox:
+			for attempts := 1; ; attempts++ {
+				msg := published.Copy()
+				msg.SetContext(ctx)
+
+				select {
+				case s.out <- msg:
+				case <-s.closing:
+					return
+				}
+
+				select {
+				case <-msg.Acked():
+				case <-msg.Nacked():
+					if s.retryPolicy == nil {
+						continue
+					}
+					if attempts >= s.retryPolicy.MaxAttempts {
+						s.mq.deadLetter(s.topic, s.name, published)
+						break
+					}
+					select {
+					case <-time.After(s.retryPolicy.Backoff(attempts)):
+						continue
+					case <-s.closing:
+						return
+					}
+				case <-s.closing:
+					return
+				}
+				break
+			}
+		}
+	}
+}
+
+func getDeadLetterKey(topic, name string) string {
+	return topic + "@" + name
+}
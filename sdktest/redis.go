@@ -0,0 +1,121 @@
+package sdktest
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+	"github.com/hdget/sdk/common/provider"
+	"github.com/hdget/sdk/providers/config/koanf"
+	"github.com/hdget/sdk/providers/redis/redigo"
+	"github.com/pkg/errors"
+)
+
+// Redis 基于miniredis的redis, My()和By(name)返回连接到不同miniredis实例的redigo客户端
+// miniredis不支持的命令(例如: bloom filter)会返回错误
+type Redis struct {
+	mutex     sync.Mutex
+	servers   map[string]*miniredis.Miniredis
+	providers map[string]provider.Redis
+}
+
+const (
+	redisProviderName = "redis-sdktest"
+	defaultClientName = ""
+)
+
+var (
+	// ErrNil 对应redis返回的nil
+	ErrNil = redis.ErrNil
+)
+
+func NewRedis() *Redis {
+	return &Redis{
+		servers:   make(map[string]*miniredis.Miniredis),
+		providers: make(map[string]provider.Redis),
+	}
+}
+
+func (r *Redis) GetCapability() provider.Capability {
+	return newCapability[provider.Redis](provider.CategoryRedis, redisProviderName, r)
+}
+
+// My 缺省的客户端, 启动miniredis失败时返回nil
+func (r *Redis) My() provider.RedisClient {
+	return r.client(defaultClientName)
+}
+
+// By 名字为name的客户端, 不同名字的客户端使用不同的miniredis实例
+func (r *Redis) By(name string) provider.RedisClient {
+	return r.client(name)
+}
+
+// Server 名字为name的客户端连接的miniredis实例, 用于在测试中检查数据或者修改时间, 缺省客户端的名字为空
+func (r *Redis) Server(name string) *miniredis.Miniredis {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, err := r.start(name); err != nil {
+		return nil
+	}
+	return r.servers[name]
+}
+
+// Close 关闭所有客户端和miniredis实例
+func (r *Redis) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for name, p := range r.providers {
+		if closer, ok := p.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		r.servers[name].Close()
+	}
+	r.servers = make(map[string]*miniredis.Miniredis)
+	r.providers = make(map[string]provider.Redis)
+	return nil
+}
+
+func (r *Redis) client(name string) provider.RedisClient {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	p, err := r.start(name)
+	if err != nil {
+		return nil
+	}
+	return p.My()
+}
+
+// start 第一次使用时启动miniredis, 通过redigo provider创建连接它的客户端
+func (r *Redis) start(name string) (provider.Redis, error) {
+	if p, exists := r.providers[name]; exists {
+		return p, nil
+	}
+
+	server := miniredis.NewMiniRedis()
+	if err := server.Start(); err != nil {
+		return nil, errors.Wrap(err, "start miniredis")
+	}
+
+	configProvider, err := koanf.New(testApp, koanf.WithConfigContent([]byte(fmt.Sprintf(`[sdk.redis.default]
+host = "%s"
+port = %d`, server.Host(), server.Server().Addr().Port))))
+	if err != nil {
+		server.Close()
+		return nil, err
+	}
+
+	p, err := redigo.New(configProvider, NewLogger())
+	if err != nil {
+		server.Close()
+		return nil, err
+	}
+
+	r.servers[name] = server
+	r.providers[name] = p
+	return p, nil
+}
@@ -0,0 +1,98 @@
+// Package sdktest 提供单元测试使用的sdk实例
+// 配置从内存加载, 日志, 数据库和消息队列使用内存实现, redis使用连接miniredis的redigo客户端, 同一进程中可以创建多个互不影响的实例
+package sdktest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hdget/sdk"
+	"github.com/hdget/sdk/common/provider"
+	"go.uber.org/fx"
+)
+
+// Env 测试环境, 包含sdk实例以及各个内存实现, 方便在测试中检查日志、已执行的SQL和已发布的消息
+type Env struct {
+	Instance *sdk.Instance
+	Logger   *Logger
+	Redis    *Redis
+	Db       *Database
+	Mq       *MessageQueue
+}
+
+const (
+	testApp = "sdktest"
+)
+
+// New 使用内存中的配置内容创建一个独立的sdk实例
+// capabilities中指定的能力会替换同类别的内存实现, 被替换的内存实现在Env中为nil
+// 测试结束时自动关闭该实例
+func New(t testing.TB, configContent []byte, capabilities ...provider.Capability) *Env {
+	t.Helper()
+
+	// 空的配置内容也从内存加载, 避免去查找配置文件
+	if configContent == nil {
+		configContent = []byte{}
+	}
+
+	env := &Env{
+		Logger: NewLogger(),
+		Redis:  NewRedis(),
+		Db:     NewDatabase(),
+		Mq:     NewMessageQueue(),
+	}
+
+	replaced := make(map[provider.Category]bool)
+	for _, c := range capabilities {
+		replaced[c.Category] = true
+	}
+
+	fakes := []provider.Capability{
+		env.Logger.GetCapability(),
+		env.Redis.GetCapability(),
+		env.Db.GetCapability(),
+		env.Mq.GetCapability(),
+	}
+	for _, c := range fakes {
+		if !replaced[c.Category] {
+			capabilities = append(capabilities, c)
+		}
+	}
+
+	if replaced[provider.CategoryLogger] {
+		env.Logger = nil
+	}
+	if replaced[provider.CategoryRedis] {
+		env.Redis = nil
+	}
+	if replaced[provider.CategoryDb] {
+		env.Db = nil
+	}
+	if replaced[provider.CategoryMq] {
+		env.Mq = nil
+	}
+
+	instance := sdk.NewInstance(testApp, sdk.WithConfigContent(configContent))
+	if err := instance.Initialize(capabilities...); err != nil {
+		t.Fatalf("initialize sdk instance: %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = instance.Shutdown(context.Background())
+	})
+
+	env.Instance = instance
+	return env
+}
+
+// newCapability 生成直接提供给定值的能力
+func newCapability[T any](category provider.Category, name string, value T) provider.Capability {
+	return provider.Capability{
+		Category: category,
+		Name:     name,
+		Module: fx.Module(
+			name,
+			fx.Provide(func() T { return value }),
+		),
+	}
+}
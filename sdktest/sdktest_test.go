@@ -0,0 +1,244 @@
+package sdktest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hdget/sdk/common/mq/mqtest"
+	"github.com/hdget/sdk/common/provider"
+)
+
+func TestNew(t *testing.T) {
+	env1 := New(t, []byte(`[app]
+name = "first"`))
+	env2 := New(t, []byte(`[app]
+name = "second"`))
+
+	if got := env1.Instance.Config().Get("app.name"); got != "first" {
+		t.Fatalf("env1 app.name = %v, want first", got)
+	}
+	if got := env2.Instance.Config().Get("app.name"); got != "second" {
+		t.Fatalf("env2 app.name = %v, want second", got)
+	}
+
+	env1.Instance.Logger().Info("hello", "key", "value")
+	if !env1.Logger.Contains("info", "hello") || env2.Logger.Contains("info", "hello") {
+		t.Fatal("logger entries should be recorded per instance")
+	}
+}
+
+func TestRedis(t *testing.T) {
+	env := New(t, nil)
+	client := env.Instance.Redis().My()
+
+	if err := client.Set("counter", 1); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := client.Incr("counter"); err != nil || v != 2 {
+		t.Fatalf("Incr() = %d, %v, want 2", v, err)
+	}
+
+	if _, err := client.HSet("hash", "field", "value"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := client.HGetString("hash", "field"); err != nil || v != "value" {
+		t.Fatalf("HGetString() = %s, %v, want value", v, err)
+	}
+
+	_ = client.ZAdd("zset", 2, "b")
+	_ = client.ZAdd("zset", 1, "a")
+	members, err := client.ZRangeByScore("zset", "-inf", "+inf", false, nil)
+	if err != nil || len(members) != 2 || members[0] != "a" {
+		t.Fatalf("ZRangeByScore() = %v, %v, want [a b]", members, err)
+	}
+
+	if _, err = client.Get("missing"); err != ErrNil {
+		t.Fatalf("Get() error = %v, want ErrNil", err)
+	}
+
+	// 不同名字的客户端互相独立
+	if exists, err := env.Instance.Redis().By("other").Exists("counter"); err != nil || exists {
+		t.Fatalf("By(other).Exists() = %v, %v, want false", exists, err)
+	}
+	if v, err := env.Redis.Server("").Get("counter"); err != nil || v != "2" {
+		t.Fatalf("miniredis counter = %s, %v, want 2", v, err)
+	}
+}
+
+func TestMqConformance(t *testing.T) {
+	mqtest.Run(t, func(t *testing.T) provider.MessageQueue {
+		return NewMessageQueue()
+	}, mqtest.Features{DelayMessage: true, DeadLetter: true})
+}
+
+func TestMqDeadLetter(t *testing.T) {
+	env := New(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscriber, _ := env.Instance.Mq().NewSubscriber("test", &provider.SubscriberOption{
+		RetryPolicy: &provider.RetryPolicy{MaxAttempts: 2},
+	})
+	messages, err := subscriber.Subscribe(ctx, "topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publisher, _ := env.Instance.Mq().NewPublisher("test")
+	if err = publisher.Publish("topic", [][]byte{[]byte("poison")}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-messages:
+			msg.Nack()
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+	}
+
+	dlq := env.Instance.Mq().(provider.MessageQueueDeadLetter)
+	var deadLetters []*provider.Message
+	for i := 0; i < 100 && len(deadLetters) == 0; i++ {
+		time.Sleep(time.Millisecond)
+		deadLetters, _ = dlq.ListDeadLetters("topic", "test", 0)
+	}
+	if len(deadLetters) != 1 || string(deadLetters[0].Payload) != "poison" {
+		t.Fatalf("ListDeadLetters() = %v, want 1 message", deadLetters)
+	}
+
+	if n, err := dlq.RepublishDeadLetters("topic", "test", 0); err != nil || n != 1 {
+		t.Fatalf("RepublishDeadLetters() = %d, %v, want 1", n, err)
+	}
+	select {
+	case msg := <-messages:
+		msg.Ack()
+	case <-time.After(time.Second):
+		t.Fatal("dead letter not republished")
+	}
+}
+
+func TestRedisStreamAndPubSub(t *testing.T) {
+	env := New(t, nil)
+	client := env.Instance.Redis().My()
+
+	if err := client.XGroupCreate("stream", "group", "0"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := client.XAdd("stream", "", map[string]any{"k": "v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := client.XReadGroup("stream", "group", "c1", 10, 0)
+	if err != nil || len(messages) != 1 || messages[0].ID != id || messages[0].Values["k"] != "v" {
+		t.Fatalf("XReadGroup() = %v, %v", messages, err)
+	}
+	if messages, err = client.XReadGroup("stream", "group", "c1", 10, 20*time.Millisecond); err != nil || len(messages) != 0 {
+		t.Fatalf("XReadGroup() = %v, %v, want empty", messages, err)
+	}
+	if n, err := client.XAck("stream", "group", id); err != nil || n != 1 {
+		t.Fatalf("XAck() = %d, %v, want 1", n, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := client.PSubscribe(ctx, "news.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 订阅是异步完成的, 等待miniredis收到订阅后再发布
+	for i := 0; i < 100 && env.Redis.Server("").PubSubNumPat() == 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n, err := client.Publish("news.sport", "hello"); err != nil || n != 1 {
+		t.Fatalf("Publish() = %d, %v, want 1", n, err)
+	}
+	if msg := <-ch; msg.Channel != "news.sport" || msg.Pattern != "news.*" || string(msg.Data) != "hello" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel should be closed after ctx is canceled")
+	}
+
+	_ = client.ZAddFloat64("scores", 1.5, "a")
+	cursor, scores, err := client.ZScan("scores", 0, "*", 10)
+	if err != nil || cursor != 0 || scores["a"] != 1.5 {
+		t.Fatalf("ZScan() = %d, %v, %v", cursor, scores, err)
+	}
+}
+
+func TestDb(t *testing.T) {
+	env := New(t, nil)
+	env.Db.Client().Stub("FROM users", []string{"name"}, []any{"tom"})
+
+	err := env.Instance.Db().Write().RunInTransaction(context.Background(), func(ctx context.Context) error {
+		_, err := env.Instance.Db().Write().ExecContext(ctx, "UPDATE users SET name = ?", "tom")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var name string
+	if err = env.Instance.Db().Read().QueryRow("SELECT name FROM users").Scan(&name); err != nil || name != "tom" {
+		t.Fatalf("QueryRow() = %s, %v, want tom", name, err)
+	}
+
+	statements := env.Db.Client().Statements()
+	if len(statements) != 4 || statements[0].Query != "BEGIN" || statements[2].Query != "COMMIT" {
+		t.Fatalf("unexpected statements: %v", statements)
+	}
+}
+
+func TestMq(t *testing.T) {
+	env := New(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	subscriber, _ := env.Instance.Mq().NewSubscriber("test")
+	messages, err := subscriber.Subscribe(ctx, "topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publisher, _ := env.Instance.Mq().NewPublisher("test")
+	if err = publisher.Publish("topic", [][]byte{[]byte("hello")}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-messages:
+		msg.Nack()
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+
+	// nack的消息会重新投递
+	select {
+	case msg := <-messages:
+		if string(msg.Payload) != "hello" {
+			t.Fatalf("payload = %s, want hello", msg.Payload)
+		}
+		msg.Ack()
+	case <-time.After(time.Second):
+		t.Fatal("message not redelivered")
+	}
+
+	out := provider.NewMessage([]byte("world"))
+	out.Metadata.Set(provider.MetadataKeyCorrelationID, "cid")
+	if err = publisher.PublishMessage("topic", []*provider.Message{out}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-messages:
+		if msg.UUID != out.UUID || msg.Metadata.Get(provider.MetadataKeyCorrelationID) != "cid" {
+			t.Fatalf("unexpected message: %s, %v", msg.UUID, msg.Metadata)
+		}
+		msg.Ack()
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+}